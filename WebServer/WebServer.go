@@ -1,17 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"regexp"
-	"seng468/WebServer/Commands"
 	"sync/atomic"
 	"time"
 
-	"golang.org/x/sync/syncmap"
-
-	"seng468/WebServer/UserSessions"
 	"seng468/WebServer/logger"
 	"seng468/WebServer/transmitter"
 	"strings"
@@ -21,8 +18,11 @@ import (
 type WebServer struct {
 	Name              string
 	transactionNumber int64
-	userSessions      *syncmap.Map
+	sessions          *sessionSigner
+	admins            map[string]bool
 	transmitter       *transmitter.Transmitter
+	events            *eventHub
+	limiter           *rateLimiter
 	logger            logger.Logger
 	validPath         *regexp.Regexp
 }
@@ -38,497 +38,189 @@ func (webServer *WebServer) makeHandler(fn func(http.ResponseWriter, *http.Reque
 	}
 }
 
-// Garuntees that the user exists in the session cache for managing operations
-func (webServer *WebServer) loginHandler(writer http.ResponseWriter, request *http.Request) {
-	userName := request.FormValue("username")
-	if _, ok := webServer.userSessions.Load(userName); !ok {
-		webServer.userSessions.Store(userName, usersessions.NewUserSession(userName))
-	}
-}
-
-func (webServer *WebServer) addHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	amount := request.FormValue("amount")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "ADD", username, nil, nil, amount)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "ADD,"+username+","+amount)
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
-	}
-}
-
-func (webServer *WebServer) quoteHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "QUOTE",
-		username, stock, nil, nil)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "QUOTE,"+username+","+stock)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+// register creates an account for a new user
+func (webServer *WebServer) register(transNum int, username string, password string) transmitter.Result {
+	if username == "" || len(password) < minPasswordLength {
+		return failure("BAD_REQUEST",
+			fmt.Sprintf("A username and a password of at least %d characters are required", minPasswordLength))
 	}
-	writer.Write([]byte(resp))
+	return webServer.transmitter.MakeRequest(transNum, "REGISTER", username, password)
 }
 
-func (webServer *WebServer) buyHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-	command := commands.NewCommand("BUY", username, []string{stock, amount})
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "BUY",
-		username, stock, nil, amount)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Invalid request", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "BUY,"+username+","+stock+","+amount)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+// authenticate checks the user's password
+func (webServer *WebServer) authenticate(transNum int, username string, password string) transmitter.Result {
+	if username == "" || password == "" {
+		return failure("BAD_REQUEST", "A username and password are required")
 	}
-
-	// Append buy to pendingBuys list
-	userSession.PendingBuys = append(userSession.PendingBuys, command)
+	return webServer.transmitter.MakeRequest(transNum, "AUTHENTICATE", username, password)
 }
 
-func (webServer *WebServer) commitBuyHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "COMMIT_BUY",
-		username, nil, nil, nil)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	if !userSession.HasPendingBuys() {
-		// No pendings buys, return error
-		go webServer.logger.SystemError(webServer.Name, currTransNum, "COMMIT_BUY",
-			username, nil, nil, nil, "No pending buys to commit")
-		http.Error(writer, "No pending buys to commit", 400)
-		return
-	}
-
-	lastBuyCommand := userSession.PendingBuys[0]
-	var resp string
-	if lastBuyCommand.HasTimeElapsed() {
-		// Time has elapsed on Buy, automatically cancel request
-		resp = webServer.transmitter.MakeRequest(currTransNum, "CANCEL_BUY,"+username)
-		webServer.logger.SystemError(webServer.Name, currTransNum, "COMMIT_BUY",
-			username, nil, nil, nil, "Time elapsed on most recent buy request")
-		http.Error(writer, "Time elapsed on most recent buy request", 400)
-		if len(userSession.PendingBuys) <= 1 {
-			// clear the list
-			userSession.PendingBuys = nil
-		} else {
-			// Pop last sell off the pending list.
-			userSession.PendingBuys = userSession.PendingBuys[1:]
-		}
-		return
-	} else {
-		resp = webServer.transmitter.MakeRequest(currTransNum, "COMMIT_BUY,"+username)
-	}
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid request", 400)
-		return
-	}
-
-	if len(userSession.PendingBuys) <= 1 {
-		// clear the list
-		userSession.PendingBuys = nil
-	} else {
-		// Pop last sell off the pending list.
-		userSession.PendingBuys = userSession.PendingBuys[1:]
-	}
+// startSession issues a session token for the user and sets it as the session cookie
+func (webServer *WebServer) startSession(writer http.ResponseWriter, username string) sessionBody {
+	token, claims := webServer.sessions.issue(username, time.Now())
+	http.SetCookie(writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Unix(claims.Expires, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return sessionBody{Token: token, Expires: claims.Expires}
 }
 
-func (webServer *WebServer) cancelBuyHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "CANCEL_BUY",
-		username, nil, nil, nil)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	if !userSession.HasPendingBuys() {
-		webServer.logger.SystemError(webServer.Name, currTransNum, "CANCEL_BUY",
-			username, nil, nil, nil, "No pending buys to cancel")
-		http.Error(writer, "No pending buys to cancel", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "CANCEL_BUY,"+username)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid request", 400)
-		return
-	}
-
-	if len(userSession.PendingBuys) <= 1 {
-		// clear the list
-		userSession.PendingBuys = nil
-	} else {
-		// Pop last sell off the pending list.
-		userSession.PendingBuys = userSession.PendingBuys[1:]
+// endSession logs out the session the request was made with and clears the cookie
+func (webServer *WebServer) endSession(writer http.ResponseWriter, request *http.Request) {
+	claims, err := webServer.sessions.verify(sessionToken(request), time.Now())
+	if err == nil {
+		webServer.sessions.revoke(claims, time.Now())
 	}
+	http.SetCookie(writer, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
 }
 
-func (webServer *WebServer) sellHandler(writer http.ResponseWriter, request *http.Request) {
+// Creates an account for a new user
+func (webServer *WebServer) registerHandler(writer http.ResponseWriter, request *http.Request) {
 	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-	command := commands.NewCommand("SELL", username, []string{stock, amount})
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "SELL",
-		username, stock, nil, amount)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "SELL,"+username+","+stock+","+amount)
-	if resp == "-1" {
-		http.Error(writer, "Bad response from transactionserv", 400)
+	resp := webServer.register(currTransNum, request.FormValue("username"), request.FormValue("password"))
+	if !resp.Succeeded() {
+		writeFailure(writer, resp)
 		return
 	}
-
-	userSession.PendingSells = append(userSession.PendingSells, command)
+	writer.WriteHeader(http.StatusCreated)
 }
 
-func (webServer *WebServer) commitSellHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "COMMIT_SELL",
-		username, nil, nil, nil)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	if !userSession.HasPendingSells() {
-		// No pendings buys, return error
-		webServer.logger.SystemError(webServer.Name, currTransNum, "COMMIT_SELL",
-			username, nil, nil, nil, "No pending sells to commit")
-		http.Error(writer, "No pending sells to commit", 400)
-		return
-	}
-
-	command := userSession.PendingSells[0]
-	var resp string
-
-	if command.HasTimeElapsed() {
-		// Time has elapsed on Buy, automatically cancel request
-		resp = webServer.transmitter.MakeRequest(currTransNum, "CANCEL_SELL,"+username)
-		webServer.logger.SystemError(webServer.Name, currTransNum, "COMMIT_SELL",
-			username, nil, nil, nil, "Time elapsed on most recent sell")
-		http.Error(writer, "Time elapsed on most recent sell", 400)
-		// Pop off request when invalid
-		if len(userSession.PendingSells) <= 1 {
-			// clear the list
-			userSession.PendingSells = nil
-		} else {
-			// Pop last sell off the pending list.
-			userSession.PendingSells = userSession.PendingSells[1:]
-		}
-		return
-	} else {
-		resp = webServer.transmitter.MakeRequest(currTransNum, "COMMIT_SELL,"+username)
-	}
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid request", 400)
-		return
-	}
-	if len(userSession.PendingSells) <= 1 {
-		// clear the list
-		userSession.PendingSells = nil
-	} else {
-		// Pop last sell off the pending list.
-		userSession.PendingSells = userSession.PendingSells[1:]
-	}
-
-}
-
-func (webServer *WebServer) cancelSellHandler(writer http.ResponseWriter, request *http.Request) {
+// Checks the user's password and issues a session token, both as a cookie and in the body
+func (webServer *WebServer) loginHandler(writer http.ResponseWriter, request *http.Request) {
 	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
 	username := request.FormValue("username")
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "CANCEL_SELL",
-		username, nil, nil, nil)
-
-	val, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-	userSession := val.(*usersessions.UserSession)
-
-	if !userSession.HasPendingSells() {
-		webServer.logger.SystemError(webServer.Name, currTransNum, "CANCEL_SELL",
-			username, nil, nil, nil, "User has no pending sells")
-		http.Error(writer, "No pending sells to cancel", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "CANCEL_SELL,"+username)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
+	resp := webServer.authenticate(currTransNum, username, request.FormValue("password"))
+	if !resp.Succeeded() {
+		writeFailure(writer, resp)
 		return
 	}
-
-	if len(userSession.PendingSells) <= 1 {
-		// clear the list
-		userSession.PendingSells = nil
-	} else {
-		// Pop last sell off the pending list.
-		userSession.PendingSells = userSession.PendingSells[1:]
-	}
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(webServer.startSession(writer, username))
 }
 
-func (webServer *WebServer) setBuyAmountHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "SET_BUY_AMOUNT",
-		username, stock, nil, amount)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "SET_BUY_AMOUNT,"+username+","+stock+","+amount)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
-	}
+// Ends the session the request was made with
+func (webServer *WebServer) logoutHandler(writer http.ResponseWriter, request *http.Request) {
+	webServer.endSession(writer, request)
 }
 
-func (webServer *WebServer) cancelSetBuyHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "CANCEL_SET_BUY",
-		username, stock, nil, nil)
+// run logs a user command to the audit server and sends it to the transaction server.
+// Empty stock and amount are left out of the command, as are empty options at
+// the end, such as a trigger's ID and expiry. Pending orders are only kept by
+// the transaction server, which decides which one a commit or cancel takes.
+func (webServer *WebServer) run(transNum int, command string, username string, stock string,
+	amount string, options ...string) transmitter.Result {
+	webServer.logger.UserCommand(webServer.Name, transNum, command,
+		username, orNil(stock), nil, orNil(amount))
 
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
+	args := []string{username}
+	if stock != "" {
+		args = append(args, stock)
 	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "CANCEL_SET_BUY,"+username+","+stock)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+	if amount != "" {
+		args = append(args, amount)
 	}
-}
-
-func (webServer *WebServer) setBuyTriggerHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "SET_BUY_TRIGGER",
-		username, stock, nil, amount)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "SET_BUY_TRIGGER,"+username+","+stock+","+amount)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+	for len(options) > 0 && options[len(options)-1] == "" {
+		options = options[:len(options)-1]
 	}
+	args = append(args, options...)
+	return webServer.transmitter.MakeRequest(transNum, command, args...)
 }
 
-func (webServer *WebServer) setSellAmountHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "SET_SELL_AMOUNT",
-		username, stock, nil, amount)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "SET_SELL_AMOUNT,"+username+","+stock+","+amount)
-
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+func orNil(value string) interface{} {
+	if value == "" {
+		return nil
 	}
+	return value
 }
 
-func (webServer *WebServer) setSellTriggerHandler(writer http.ResponseWriter, request *http.Request) {
-	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-	amount := request.FormValue("amount")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "SET_SELL_TRIGGER",
-		username, stock, nil, amount)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "SET_SELL_TRIGGER,"+username+","+stock+","+amount)
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
-		return
+// commandHandler serves a form POST for a command, replying with its payload
+// on success, such as the trigger ID from SET_BUY_AMOUNT or SET_SELL_AMOUNT.
+// options names the optional form values the command takes after the amount,
+// in order, such as a trigger's "id" and "expiry".
+func (webServer *WebServer) commandHandler(command string,
+	options ...string) func(http.ResponseWriter, *http.Request, string) {
+	return func(writer http.ResponseWriter, request *http.Request, username string) {
+		currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
+		values := make([]string, len(options))
+		for i, option := range options {
+			values[i] = request.FormValue(option)
+		}
+		resp := webServer.run(currTransNum, command, username,
+			request.FormValue("stock"), request.FormValue("amount"), values...)
+		if !resp.Succeeded() {
+			writeFailure(writer, resp)
+			return
+		}
+		writer.Write([]byte(resp.PayloadString()))
 	}
 }
 
-func (webServer *WebServer) cancelSetSellHandler(writer http.ResponseWriter, request *http.Request) {
+func (webServer *WebServer) quoteHandler(writer http.ResponseWriter, request *http.Request, username string) {
 	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
-	stock := request.FormValue("stock")
-
-	webServer.logger.UserCommand(webServer.Name, currTransNum, "CANCEL_SET_SELL",
-		username, stock, nil, nil)
-
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "CANCEL_SET_SELL,"+username+","+stock)
-	if resp == "-1" {
-		http.Error(writer, "Invalid Request", 400)
+	resp := webServer.run(currTransNum, "QUOTE", username, request.FormValue("stock"), "")
+	if !resp.Succeeded() {
+		writeFailure(writer, resp)
 		return
 	}
+	writer.Write([]byte(resp.PayloadString()))
 }
 
-func (webServer *WebServer) dumplogHandler(writer http.ResponseWriter, request *http.Request) {
+func (webServer *WebServer) dumplogHandler(writer http.ResponseWriter, request *http.Request, username string) {
 	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
 	filename := request.FormValue("filename")
 
-	if len(username) == 0 {
-		webServer.logger.UserCommand(webServer.Name, currTransNum, "DUMPLOG",
-			nil, nil, filename, nil)
+	webServer.logger.UserCommand(webServer.Name, currTransNum, "DUMPLOG",
+		username, nil, filename, nil)
+
+	// Admins dump every user's transactions, everyone else only their own
+	if webServer.admins[username] {
+		webServer.logger.DumpLog(filename, nil)
 	} else {
-		webServer.logger.UserCommand(webServer.Name, currTransNum, "DUMPLOG",
-			username, nil, filename, nil)
+		webServer.logger.DumpLog(filename, username)
 	}
-
-	webServer.logger.DumpLog(filename, nil)
 	file := webServer.transmitter.RetrieveDumplog(filename)
 	writer.Write(file)
 }
 
-func (webServer *WebServer) displaySummaryHandler(writer http.ResponseWriter, request *http.Request) {
+// Reports the transmitter's connection and latency stats as JSON
+func (webServer *WebServer) statsHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(webServer.transmitter.Stats())
+}
+
+func (webServer *WebServer) displaySummaryHandler(writer http.ResponseWriter, request *http.Request, username string) {
 	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
-	username := request.FormValue("username")
 
 	webServer.logger.UserCommand(webServer.Name, currTransNum, "DISPLAY_SUMMARY",
 		username, nil, nil, nil)
 
-	_, ok := webServer.userSessions.Load(username)
-	// User must be logged in to execute any commands.
-	if !ok {
-		http.Error(writer, "Must be logged in to perform commands", 400)
-		return
-	}
-
-	resp := webServer.transmitter.MakeRequest(currTransNum, "DISPLAY_SUMMARY,"+username)
-	if resp == "-1" {
+	resp := webServer.transmitter.MakeRequest(currTransNum, "DISPLAY_SUMMARY", username)
+	if !resp.Succeeded() {
 		webServer.logger.SystemError(webServer.Name, currTransNum, "DISPLAY_SUMMARY",
 			username, nil, nil, nil, "Bad response from transactionserv")
-		http.Error(writer, "Invalid Request", 400)
+		writeFailure(writer, resp)
 		return
 	}
-	lines := strings.Split(resp, ";")
+	lines := strings.Split(resp.PayloadString(), ";")
 	fmt.Fprintln(writer, strings.Join(lines, "\n"))
 }
 
 func main() {
 	serverAddress := ":" + os.Getenv("webport")
 	auditAddr := "http://" + os.Getenv("auditaddr") + ":" + os.Getenv("auditport")
+	if err := checkSessionSecret(os.Getenv("sessionsecret")); err != nil {
+		panic(err)
+	}
 
 	webServer := &WebServer{
 		Name:              "webserver",
 		transactionNumber: 0,
-		userSessions:      new(syncmap.Map),
+		sessions:          newSessionSigner(os.Getenv("sessionsecret")),
+		admins:            make(map[string]bool),
 		transmitter:       transmitter.NewTransmitter(os.Getenv("transaddr"), os.Getenv("transport")),
 		logger: logger.AuditLogger{
 			Addr: auditAddr,
@@ -536,27 +228,50 @@ func main() {
 				Timeout: time.Second,
 			},
 		},
-		validPath: regexp.MustCompile("^/(ADD|QUOTE|BUY|COMMIT_BUY|CANCEL_BUY|SELL|COMMIT_SELL|CANCEL_SELL|SET_BUY_AMOUNT|CANCEL_SET_BUY|SET_BUY_TRIGGER|SET_SELL_AMOUNT|SET_SELL_TRIGGER|CANCEL_SET_SELL|DUMPLOG|DISPLAY_SUMMARY|LOGIN)/$"),
+		validPath: regexp.MustCompile("^/(ADD|QUOTE|BUY|COMMIT_BUY|CANCEL_BUY|SELL|COMMIT_SELL|CANCEL_SELL|SET_BUY_AMOUNT|CANCEL_SET_BUY|SET_BUY_TRIGGER|SET_SELL_AMOUNT|SET_SELL_TRIGGER|CANCEL_SET_SELL|DUMPLOG|DISPLAY_SUMMARY|LOGIN|REGISTER|LOGOUT|STATS|RATELIMITS)/$"),
 	}
 
 	http.Handle("/", http.FileServer(http.Dir("./html")))
-	http.HandleFunc("/ADD/", webServer.addHandler)
-	http.HandleFunc("/QUOTE/", webServer.quoteHandler)
-	http.HandleFunc("/BUY/", webServer.buyHandler)
-	http.HandleFunc("/COMMIT_BUY/", webServer.commitBuyHandler)
-	http.HandleFunc("/CANCEL_BUY/", webServer.cancelBuyHandler)
-	http.HandleFunc("/SELL/", webServer.sellHandler)
-	http.HandleFunc("/COMMIT_SELL/", webServer.commitSellHandler)
-	http.HandleFunc("/CANCEL_SELL/", webServer.cancelSellHandler)
-	http.HandleFunc("/SET_BUY_AMOUNT/", webServer.setBuyAmountHandler)
-	http.HandleFunc("/CANCEL_SET_BUY/", webServer.cancelSetBuyHandler)
-	http.HandleFunc("/SET_BUY_TRIGGER/", webServer.setBuyTriggerHandler)
-	http.HandleFunc("/SET_SELL_AMOUNT/", webServer.setSellAmountHandler)
-	http.HandleFunc("/SET_SELL_TRIGGER/", webServer.setSellTriggerHandler)
-	http.HandleFunc("/CANCEL_SET_SELL/", webServer.cancelSetSellHandler)
-	http.HandleFunc("/DUMPLOG/", webServer.dumplogHandler)
-	http.HandleFunc("/DISPLAY_SUMMARY/", webServer.displaySummaryHandler)
-	http.HandleFunc("/LOGIN/", webServer.loginHandler)
+	http.HandleFunc("/ADD/", webServer.userRoute("ADD", webServer.commandHandler("ADD")))
+	http.HandleFunc("/QUOTE/", webServer.userRoute("QUOTE", webServer.quoteHandler))
+	http.HandleFunc("/BUY/", webServer.userRoute("BUY", webServer.commandHandler("BUY")))
+	http.HandleFunc("/COMMIT_BUY/", webServer.userRoute("COMMIT_BUY", webServer.commandHandler("COMMIT_BUY")))
+	http.HandleFunc("/CANCEL_BUY/", webServer.userRoute("CANCEL_BUY", webServer.commandHandler("CANCEL_BUY")))
+	http.HandleFunc("/SELL/", webServer.userRoute("SELL", webServer.commandHandler("SELL")))
+	http.HandleFunc("/COMMIT_SELL/", webServer.userRoute("COMMIT_SELL", webServer.commandHandler("COMMIT_SELL")))
+	http.HandleFunc("/CANCEL_SELL/", webServer.userRoute("CANCEL_SELL", webServer.commandHandler("CANCEL_SELL")))
+	http.HandleFunc("/SET_BUY_AMOUNT/", webServer.userRoute("SET_BUY_AMOUNT", webServer.commandHandler("SET_BUY_AMOUNT")))
+	http.HandleFunc("/CANCEL_SET_BUY/", webServer.userRoute("CANCEL_SET_BUY", webServer.commandHandler("CANCEL_SET_BUY", "id")))
+	http.HandleFunc("/SET_BUY_TRIGGER/", webServer.userRoute("SET_BUY_TRIGGER",
+		webServer.commandHandler("SET_BUY_TRIGGER", "id", "expiry")))
+	http.HandleFunc("/SET_SELL_AMOUNT/", webServer.userRoute("SET_SELL_AMOUNT", webServer.commandHandler("SET_SELL_AMOUNT")))
+	http.HandleFunc("/SET_SELL_TRIGGER/", webServer.userRoute("SET_SELL_TRIGGER",
+		webServer.commandHandler("SET_SELL_TRIGGER", "id", "expiry")))
+	http.HandleFunc("/CANCEL_SET_SELL/", webServer.userRoute("CANCEL_SET_SELL", webServer.commandHandler("CANCEL_SET_SELL", "id")))
+	http.HandleFunc("/DUMPLOG/", webServer.userRoute("DUMPLOG", webServer.dumplogHandler))
+	http.HandleFunc("/DISPLAY_SUMMARY/", webServer.userRoute("DISPLAY_SUMMARY", webServer.displaySummaryHandler))
+	http.HandleFunc("/REGISTER/", webServer.publicRoute(webServer.registerHandler))
+	http.HandleFunc("/LOGIN/", webServer.publicRoute(webServer.loginHandler))
+	http.HandleFunc("/LOGOUT/", webServer.logoutHandler)
+	http.HandleFunc("/STATS/", webServer.statsHandler)
+	http.HandleFunc("/RATELIMITS/", webServer.rateLimitsHandler)
+	webServer.registerAPI(http.DefaultServeMux)
+
+	webServer.events = newEventHub(webServer.refreshQuote)
+	go webServer.events.Run(time.Second)
+	go listenForEvents(os.Getenv("dbaddr")+":"+os.Getenv("dbport"), eventsChannel, webServer.events)
+
+	limiter, err := parseRateLimits(os.Getenv("ratelimits"))
+	if err != nil {
+		panic(err)
+	}
+	webServer.limiter = limiter
+
+	for _, admin := range strings.Split(os.Getenv("adminusers"), ",") {
+		if admin != "" {
+			webServer.admins[admin] = true
+		}
+	}
 
 	fmt.Printf("Successfully started server on %s\n", serverAddress)
 	panic(http.ListenAndServe(":"+os.Getenv("webport"), nil))
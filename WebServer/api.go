@@ -0,0 +1,450 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"seng468/WebServer/transmitter"
+
+	"github.com/shopspring/decimal"
+)
+
+// apiPrefix is where the versioned JSON API is served.
+// The form-POST endpoints such as /BUY/ are kept for the WorkloadGen.
+const apiPrefix = "/api/v1"
+
+// apiRoute is one endpoint of the JSON API. The same table registers the
+// handlers and generates the OpenAPI document, so the two can't disagree.
+type apiRoute struct {
+	method  string
+	path    string // Relative to apiPrefix, with {name} path parameters
+	summary string
+	public  bool // Served without a session
+	query   []apiParam
+
+	// command is whose rate limits apply to the route, with {side} replaced by
+	// the order's side. Public routes are only held to the global limit.
+	command string
+
+	// Zero values of the request and reply bodies, or nil for none
+	request interface{}
+	reply   interface{}
+	status  int
+
+	handle func(call apiCall) (interface{}, transmitter.Result)
+
+	// stream, instead of handle, serves a route that writes its own reply as
+	// server-sent events of the reply type
+	stream func(webServer *WebServer, writer http.ResponseWriter, request *http.Request, username string)
+}
+
+// apiParam documents a query parameter
+type apiParam struct {
+	name        string
+	description string
+	schema      string
+}
+
+// apiCall is a request the API is serving. Body points to a decoded value
+// of the route's request type.
+type apiCall struct {
+	webServer *WebServer
+	writer    http.ResponseWriter
+	request   *http.Request
+	transNum  int
+	user      string
+	body      interface{}
+}
+
+// side reads the {side} path parameter as BUY or SELL
+func (call apiCall) side() (string, bool) {
+	return parseSide(call.request.PathValue("side"))
+}
+
+// rateCommand fills in the side of a route's command from the path or the request body
+func (call apiCall) rateCommand(command string) string {
+	side := call.request.PathValue("side")
+	switch body := call.body.(type) {
+	case *orderBody:
+		side = body.Side
+	case *triggerBody:
+		side = body.Side
+	}
+	return strings.ReplaceAll(command, "{side}", strings.ToUpper(side))
+}
+
+func parseSide(side string) (string, bool) {
+	side = strings.ToUpper(side)
+	return side, side == "BUY" || side == "SELL"
+}
+
+// API bodies. Amounts and prices are decimal strings, as in the form endpoints.
+
+type credentialsBody struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type depositBody struct {
+	Amount string `json:"amount" doc:"Dollars to add to the account"`
+}
+
+type quoteBody struct {
+	Stock string `json:"stock"`
+	Price string `json:"price"`
+}
+
+type orderBody struct {
+	Side   string `json:"side" doc:"BUY or SELL"`
+	Stock  string `json:"stock"`
+	Amount string `json:"amount" doc:"Dollars to buy or sell as many whole shares as possible for"`
+}
+
+type triggerBody struct {
+	ID      string `json:"id,omitempty" doc:"Set by the server. Identifies the trigger in the other trigger routes."`
+	Side    string `json:"side" doc:"BUY or SELL"`
+	Stock   string `json:"stock"`
+	Amount  string `json:"amount" doc:"Dollars to buy, or sell shares worth, when the trigger fires"`
+	Price   string `json:"price,omitempty" doc:"Price that fires the trigger. Can be set later."`
+	Expires string `json:"expires,omitempty" doc:"When the trigger expires, in unix milliseconds. Needs a price."`
+}
+
+type triggerPriceBody struct {
+	Price   string `json:"price"`
+	Expires string `json:"expires,omitempty" doc:"When the trigger expires, in unix milliseconds. Never without one."`
+}
+
+type triggerStatusBody struct {
+	ID     string          `json:"id"`
+	Side   string          `json:"side"`
+	Stock  string          `json:"stock"`
+	Funds  decimal.Decimal `json:"funds" doc:"Dollars reserved by a BUY trigger"`
+	Shares int64           `json:"shares" doc:"Shares reserved by a SELL trigger"`
+}
+
+type holdingBody struct {
+	Stock    string `json:"stock"`
+	Shares   int64  `json:"shares"`
+	Reserved int64  `json:"reserved" doc:"Shares held for pending sells and sell triggers"`
+}
+
+type pendingOrderBody struct {
+	Type    string          `json:"type" doc:"Buy or Sell"`
+	Stock   string          `json:"stock"`
+	Cost    decimal.Decimal `json:"cost"`
+	Shares  int64           `json:"shares"`
+	Created time.Time       `json:"created"`
+}
+
+type historyEntryBody struct {
+	TransNum  int             `json:"transNum"`
+	Command   string          `json:"command"`
+	Stock     string          `json:"stock"`
+	Funds     decimal.Decimal `json:"funds"`
+	Shares    int64           `json:"shares"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp int64           `json:"timestamp" doc:"Milliseconds since the Unix epoch"`
+}
+
+// accountBody is the transaction server's ACCOUNT payload
+type accountBody struct {
+	User           string                     `json:"user"`
+	Funds          decimal.Decimal            `json:"funds"`
+	ReservedFunds  decimal.Decimal            `json:"reservedFunds"`
+	Stocks         map[string]int64           `json:"stocks"`
+	ReservedStocks map[string]int64           `json:"reservedStocks"`
+	BuyOrders      []pendingOrderBody         `json:"buyOrders"`
+	SellOrders     []pendingOrderBody         `json:"sellOrders"`
+	BuyTriggers    map[string]decimal.Decimal `json:"buyTriggers"`
+	SellTriggers   map[string]int64           `json:"sellTriggers"`
+	Triggers       []triggerStatusBody        `json:"triggers" doc:"Each trigger by its ID"`
+	History        []historyEntryBody         `json:"history" doc:"The most recent history, newest first"`
+}
+
+var apiRoutes = []apiRoute{
+	{method: "POST", path: "/accounts", summary: "Register a new account", public: true,
+		request: credentialsBody{}, status: http.StatusCreated, handle: apiRegister},
+	{method: "POST", path: "/sessions", summary: "Log in, returning a bearer token and setting the session cookie",
+		public: true, request: credentialsBody{}, reply: sessionBody{}, status: http.StatusCreated, handle: apiLogin},
+	{method: "DELETE", path: "/sessions", summary: "Log out", status: http.StatusNoContent, command: "LOGOUT",
+		handle: apiLogout},
+
+	{method: "GET", path: "/accounts/me", summary: "Get your balances, holdings, pending orders, triggers and recent history",
+		reply: accountBody{}, status: http.StatusOK, command: "DISPLAY_SUMMARY", handle: apiAccount},
+	{method: "POST", path: "/accounts/me/deposits", summary: "Add funds to your account",
+		request: depositBody{}, status: http.StatusNoContent, command: "ADD", handle: apiDeposit},
+	{method: "GET", path: "/accounts/me/history", summary: "Get a page of changes to your account, newest first",
+		query: []apiParam{{name: "page", description: "Page number, starting from 0", schema: "integer"}},
+		reply: []historyEntryBody{}, status: http.StatusOK, command: "HISTORY", handle: apiHistory},
+
+	{method: "GET", path: "/holdings", summary: "List the stocks you hold",
+		reply: []holdingBody{}, status: http.StatusOK, command: "DISPLAY_SUMMARY", handle: apiHoldings},
+	{method: "GET", path: "/quotes/{stock}", summary: "Get a stock's price",
+		reply: quoteBody{}, status: http.StatusOK, command: "QUOTE", handle: apiQuote},
+
+	{method: "GET", path: "/orders", summary: "List your pending orders",
+		reply: []pendingOrderBody{}, status: http.StatusOK, command: "DISPLAY_SUMMARY", handle: apiOrders},
+	{method: "POST", path: "/orders", summary: "Place a buy or sell, which must be committed within a minute",
+		request: orderBody{}, reply: orderBody{}, status: http.StatusCreated, command: "{side}", handle: apiPlaceOrder},
+	{method: "POST", path: "/orders/{side}/commit", summary: "Commit your most recent pending buy or sell",
+		status: http.StatusNoContent, command: "COMMIT_{side}", handle: apiCommitOrder},
+	{method: "DELETE", path: "/orders/{side}", summary: "Cancel your most recent pending buy or sell",
+		status: http.StatusNoContent, command: "CANCEL_{side}", handle: apiCancelOrder},
+
+	{method: "GET", path: "/triggers", summary: "List each of your triggers and what it has reserved",
+		reply: []triggerStatusBody{}, status: http.StatusOK, command: "DISPLAY_SUMMARY", handle: apiTriggers},
+	{method: "POST", path: "/triggers", summary: "Set up a trigger, reserving its amount",
+		request: triggerBody{}, reply: triggerBody{}, status: http.StatusCreated, command: "SET_{side}_AMOUNT",
+		handle: apiSetTrigger},
+	{method: "PUT", path: "/triggers/{side}/{stock}/{id}/price", summary: "Set the price that fires a trigger",
+		request: triggerPriceBody{}, status: http.StatusNoContent, command: "SET_{side}_TRIGGER", handle: apiSetTriggerPrice},
+	{method: "DELETE", path: "/triggers/{side}/{stock}/{id}", summary: "Cancel a trigger, releasing its reservation",
+		status: http.StatusNoContent, command: "CANCEL_SET_{side}", handle: apiCancelTrigger},
+
+	{method: "GET", path: "/events", summary: "Stream your balance, order and trigger fill events, and quotes of watched stocks",
+		query: []apiParam{{name: "watch", description: "Comma separated stocks to stream quotes of", schema: "string"}},
+		reply: eventBody{}, status: http.StatusOK, command: "EVENTS", stream: (*WebServer).eventsHandler},
+}
+
+// registerAPI serves every API route, the OpenAPI document, and JSON 404s for other API paths
+func (webServer *WebServer) registerAPI(mux *http.ServeMux) {
+	for _, route := range apiRoutes {
+		mux.HandleFunc(route.method+" "+apiPrefix+route.path, webServer.apiHandler(route))
+	}
+
+	document, err := json.Marshal(openAPIDocument(apiRoutes))
+	if err != nil {
+		panic(err)
+	}
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.json", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(document)
+	})
+	mux.HandleFunc(apiPrefix+"/", func(writer http.ResponseWriter, request *http.Request) {
+		writeError(writer, codeNotFound, "No such API endpoint")
+	})
+}
+
+// apiHandler checks the session unless the route is public, decodes the
+// route's request body, and writes the handler's reply or failure as JSON
+func (webServer *WebServer) apiHandler(route apiRoute) http.HandlerFunc {
+	if route.stream != nil {
+		return webServer.userRoute(route.command, func(writer http.ResponseWriter, request *http.Request,
+			username string) {
+			route.stream(webServer, writer, request, username)
+		})
+	}
+	serve := func(writer http.ResponseWriter, request *http.Request, username string) {
+		call := apiCall{webServer: webServer, writer: writer, request: request, user: username}
+		if route.request != nil {
+			call.body = reflect.New(reflect.TypeOf(route.request)).Interface()
+			decoder := json.NewDecoder(request.Body)
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(call.body); err != nil {
+				writeError(writer, "BAD_REQUEST", "Could not parse the request body: "+err.Error())
+				return
+			}
+		}
+		if ok, wait := webServer.limiter.allow(username, call.rateCommand(route.command), time.Now()); !ok {
+			writeRateLimited(writer, wait)
+			return
+		}
+
+		call.transNum = int(atomic.AddInt64(&webServer.transactionNumber, 1))
+		reply, resp := route.handle(call)
+		if !resp.Succeeded() {
+			writeFailure(writer, resp)
+			return
+		}
+		if route.reply == nil {
+			writer.WriteHeader(route.status)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(route.status)
+		json.NewEncoder(writer).Encode(reply)
+	}
+	if route.public {
+		return func(writer http.ResponseWriter, request *http.Request) {
+			serve(writer, request, "")
+		}
+	}
+	return webServer.requireUser(serve)
+}
+
+// succeeded is the result of a handler that didn't need the transaction server
+var succeeded = transmitter.Result{Status: 1}
+
+func badRequest(message string) (interface{}, transmitter.Result) {
+	return nil, failure("BAD_REQUEST", message)
+}
+
+func apiRegister(call apiCall) (interface{}, transmitter.Result) {
+	body := call.body.(*credentialsBody)
+	return nil, call.webServer.register(call.transNum, body.Username, body.Password)
+}
+
+func apiLogin(call apiCall) (interface{}, transmitter.Result) {
+	body := call.body.(*credentialsBody)
+	resp := call.webServer.authenticate(call.transNum, body.Username, body.Password)
+	if !resp.Succeeded() {
+		return nil, resp
+	}
+	return call.webServer.startSession(call.writer, body.Username), resp
+}
+
+func apiLogout(call apiCall) (interface{}, transmitter.Result) {
+	call.webServer.endSession(call.writer, call.request)
+	return nil, succeeded
+}
+
+// account fetches the user's account from the transaction server.
+// It's audited as a DISPLAY_SUMMARY, which reads the same information.
+func (call apiCall) account() (accountBody, transmitter.Result) {
+	var account accountBody
+	call.webServer.logger.UserCommand(call.webServer.Name, call.transNum, "DISPLAY_SUMMARY",
+		call.user, nil, nil, nil)
+	resp := call.webServer.transmitter.MakeRequest(call.transNum, "ACCOUNT", call.user)
+	if resp.Succeeded() {
+		if err := json.Unmarshal(resp.Payload, &account); err != nil {
+			return account, failure("BAD_RESPONSE", "Could not parse the account: "+err.Error())
+		}
+	}
+	return account, resp
+}
+
+func apiAccount(call apiCall) (interface{}, transmitter.Result) {
+	return call.account()
+}
+
+func apiDeposit(call apiCall) (interface{}, transmitter.Result) {
+	body := call.body.(*depositBody)
+	return nil, call.webServer.run(call.transNum, "ADD", call.user, "", body.Amount)
+}
+
+func apiHistory(call apiCall) (interface{}, transmitter.Result) {
+	page := call.request.URL.Query().Get("page")
+	if page == "" {
+		page = "0"
+	} else if n, err := strconv.Atoi(page); err != nil || n < 0 {
+		return badRequest("page must be a number from 0")
+	}
+	resp := call.webServer.transmitter.MakeRequest(call.transNum, "HISTORY", call.user, page)
+	history := []historyEntryBody{}
+	if resp.Succeeded() && len(resp.Payload) > 0 {
+		if err := json.Unmarshal(resp.Payload, &history); err != nil {
+			return nil, failure("BAD_RESPONSE", "Could not parse the history: "+err.Error())
+		}
+	}
+	return history, resp
+}
+
+func apiHoldings(call apiCall) (interface{}, transmitter.Result) {
+	account, resp := call.account()
+	holdings := []holdingBody{}
+	for stock, shares := range account.Stocks {
+		holdings = append(holdings, holdingBody{Stock: stock, Shares: shares, Reserved: account.ReservedStocks[stock]})
+	}
+	for stock, reserved := range account.ReservedStocks {
+		if _, held := account.Stocks[stock]; !held {
+			holdings = append(holdings, holdingBody{Stock: stock, Reserved: reserved})
+		}
+	}
+	return holdings, resp
+}
+
+func apiQuote(call apiCall) (interface{}, transmitter.Result) {
+	stock := call.request.PathValue("stock")
+	resp := call.webServer.run(call.transNum, "QUOTE", call.user, stock, "")
+	return quoteBody{Stock: stock, Price: resp.PayloadString()}, resp
+}
+
+func apiOrders(call apiCall) (interface{}, transmitter.Result) {
+	account, resp := call.account()
+	return append(append([]pendingOrderBody{}, account.BuyOrders...), account.SellOrders...), resp
+}
+
+func apiPlaceOrder(call apiCall) (interface{}, transmitter.Result) {
+	body := call.body.(*orderBody)
+	side, valid := parseSide(body.Side)
+	if !valid {
+		return badRequest("side must be BUY or SELL")
+	}
+	body.Side = side
+	return body, call.webServer.run(call.transNum, side, call.user, body.Stock, body.Amount)
+}
+
+func apiCommitOrder(call apiCall) (interface{}, transmitter.Result) {
+	side, valid := call.side()
+	if !valid {
+		return badRequest("side must be buy or sell")
+	}
+	return nil, call.webServer.run(call.transNum, "COMMIT_"+side, call.user, "", "")
+}
+
+func apiCancelOrder(call apiCall) (interface{}, transmitter.Result) {
+	side, valid := call.side()
+	if !valid {
+		return badRequest("side must be buy or sell")
+	}
+	return nil, call.webServer.run(call.transNum, "CANCEL_"+side, call.user, "", "")
+}
+
+func apiTriggers(call apiCall) (interface{}, transmitter.Result) {
+	account, resp := call.account()
+	return append([]triggerStatusBody{}, account.Triggers...), resp
+}
+
+// apiSetTrigger sets a trigger's amount and, if given, its price. The price is
+// set on the trigger by the ID SET_BUY_AMOUNT or SET_SELL_AMOUNT returned, so it
+// can't start another trigger set at the same time. A trigger whose price is
+// rejected is cancelled again so nothing stays reserved.
+func apiSetTrigger(call apiCall) (interface{}, transmitter.Result) {
+	body := call.body.(*triggerBody)
+	side, valid := parseSide(body.Side)
+	if !valid {
+		return badRequest("side must be BUY or SELL")
+	}
+	body.Side = side
+	if body.Price == "" && body.Expires != "" {
+		return badRequest("expires needs a price")
+	}
+	resp := call.webServer.run(call.transNum, "SET_"+side+"_AMOUNT", call.user, body.Stock, body.Amount)
+	if !resp.Succeeded() {
+		return body, resp
+	}
+	body.ID = resp.PayloadString()
+	if body.Price == "" {
+		return body, resp
+	}
+	resp = call.webServer.run(call.transNum, "SET_"+side+"_TRIGGER", call.user, body.Stock, body.Price, body.ID,
+		body.Expires)
+	if !resp.Succeeded() {
+		call.webServer.run(call.transNum, "CANCEL_SET_"+side, call.user, body.Stock, "", body.ID)
+	}
+	return body, resp
+}
+
+func apiSetTriggerPrice(call apiCall) (interface{}, transmitter.Result) {
+	side, valid := call.side()
+	if !valid {
+		return badRequest("side must be buy or sell")
+	}
+	body := call.body.(*triggerPriceBody)
+	return nil, call.webServer.run(call.transNum, "SET_"+side+"_TRIGGER", call.user,
+		call.request.PathValue("stock"), body.Price, call.request.PathValue("id"), body.Expires)
+}
+
+func apiCancelTrigger(call apiCall) (interface{}, transmitter.Result) {
+	side, valid := call.side()
+	if !valid {
+		return badRequest("side must be buy or sell")
+	}
+	return nil, call.webServer.run(call.transNum, "CANCEL_SET_"+side, call.user,
+		call.request.PathValue("stock"), "", call.request.PathValue("id"))
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"seng468/WebServer/logger"
+	"seng468/WebServer/transmitter"
+)
+
+// quietLogger drops the audit logs the API writes
+type quietLogger struct{ logger.Logger }
+
+func (quietLogger) UserCommand(string, int, string, interface{}, interface{}, interface{}, interface{}) {
+}
+func (quietLogger) SystemError(string, int, string, interface{}, interface{}, interface{}, interface{},
+	interface{}) {
+}
+
+// newAPIServer serves the API with a transmitter that can't reach a transaction server
+func newAPIServer(t *testing.T) (*WebServer, *httptest.Server) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	webServer := &WebServer{
+		Name:        "webserver",
+		sessions:    newSessionSigner("secret"),
+		transmitter: transmitter.NewTransmitter(host, port),
+		events:      newEventHub(func(string, string) {}),
+		logger:      quietLogger{},
+	}
+	mux := http.NewServeMux()
+	webServer.registerAPI(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return webServer, server
+}
+
+// call makes an API request and returns the status and the error code of a failure
+func call(t *testing.T, server *httptest.Server, method string, path string, token string,
+	body string) (int, string) {
+	request, _ := http.NewRequest(method, server.URL+apiPrefix+path, strings.NewReader(body))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var failure errorBody
+	json.NewDecoder(resp.Body).Decode(&failure)
+	return resp.StatusCode, failure.Code
+}
+
+func TestAPIErrors(t *testing.T) {
+	webServer, server := newAPIServer(t)
+	token, _ := webServer.sessions.issue("user1", time.Now())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		code   string
+	}{
+		{"no session", "GET", "/accounts/me", "", "", http.StatusUnauthorized, codeNotLoggedIn},
+		{"bad json", "POST", "/orders", token, "{", http.StatusBadRequest, "BAD_REQUEST"},
+		{"unknown field", "POST", "/orders", token, `{"username":"user2"}`, http.StatusBadRequest, "BAD_REQUEST"},
+		{"bad side", "POST", "/orders", token, `{"side":"HOLD","stock":"ABC","amount":"1"}`,
+			http.StatusBadRequest, "BAD_REQUEST"},
+		{"bad side path", "POST", "/orders/hold/commit", token, "", http.StatusBadRequest, "BAD_REQUEST"},
+		// Only the transaction server knows what's pending, the web server never answers for it
+		{"commit", "POST", "/orders/buy/commit", token, "", http.StatusServiceUnavailable, transmitter.CodeUnavailable},
+		{"cancel", "DELETE", "/orders/sell", token, "", http.StatusServiceUnavailable, transmitter.CodeUnavailable},
+		{"short password", "POST", "/accounts", "", `{"username":"user1","password":"short"}`,
+			http.StatusBadRequest, "BAD_REQUEST"},
+		{"bad page", "GET", "/accounts/me/history?page=-1", token, "", http.StatusBadRequest, "BAD_REQUEST"},
+		{"unknown path", "GET", "/portfolio", token, "", http.StatusNotFound, codeNotFound},
+		{"no transaction server", "GET", "/quotes/ABC", token, "", http.StatusServiceUnavailable,
+			transmitter.CodeUnavailable},
+	}
+	for _, test := range tests {
+		status, code := call(t, server, test.method, test.path, test.token, test.body)
+		if status != test.status || code != test.code {
+			t.Errorf("%s: expected %d %s, got %d %s", test.name, test.status, test.code, status, code)
+		}
+	}
+
+	if status, _ := call(t, server, "DELETE", "/sessions", token, ""); status != http.StatusNoContent {
+		t.Error("Logging out should succeed, got ", status)
+	}
+	if status, _ := call(t, server, "GET", "/orders", token, ""); status != http.StatusUnauthorized {
+		t.Error("A logged out token should be rejected, got ", status)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	_, server := newAPIServer(t)
+	resp, err := http.Get(server.URL + apiPrefix + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var document struct {
+		Paths      map[string]map[string]json.RawMessage
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage
+			}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range apiRoutes {
+		if _, ok := document.Paths[apiPrefix+route.path][strings.ToLower(route.method)]; !ok {
+			t.Errorf("%s %s is not documented", route.method, route.path)
+		}
+	}
+	for _, name := range []string{"Account", "Order", "Trigger", "Error", "Session", "PendingOrder"} {
+		if len(document.Components.Schemas[name].Properties) == 0 {
+			t.Errorf("Schema %s is missing", name)
+		}
+	}
+	if _, ok := document.Components.Schemas["Account"].Properties["buyOrders"]; !ok {
+		t.Error("Schemas should use json field names")
+	}
+}
+
+// fakeTransactionServer answers the transmitter's framed requests with handle,
+// which is given the command followed by its arguments
+func fakeTransactionServer(t *testing.T, webServer *WebServer, handle func(fields []string) transmitter.Result) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFrames(conn, handle)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	webServer.transmitter = transmitter.NewTransmitter(host, port)
+}
+
+func serveFrames(conn net.Conn, handle func(fields []string) transmitter.Result) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if _, err := io.ReadFull(reader, make([]byte, 4)); err != nil {
+		return
+	}
+	conn.Write([]byte("TSF\x01"))
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[:4])-4)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+		count := int(binary.BigEndian.Uint16(body[4:]))
+		body = body[6:]
+		var fields []string
+		for i := 0; i < count; i++ {
+			size := int(binary.BigEndian.Uint16(body))
+			fields = append(fields, string(body[2:2+size]))
+			body = body[2+size:]
+		}
+		encoded, _ := json.Marshal(handle(fields))
+		binary.BigEndian.PutUint32(header[:4], uint32(4+len(encoded)))
+		conn.Write(append(header[:], encoded...))
+	}
+}
+
+func TestAPITriggerIDs(t *testing.T) {
+	webServer, server := newAPIServer(t)
+	token, _ := webServer.sessions.issue("user1", time.Now())
+	var sent []string
+	var lock sync.Mutex
+	fakeTransactionServer(t, webServer, func(fields []string) transmitter.Result {
+		lock.Lock()
+		defer lock.Unlock()
+		sent = append(sent, strings.Join(fields, ","))
+		switch fields[0] {
+		case "SET_BUY_AMOUNT":
+			return transmitter.Result{Status: 1, Payload: json.RawMessage(`"id1"`)}
+		case "SET_BUY_TRIGGER":
+			return transmitter.Result{Status: -1, Code: "BAD_REQUEST", Message: "bad price"}
+		case "ACCOUNT":
+			return transmitter.Result{Status: 1, Payload: json.RawMessage(`{"triggers":[` +
+				`{"id":"id1","side":"BUY","stock":"ABC","funds":"10","shares":0},` +
+				`{"id":"id2","side":"BUY","stock":"ABC","funds":"5","shares":0}]}`)}
+		}
+		return transmitter.Result{Status: 1}
+	})
+	expectSent := func(expected ...string) {
+		lock.Lock()
+		defer lock.Unlock()
+		if strings.Join(sent, ";") != strings.Join(expected, ";") {
+			t.Errorf("Expected %v to be sent, got %v", expected, sent)
+		}
+		sent = nil
+	}
+
+	// A rejected price cancels the trigger that was just set, not the latest one
+	status, _ := call(t, server, "POST", "/triggers", token,
+		`{"side":"buy","stock":"ABC","amount":"10.00","price":"0","expires":"4102444800000"}`)
+	if status != http.StatusBadRequest {
+		t.Error("Expected the rejected price to fail, got ", status)
+	}
+	expectSent("SET_BUY_AMOUNT,user1,ABC,10.00", "SET_BUY_TRIGGER,user1,ABC,0,id1,4102444800000",
+		"CANCEL_SET_BUY,user1,ABC,id1")
+	if status, _ := call(t, server, "POST", "/triggers", token,
+		`{"side":"buy","stock":"ABC","amount":"10.00","expires":"4102444800000"}`); status != http.StatusBadRequest {
+		t.Error("An expiry without a price should be rejected, got ", status)
+	}
+	expectSent()
+
+	request, _ := http.NewRequest("POST", server.URL+apiPrefix+"/triggers",
+		strings.NewReader(`{"side":"buy","stock":"ABC","amount":"10.00"}`))
+	request.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set triggerBody
+	json.NewDecoder(resp.Body).Decode(&set)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || set.ID != "id1" {
+		t.Error("Expected the new trigger's ID in the reply, got ", resp.StatusCode, set)
+	}
+	expectSent("SET_BUY_AMOUNT,user1,ABC,10.00")
+
+	if status, _ := call(t, server, "PUT", "/triggers/buy/ABC/id2/price", token, `{"price":"5.00"}`); status !=
+		http.StatusBadRequest {
+		t.Error("Expected the fake's refusal, got ", status)
+	}
+	expectSent("SET_BUY_TRIGGER,user1,ABC,5.00,id2")
+	call(t, server, "PUT", "/triggers/buy/ABC/id2/price", token, `{"price":"5.00","expires":"4102444800000"}`)
+	expectSent("SET_BUY_TRIGGER,user1,ABC,5.00,id2,4102444800000")
+	if status, _ := call(t, server, "DELETE", "/triggers/buy/ABC/id2", token, ""); status != http.StatusNoContent {
+		t.Error("Expected the trigger to be cancelled, got ", status)
+	}
+	expectSent("CANCEL_SET_BUY,user1,ABC,id2")
+
+	request, _ = http.NewRequest("GET", server.URL+apiPrefix+"/triggers", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	resp, err = http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var triggers []triggerStatusBody
+	json.NewDecoder(resp.Body).Decode(&triggers)
+	if len(triggers) != 2 || triggers[0].ID != "id1" || triggers[1].ID != "id2" {
+		t.Error("Expected each trigger listed by ID, got ", triggers)
+	}
+}
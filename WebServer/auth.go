@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session tokens are issued at /LOGIN/ and accepted from the session cookie
+// or an "Authorization: Bearer" header
+const (
+	sessionCookie     = "session"
+	sessionTTL        = time.Hour * 12
+	minPasswordLength = 8
+)
+
+var (
+	errBadToken = errors.New("session token is malformed or has a bad signature")
+	errExpired  = errors.New("session has expired")
+	errRevoked  = errors.New("session has been logged out")
+)
+
+// sessionClaims is what a session token vouches for
+type sessionClaims struct {
+	User    string `json:"user"`
+	ID      string `json:"sid"`
+	Expires int64  `json:"exp"`
+}
+
+// sessionBody is the reply to a login
+type sessionBody struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires" doc:"Unix time the session expires at"`
+}
+
+// sessionSigner issues and verifies session tokens. A token is the base64
+// encoded JSON claims and their HMAC-SHA256, joined by ".". Every web server
+// behind the proxy must share the key for tokens to work on all of them.
+// Logged out sessions are remembered until they would have expired.
+type sessionSigner struct {
+	key []byte
+
+	lock    sync.Mutex
+	revoked map[string]time.Time
+}
+
+// placeholderSecret is the sessionsecret the deploy files used to ship with.
+// Anyone who has read them could forge a session with it.
+const placeholderSecret = "change-me-before-deploying"
+
+// checkSessionSecret refuses a missing sessionsecret, as the web servers
+// must share one, and the old placeholder
+func checkSessionSecret(secret string) error {
+	if secret == "" {
+		return errors.New("sessionsecret must be set to a key shared by every web server")
+	} else if secret == placeholderSecret {
+		return errors.New("sessionsecret is still the placeholder, set it to a secret key")
+	}
+	return nil
+}
+
+// newSessionSigner signs with secret, see checkSessionSecret
+func newSessionSigner(secret string) *sessionSigner {
+	return &sessionSigner{key: []byte(secret), revoked: make(map[string]time.Time)}
+}
+
+func (s *sessionSigner) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue returns a new token for user valid until sessionTTL after now
+func (s *sessionSigner) issue(user string, now time.Time) (string, sessionClaims) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	claims := sessionClaims{User: user, ID: hex.EncodeToString(id), Expires: now.Add(sessionTTL).Unix()}
+	encoded, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString(encoded)
+	return payload + "." + s.sign(payload), claims
+}
+
+// verify returns the claims of a token that is correctly signed, unexpired, and not logged out
+func (s *sessionSigner) verify(token string, now time.Time) (sessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return sessionClaims{}, errBadToken
+	}
+	encoded, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return sessionClaims{}, errBadToken
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(encoded, &claims); err != nil || claims.User == "" {
+		return sessionClaims{}, errBadToken
+	}
+	if now.Unix() >= claims.Expires {
+		return sessionClaims{}, errExpired
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.revoked[claims.ID]; ok {
+		return sessionClaims{}, errRevoked
+	}
+	return claims, nil
+}
+
+// revoke logs a session out, forgetting sessions that have expired anyway
+func (s *sessionSigner) revoke(claims sessionClaims, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for id, expires := range s.revoked {
+		if now.After(expires) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[claims.ID] = time.Unix(claims.Expires, 0)
+}
+
+// sessionToken finds the token sent with a request, preferring the Authorization header
+func sessionToken(request *http.Request) string {
+	if auth := request.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if cookie, err := request.Cookie(sessionCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// requireUser only calls fn for requests with a valid session, passing the session's user.
+// The user is never taken from the request's form.
+func (webServer *WebServer) requireUser(fn func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		claims, err := webServer.sessions.verify(sessionToken(request), time.Now())
+		if err != nil {
+			writeError(writer, codeNotLoggedIn, "Must be logged in to perform commands: "+err.Error())
+			return
+		}
+		fn(writer, request, claims.User)
+	}
+}
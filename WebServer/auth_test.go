@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionSigner(t *testing.T) {
+	signer := newSessionSigner("secret")
+	now := time.Now()
+
+	token, issued := signer.issue("user1", now)
+	claims, err := signer.verify(token, now)
+	if err != nil || claims.User != "user1" || claims.ID != issued.ID {
+		t.Fatal("A fresh token should verify, got ", claims, err)
+	}
+
+	if _, err := newSessionSigner("other").verify(token, now); err != errBadToken {
+		t.Error("A token signed with another key should be rejected, got ", err)
+	}
+	payload := strings.Split(token, ".")[0]
+	forged, _ := newSessionSigner("other").issue("user2", now)
+	if _, err := signer.verify(strings.Split(forged, ".")[0]+"."+strings.Split(token, ".")[1], now); err != errBadToken {
+		t.Error("Swapping claims under a valid signature should be rejected, got ", err)
+	}
+	if _, err := signer.verify(payload, now); err != errBadToken {
+		t.Error("An unsigned token should be rejected, got ", err)
+	}
+
+	if _, err := signer.verify(token, now.Add(sessionTTL)); err != errExpired {
+		t.Error("A token past its expiry should be rejected, got ", err)
+	}
+
+	signer.revoke(claims, now)
+	if _, err := signer.verify(token, now); err != errRevoked {
+		t.Error("A logged out token should be rejected, got ", err)
+	}
+	other, _ := signer.issue("user1", now)
+	if _, err := signer.verify(other, now); err != nil {
+		t.Error("Logging out shouldn't end the user's other sessions, got ", err)
+	}
+}
+
+func TestCheckSessionSecret(t *testing.T) {
+	if checkSessionSecret("") == nil {
+		t.Error("A missing secret should be refused")
+	}
+	if checkSessionSecret(placeholderSecret) == nil {
+		t.Error("The placeholder secret should be refused")
+	}
+	if err := checkSessionSecret("a-real-secret"); err != nil {
+		t.Error("A set secret should be accepted, got ", err)
+	}
+}
+
+func TestRequireUser(t *testing.T) {
+	webServer := &WebServer{sessions: newSessionSigner("secret")}
+	var user string
+	handler := webServer.requireUser(func(writer http.ResponseWriter, request *http.Request, username string) {
+		user = username
+	})
+	token, _ := webServer.sessions.issue("user1", time.Now())
+
+	// The form's username is never trusted
+	request := httptest.NewRequest("POST", "/ADD/?username=user2", nil)
+	request.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+	handler(httptest.NewRecorder(), request)
+	if user != "user1" {
+		t.Errorf("Expected the cookie's user1, got %q", user)
+	}
+
+	user = ""
+	request = httptest.NewRequest("POST", "/ADD/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	handler(httptest.NewRecorder(), request)
+	if user != "user1" {
+		t.Errorf("Expected the bearer token's user1, got %q", user)
+	}
+
+	user = ""
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/ADD/?username=user1", nil))
+	if user != "" || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("A request without a session should get 401, got %d for %q", recorder.Code, user)
+	}
+}
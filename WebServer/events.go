@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// eventsChannel is the redis channel the transaction and trigger servers publish user events on
+const eventsChannel = "UserEvents"
+
+const (
+	// streamBuffer is how many events a browser can fall behind before new ones are dropped
+	streamBuffer = 100
+	// orderWarning is how long before a pending order expires the user is warned
+	orderWarning = time.Second * 15
+	// quoteRefresh matches the quoteserver's cache lifetime, refreshing any
+	// faster would only return the same quote
+	quoteRefresh = (time.Second * 60) + time.Millisecond
+	// keepAlive is how often an idle stream is written to, so proxies don't close it
+	keepAlive = time.Second * 30
+)
+
+// eventOrderExpiring is sent by the web server itself, the other event types
+// are published by the transaction and trigger servers
+const eventOrderExpiring = "order_expiring"
+
+// eventBody is a user event. Data is forwarded as it was published.
+type eventBody struct {
+	Type     string          `json:"type" doc:"balance, order, order_expiring, trigger_fill or quote"`
+	User     string          `json:"user,omitempty" doc:"Missing from quotes, which go to everyone watching the stock"`
+	TransNum int             `json:"transNum"`
+	Time     int64           `json:"time" doc:"Milliseconds since the Unix epoch"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// orderEventData is the data of order and order_expiring events
+type orderEventData struct {
+	Side    string          `json:"side"`
+	State   string          `json:"state"`
+	Stock   string          `json:"stock"`
+	Cost    decimal.Decimal `json:"cost"`
+	Shares  int64           `json:"shares"`
+	Expires int64           `json:"expires,omitempty"`
+}
+
+// same reports whether o and other describe the same order, ignoring its state
+func (o orderEventData) same(other orderEventData) bool {
+	return o.Side == other.Side && o.Stock == other.Stock && o.Cost.Equal(other.Cost) && o.Shares == other.Shares
+}
+
+// eventStream is one browser's subscription to a user's events
+type eventStream struct {
+	user   string
+	watch  []string
+	events chan eventBody
+}
+
+// pendingOrder is an order a user with a stream may need warning about
+type pendingOrder struct {
+	order  orderEventData
+	warned bool
+}
+
+// eventHub fans the events published to this web server out to the streams
+// of logged in users. It also warns users about pending orders shortly before
+// they expire, and keeps quotes coming for watched stocks nobody else is quoting.
+// Delivery never blocks on a slow stream.
+type eventHub struct {
+	lock     sync.Mutex
+	streams  map[string]map[*eventStream]bool // By user
+	watchers map[string]map[*eventStream]bool // By stock
+	orders   map[string][]pendingOrder        // By user, oldest first, only for users with a stream
+	quoted   map[string]time.Time             // When each watched stock's price last arrived
+
+	// quote asks for a stock to be quoted on behalf of user. The quote arrives as an event.
+	quote func(user string, stock string)
+}
+
+func newEventHub(quote func(user string, stock string)) *eventHub {
+	return &eventHub{
+		streams:  make(map[string]map[*eventStream]bool),
+		watchers: make(map[string]map[*eventStream]bool),
+		orders:   make(map[string][]pendingOrder),
+		quoted:   make(map[string]time.Time),
+		quote:    quote,
+	}
+}
+
+// subscribe returns a stream of user's events and the quotes of the watched stocks
+func (h *eventHub) subscribe(user string, watch []string) *eventStream {
+	stream := &eventStream{user: user, watch: watch, events: make(chan eventBody, streamBuffer)}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.streams[user] == nil {
+		h.streams[user] = make(map[*eventStream]bool)
+	}
+	h.streams[user][stream] = true
+	for _, stock := range watch {
+		if h.watchers[stock] == nil {
+			h.watchers[stock] = make(map[*eventStream]bool)
+			// Not quoted yet, so the next refresh quotes it straight away
+			h.quoted[stock] = time.Time{}
+		}
+		h.watchers[stock][stream] = true
+	}
+	return stream
+}
+
+func (h *eventHub) unsubscribe(stream *eventStream) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.streams[stream.user], stream)
+	if len(h.streams[stream.user]) == 0 {
+		delete(h.streams, stream.user)
+		delete(h.orders, stream.user)
+	}
+	for _, stock := range stream.watch {
+		delete(h.watchers[stock], stream)
+		if len(h.watchers[stock]) == 0 {
+			delete(h.watchers, stock)
+			delete(h.quoted, stock)
+		}
+	}
+}
+
+// deliver sends an event to every stream it's for. Events for users without
+// a stream on this web server are dropped.
+func (h *eventHub) deliver(event eventBody) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if event.Type == "quote" {
+		var quote struct{ Stock string }
+		if err := json.Unmarshal(event.Data, &quote); err != nil {
+			return
+		}
+		if _, watched := h.quoted[quote.Stock]; watched {
+			h.quoted[quote.Stock] = time.Now()
+		}
+		h.send(h.watchers[quote.Stock], event)
+		return
+	}
+
+	streams := h.streams[event.User]
+	if len(streams) == 0 {
+		return
+	}
+	if event.Type == "order" {
+		var order orderEventData
+		if err := json.Unmarshal(event.Data, &order); err == nil {
+			h.track(event.User, order)
+		}
+	}
+	h.send(streams, event)
+}
+
+func (h *eventHub) send(streams map[*eventStream]bool, event eventBody) {
+	for stream := range streams {
+		select {
+		case stream.events <- event:
+		default:
+		}
+	}
+}
+
+// track follows a user's pending orders. Commits and cancels take the newest
+// matching order and expiry the oldest, as the transaction server does.
+func (h *eventHub) track(user string, order orderEventData) {
+	orders := h.orders[user]
+	switch order.State {
+	case "pending":
+		h.orders[user] = append(orders, pendingOrder{order: order})
+	case "committed", "cancelled":
+		for i := len(orders) - 1; i >= 0; i-- {
+			if orders[i].order.same(order) {
+				h.orders[user] = append(orders[:i], orders[i+1:]...)
+				return
+			}
+		}
+	case "expired":
+		for i := range orders {
+			if orders[i].order.same(order) {
+				h.orders[user] = append(orders[:i], orders[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Run warns about expiring orders and refreshes watched quotes once per interval, forever
+func (h *eventHub) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		h.tick(now)
+	}
+}
+
+func (h *eventHub) tick(now time.Time) {
+	h.lock.Lock()
+	nowMillis := now.UnixNano() / int64(time.Millisecond)
+	warnBefore := now.Add(orderWarning).UnixNano() / int64(time.Millisecond)
+	overdue := now.Add(-orderWarning).UnixNano() / int64(time.Millisecond)
+	for user, orders := range h.orders {
+		remaining := orders[:0]
+		for _, pending := range orders {
+			if pending.order.Expires <= overdue {
+				// Its expired event was lost, stop following it
+				continue
+			}
+			if !pending.warned && pending.order.Expires <= warnBefore {
+				pending.warned = true
+				data, _ := json.Marshal(pending.order)
+				h.send(h.streams[user], eventBody{Type: eventOrderExpiring, User: user, Time: nowMillis, Data: data})
+			}
+			remaining = append(remaining, pending)
+		}
+		h.orders[user] = remaining
+	}
+
+	type quoteRequest struct{ user, stock string }
+	var stale []quoteRequest
+	for stock, quoted := range h.quoted {
+		if now.Sub(quoted) < quoteRefresh {
+			continue
+		}
+		for stream := range h.watchers[stock] {
+			stale = append(stale, quoteRequest{stream.user, stock})
+			break
+		}
+		h.quoted[stock] = now
+	}
+	h.lock.Unlock()
+
+	for _, request := range stale {
+		go h.quote(request.user, request.stock)
+	}
+}
+
+// listenForEvents delivers the events published on channel at the redis server
+// at addr to the hub, reconnecting whenever the subscription is lost
+func listenForEvents(addr string, channel string, hub *eventHub) {
+	for {
+		err := receiveEvents(addr, channel, hub)
+		fmt.Println("Lost the event subscription, reconnecting: ", err)
+		time.Sleep(time.Second)
+	}
+}
+
+func receiveEvents(addr string, channel string, hub *eventHub) error {
+	conn, err := redis.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	subscription := redis.PubSubConn{Conn: conn}
+	if err := subscription.Subscribe(channel); err != nil {
+		return err
+	}
+	for {
+		switch message := subscription.Receive().(type) {
+		case redis.Message:
+			var event eventBody
+			if err := json.Unmarshal(message.Data, &event); err != nil {
+				fmt.Println("Error decoding event: ", err)
+				continue
+			}
+			hub.deliver(event)
+		case error:
+			return message
+		}
+	}
+}
+
+// eventsHandler streams the user's events as server-sent events until the
+// browser goes away or the session ends. Each event's name is its type.
+// ?watch=ABC,DEF adds live quotes of those stocks.
+func (webServer *WebServer) eventsHandler(writer http.ResponseWriter, request *http.Request, username string) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeError(writer, "INTERNAL", "Streaming is not supported")
+		return
+	}
+	var watch []string
+	for _, stock := range strings.Split(request.URL.Query().Get("watch"), ",") {
+		if stock != "" {
+			watch = append(watch, stock)
+		}
+	}
+	stream := webServer.events.subscribe(username, watch)
+	defer webServer.events.unsubscribe(stream)
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(http.StatusOK)
+	fmt.Fprint(writer, "retry: 5000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event := <-stream.events:
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", event.Type, data)
+		case <-ticker.C:
+			// A stream outliving its session would leak events to a logged out browser
+			if _, err := webServer.sessions.verify(sessionToken(request), time.Now()); err != nil {
+				return
+			}
+			fmt.Fprint(writer, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// refreshQuote quotes a watched stock on behalf of one of its watchers. It
+// isn't a command the user sent, so it's audited as a system event.
+func (webServer *WebServer) refreshQuote(username string, stock string) {
+	currTransNum := int(atomic.AddInt64(&webServer.transactionNumber, 1))
+	webServer.logger.SystemEvent(webServer.Name, currTransNum, "QUOTE", username, stock, nil, nil)
+	webServer.transmitter.MakeRequest(currTransNum, "QUOTE", username, stock)
+}
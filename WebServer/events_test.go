@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func event(eventType string, user string, data string) eventBody {
+	return eventBody{Type: eventType, User: user, Data: json.RawMessage(data)}
+}
+
+// expectEvents checks the events waiting on a stream, in order
+func expectEvents(t *testing.T, stream *eventStream, types ...string) {
+	t.Helper()
+	for _, expected := range types {
+		select {
+		case e := <-stream.events:
+			if e.Type != expected {
+				t.Errorf("Expected a %s event for %s, got %s", expected, stream.user, e.Type)
+			}
+		default:
+			t.Errorf("Expected a %s event for %s", expected, stream.user)
+		}
+	}
+	select {
+	case e := <-stream.events:
+		t.Errorf("Unexpected %s event for %s", e.Type, stream.user)
+	default:
+	}
+}
+
+func orderData(state string, expires time.Time) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"side": "BUY", "state": state, "stock": "ABC", "cost": "30", "shares": 3,
+		"expires": expires.UnixNano() / int64(time.Millisecond),
+	})
+	return string(data)
+}
+
+func TestEventHub(t *testing.T) {
+	var lock sync.Mutex
+	var quoted []string
+	hub := newEventHub(func(user string, stock string) {
+		lock.Lock()
+		defer lock.Unlock()
+		quoted = append(quoted, user+":"+stock)
+	})
+	watcher := hub.subscribe("user1", []string{"ABC"})
+	other := hub.subscribe("user2", nil)
+
+	hub.deliver(event("balance", "user1", `{"funds":"10"}`))
+	hub.deliver(event("quote", "", `{"stock":"ABC","price":"12.5"}`))
+	hub.deliver(event("quote", "", `{"stock":"XYZ","price":"1"}`))
+	hub.deliver(event("trigger_fill", "user3", `{}`))
+	expectEvents(t, watcher, "balance", "quote")
+	expectEvents(t, other)
+
+	// Only the order still pending near its expiry is warned about, once
+	now := time.Now()
+	expires := now.Add(orderWarning - time.Second)
+	hub.deliver(event("order", "user2", orderData("pending", expires)))
+	hub.deliver(event("order", "user2", orderData("pending", expires)))
+	hub.deliver(event("order", "user2", orderData("committed", time.Time{})))
+	expectEvents(t, other, "order", "order", "order")
+	hub.tick(now.Add(-orderWarning))
+	expectEvents(t, other)
+	hub.tick(now)
+	expectEvents(t, other, eventOrderExpiring)
+	hub.tick(now)
+	expectEvents(t, other)
+
+	// ABC was quoted by the event above, so only the newly watched stock is refreshed
+	third := hub.subscribe("user3", []string{"ABC", "DEF"})
+	hub.tick(now)
+	time.Sleep(time.Millisecond * 10)
+	lock.Lock()
+	if len(quoted) != 1 || quoted[0] != "user3:DEF" {
+		t.Error("Expected DEF to be quoted for user3, quoted ", quoted)
+	}
+	lock.Unlock()
+
+	hub.unsubscribe(watcher)
+	hub.unsubscribe(third)
+	hub.deliver(event("quote", "", `{"stock":"ABC","price":"13"}`))
+	expectEvents(t, watcher)
+	if len(hub.quoted) != 0 || len(hub.watchers) != 0 {
+		t.Error("Unwatched stocks should be forgotten, have ", hub.quoted)
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	webServer, server := newAPIServer(t)
+	token, _ := webServer.sessions.issue("user1", time.Now())
+
+	if status, code := call(t, server, "GET", "/events", "", ""); status != http.StatusUnauthorized ||
+		code != codeNotLoggedIn {
+		t.Errorf("A stream without a session should get 401, got %d %s", status, code)
+	}
+
+	request, _ := http.NewRequest("GET", server.URL+apiPrefix+"/events?watch=ABC", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("Expected an event stream, got ", resp.Header.Get("Content-Type"))
+	}
+
+	// The stream is subscribed by the time its first line arrives
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n')
+	webServer.events.deliver(event("trigger_fill", "user1", `{"stock":"ABC"}`))
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if lines[0] != "event: trigger_fill" || !strings.Contains(lines[1], `"data":{"stock":"ABC"}`) {
+		t.Error("Unexpected event ", lines)
+	}
+}
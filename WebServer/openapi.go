@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// pathParam finds the {name} parameters of an API path
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIDocument describes routes as an OpenAPI 3 document. Bodies are
+// described by reflecting on the routes' request and reply types, using
+// their json tags for names and doc tags for descriptions.
+func openAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	for _, route := range routes {
+		path := apiPrefix + route.path
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+
+		var params []interface{}
+		for _, match := range pathParam.FindAllStringSubmatch(route.path, -1) {
+			params = append(params, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, param := range route.query {
+			params = append(params, map[string]interface{}{
+				"name": param.name, "in": "query", "description": param.description,
+				"schema": map[string]string{"type": param.schema},
+			})
+		}
+
+		reply := map[string]interface{}{"description": http.StatusText(route.status)}
+		if route.reply != nil {
+			mediaType := "application/json"
+			if route.stream != nil {
+				mediaType = "text/event-stream"
+			}
+			reply["content"] = content(mediaType, schemaOf(reflect.TypeOf(route.reply), schemas))
+		}
+		operation := map[string]interface{}{
+			"summary": route.summary,
+			"responses": map[string]interface{}{
+				strconv.Itoa(route.status): reply,
+				"default": map[string]interface{}{
+					"description": "The request failed. The status depends on the error code.",
+					"content":     jsonContent(schemaOf(reflect.TypeOf(errorBody{}), schemas)),
+				},
+			},
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+		if route.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(reflect.TypeOf(route.request), schemas)),
+			}
+		}
+		if route.public {
+			operation["security"] = []interface{}{}
+		}
+		paths[path][strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Day Trading API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer":  map[string]string{"type": "http", "scheme": "bearer"},
+				"session": map[string]string{"type": "apiKey", "in": "cookie", "name": sessionCookie},
+			},
+		},
+		"security": []interface{}{
+			map[string][]string{"bearer": {}},
+			map[string][]string{"session": {}},
+		},
+	}
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return content("application/json", schema)
+}
+
+func content(mediaType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{mediaType: map[string]interface{}{"schema": schema}}
+}
+
+var (
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	timeType    = reflect.TypeOf(time.Time{})
+	rawType     = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns the schema of t. Structs are added to schemas and referenced by name.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == decimalType:
+		return map[string]interface{}{"type": "string", "format": "decimal"}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawType:
+		// Any JSON value
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, done := schemas[name]; !done {
+			// Claim the name first in case the struct refers to itself
+			schemas[name] = nil
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" || field.PkgPath != "" {
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOf(field.Type, schemas)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			if _, isRef := property["$ref"]; isRef {
+				property = map[string]interface{}{"allOf": []interface{}{property}}
+			}
+			property["description"] = doc
+		}
+		properties[name] = property
+		if len(tag) == 1 || tag[1] != "omitempty" {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName names a body type's schema, so orderBody is described as Order
+func schemaName(t reflect.Type) string {
+	name := []rune(strings.TrimSuffix(t.Name(), "Body"))
+	if len(name) > 0 {
+		name[0] = unicode.ToUpper(name[0])
+	}
+	return string(name)
+}
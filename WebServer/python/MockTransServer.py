@@ -1,4 +1,5 @@
 import socket
+import struct
 
 serverSocket = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
 host = socket.gethostname()
@@ -7,14 +8,31 @@ totalMsgs = 0
 serverSocket.bind(('', port))
 serverSocket.listen(5)
 
+
+def recvExactly(sock, size):
+    data = b''
+    while len(data) < size:
+        chunk = sock.recv(size - len(data))
+        if not chunk:
+            raise EOFError()
+        data += chunk
+    return data
+
+
 print("started fake transaction server on {0} port: {1}".format(host, port))
 clientsocket, addr = serverSocket.accept()
+
+# The webserver opens with the frame preface "TSF" + version; agree on version 1
+recvExactly(clientsocket, 4)
+clientsocket.send(b'TSF\x01')
+
 while 1:
     # print("got a connection from %s" % str(addr))
     totalMsgs += 1
-    msg = clientsocket.recv(1024)
-    print('[{0}] got message {1} from client'.format(totalMsgs, msg))
-    response = "1\n"
-    clientsocket.send(response.encode())
+    length, requestID = struct.unpack('>II', recvExactly(clientsocket, 8))
+    msg = recvExactly(clientsocket, length - 4)
+    print('[{0}] got request {1} {2} from client'.format(totalMsgs, requestID, msg))
+    response = b'{"status":1}'
+    clientsocket.send(struct.pack('>II', len(response) + 4, requestID) + response)
 
-clientsocket.close()
\ No newline at end of file
+clientsocket.close()
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit policy names other than commands
+const (
+	globalPolicy = "global" // Every request to this web server
+	userPolicy   = "user"   // Every request by one user
+)
+
+// bucketSweep is how often buckets that have refilled are forgotten
+const bucketSweep = time.Minute
+
+// ratePolicy is a token bucket's size and how fast it refills. Each request
+// takes a token, so a policy allows Burst requests at once and Rate a second after.
+type ratePolicy struct {
+	Name  string  `json:"name"`
+	Rate  float64 `json:"rate" doc:"Requests a second"`
+	Burst float64 `json:"burst"`
+}
+
+// rateCounts is what a policy has decided
+type rateCounts struct {
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+}
+
+// rateLimitStats reports each policy and what it has decided
+type rateLimitStats struct {
+	ratePolicy
+	rateCounts
+	Buckets int `json:"buckets" doc:"Users the policy is currently tracking"`
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the bucket was last used
+func (b *tokenBucket) refill(policy ratePolicy, now time.Time) {
+	b.tokens = math.Min(policy.Burst, b.tokens+now.Sub(b.last).Seconds()*policy.Rate)
+	b.last = now
+}
+
+type bucketKey struct {
+	policy, user string
+}
+
+// rateLimiter holds a global token bucket, and for each user a bucket for all
+// of their requests and one per rate limited command. A request is allowed
+// only if every bucket that applies has a token, and then takes one from each.
+// A nil rateLimiter allows everything.
+type rateLimiter struct {
+	lock     sync.Mutex
+	policies map[string]ratePolicy // By name, commands in upper case
+	buckets  map[bucketKey]*tokenBucket
+	counts   map[string]*rateCounts
+	swept    time.Time
+}
+
+// parseRateLimits reads policies written as name=rate/unit[:burst], separated
+// by commas, where name is global, user or a command and unit is s, m or h.
+// For example "global=2000/s,user=50/s:100,QUOTE=10/m". The burst defaults
+// to the rate per unit. Returns nil if spec has no policies.
+func parseRateLimits(spec string) (*rateLimiter, error) {
+	limiter := &rateLimiter{
+		policies: make(map[string]ratePolicy),
+		buckets:  make(map[bucketKey]*tokenBucket),
+		counts:   make(map[string]*rateCounts),
+	}
+	units := map[string]float64{"s": 1, "m": 60, "h": 3600}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limit, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q should be name=rate/unit[:burst]", entry)
+		}
+		if name != globalPolicy && name != userPolicy {
+			name = strings.ToUpper(name)
+		}
+		limit, burstText, hasBurst := strings.Cut(limit, ":")
+		countText, unit, _ := strings.Cut(limit, "/")
+		count, err := strconv.ParseFloat(countText, 64)
+		if err != nil || count <= 0 || units[unit] == 0 {
+			return nil, fmt.Errorf("rate limit %q should have a positive rate per s, m or h", entry)
+		}
+		policy := ratePolicy{Name: name, Rate: count / units[unit], Burst: math.Max(1, math.Ceil(count))}
+		if hasBurst {
+			policy.Burst, err = strconv.ParseFloat(burstText, 64)
+			if err != nil || policy.Burst < 1 {
+				return nil, fmt.Errorf("rate limit %q should have a burst of at least 1", entry)
+			}
+		}
+		limiter.policies[name] = policy
+		limiter.counts[name] = &rateCounts{}
+	}
+	if len(limiter.policies) == 0 {
+		return nil, nil
+	}
+	return limiter, nil
+}
+
+// allow takes a token for a request by user running command, or returns how
+// long until every policy it's over has a token again. Requests without a user
+// are only held to the global policy.
+func (l *rateLimiter) allow(user string, command string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	keys := []bucketKey{{policy: globalPolicy}}
+	if user != "" {
+		keys = append(keys, bucketKey{userPolicy, user}, bucketKey{command, user})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now.Sub(l.swept) >= bucketSweep {
+		l.sweep(now)
+	}
+
+	var buckets []*tokenBucket
+	var policies []ratePolicy
+	var wait time.Duration
+	for _, key := range keys {
+		policy, ok := l.policies[key.policy]
+		if !ok {
+			continue
+		}
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = &tokenBucket{tokens: policy.Burst, last: now}
+			l.buckets[key] = bucket
+		}
+		bucket.refill(policy, now)
+		if bucket.tokens < 1 {
+			l.counts[policy.Name].Limited++
+			missing := time.Duration((1 - bucket.tokens) / policy.Rate * float64(time.Second))
+			if missing > wait {
+				wait = missing
+			}
+		}
+		buckets = append(buckets, bucket)
+		policies = append(policies, policy)
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for i, bucket := range buckets {
+		bucket.tokens--
+		l.counts[policies[i].Name].Allowed++
+	}
+	return true, 0
+}
+
+// sweep forgets the buckets that have refilled, which a new bucket would match
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		policy := l.policies[key.policy]
+		bucket.refill(policy, now)
+		if bucket.tokens >= policy.Burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// stats reports every policy, sorted by name
+func (l *rateLimiter) stats() []rateLimitStats {
+	stats := []rateLimitStats{}
+	if l == nil {
+		return stats
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for name, policy := range l.policies {
+		stat := rateLimitStats{ratePolicy: policy, rateCounts: *l.counts[name]}
+		for key := range l.buckets {
+			if key.policy == name {
+				stat.Buckets++
+			}
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// writeRateLimited rejects a request, telling the client how many seconds to wait
+func writeRateLimited(writer http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	writer.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(writer, codeRateLimited, fmt.Sprintf("Too many requests, retry in %d seconds", seconds))
+}
+
+// limited holds a logged in user's requests for command to the rate limits
+func (webServer *WebServer) limited(command string,
+	fn func(http.ResponseWriter, *http.Request, string)) func(http.ResponseWriter, *http.Request, string) {
+	return func(writer http.ResponseWriter, request *http.Request, username string) {
+		if ok, wait := webServer.limiter.allow(username, command, time.Now()); !ok {
+			writeRateLimited(writer, wait)
+			return
+		}
+		fn(writer, request, username)
+	}
+}
+
+// userRoute serves command to logged in users, within the rate limits
+func (webServer *WebServer) userRoute(command string,
+	fn func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return webServer.requireUser(webServer.limited(command, fn))
+}
+
+// publicRoute serves requests that don't need a session, within the global rate limit
+func (webServer *WebServer) publicRoute(fn http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if ok, wait := webServer.limiter.allow("", "", time.Now()); !ok {
+			writeRateLimited(writer, wait)
+			return
+		}
+		fn(writer, request)
+	}
+}
+
+// Reports each rate limit policy and how many requests it has allowed and limited as JSON
+func (webServer *WebServer) rateLimitsHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(webServer.limiter.stats())
+}
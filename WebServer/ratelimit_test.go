@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limiter, err := parseRateLimits("global=100/s:200, user=5/s,quote=30/m:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]ratePolicy{
+		"global": {Name: "global", Rate: 100, Burst: 200},
+		"user":   {Name: "user", Rate: 5, Burst: 5},
+		"QUOTE":  {Name: "QUOTE", Rate: 0.5, Burst: 3},
+	}
+	for name, policy := range expected {
+		if limiter.policies[name] != policy {
+			t.Errorf("Expected %s to be %v, got %v", name, policy, limiter.policies[name])
+		}
+	}
+
+	if limiter, err := parseRateLimits(" "); limiter != nil || err != nil {
+		t.Error("No policies should mean no limiter, got ", limiter, err)
+	}
+	for _, spec := range []string{"user", "user=5", "user=0/s", "user=5/d", "user=5/s:0", "user=5/s:x"} {
+		if _, err := parseRateLimits(spec); err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter, _ := parseRateLimits("global=100/s,user=3/s,QUOTE=1/s")
+	now := time.Now()
+
+	// The command's bucket runs out first, and waiting on it spends nothing else
+	if ok, _ := limiter.allow("user1", "QUOTE", now); !ok {
+		t.Error("The first quote should be allowed")
+	}
+	ok, wait := limiter.allow("user1", "QUOTE", now)
+	if ok || wait != time.Second {
+		t.Errorf("The second quote should wait a second, got %v %v", ok, wait)
+	}
+	if ok, _ := limiter.allow("user1", "BUY", now); !ok {
+		t.Error("A limited quote shouldn't spend the user's tokens")
+	}
+	if ok, _ := limiter.allow("user1", "BUY", now); !ok {
+		t.Error("The user's third request should be allowed")
+	}
+	if ok, _ := limiter.allow("user1", "BUY", now); ok {
+		t.Error("The user's fourth request should be limited")
+	}
+	if ok, _ := limiter.allow("user2", "QUOTE", now); !ok {
+		t.Error("Users shouldn't share buckets")
+	}
+	if ok, _ := limiter.allow("user1", "QUOTE", now.Add(time.Second)); !ok {
+		t.Error("A quote should be allowed once the bucket refills")
+	}
+
+	counts := map[string]rateCounts{}
+	for _, stat := range limiter.stats() {
+		counts[stat.Name] = stat.rateCounts
+	}
+	if counts["QUOTE"] != (rateCounts{Allowed: 3, Limited: 1}) || counts["user"] != (rateCounts{Allowed: 5, Limited: 1}) ||
+		counts["global"] != (rateCounts{Allowed: 5}) {
+		t.Error("Unexpected counts ", counts)
+	}
+
+	limiter.sweep(now.Add(time.Minute))
+	if len(limiter.buckets) != 0 {
+		t.Error("Refilled buckets should be forgotten, have ", limiter.buckets)
+	}
+
+	var unlimited *rateLimiter
+	if ok, _ := unlimited.allow("user1", "QUOTE", now); !ok || len(unlimited.stats()) != 0 {
+		t.Error("A nil limiter should allow everything")
+	}
+}
+
+func TestAPIRateLimits(t *testing.T) {
+	webServer, server := newAPIServer(t)
+	webServer.limiter, _ = parseRateLimits("user=100/m,BUY=1/m,QUOTE=1/m")
+	token, _ := webServer.sessions.issue("user1", time.Now())
+
+	// Every attempt at a buy spends a token, whether or not the transaction server answers
+	order := `{"side":"buy","stock":"ABC","amount":"10"}`
+	call(t, server, "POST", "/orders", token, order)
+	if status, code := call(t, server, "POST", "/orders", token, order); status != http.StatusTooManyRequests ||
+		code != codeRateLimited {
+		t.Errorf("A second buy should get 429, got %d %s", status, code)
+	}
+	if status, _ := call(t, server, "POST", "/orders", token, `{"side":"sell","stock":"ABC","amount":"10"}`); status ==
+		http.StatusTooManyRequests {
+		t.Error("Sells shouldn't be held to the buy limit")
+	}
+
+	call(t, server, "GET", "/quotes/ABC", token, "")
+	request, _ := http.NewRequest("GET", server.URL+apiPrefix+"/quotes/ABC", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "60" {
+		t.Errorf("A second quote should get 429 with Retry-After 60, got %d %q", resp.StatusCode,
+			resp.Header.Get("Retry-After"))
+	}
+}
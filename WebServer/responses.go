@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"seng468/WebServer/transmitter"
+)
+
+// Error codes for requests the web server rejects before reaching the transaction server
+const (
+	codeNotLoggedIn = "NOT_LOGGED_IN"
+	codeNotFound    = "NOT_FOUND"
+	codeRateLimited = "RATE_LIMITED"
+)
+
+// httpStatuses maps error codes to the HTTP status sent to the client.
+// Codes missing from the map are sent as 500 Internal Server Error.
+var httpStatuses = map[string]int{
+	"BAD_REQUEST":               http.StatusBadRequest,
+	codeNotLoggedIn:             http.StatusUnauthorized,
+	"INSUFFICIENT_FUNDS":        http.StatusUnprocessableEntity,
+	"INSUFFICIENT_STOCK":        http.StatusUnprocessableEntity,
+	"INSUFFICIENT_RESERVE":      http.StatusUnprocessableEntity,
+	"NO_PENDING_BUY":            http.StatusNotFound,
+	"NO_PENDING_SELL":           http.StatusNotFound,
+	"NO_TRIGGER":                http.StatusNotFound,
+	codeNotFound:                http.StatusNotFound,
+	codeRateLimited:             http.StatusTooManyRequests,
+	"ORDER_EXPIRED":             http.StatusGone,
+	"TRIGGER_EXISTS":            http.StatusConflict,
+	"ACCOUNT_EXISTS":            http.StatusConflict,
+	"BAD_CREDENTIALS":           http.StatusUnauthorized,
+	"QUOTE_UNAVAILABLE":         http.StatusServiceUnavailable,
+	"TRIGGER_UNAVAILABLE":       http.StatusServiceUnavailable,
+	"DATABASE_UNAVAILABLE":      http.StatusServiceUnavailable,
+	transmitter.CodeUnavailable: http.StatusServiceUnavailable,
+	transmitter.CodeTimeout:     http.StatusGatewayTimeout,
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError replies with a JSON error body and the HTTP status for code
+func writeError(writer http.ResponseWriter, code string, message string) {
+	status, ok := httpStatuses[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(errorBody{Code: code, Message: message})
+}
+
+// writeFailure replies with the error from a failed transaction server result
+func writeFailure(writer http.ResponseWriter, resp transmitter.Result) {
+	writeError(writer, resp.Code, resp.Message)
+}
+
+// failure returns a failed result for a request the web server rejects itself
+func failure(code string, message string) transmitter.Result {
+	return transmitter.Result{Status: -1, Code: code, Message: message}
+}
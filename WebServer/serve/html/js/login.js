@@ -4,24 +4,44 @@ $(document).ready(function(){
 	$('#userName').on('click', () => $('#userName').val(''));
 });
 
-// Logs the user into the webserver.
+// Logs the user into the webserver. The webserver sets the session cookie.
 function loginRequest() {
 	var userName = $('#userName').val();
 	$.ajax({
 		type: 'POST',
 		url: "LOGIN/",
-		data: {username: userName},
+		data: {username: userName, password: $('#password').val()},
 		success: function(data, status){
 			setCookie("dayTradingUsername", $('#userName').val(), 10)
 			// Redirect user to actions page
 			window.location.replace("/actions.html");
 		},
-		error:function(){
-			alert("Error occured while logging in!")
+		error: function(jqXHR){
+			alert(errorMessage(jqXHR, "Error occured while logging in!"))
 		}
 	});
 };
 
+// Creates an account, then logs into it.
+function registerRequest() {
+	$.ajax({
+		type: 'POST',
+		url: "REGISTER/",
+		data: {username: $('#userName').val(), password: $('#password').val()},
+		success: loginRequest,
+		error: function(jqXHR){
+			alert(errorMessage(jqXHR, "Error occured while registering!"))
+		}
+	});
+};
+
+function errorMessage(jqXHR, fallback) {
+	if (jqXHR.responseJSON && jqXHR.responseJSON.message) {
+		return jqXHR.responseJSON.message;
+	}
+	return fallback;
+}
+
 function setCookie(cname, cvalue, exdays) {
     var d = new Date();
     d.setTime(d.getTime() + (exdays*24*60*60*1000));
@@ -3,6 +3,10 @@ var pendingSells = [];
 var pendingBuys = [];
 var triggers = [];
 var submitRequest;
+var eventSource;
+// Stocks quoted this session, whose prices are pushed as they change
+var watchedStocks = [];
+var maxEvents = 20;
 
 $(document).ready(function(){
 	userName = getCookie("dayTradingUsername");
@@ -21,8 +25,52 @@ $(document).ready(function(){
     $('#submitButton').on('click', submitRequest);
     $('#textInputOne').on('click', () => $('#textInputOne').val(''));
     $('#textInputTwo').on('click', () => $('#textInputTwo').val(''));
+
+    listenForEvents();
 });
 
+// Opens the stream of pushed events, replacing any open one
+function listenForEvents() {
+    if (eventSource) {
+        eventSource.close();
+    }
+    eventSource = new EventSource("/api/v1/events?watch=" + encodeURIComponent(watchedStocks.join(",")));
+    ["balance", "order", "order_expiring", "trigger_fill", "quote"].forEach(type => {
+        eventSource.addEventListener(type, e => displayEvent(JSON.parse(e.data)));
+    });
+    eventSource.onerror = () => {
+        // The browser reconnects by itself unless the session was refused
+        if (eventSource.readyState === EventSource.CLOSED) {
+            logout();
+        }
+    };
+}
+
+function describeEvent(event) {
+    var data = event.data;
+    switch (event.type) {
+        case "balance":
+            return "Balance: $" + data.funds;
+        case "order":
+            return data.side + " " + data.stock + " for $" + data.cost + " " + data.state;
+        case "order_expiring":
+            var seconds = Math.max(0, Math.round((data.expires - Date.now()) / 1000));
+            return data.side + " " + data.stock + " for $" + data.cost + " expires in " + seconds + "s, commit it soon";
+        case "trigger_fill":
+            return data.action + " trigger on " + data.stock + " filled at $" + data.price;
+        case "quote":
+            return "Stock: " + data.stock + " - $" + data.price;
+    }
+    return event.type;
+}
+
+// Adds an event to the top of the list, dropping the oldest
+function displayEvent(event) {
+    var time = new Date(event.time).toLocaleTimeString();
+    $('#eventsList').prepend($('<li>').text(time + " " + describeEvent(event)));
+    $('#eventsList li').slice(maxEvents).remove();
+}
+
 function checkLogin(userName) {
     if (userName === "") {
        // User isn't logged in! Redirect back to login!
@@ -30,6 +78,19 @@ function checkLogin(userName) {
     }
 }
 
+// Ends the session and returns to the login page
+function logout() {
+    if (eventSource) {
+        eventSource.close();
+    }
+    document.cookie = "dayTradingUsername=;expires=Thu, 01 Jan 1970 00:00:00 UTC;path=/";
+    $.ajax({
+        url: "/LOGOUT/",
+        type: 'POST',
+        complete: () => window.location.replace("/")
+    });
+}
+
 function getCookie(cname) {
     var name = cname + "=",
     decodedCookie = decodeURIComponent(document.cookie),
@@ -144,7 +205,7 @@ function toggleDisplayInputs(numVisible, displayTextOne, displayTextTwo){
 
 function submitRequest() {
     var command = $('#commands').val(),
-    params = {username: userName};
+    params = {};
     submitRequest = {command: $('#commands').val(),
 					 amount: 0,
 					 stock: ''};
@@ -172,8 +233,13 @@ function submitRequest() {
     		displaySuccess(data);
     	},
     	error: function(jqXHR, textStatus, errorThrown) {
+    		if (jqXHR.status === 401) {
+    			// Session expired or logged out
+    			logout();
+    			return;
+    		}
     		// Display error message to user.
-    		var err = jqXHR.responseText;
+    		var err = jqXHR.responseJSON ? jqXHR.responseJSON.message : jqXHR.responseText;
     		$('#resultsDiv').text('Error occured: ' + err);
     	}
     });
@@ -193,6 +259,11 @@ function displaySuccess(data) {
 	// Display different message for successful quotes
 	if (submitRequest.command.localeCompare('QUOTE') === 0) {
 		successMsg = "Stock: " + submitRequest.stock + " - $" + data;
+		// Keep pushing the stock's price from now on
+		if (!watchedStocks.includes(submitRequest.stock)) {
+			watchedStocks.push(submitRequest.stock);
+			listenForEvents();
+		}
 	}
 
 	// Handle dumplog
@@ -0,0 +1,86 @@
+package transmitter
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// The transmitter speaks the transaction server's framed protocol: after a
+// "TSF"+version preface, each request and reply is a length-prefixed frame
+// carrying a request ID. See socketserver/frame.go in the transaction server.
+
+// frameVersion is the highest frame version the transmitter speaks
+const frameVersion = 1
+
+// maxFrameSize matches the transaction server's limit on a single frame
+const maxFrameSize = 1 << 20
+
+var framePreface = []byte("TSF")
+
+// negotiate sends the frame preface and waits for the server to pick a version
+func negotiate(conn net.Conn, timeout time.Duration) error {
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(append(append([]byte{}, framePreface...), frameVersion)); err != nil {
+		return err
+	}
+	reply := make([]byte, len(framePreface)+1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if string(reply[:len(framePreface)]) != string(framePreface) {
+		return errors.New("transaction server does not speak the framed protocol")
+	}
+	if version := reply[len(framePreface)]; version != frameVersion {
+		return fmt.Errorf("transaction server chose unsupported frame version %d", version)
+	}
+	return nil
+}
+
+// writeRequest writes one request frame. fields holds the command followed by its arguments.
+func writeRequest(w io.Writer, id uint32, transNum int, fields ...string) error {
+	body := make([]byte, 6)
+	binary.BigEndian.PutUint32(body, uint32(transNum))
+	binary.BigEndian.PutUint16(body[4:], uint16(len(fields)))
+	for _, field := range fields {
+		if len(field) > 0xFFFF {
+			return fmt.Errorf("field of %d bytes is too long to frame", len(field))
+		}
+		var size [2]byte
+		binary.BigEndian.PutUint16(size[:], uint16(len(field)))
+		body = append(append(body, size[:]...), field...)
+	}
+
+	frame := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:], id)
+	_, err := w.Write(append(frame, body...))
+	return err
+}
+
+// readReply reads one reply frame and returns its request ID and result
+func readReply(r io.Reader) (uint32, Result, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, Result{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || length > maxFrameSize {
+		return 0, Result{}, fmt.Errorf("reply frame of %d bytes is out of range", length)
+	}
+	id := binary.BigEndian.Uint32(header[4:])
+
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return id, Result{}, err
+	}
+	var res Result
+	err := json.Unmarshal(body, &res)
+	return id, res, err
+}
@@ -0,0 +1,115 @@
+package transmitter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var errConnClosed = errors.New("connection to transaction server closed")
+
+// muxConn is one framed connection shared by many concurrent requests.
+// Requests are written whole under writeLock, and a single reader goroutine
+// hands each reply to the request waiting on its ID.
+type muxConn struct {
+	conn      net.Conn
+	writeLock sync.Mutex
+
+	lock    sync.Mutex
+	pending map[uint32]chan Result
+	err     error
+}
+
+func newMuxConn(conn net.Conn) *muxConn {
+	mc := &muxConn{
+		conn:    conn,
+		pending: make(map[uint32]chan Result),
+	}
+	go mc.readReplies()
+	return mc
+}
+
+// register reserves id for a request and returns the channel its reply will arrive on
+func (mc *muxConn) register(id uint32) (chan Result, error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if mc.err != nil {
+		return nil, mc.err
+	}
+	reply := make(chan Result, 1)
+	mc.pending[id] = reply
+	return reply, nil
+}
+
+// unregister forgets a request that gave up waiting, so a late reply is dropped
+func (mc *muxConn) unregister(id uint32) {
+	mc.lock.Lock()
+	delete(mc.pending, id)
+	mc.lock.Unlock()
+}
+
+// alive reports whether the connection can still take requests
+func (mc *muxConn) alive() bool {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	return mc.err == nil
+}
+
+// send writes one request frame, closing the connection if the write fails
+func (mc *muxConn) send(id uint32, transNum int, fields []string, deadline time.Time) error {
+	mc.writeLock.Lock()
+	defer mc.writeLock.Unlock()
+	mc.conn.SetWriteDeadline(deadline)
+	err := writeRequest(mc.conn, id, transNum, fields...)
+	if err != nil {
+		// A partial frame leaves the stream unusable for everyone
+		mc.fail(err)
+	}
+	return err
+}
+
+// readReplies delivers replies until the connection fails
+func (mc *muxConn) readReplies() {
+	reader := bufio.NewReader(mc.conn)
+	for {
+		id, res, err := readReply(reader)
+		if err != nil {
+			mc.fail(err)
+			return
+		}
+
+		mc.lock.Lock()
+		reply, ok := mc.pending[id]
+		delete(mc.pending, id)
+		mc.lock.Unlock()
+		if !ok {
+			fmt.Println("Dropping reply to abandoned request ", id)
+			continue
+		}
+		reply <- res
+	}
+}
+
+// fail closes the connection and fails every request still waiting on it
+func (mc *muxConn) fail(err error) {
+	mc.lock.Lock()
+	defer mc.lock.Unlock()
+	if mc.err != nil {
+		return
+	}
+	fmt.Println("ERROR connection to transaction server failed: ", err)
+	mc.err = errConnClosed
+	mc.conn.Close()
+	for id, reply := range mc.pending {
+		reply <- unavailable(fmt.Errorf("%v: %v", errConnClosed, err))
+		delete(mc.pending, id)
+	}
+}
+
+// close shuts the connection down, failing any requests in flight
+func (mc *muxConn) close() {
+	mc.fail(errConnClosed)
+}
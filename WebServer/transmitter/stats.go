@@ -0,0 +1,80 @@
+package transmitter
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats describes the transmitter's connections and the requests it has made
+type Stats struct {
+	Connections int           `json:"connections"`
+	InFlight    int           `json:"inFlight"`
+	Requests    uint64        `json:"requests"`
+	Failures    uint64        `json:"failures"`
+	Timeouts    uint64        `json:"timeouts"`
+	Reconnects  uint64        `json:"reconnects"`
+	MeanLatency time.Duration `json:"meanLatency"`
+	MaxLatency  time.Duration `json:"maxLatency"`
+}
+
+// counters accumulates request outcomes for Stats
+type counters struct {
+	lock         sync.Mutex
+	inFlight     int
+	requests     uint64
+	failures     uint64
+	timeouts     uint64
+	reconnects   uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+func (c *counters) started() {
+	c.lock.Lock()
+	c.inFlight++
+	c.lock.Unlock()
+}
+
+// finished records a request that took latency. Requests the server answered,
+// successfully or not, count toward latency; failures and timeouts don't.
+func (c *counters) finished(latency time.Duration, res Result) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inFlight--
+	c.requests++
+	switch res.Code {
+	case CodeUnavailable:
+		c.failures++
+		return
+	case CodeTimeout:
+		c.timeouts++
+		return
+	}
+	c.totalLatency += latency
+	if latency > c.maxLatency {
+		c.maxLatency = latency
+	}
+}
+
+func (c *counters) reconnected() {
+	c.lock.Lock()
+	c.reconnects++
+	c.lock.Unlock()
+}
+
+func (c *counters) snapshot() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := Stats{
+		InFlight:   c.inFlight,
+		Requests:   c.requests,
+		Failures:   c.failures,
+		Timeouts:   c.timeouts,
+		Reconnects: c.reconnects,
+		MaxLatency: c.maxLatency,
+	}
+	if answered := c.requests - c.failures - c.timeouts; answered > 0 {
+		stats.MeanLatency = c.totalLatency / time.Duration(answered)
+	}
+	return stats
+}
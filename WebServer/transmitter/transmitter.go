@@ -1,7 +1,7 @@
 package transmitter
 
 import (
-	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -9,73 +9,187 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/fatih/pool"
 )
 
 type Transmitters interface {
-	MakeRequest() string
+	MakeRequest(transNum int, command string, args ...string) Result
+}
+
+// Error codes MakeRequest reports when the transaction server could not be
+// reached or sent back something that is not a Result, or did not reply in time
+const (
+	CodeUnavailable = "TRANSACTION_SERVER_UNAVAILABLE"
+	CodeTimeout     = "TRANSACTION_SERVER_TIMEOUT"
+)
+
+// Result is the transaction server's reply to a command
+type Result struct {
+	Status  int             `json:"status"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Succeeded reports whether the transaction server completed the command
+func (r Result) Succeeded() bool {
+	return r.Status == 1
+}
+
+// PayloadString returns a string payload, such as a quote or a summary.
+// Returns "" if there is no payload or it is not a string.
+func (r Result) PayloadString() string {
+	var payload string
+	json.Unmarshal(r.Payload, &payload)
+	return payload
 }
 
+func unavailable(err error) Result {
+	return Result{Status: -1, Code: CodeUnavailable, Message: err.Error()}
+}
+
+// Connection and request limits for NewTransmitter
+const (
+	defaultConnections    = 8
+	defaultRequestTimeout = time.Second * 10
+	dialTimeout           = time.Second * 5
+)
+
+// Transmitter multiplexes requests to the transaction server over a few
+// long-lived framed connections. Each request is tagged with an ID so many
+// can be in flight on one connection; replies are matched back by ID and
+// may arrive in any order. A connection that fails is redialed the next
+// time a request is sent on it.
 type Transmitter struct {
-	address        string
-	port           string
-	connection     net.Conn
-	connectionPool pool.Pool
+	address string
+	port    string
+	dial    func() (net.Conn, error)
+	timeout time.Duration
+
+	conns     []*connSlot
+	next      uint32
+	requestID uint32
+	stats     counters
 }
 
 func NewTransmitter(addr string, prt string) *Transmitter {
 	transmitter := new(Transmitter)
 	transmitter.address = addr
 	transmitter.port = prt
-	factory := func() (net.Conn, error) {
+	transmitter.timeout = defaultRequestTimeout
+	transmitter.conns = make([]*connSlot, defaultConnections)
+	for i := range transmitter.conns {
+		transmitter.conns[i] = new(connSlot)
+	}
+	transmitter.dial = func() (net.Conn, error) {
 		return net.DialTimeout(
 			"tcp",
 			addr+":"+prt,
-			time.Second*5,
+			dialTimeout,
 		)
 	}
-	var err error
-	transmitter.connectionPool, err = pool.NewChannelPool(100, 1500, factory)
+	return transmitter
+}
 
-	// This is real bad and should abort the entire webserver
-	if err != nil {
-		panic(err)
+// connSlot holds one of the transmitter's connections. Its lock is held while
+// redialing so concurrent requests wait for one replacement instead of each dialing.
+type connSlot struct {
+	lock sync.Mutex
+	mc   *muxConn
+}
+
+// getConn returns a live connection, taking turns between them so load spreads
+// evenly, and dialing a replacement if the chosen one has failed
+func (trans *Transmitter) getConn() (*muxConn, error) {
+	slot := trans.conns[int(atomic.AddUint32(&trans.next, 1))%len(trans.conns)]
+
+	slot.lock.Lock()
+	defer slot.lock.Unlock()
+	if slot.mc != nil && slot.mc.alive() {
+		return slot.mc, nil
 	}
 
-	return transmitter
+	conn, err := trans.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := negotiate(conn, dialTimeout); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if slot.mc != nil {
+		trans.stats.reconnected()
+	}
+	slot.mc = newMuxConn(conn)
+	return slot.mc, nil
 }
 
-func (trans *Transmitter) MakeRequest(transNum int, message string) string {
-	prefix := strconv.Itoa(transNum)
-	message = prefix + ";" + message
-	message += "\n"
+// MakeRequest sends command and its arguments to the transaction server and waits for the result.
+// Arguments are framed individually, so they may contain commas. It is safe to call
+// concurrently; a request that is not answered within the transmitter's timeout
+// fails with CodeTimeout.
+func (trans *Transmitter) MakeRequest(transNum int, command string, args ...string) Result {
+	start := time.Now()
+	trans.stats.started()
+	res := trans.request(start.Add(trans.timeout), transNum, append([]string{command}, args...))
+	trans.stats.finished(time.Since(start), res)
+	return res
+}
 
-	conn, err := trans.connectionPool.Get()
+func (trans *Transmitter) request(deadline time.Time, transNum int, fields []string) Result {
+	mc, err := trans.getConn()
 	if err != nil {
 		fmt.Println("ERROR1: ", err.Error())
+		return unavailable(err)
 	}
-	defer conn.Close()
 
-	n, err := conn.Write([]byte(message))
+	id := atomic.AddUint32(&trans.requestID, 1)
+	reply, err := mc.register(id)
 	if err != nil {
+		return unavailable(err)
+	}
+	if err := mc.send(id, transNum, fields, deadline); err != nil {
 		fmt.Println("ERROR2: ", err)
-		pc, _ := conn.(*pool.PoolConn)
-		pc.MarkUnusable()
-		pc.Close()
-	} else {
-		fmt.Println("wrote ", n, " bytes")
+		mc.unregister(id)
+		return unavailable(err)
 	}
 
-	reply, err := bufio.NewReader(conn).ReadString('\n')
-	if err != nil {
-		fmt.Println("ERROR3: ", err)
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case res := <-reply:
+		return res
+	case <-timer.C:
+		mc.unregister(id)
+		fmt.Println("ERROR3: request ", id, " timed out: ", fields[0])
+		return Result{Status: -1, Code: CodeTimeout, Message: "Transaction server did not reply in time"}
+	}
+}
+
+// Stats returns a snapshot of the transmitter's connections and request counts
+func (trans *Transmitter) Stats() Stats {
+	stats := trans.stats.snapshot()
+	for _, slot := range trans.conns {
+		slot.lock.Lock()
+		if slot.mc != nil && slot.mc.alive() {
+			stats.Connections++
+		}
+		slot.lock.Unlock()
 	}
-	fmt.Println("recvd: ", reply)
+	return stats
+}
 
-	return reply
+// Close closes every connection, failing any requests in flight
+func (trans *Transmitter) Close() {
+	for _, slot := range trans.conns {
+		slot.lock.Lock()
+		if slot.mc != nil {
+			slot.mc.close()
+		}
+		slot.lock.Unlock()
+	}
 }
 
 func (trans *Transmitter) RetrieveDumplog(filename string) []byte {
@@ -0,0 +1,189 @@
+package transmitter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer speaks the server side of the framed protocol. Each request is
+// answered by handle on its own goroutine, so replies can go out of order.
+type fakeServer struct {
+	listener net.Listener
+	handle   func(fields []string) (Result, bool)
+	dials    int
+	lock     sync.Mutex
+}
+
+func newFakeServer(t *testing.T, handle func(fields []string) (Result, bool)) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{listener: listener, handle: handle}
+	go s.serve()
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		s.dials++
+		s.lock.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *fakeServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if _, err := io.ReadFull(reader, make([]byte, 4)); err != nil {
+		return
+	}
+	conn.Write(append(append([]byte{}, framePreface...), frameVersion))
+
+	var writeLock sync.Mutex
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(reader, header[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[:4])-4)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+		id := binary.BigEndian.Uint32(header[4:])
+		count := int(binary.BigEndian.Uint16(body[4:]))
+		body = body[6:]
+		var fields []string
+		for i := 0; i < count; i++ {
+			size := int(binary.BigEndian.Uint16(body))
+			fields = append(fields, string(body[2:2+size]))
+			body = body[2+size:]
+		}
+
+		go func() {
+			res, ok := s.handle(fields)
+			if !ok {
+				conn.Close()
+				return
+			}
+			encoded, _ := json.Marshal(res)
+			frame := make([]byte, 8)
+			binary.BigEndian.PutUint32(frame, uint32(4+len(encoded)))
+			binary.BigEndian.PutUint32(frame[4:], id)
+			writeLock.Lock()
+			conn.Write(append(frame, encoded...))
+			writeLock.Unlock()
+		}()
+	}
+}
+
+func (s *fakeServer) transmitter(connections int) *Transmitter {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	trans := NewTransmitter(addr.IP.String(), strings.TrimPrefix(addr.String(), addr.IP.String()+":"))
+	trans.conns = trans.conns[:connections]
+	return trans
+}
+
+func echo(fields []string) Result {
+	payload, _ := json.Marshal(strings.Join(fields[1:], ","))
+	return Result{Status: 1, Payload: payload}
+}
+
+func TestConcurrentRequestsShareConnections(t *testing.T) {
+	server := newFakeServer(t, func(fields []string) (Result, bool) {
+		// Reply to earlier requests later, so replies arrive out of order
+		if fields[1] == "slow" {
+			time.Sleep(time.Millisecond * 20)
+		}
+		return echo(fields), true
+	})
+	defer server.listener.Close()
+	trans := server.transmitter(2)
+	defer trans.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			speed := "fast"
+			if i%2 == 0 {
+				speed = "slow"
+			}
+			user := "user," + string(rune('a'+i%26))
+			res := trans.MakeRequest(i, "ECHO", speed, user)
+			if expected := speed + "," + user; res.PayloadString() != expected {
+				t.Errorf("Request %d got %v, expected %s", i, res, expected)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := trans.Stats()
+	server.lock.Lock()
+	dials := server.dials
+	server.lock.Unlock()
+	if dials > 2 || stats.Connections != 2 {
+		t.Errorf("Expected 200 requests to share 2 connections, dialed %d: %+v", dials, stats)
+	}
+	if stats.Requests != 200 || stats.InFlight != 0 || stats.Failures != 0 {
+		t.Error("Unexpected stats ", stats)
+	}
+}
+
+func TestRequestTimesOut(t *testing.T) {
+	block := make(chan struct{})
+	server := newFakeServer(t, func(fields []string) (Result, bool) {
+		if fields[0] == "HANG" {
+			<-block
+		}
+		return echo(fields), true
+	})
+	defer server.listener.Close()
+	defer close(block)
+	trans := server.transmitter(1)
+	trans.timeout = time.Millisecond * 50
+	defer trans.Close()
+
+	if res := trans.MakeRequest(1, "HANG"); res.Code != CodeTimeout {
+		t.Error("Expected the hung request to time out, got ", res)
+	}
+	// The connection is still usable by other requests
+	if res := trans.MakeRequest(2, "ECHO", "ok"); res.PayloadString() != "ok" {
+		t.Error("Expected a reply after a timeout, got ", res)
+	}
+	if stats := trans.Stats(); stats.Timeouts != 1 || stats.Reconnects != 0 {
+		t.Error("Unexpected stats ", stats)
+	}
+}
+
+func TestReconnectsAfterConnectionDrops(t *testing.T) {
+	server := newFakeServer(t, func(fields []string) (Result, bool) {
+		return echo(fields), fields[0] != "DROP"
+	})
+	defer server.listener.Close()
+	trans := server.transmitter(1)
+	defer trans.Close()
+
+	if res := trans.MakeRequest(1, "DROP"); res.Code != CodeUnavailable {
+		t.Error("Expected the dropped request to fail, got ", res)
+	}
+	if res := trans.MakeRequest(2, "ECHO", "again"); res.PayloadString() != "again" {
+		t.Error("Expected the transmitter to redial, got ", res)
+	}
+	if stats := trans.Stats(); stats.Reconnects != 1 || stats.Failures != 1 {
+		t.Error("Unexpected stats ", stats)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"regexp"
@@ -31,12 +32,23 @@ var transcount uint64
 var endpointTimes map[string][]endpointHit
 var endpointMutex sync.Mutex
 
-// go run WorkloadGen.go serverAddr:port workloadfile
+// Every workload user is registered with this password unless one is given
+var password = "workload-password"
+
+// The dumplog is fetched as this user, which must be in the web servers' adminusers.
+// Admin accounts can't be registered, so it logs in with the adminpassword the
+// transaction servers created it with.
+const adminUser = "admin"
+
+// go run WorkloadGen.go serverAddr:port workloadfile delay getlog [password]
 func main() {
 	if len(os.Args) < 5 {
-		fmt.Printf("Usage: server address, workloadfile, delay(ms), getlog(bool)")
+		fmt.Printf("Usage: server address, workloadfile, delay(ms), getlog(bool), [password]")
 		return
 	}
+	if len(os.Args) > 5 {
+		password = os.Args[5]
+	}
 
 	serverAddr := os.Args[1]
 	workloadFile := os.Args[2]
@@ -75,7 +87,9 @@ func runRequests(serverAddr string, users map[string][]outgoingRequest, delay in
 	// Wait for commands, then manually post the final dumplog
 	wg.Wait()
 	if getLog {
-		resp, httpErr := http.PostForm("http://"+serverAddr+"/DUMPLOG/", url.Values{"filename": {"./output.xml"}})
+		client := newClient()
+		login(client, serverAddr, adminUser, os.Getenv("adminpassword"))
+		resp, httpErr := client.PostForm("http://"+serverAddr+"/DUMPLOG/", url.Values{"filename": {"./output.xml"}})
 		if httpErr != nil {
 			panic(httpErr)
 		}
@@ -100,18 +114,10 @@ func runRequests(serverAddr string, users map[string][]outgoingRequest, delay in
 func runUserRequests(serverAddr string, delay int, userName string, commands []outgoingRequest, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	//timeout := time.Duration(3 * time.Second)
-	client := http.Client{
-	//Timeout: timeout,
-	}
+	client := newClient()
 
 	// Issue login before executing any commands
-	resp, err := client.PostForm("http://"+serverAddr+"/"+"LOGIN"+"/", url.Values{"username": {userName}})
-	if err != nil {
-		fmt.Println(err)
-	}
-	io.Copy(ioutil.Discard, resp.Body)
-	resp.Body.Close()
+	registerAndLogin(client, serverAddr, userName)
 
 	for _, command := range commands {
 		time.Sleep(time.Duration(rand.Intn(delay)) * time.Millisecond)
@@ -143,6 +149,44 @@ func runUserRequests(serverAddr string, delay int, userName string, commands []o
 	}
 }
 
+// newClient returns a client that keeps the session cookie set at login
+func newClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	//timeout := time.Duration(3 * time.Second)
+	return &http.Client{
+		Jar: jar,
+		//Timeout: timeout,
+	}
+}
+
+// registerAndLogin registers userName, unless it already exists, and logs the client in as them
+func registerAndLogin(client *http.Client, serverAddr string, userName string) {
+	credentials := url.Values{"username": {userName}, "password": {password}}
+	resp, err := client.PostForm("http://"+serverAddr+"/REGISTER/", credentials)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	login(client, serverAddr, userName, password)
+}
+
+// login logs the client in as an already registered userName
+func login(client *http.Client, serverAddr string, userName string, userPassword string) {
+	credentials := url.Values{"username": {userName}, "password": {userPassword}}
+	resp, err := client.PostForm("http://"+serverAddr+"/LOGIN/", credentials)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("Couldn't log in as %v: %v\n", userName, resp.Status)
+	}
+}
+
 func splitUsersFromFile(filename string) map[string][]outgoingRequest {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -0,0 +1,115 @@
+// Package legacy imitates the legacy quote server, so the quote server can be
+// run and tested without it. Outages can be injected to see how it copes.
+package legacy
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Outage is how the server misbehaves
+type Outage int32
+
+const (
+	Up   Outage = iota // Answers every request
+	Drop               // Closes connections without answering
+	Hang               // Holds connections open without answering
+)
+
+// Server answers one quote request per connection, as the legacy server does:
+// "STOCK,user\n" gets "price,STOCK,user,milliseconds,key\n" and the connection
+// is closed.
+type Server struct {
+	// Every reply is held back by Delay, and up to MaxDelay more at random
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	outage   int32
+	requests int64
+}
+
+// SetOutage changes how the server treats new requests
+func (s *Server) SetOutage(outage Outage) {
+	atomic.StoreInt32(&s.outage, int32(outage))
+}
+
+// Requests is how many connections the server has accepted
+func (s *Server) Requests() int64 {
+	return atomic.LoadInt64(&s.requests)
+}
+
+// Serve answers connections on l until it's closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.requests, 1)
+		go s.handleRequest(conn)
+	}
+}
+
+// Handles incoming requests.
+func (s *Server) handleRequest(conn net.Conn) {
+	switch Outage(atomic.LoadInt32(&s.outage)) {
+	case Drop:
+		conn.Close()
+		return
+	case Hang:
+		// Wait for the client to give up
+		conn.Read(make([]byte, 1))
+		conn.Close()
+		return
+	}
+
+	// Make a buffer to hold incoming data.
+	buf := make([]byte, 1024)
+	// Read the incoming connection into the buffer.
+	recv, err := conn.Read(buf)
+	if err != nil {
+		fmt.Println("Error reading:", err.Error())
+	}
+	split := strings.Split(string(buf[:recv]), ",")
+	if len(split) < 2 {
+		conn.Close()
+		return
+	}
+	delay := s.Delay
+	if s.MaxDelay > 0 {
+		delay += time.Duration(rand.Int63n(int64(s.MaxDelay)))
+	}
+	time.Sleep(delay)
+	// Send a response back to person contacting us.
+	conn.Write([]byte(makeResponse(strings.TrimSpace(split[0]), strings.TrimSpace(split[1]))))
+	// Close the connection when you're done with it.
+	conn.Close()
+}
+
+func makeResponse(stock string, username string) string {
+	now := time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
+	amount := fmt.Sprintf("%d.%d", rand.Intn(500)+1, rand.Intn(100))
+	crypto := randSeq(25)
+	// (?P<quote>.+),(?P<stock>.+),(?P<user>.+),(?P<time>.+),(?P<key>.+)
+	output := fmt.Sprintf("%s,%s,%s,%d,%s\n",
+		amount,
+		stock,
+		username,
+		now,
+		crypto)
+	return output
+}
+
+var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+func randSeq(n int) string {
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
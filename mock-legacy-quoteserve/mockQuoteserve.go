@@ -1,12 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
 	"net"
 	"os"
-	"strings"
 	"time"
+
+	"seng468/mock-legacy-quoteserve/legacy"
 )
 
 const (
@@ -15,66 +16,32 @@ const (
 	connType = "tcp"        // NOTE: not HTPP
 )
 
+var outages = map[string]legacy.Outage{"": legacy.Up, "drop": legacy.Drop, "hang": legacy.Hang}
+
 func main() {
+	addr := flag.String("addr", connHost+":"+connPort, "Address to listen on")
+	outageName := flag.String("outage", "", "Start in an outage, dropping or hanging every request (drop or hang)")
+	flag.Parse()
+	outage, ok := outages[*outageName]
+	if !ok {
+		fmt.Println("Unknown outage:", *outageName)
+		os.Exit(1)
+	}
+
 	// Listen for incoming connections.
-	l, err := net.Listen(connType, connHost+":"+connPort)
+	l, err := net.Listen(connType, *addr)
 	if err != nil {
 		fmt.Println("Error listening:", err.Error())
 		os.Exit(1)
 	}
 	// Close the listener when the application closes.
 	defer l.Close()
-	fmt.Println("Listening on " + connHost + ":" + connPort)
-	for {
-		// Listen for an incoming connection.
-		conn, err := l.Accept()
-		if err != nil {
-			fmt.Println("Error accepting: ", err.Error())
-			os.Exit(1)
-		}
-		// Handle connections in a new goroutine.
-		go handleRequest(conn)
-	}
-}
+	fmt.Println("Listening on " + *addr)
 
-// Handles incoming requests.
-func handleRequest(conn net.Conn) {
-	// Make a buffer to hold incoming data.
-	buf := make([]byte, 1024)
-	// Read the incoming connection into the buffer.
-	recv, err := conn.Read(buf)
-	if err != nil {
-		fmt.Println("Error reading:", err.Error())
-	}
-	split := strings.Split(string(buf[:recv]), ",")
-	time.Sleep(time.Duration(rand.Intn(30)) * time.Millisecond)
-	// Send a response back to person contacting us.
-	conn.Write([]byte(makeResponse(strings.TrimSpace(split[0]), strings.TrimSpace(split[1]))))
-	// Close the connection when you're done with it.
-	conn.Close()
-}
-
-func makeResponse(stock string, username string) string {
-	now := time.Now().UnixNano() / (int64(time.Millisecond) / int64(time.Nanosecond))
-	amount := fmt.Sprintf("%d.%d", rand.Intn(500)+1, rand.Intn(100))
-	crypto := randSeq(25)
-	// (?P<quote>.+),(?P<stock>.+),(?P<user>.+),(?P<time>.+),(?P<key>.+)
-	output := fmt.Sprintf("%s,%s,%s,%d,%s\n",
-		amount,
-		stock,
-		username,
-		now,
-		crypto)
-	fmt.Println(output)
-	return output
-}
-
-var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-
-func randSeq(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+	server := &legacy.Server{MaxDelay: 30 * time.Millisecond}
+	server.SetOutage(outage)
+	if err := server.Serve(l); err != nil {
+		fmt.Println("Error accepting: ", err.Error())
+		os.Exit(1)
 	}
-	return string(b)
 }
@@ -44,6 +44,8 @@ docker image build \
 --build-arg auditport=${auditport} \
 --build-arg legacyquoteaddr=${legacyquoteaddr} \
 --build-arg legacyquoteport=${legacyquoteport} \
+--build-arg legacyquoteconns=${legacyquoteconns} \
+--build-arg quoterevalidate=${quoterevalidate} \
 -t teamrandint/quoteserver .
 
 cd ../triggerserver
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The legacy server answers one request per connection and then closes it,
+// so connections can't be kept open and reused. Instead the number open at
+// once is bounded, and a circuit breaker stops the quote server from dialing
+// a legacy server that keeps failing.
+
+// Errors a quote can fail with. Each starts with the code reported to clients.
+var (
+	errLegacyUnavailable = errors.New("LEGACY_UNAVAILABLE: the legacy quote server is failing, try again later")
+	errLegacyBusy        = errors.New("LEGACY_BUSY: too many quotes are waiting on the legacy quote server")
+)
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"    // Requests go to the legacy server
+	circuitOpen     = "open"      // Requests fail fast until the cooldown is over
+	circuitHalfOpen = "half-open" // One probe request is deciding whether to close
+)
+
+const (
+	defaultLegacyConns = 50
+	legacyTimeout      = time.Second * 2 // For connecting and for the reply
+	legacyAttempts     = 3
+	legacyBackoff      = time.Millisecond * 100 // Before the second attempt, doubling after each
+	circuitThreshold   = 5                      // Failures in a row that open the circuit
+	circuitCooldown    = time.Second            // Doubles each time a probe fails
+	circuitMaxCooldown = time.Second * 30
+)
+
+// legacyClient asks the legacy quote server for quotes, retrying failures
+// with exponential backoff. Once the server has failed circuitThreshold times
+// in a row the circuit opens, and quotes fail fast with errLegacyUnavailable
+// until a cooldown is over and a single probe request succeeds.
+type legacyClient struct {
+	addr     string
+	slots    chan struct{} // Holds a token for each request open to the legacy server
+	timeout  time.Duration
+	attempts int
+	backoff  time.Duration
+	cooldown time.Duration // How long the circuit first stays open
+
+	lock      sync.Mutex
+	state     string
+	failures  int // In a row
+	openFor   time.Duration
+	openUntil time.Time
+
+	requests, failed, rejected uint64
+}
+
+func newLegacyClient(addr string, conns int) *legacyClient {
+	return &legacyClient{
+		addr:     addr,
+		slots:    make(chan struct{}, conns),
+		timeout:  legacyTimeout,
+		attempts: legacyAttempts,
+		backoff:  legacyBackoff,
+		state:    circuitClosed,
+		cooldown: circuitCooldown,
+	}
+}
+
+// ask sends a quote request to the legacy server and returns its reply
+func (c *legacyClient) ask(stock string, user string) (string, error) {
+	probe, ok := c.admit(time.Now())
+	if !ok {
+		atomic.AddUint64(&c.rejected, 1)
+		return "", errLegacyUnavailable
+	}
+	backoff := c.backoff
+	for attempt := 1; ; attempt++ {
+		reply, err := c.request(stock, user)
+		if err == errLegacyBusy {
+			if probe {
+				c.release()
+			}
+			return "", err
+		}
+		if err == nil {
+			c.succeeded()
+			return reply, nil
+		}
+		fmt.Println("Error asking the legacy quote server for", stock, err)
+		if open := c.failedAttempt(time.Now()); open || probe || attempt == c.attempts {
+			return "", fmt.Errorf("LEGACY_FAILED: %v", err)
+		}
+		// Jitter keeps retries of the requests that failed together apart
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff)/2+1)))
+		backoff *= 2
+	}
+}
+
+// request makes one request of the legacy server, waiting up to the timeout for a free slot
+func (c *legacyClient) request(stock string, user string) (string, error) {
+	wait := time.NewTimer(c.timeout)
+	defer wait.Stop()
+	select {
+	case c.slots <- struct{}{}:
+	case <-wait.C:
+		return "", errLegacyBusy
+	}
+	defer func() { <-c.slots }()
+	atomic.AddUint64(&c.requests, 1)
+
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := fmt.Fprintf(conn, "%s,%s\n", stock, user); err != nil {
+		return "", err
+	}
+	return bufio.NewReader(conn).ReadString('\n')
+}
+
+// admit decides whether a request may go to the legacy server, and whether it's the probe of a half open circuit
+func (c *legacyClient) admit(now time.Time) (probe bool, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch c.state {
+	case circuitOpen:
+		if now.Before(c.openUntil) {
+			return false, false
+		}
+		c.state = circuitHalfOpen
+		return true, true
+	case circuitHalfOpen:
+		return false, false
+	}
+	return false, true
+}
+
+// release lets another request probe a half open circuit, when the probe never reached the legacy server
+func (c *legacyClient) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.state == circuitHalfOpen {
+		c.state = circuitOpen
+	}
+}
+
+func (c *legacyClient) succeeded() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.state = circuitClosed
+	c.failures = 0
+	c.openFor = 0
+}
+
+// failedAttempt counts a failure, opening the circuit if the legacy server
+// keeps failing. Returns whether the circuit is open, so retrying is pointless.
+func (c *legacyClient) failedAttempt(now time.Time) bool {
+	atomic.AddUint64(&c.failed, 1)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.failures++
+	switch {
+	case c.state == circuitHalfOpen:
+		c.openFor *= 2
+		if c.openFor > circuitMaxCooldown {
+			c.openFor = circuitMaxCooldown
+		}
+	case c.state == circuitClosed && c.failures >= circuitThreshold:
+		c.openFor = c.cooldown
+	default:
+		return c.state != circuitClosed
+	}
+	c.state = circuitOpen
+	c.openUntil = now.Add(c.openFor)
+	return true
+}
+
+// legacyHealth reports on the legacy quote server
+type legacyHealth struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"` // In a row
+	RetryAt  int64  `json:"retryAt,omitempty"`
+	InFlight int    `json:"inFlight"`
+	Requests uint64 `json:"requests"`
+	Failed   uint64 `json:"failed"`
+	Rejected uint64 `json:"rejected"` // Failed fast while the circuit was open
+}
+
+func (c *legacyClient) health() legacyHealth {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	health := legacyHealth{
+		State:    c.state,
+		Failures: c.failures,
+		InFlight: len(c.slots),
+		Requests: atomic.LoadUint64(&c.requests),
+		Failed:   atomic.LoadUint64(&c.failed),
+		Rejected: atomic.LoadUint64(&c.rejected),
+	}
+	if c.state == circuitOpen {
+		// Milliseconds since the Unix epoch
+		health.RetryAt = c.openUntil.UnixNano() / int64(time.Millisecond)
+	}
+	return health
+}
+
+// Reports the legacy quote server's health as JSON, with a 503 while quotes are failing fast
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	health := legacyQuotes.health()
+	w.Header().Set("Content-Type", "application/json")
+	if health.State != circuitClosed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
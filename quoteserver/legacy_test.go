@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"seng468/mock-legacy-quoteserve/legacy"
+)
+
+// fastClient makes startLegacy's client time out, back off and cool down quickly
+func fastClient(t *testing.T) *legacy.Server {
+	server := startLegacy(t)
+	server.Delay = 0
+	legacyQuotes.timeout = time.Millisecond * 100
+	legacyQuotes.backoff = time.Millisecond * 10
+	legacyQuotes.cooldown = time.Millisecond * 100
+	return server
+}
+
+func healthStatus(t *testing.T) (int, legacyHealth) {
+	recorder := httptest.NewRecorder()
+	healthHandler(recorder, httptest.NewRequest("GET", "/health", nil))
+	return recorder.Code, legacyQuotes.health()
+}
+
+func TestLegacyCircuitBreaker(t *testing.T) {
+	server := fastClient(t)
+	if _, err := legacyQuotes.ask("ABC", "user1"); err != nil {
+		t.Fatal("The legacy server is up, got ", err)
+	}
+
+	// Each failed quote retries, until enough failures in a row open the circuit
+	server.SetOutage(legacy.Drop)
+	if _, err := legacyQuotes.ask("ABC", "user1"); err == nil || err == errLegacyUnavailable {
+		t.Error("A failing quote should report the legacy server's error, got ", err)
+	}
+	if server.Requests() != 1+legacyAttempts {
+		t.Errorf("Expected %d attempts, made %d", legacyAttempts, server.Requests()-1)
+	}
+	legacyQuotes.ask("ABC", "user1")
+	if status, health := healthStatus(t); status != http.StatusServiceUnavailable || health.State != circuitOpen {
+		t.Errorf("The circuit should be open after %d failures, got %d %+v", circuitThreshold, status, health)
+	}
+
+	// While open, quotes fail fast without reaching the legacy server
+	requests := server.Requests()
+	if _, err := legacyQuotes.ask("ABC", "user1"); err != errLegacyUnavailable || server.Requests() != requests {
+		t.Error("An open circuit should fail fast, got ", err)
+	}
+
+	// A failed probe keeps it open for twice as long
+	time.Sleep(time.Millisecond * 100)
+	if _, err := legacyQuotes.ask("ABC", "user1"); err == nil || server.Requests() != requests+1 {
+		t.Error("The probe should fail once, got ", err)
+	}
+	if _, health := healthStatus(t); health.State != circuitOpen || legacyQuotes.openFor != time.Millisecond*200 {
+		t.Errorf("A failed probe should double the cooldown, got %+v %v", health, legacyQuotes.openFor)
+	}
+
+	// Once the legacy server recovers, the next probe closes the circuit
+	server.SetOutage(legacy.Up)
+	time.Sleep(time.Millisecond * 200)
+	if _, err := legacyQuotes.ask("ABC", "user1"); err != nil {
+		t.Error("The probe should succeed, got ", err)
+	}
+	if status, health := healthStatus(t); status != http.StatusOK || health.State != circuitClosed ||
+		health.Failures != 0 {
+		t.Errorf("A successful probe should close the circuit, got %d %+v", status, health)
+	}
+}
+
+func TestLegacyTimesOut(t *testing.T) {
+	server := fastClient(t)
+	server.SetOutage(legacy.Hang)
+	start := time.Now()
+	if _, err := legacyQuotes.ask("ABC", "user1"); err == nil {
+		t.Error("A hung legacy server should time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Error("Attempts should time out, took ", elapsed)
+	}
+}
+
+func TestLegacyConnectionLimit(t *testing.T) {
+	server := startLegacy(t)
+	legacyQuotes = newLegacyClient(legacyQuotes.addr, 2)
+
+	// 6 quotes of 50ms at 2 at a time take at least 150ms
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := legacyQuotes.ask(fmt.Sprint("S", i), "user1"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < time.Millisecond*150 || server.Requests() != 6 {
+		t.Errorf("Expected 6 requests 2 at a time, made %d in %v", server.Requests(), elapsed)
+	}
+
+	// Quotes that can't get a connection in time give up without counting against the legacy server
+	legacyQuotes.timeout = time.Millisecond * 10
+	legacyQuotes.slots <- struct{}{}
+	legacyQuotes.slots <- struct{}{}
+	if _, err := legacyQuotes.ask("ABC", "user1"); err != errLegacyBusy || legacyQuotes.health().Failures != 0 {
+		t.Error("Expected the quote to give up waiting, got ", err)
+	}
+}
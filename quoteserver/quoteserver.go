@@ -1,10 +1,8 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"seng468/quoteserver/logger"
@@ -14,6 +12,7 @@ import (
 
 	"github.com/patrickmn/go-cache"
 	"github.com/shopspring/decimal"
+	"golang.org/x/sync/singleflight"
 	// _ "net/http/pprof"
 )
 
@@ -48,36 +47,54 @@ func getReply(msg string) *QuoteReply {
 	}
 }
 
-func quote(user string, stock string, transNum int) (decimal.Decimal, error) {
-	quote, found := quoteCache.Get(stock)
-	if found {
-		d, _ := decimal.NewFromString(quote.(string))
-		return d, nil
-	}
+// quoteValidity is how long a quote from the legacy server can be given out
+const quoteValidity = time.Minute
 
-	var conn net.Conn
-	var err error
-	for {
-		conn, err = net.DialTimeout("tcp",
-			os.Getenv("legacyquoteaddr")+":"+os.Getenv("legacyquoteport"),
-			time.Second*1,
-		)
-		if err != nil { // trans server down? retry
-			fmt.Println(err.Error())
-		} else {
-			break
+// cachedQuote is a stock's price and when the legacy server gave it
+type cachedQuote struct {
+	price   decimal.Decimal
+	fetched time.Time
+}
+
+// quote gives the cached price of a stock, fetching it from the legacy server
+// on a miss. A cached quote within revalidate of expiring is still given out,
+// but is refreshed in the background so busy stocks never miss.
+func quote(user string, stock string, transNum int) (decimal.Decimal, error) {
+	if cached, found := quoteCache.Get(stock); found {
+		q := cached.(cachedQuote)
+		if revalidate > 0 && time.Since(q.fetched) >= quoteValidity-revalidate {
+			// Joins a refresh already under way, so only one is made
+			fetches.DoChan(stock, func() (interface{}, error) {
+				price, err := legacyQuote(user, stock, transNum)
+				if err != nil {
+					fmt.Println("Error refreshing quote for", stock, err)
+				}
+				return price, err
+			})
 		}
+		return q.price, nil
 	}
+	return fetchQuote(user, stock, transNum)
+}
 
-	request := fmt.Sprintf("%s,%s\n", stock, user)
+// fetchQuote gets a stock's price from the legacy server and caches it.
+// Concurrent fetches of a stock share one legacy request, and its audit event,
+// with the user and transaction of whichever asked first.
+func fetchQuote(user string, stock string, transNum int) (decimal.Decimal, error) {
+	price, err, _ := fetches.Do(stock, func() (interface{}, error) {
+		return legacyQuote(user, stock, transNum)
+	})
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return price.(decimal.Decimal), nil
+}
 
-	conn.Write([]byte(request))
-	message, err := bufio.NewReader(conn).ReadString('\n')
+func legacyQuote(user string, stock string, transNum int) (decimal.Decimal, error) {
+	message, err := legacyQuotes.ask(stock, user)
 	if err != nil {
-		fmt.Println(err)
 		return decimal.Decimal{}, err
 	}
-	defer conn.Close()
 	reply := getReply(message)
 	fmt.Println(reply)
 	if reply == nil {
@@ -85,7 +102,7 @@ func quote(user string, stock string, transNum int) (decimal.Decimal, error) {
 	}
 	auditServer.QuoteServer("quoteserver", transNum, reply.quote.String(), reply.stock,
 		reply.user, reply.time, reply.key)
-	quoteCache.Set(reply.stock, reply.quote.String(), cache.DefaultExpiration)
+	quoteCache.Set(stock, cachedQuote{price: reply.quote, fetched: time.Now()}, cache.DefaultExpiration)
 	return reply.quote, nil
 }
 
@@ -96,18 +113,45 @@ func quoteHandler(w http.ResponseWriter, r *http.Request) {
 	transNum, _ := strconv.Atoi(query.Get("transNum"))
 	reply, err := quote(user, stock, transNum)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		if err == errLegacyUnavailable || err == errLegacyBusy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		fmt.Fprint(w, err.Error())
 		fmt.Println("Error receiving quote from legacy quote server", err)
 		return
 	}
-	fmt.Fprintf(w, reply.StringFixed(2))
+	fmt.Fprint(w, reply.StringFixed(2))
 }
 
-var quoteCache = cache.New(time.Minute, time.Minute)
-var auditServer = logger.AuditLogger{Addr: "http://" + os.Getenv("auditaddr") + ":" + os.Getenv("auditport")}
+var quoteCache = cache.New(quoteValidity, quoteValidity)
+var fetches singleflight.Group
+var legacyQuotes *legacyClient
+var auditServer logger.Logger = logger.AuditLogger{Addr: "http://" + os.Getenv("auditaddr") + ":" + os.Getenv("auditport")}
+
+// revalidate is how long before a cached quote expires that asking for it
+// refreshes it. Zero only fetches quotes that have expired.
+var revalidate time.Duration
 
 func main() {
+	if window := os.Getenv("quoterevalidate"); window != "" {
+		var err error
+		if revalidate, err = time.ParseDuration(window); err != nil || revalidate < 0 || revalidate >= quoteValidity {
+			panic(fmt.Sprintf("quoterevalidate should be a duration under %s, got %q", quoteValidity, window))
+		}
+	}
+	conns := defaultLegacyConns
+	if limit := os.Getenv("legacyquoteconns"); limit != "" {
+		var err error
+		if conns, err = strconv.Atoi(limit); err != nil || conns < 1 {
+			panic(fmt.Sprintf("legacyquoteconns should be a positive number, got %q", limit))
+		}
+	}
+	legacyQuotes = newLegacyClient(os.Getenv("legacyquoteaddr")+":"+os.Getenv("legacyquoteport"), conns)
+
 	http.HandleFunc("/quote", quoteHandler)
+	http.HandleFunc("/health", healthHandler)
 	addr := os.Getenv("quoteaddr")
 	port := os.Getenv("quoteport")
 	fmt.Printf("Quote server listening on %s:%s\n", addr, port)
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"seng468/mock-legacy-quoteserve/legacy"
+	"seng468/quoteserver/logger"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/shopspring/decimal"
+)
+
+// countingAudit counts the quote server events it's sent
+type countingAudit struct {
+	logger.Logger
+	quotes int64
+}
+
+func (a *countingAudit) QuoteServer(string, int, string, string, string, uint64, string) {
+	atomic.AddInt64(&a.quotes, 1)
+}
+
+// startLegacy serves quotes from a mock legacy server, slowly enough that
+// concurrent requests overlap
+func startLegacy(t *testing.T) *legacy.Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &legacy.Server{Delay: time.Millisecond * 50}
+	go server.Serve(listener)
+
+	previous := legacyQuotes
+	legacyQuotes = newLegacyClient(listener.Addr().String(), defaultLegacyConns)
+	t.Cleanup(func() { legacyQuotes = previous })
+	return server
+}
+
+func resetQuotes(t *testing.T, window time.Duration) *countingAudit {
+	audit := &countingAudit{}
+	previousAudit, previousWindow := auditServer, revalidate
+	auditServer, revalidate = audit, window
+	quoteCache = cache.New(quoteValidity, quoteValidity)
+	t.Cleanup(func() { auditServer, revalidate = previousAudit, previousWindow })
+	return audit
+}
+
+func TestQuoteCoalescesMisses(t *testing.T) {
+	audit := resetQuotes(t, 0)
+	server := startLegacy(t)
+
+	var wg sync.WaitGroup
+	prices := make([]decimal.Decimal, 100)
+	for i := range prices {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if prices[i], err = quote(fmt.Sprint("user", i), "ABC", i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, price := range prices {
+		if !price.Equal(prices[0]) {
+			t.Fatal("Every caller should get the same quote, got ", prices)
+		}
+	}
+	if made, audited := server.Requests(), atomic.LoadInt64(&audit.quotes); made != 1 || audited != 1 {
+		t.Errorf("Concurrent misses should share one fetch, made %d with %d audit events", made, audited)
+	}
+}
+
+func TestQuoteRevalidates(t *testing.T) {
+	audit := resetQuotes(t, time.Second*10)
+	server := startLegacy(t)
+	cached := decimal.New(5, -1) // The mock never quotes under a dollar
+
+	// Fresh quotes are given out as they are
+	quoteCache.Set("ABC", cachedQuote{price: cached, fetched: time.Now()}, cache.DefaultExpiration)
+	if price, _ := quote("user1", "ABC", 1); !price.Equal(cached) || server.Requests() != 0 {
+		t.Error("A fresh quote should come from the cache, got ", price)
+	}
+
+	// Expiring quotes are still given out while one refresh happens behind them
+	expiring := cachedQuote{price: cached, fetched: time.Now().Add(-quoteValidity + time.Second*5)}
+	quoteCache.Set("ABC", expiring, cache.DefaultExpiration)
+	for i := 0; i < 20; i++ {
+		if price, _ := quote("user1", "ABC", i); !price.Equal(cached) {
+			t.Error("An expiring quote should still be given out, got ", price)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		refreshed, _ := quoteCache.Get("ABC")
+		if !refreshed.(cachedQuote).price.Equal(cached) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The expiring quote was never refreshed")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if made, audited := server.Requests(), atomic.LoadInt64(&audit.quotes); made != 1 || audited != 1 {
+		t.Errorf("Expected one refresh, made %d with %d audit events", made, audited)
+	}
+}
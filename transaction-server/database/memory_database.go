@@ -0,0 +1,947 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MemoryDatabase is a UserDatabase kept entirely in process memory.
+// It mirrors the behaviour of RedisDatabase, including the compound
+// operations, so the transaction server can run and be tested without redis.
+type MemoryDatabase struct {
+	*memoryStore
+
+	// Recorded in the history of every change, see WithTransaction
+	transNum int
+	command  string
+}
+
+// memoryStore is shared by every copy of a MemoryDatabase
+type memoryStore struct {
+	lock  sync.Mutex
+	users map[string]*memoryAccount
+
+	// IDs of executed triggers, see ProcessedTriggerTTL
+	processedTriggers map[string]time.Time
+
+	// Password hashes of registered users
+	credentials map[string]string
+}
+
+// memoryAccount holds everything redis stores under a single $USERID prefix
+type memoryAccount struct {
+	funds         decimal.Decimal
+	reservedFunds decimal.Decimal
+	stocks        map[string]int64
+	reservedStock map[string]int64
+	buyOrders     []string
+	sellOrders    []string
+	buyTriggers   map[string]decimal.Decimal // Keyed by triggerField, as in redis
+	sellTriggers  map[string]int64
+	limitOrders   map[string]LimitOrder
+	history       []HistoryEntry
+}
+
+// NewMemoryDatabase returns an empty in-memory database
+func NewMemoryDatabase() *MemoryDatabase {
+	return &MemoryDatabase{
+		memoryStore: &memoryStore{
+			users:             make(map[string]*memoryAccount),
+			processedTriggers: make(map[string]time.Time),
+			credentials:       make(map[string]string),
+		},
+	}
+}
+
+// WithTransaction returns a copy of the database that records transNum and
+// command in the history entries of every change it makes
+func (db *MemoryDatabase) WithTransaction(transNum int, command string) UserDatabase {
+	return &MemoryDatabase{
+		memoryStore: db.memoryStore,
+		transNum:    transNum,
+		command:     command,
+	}
+}
+
+// record appends a history entry for a change made under the database's transaction.
+// The caller must hold db.lock.
+func (db *MemoryDatabase) record(acc *memoryAccount, stock string, funds decimal.Decimal,
+	shares int64, price decimal.Decimal) {
+	acc.history = append(acc.history,
+		newHistoryEntry(db.transNum, db.command, stock, funds, shares, price, time.Now()))
+}
+
+// GetHistory returns a page of the user's history, newest entries first.
+// Page 0 holds the most recent HistoryPageSize entries.
+func (db *MemoryDatabase) GetHistory(user string, page int) ([]HistoryEntry, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	start, stop := historyRange(page)
+	return historyWindow(db.account(user).history, start, stop), nil
+}
+
+// historyWindow returns entries start through stop counting back from the newest
+func historyWindow(history []HistoryEntry, start int, stop int) []HistoryEntry {
+	window := []HistoryEntry{}
+	for i := start; i <= stop && i < len(history); i++ {
+		window = append(window, history[len(history)-1-i])
+	}
+	return window
+}
+
+// account returns the user's account, creating it on first use.
+// The caller must hold db.lock.
+func (db *MemoryDatabase) account(user string) *memoryAccount {
+	acc, ok := db.users[user]
+	if !ok {
+		acc = &memoryAccount{
+			stocks:        make(map[string]int64),
+			reservedStock: make(map[string]int64),
+			buyTriggers:   make(map[string]decimal.Decimal),
+			sellTriggers:  make(map[string]int64),
+			limitOrders:   make(map[string]LimitOrder),
+		}
+		db.users[user] = acc
+	}
+	return acc
+}
+
+// GetUserInfo returns all of a users information in the database
+func (db *MemoryDatabase) GetUserInfo(user string) (info string, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+
+	funds, _ := acc.funds.Float64()
+	reservedFunds, _ := acc.reservedFunds.Float64()
+	userInfo := UserInfo{
+		user:          user,
+		funds:         funds,
+		reservedFunds: reservedFunds,
+		reservedStock: make(map[string]string),
+		stock:         make(map[string]string),
+		sellOrders:    orderWindow(acc.sellOrders),
+		buyOrders:     orderWindow(acc.buyOrders),
+		history:       historyWindow(acc.history, 0, SummaryHistorySize-1),
+	}
+	for stock, shares := range acc.stocks {
+		userInfo.stock[stock] = decimal.New(shares, 0).String()
+	}
+	for stock, shares := range acc.reservedStock {
+		userInfo.reservedStock[stock] = decimal.New(shares, 0).String()
+	}
+	return userInfo.getString(), nil
+}
+
+// GetAccount returns a snapshot of all of the user's account
+func (db *MemoryDatabase) GetAccount(user string) (Account, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+
+	account := newAccount(user)
+	account.Funds = acc.funds
+	account.ReservedFunds = acc.reservedFunds
+	for stock, shares := range acc.stocks {
+		if shares > 0 {
+			account.Stocks[stock] = shares
+		}
+	}
+	for stock, shares := range acc.reservedStock {
+		if shares > 0 {
+			account.ReservedStocks[stock] = shares
+		}
+	}
+	for _, order := range acc.buyOrders {
+		account.BuyOrders = append(account.BuyOrders, decodeFullOrder(user, "Buy", order))
+	}
+	for _, order := range acc.sellOrders {
+		account.SellOrders = append(account.SellOrders, decodeFullOrder(user, "Sell", order))
+	}
+	for field, amount := range acc.buyTriggers {
+		account.addBuyTrigger(field, amount)
+	}
+	for field, shares := range acc.sellTriggers {
+		account.addSellTrigger(field, shares)
+	}
+	account.sortTriggers()
+	account.LimitOrders = acc.sortedLimitOrders()
+	account.History = historyWindow(acc.history, 0, SummaryHistorySize-1)
+	return account, nil
+}
+
+// orderWindow copies the same window of orders that GetUserInfo reads from redis
+func orderWindow(orders []string) []string {
+	if len(orders) > 6 {
+		orders = orders[:6]
+	}
+	return append([]string(nil), orders...)
+}
+
+// AddFunds adds amount dollars to the user account
+func (db *MemoryDatabase) AddFunds(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.funds = acc.funds.Add(amount.Truncate(2))
+	db.record(acc, "", amount, 0, decimal.Zero)
+	return nil
+}
+
+// GetFunds returns the amount of available funds in a users account
+func (db *MemoryDatabase) GetFunds(user string) (decimal.Decimal, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).funds, nil
+}
+
+// RemoveFunds remove n funds from the user's account
+func (db *MemoryDatabase) RemoveFunds(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.funds = acc.funds.Sub(amount.Truncate(2))
+	db.record(acc, "", amount.Neg(), 0, decimal.Zero)
+	return nil
+}
+
+// AddReserveFunds adds funds to a user's reserve account
+func (db *MemoryDatabase) AddReserveFunds(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.reservedFunds = acc.reservedFunds.Add(amount.Truncate(2))
+	return nil
+}
+
+// GetReserveFunds returns the amount of funds present in a users reserve account
+func (db *MemoryDatabase) GetReserveFunds(user string) (decimal.Decimal, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).reservedFunds, nil
+}
+
+// RemoveReserveFunds removes n funds from a users account
+func (db *MemoryDatabase) RemoveReserveFunds(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.reservedFunds = acc.reservedFunds.Sub(amount.Truncate(2))
+	return nil
+}
+
+// AddStock adds shares to the user account
+func (db *MemoryDatabase) AddStock(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.stocks[stock] += shares
+	db.record(acc, stock, decimal.Zero, shares, decimal.Zero)
+	return nil
+}
+
+// GetStock returns the users available balance of said stock
+func (db *MemoryDatabase) GetStock(user string, stock string) (int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).stocks[stock], nil
+}
+
+// RemoveStock removes int stocks from the users account
+func (db *MemoryDatabase) RemoveStock(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.stocks[stock] -= shares
+	db.record(acc, stock, decimal.Zero, -shares, decimal.Zero)
+	return nil
+}
+
+// AddReserveStock adds n shares of stock to a user's reserve account
+func (db *MemoryDatabase) AddReserveStock(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.account(user).reservedStock[stock] += shares
+	return nil
+}
+
+// GetReserveStock returns the amount of shares present in a user's reserve account
+func (db *MemoryDatabase) GetReserveStock(user string, stock string) (int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).reservedStock[stock], nil
+}
+
+// RemoveReserveStock removes n shares of stock from a user's reserve account
+func (db *MemoryDatabase) RemoveReserveStock(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.account(user).reservedStock[stock] -= shares
+	return nil
+}
+
+// AddBuyTrigger records the dollar amount set aside for a user's buy trigger on stock with id
+func (db *MemoryDatabase) AddBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.account(user).buyTriggers[triggerField(stock, id)] = amount.Truncate(2)
+	return nil
+}
+
+// GetBuyTrigger returns the dollar amount set aside for a user's buy trigger on stock with id
+func (db *MemoryDatabase) GetBuyTrigger(user string, stock string, id string) (decimal.Decimal, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).buyTriggers[triggerField(stock, id)], nil
+}
+
+// RemoveBuyTrigger removes the record of a user's buy trigger on stock with id
+func (db *MemoryDatabase) RemoveBuyTrigger(user string, stock string, id string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	delete(db.account(user).buyTriggers, triggerField(stock, id))
+	return nil
+}
+
+// AddSellTrigger records the shares set aside for a user's sell trigger on stock with id
+func (db *MemoryDatabase) AddSellTrigger(user string, stock string, id string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	db.account(user).sellTriggers[triggerField(stock, id)] = shares
+	return nil
+}
+
+// GetSellTrigger returns the shares set aside for a user's sell trigger on stock with id
+func (db *MemoryDatabase) GetSellTrigger(user string, stock string, id string) (int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).sellTriggers[triggerField(stock, id)], nil
+}
+
+// RemoveSellTrigger removes the record of a user's sell trigger on stock with id
+func (db *MemoryDatabase) RemoveSellTrigger(user string, stock string, id string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	delete(db.account(user).sellTriggers, triggerField(stock, id))
+	return nil
+}
+
+// GetTriggerRecords returns the buy and sell trigger records and the limit orders of every user
+func (db *MemoryDatabase) GetTriggerRecords() ([]TriggerRecord, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	var records []TriggerRecord
+	for user, acc := range db.users {
+		for field, funds := range acc.buyTriggers {
+			stock, id := splitTriggerField(field)
+			records = append(records, TriggerRecord{User: user, Stock: stock, Action: "BUY", ID: id, Funds: funds})
+		}
+		for field, shares := range acc.sellTriggers {
+			stock, id := splitTriggerField(field)
+			records = append(records, TriggerRecord{User: user, Stock: stock, Action: "SELL", ID: id, Shares: shares})
+		}
+		for _, order := range acc.limitOrders {
+			records = append(records, order.triggerRecord())
+		}
+	}
+	return records, nil
+}
+
+// PushBuy adds a record of the users requested buy to their account
+func (db *MemoryDatabase) PushBuy(user string, stock string, cost decimal.Decimal, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.buyOrders = append(acc.buyOrders, encodeOrder(stock, cost, shares, time.Now()))
+	return nil
+}
+
+// PopBuy removes a users most recent requested buy
+func (db *MemoryDatabase) PopBuy(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, _ := popLast(&acc.buyOrders)
+	stock, cost, shares = decodeOrder(order)
+	return stock, cost, shares, nil
+}
+
+// PushSell adds a record of the users requested sell to their account
+func (db *MemoryDatabase) PushSell(user string, stock string, cost decimal.Decimal, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	acc.sellOrders = append(acc.sellOrders, encodeOrder(stock, cost, shares, time.Now()))
+	return nil
+}
+
+// PopSell removes a users most recent requested sell
+func (db *MemoryDatabase) PopSell(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, _ := popLast(&acc.sellOrders)
+	stock, cost, shares = decodeOrder(order)
+	return stock, cost, shares, nil
+}
+
+// popLast removes the newest order, matching RPOP on the redis lists
+func popLast(orders *[]string) (string, bool) {
+	if len(*orders) == 0 {
+		return "", false
+	}
+	last := (*orders)[len(*orders)-1]
+	*orders = (*orders)[:len(*orders)-1]
+	return last, true
+}
+
+// PushBuyWithFunds removes cost from the user's balance and records the pending buy
+func (db *MemoryDatabase) PushBuyWithFunds(user string, stock string, cost decimal.Decimal, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	cost = cost.Truncate(2)
+	if acc.funds.LessThan(cost) {
+		return ErrInsufficientFunds
+	}
+	acc.funds = acc.funds.Sub(cost)
+	acc.buyOrders = append(acc.buyOrders, encodeOrder(stock, cost, shares, time.Now()))
+	db.record(acc, stock, cost.Neg(), 0, orderPrice(cost, shares))
+	return nil
+}
+
+// PushSellWithStock removes shares from the user's stock account and records the pending sell
+func (db *MemoryDatabase) PushSellWithStock(user string, stock string, cost decimal.Decimal, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	if acc.stocks[stock] < shares {
+		return ErrInsufficientStock
+	}
+	acc.stocks[stock] -= shares
+	acc.sellOrders = append(acc.sellOrders, encodeOrder(stock, cost, shares, time.Now()))
+	db.record(acc, stock, decimal.Zero, -shares, orderPrice(cost, shares))
+	return nil
+}
+
+// CommitBuyOrder pops the user's most recent buy and credits its shares.
+// An expired buy is refunded instead and ErrOrderExpired is returned.
+func (db *MemoryDatabase) CommitBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := popLast(&acc.buyOrders)
+	if !ok {
+		return stock, cost, shares, ErrNoPendingOrder
+	}
+	stock, cost, shares = decodeOrder(order)
+	if orderCreated(order).Before(orderCutoff(time.Now())) {
+		acc.funds = acc.funds.Add(cost)
+		db.record(acc, stock, cost, 0, orderPrice(cost, shares))
+		return stock, cost, shares, ErrOrderExpired
+	}
+	acc.stocks[stock] += shares
+	db.record(acc, stock, decimal.Zero, shares, orderPrice(cost, shares))
+	return stock, cost, shares, nil
+}
+
+// CancelBuyOrder pops the user's most recent buy and refunds its cost
+func (db *MemoryDatabase) CancelBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := popLast(&acc.buyOrders)
+	if !ok {
+		return stock, cost, shares, ErrNoPendingOrder
+	}
+	stock, cost, shares = decodeOrder(order)
+	acc.funds = acc.funds.Add(cost)
+	db.record(acc, stock, cost, 0, orderPrice(cost, shares))
+	return stock, cost, shares, nil
+}
+
+// CommitSellOrder pops the user's most recent sell and credits its proceeds.
+// An expired sell has its shares returned instead and ErrOrderExpired is returned.
+func (db *MemoryDatabase) CommitSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := popLast(&acc.sellOrders)
+	if !ok {
+		return stock, cost, shares, ErrNoPendingOrder
+	}
+	stock, cost, shares = decodeOrder(order)
+	if orderCreated(order).Before(orderCutoff(time.Now())) {
+		acc.stocks[stock] += shares
+		db.record(acc, stock, decimal.Zero, shares, orderPrice(cost, shares))
+		return stock, cost, shares, ErrOrderExpired
+	}
+	acc.funds = acc.funds.Add(cost)
+	db.record(acc, stock, cost, 0, orderPrice(cost, shares))
+	return stock, cost, shares, nil
+}
+
+// ExpireOrders removes every pending order created more than PendingOrderTimeout
+// before now, returning its funds or shares to the user.
+func (db *MemoryDatabase) ExpireOrders(now time.Time) ([]Order, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	cutoff := orderCutoff(now)
+	var expired []Order
+	for user, acc := range db.users {
+		for len(acc.buyOrders) > 0 && orderCreated(acc.buyOrders[0]).Before(cutoff) {
+			order := decodeFullOrder(user, "Buy", acc.buyOrders[0])
+			acc.buyOrders = acc.buyOrders[1:]
+			acc.funds = acc.funds.Add(order.Cost)
+			acc.history = append(acc.history, newHistoryEntry(0, "CANCEL_BUY", order.Stock,
+				order.Cost, 0, orderPrice(order.Cost, order.Shares), now))
+			expired = append(expired, order)
+		}
+		for len(acc.sellOrders) > 0 && orderCreated(acc.sellOrders[0]).Before(cutoff) {
+			order := decodeFullOrder(user, "Sell", acc.sellOrders[0])
+			acc.sellOrders = acc.sellOrders[1:]
+			acc.stocks[order.Stock] += order.Shares
+			acc.history = append(acc.history, newHistoryEntry(0, "CANCEL_SELL", order.Stock,
+				decimal.Zero, order.Shares, orderPrice(order.Cost, order.Shares), now))
+			expired = append(expired, order)
+		}
+	}
+	return expired, nil
+}
+
+// CancelSellOrder pops the user's most recent sell and returns its shares
+func (db *MemoryDatabase) CancelSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := popLast(&acc.sellOrders)
+	if !ok {
+		return stock, cost, shares, ErrNoPendingOrder
+	}
+	stock, cost, shares = decodeOrder(order)
+	acc.stocks[stock] += shares
+	db.record(acc, stock, decimal.Zero, shares, orderPrice(cost, shares))
+	return stock, cost, shares, nil
+}
+
+// MoveFundsToReserve moves amount dollars from the user's balance into their reserve account
+func (db *MemoryDatabase) MoveFundsToReserve(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	amount = amount.Truncate(2)
+	if acc.funds.LessThan(amount) {
+		return ErrInsufficientFunds
+	}
+	acc.funds = acc.funds.Sub(amount)
+	acc.reservedFunds = acc.reservedFunds.Add(amount)
+	db.record(acc, "", amount.Neg(), 0, decimal.Zero)
+	return nil
+}
+
+// MoveReserveToFunds moves amount dollars from the user's reserve account back into their balance
+func (db *MemoryDatabase) MoveReserveToFunds(user string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	amount = amount.Truncate(2)
+	if acc.reservedFunds.LessThan(amount) {
+		return ErrInsufficientReserve
+	}
+	acc.reservedFunds = acc.reservedFunds.Sub(amount)
+	acc.funds = acc.funds.Add(amount)
+	db.record(acc, "", amount, 0, decimal.Zero)
+	return nil
+}
+
+// MoveStockToReserve moves shares of stock from the user's account into their reserve account
+func (db *MemoryDatabase) MoveStockToReserve(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	if acc.stocks[stock] < shares {
+		return ErrInsufficientStock
+	}
+	acc.stocks[stock] -= shares
+	acc.reservedStock[stock] += shares
+	db.record(acc, stock, decimal.Zero, -shares, decimal.Zero)
+	return nil
+}
+
+// MoveReserveToStock moves shares of stock from the user's reserve account back into their account
+func (db *MemoryDatabase) MoveReserveToStock(user string, stock string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	if acc.reservedStock[stock] < shares {
+		return ErrInsufficientReserve
+	}
+	acc.reservedStock[stock] -= shares
+	acc.stocks[stock] += shares
+	db.record(acc, stock, decimal.Zero, shares, decimal.Zero)
+	return nil
+}
+
+// ReserveBuyTrigger moves amount dollars into the user's reserve account held for the buy trigger on stock with id
+func (db *MemoryDatabase) ReserveBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	field := triggerField(stock, id)
+	if _, ok := acc.buyTriggers[field]; ok {
+		return ErrTriggerExists
+	}
+	amount = amount.Truncate(2)
+	if acc.funds.LessThan(amount) {
+		return ErrInsufficientFunds
+	}
+	acc.funds = acc.funds.Sub(amount)
+	acc.reservedFunds = acc.reservedFunds.Add(amount)
+	acc.buyTriggers[field] = amount
+	db.record(acc, stock, amount.Neg(), 0, decimal.Zero)
+	return nil
+}
+
+// ReleaseBuyTrigger returns the dollars held for the user's buy trigger on stock with id to their balance
+func (db *MemoryDatabase) ReleaseBuyTrigger(user string, stock string, id string) (decimal.Decimal, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	field := triggerField(stock, id)
+	amount, ok := acc.buyTriggers[field]
+	if !ok {
+		return decimal.Zero, ErrNoTrigger
+	}
+	if acc.reservedFunds.LessThan(amount) {
+		return decimal.Zero, ErrInsufficientReserve
+	}
+	acc.reservedFunds = acc.reservedFunds.Sub(amount)
+	acc.funds = acc.funds.Add(amount)
+	delete(acc.buyTriggers, field)
+	db.record(acc, stock, amount, 0, decimal.Zero)
+	return amount, nil
+}
+
+// ReserveSellTrigger moves shares of stock into the user's reserve account held for the sell trigger with id
+func (db *MemoryDatabase) ReserveSellTrigger(user string, stock string, id string, shares int64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	field := triggerField(stock, id)
+	if _, ok := acc.sellTriggers[field]; ok {
+		return ErrTriggerExists
+	}
+	if acc.stocks[stock] < shares {
+		return ErrInsufficientStock
+	}
+	acc.stocks[stock] -= shares
+	acc.reservedStock[stock] += shares
+	acc.sellTriggers[field] = shares
+	db.record(acc, stock, decimal.Zero, -shares, decimal.Zero)
+	return nil
+}
+
+// ReleaseSellTrigger returns the shares held for the user's sell trigger on stock with id to their account
+func (db *MemoryDatabase) ReleaseSellTrigger(user string, stock string, id string) (int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	field := triggerField(stock, id)
+	shares, ok := acc.sellTriggers[field]
+	if !ok {
+		return 0, ErrNoTrigger
+	}
+	if acc.reservedStock[stock] < shares {
+		return 0, ErrInsufficientReserve
+	}
+	acc.reservedStock[stock] -= shares
+	acc.stocks[stock] += shares
+	delete(acc.sellTriggers, field)
+	db.record(acc, stock, decimal.Zero, shares, decimal.Zero)
+	return shares, nil
+}
+
+// processed reports whether the trigger with id has already been executed.
+// The caller must hold db.lock.
+func (db *MemoryDatabase) processed(id string) bool {
+	executed, ok := db.processedTriggers[id]
+	return ok && time.Since(executed) < ProcessedTriggerTTL
+}
+
+// ExecuteBuyTrigger settles a fired buy trigger with the funds recorded for it
+func (db *MemoryDatabase) ExecuteBuyTrigger(user string, stock string, triggerID string,
+	price decimal.Decimal) (reserved decimal.Decimal, shares int64, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.processed(triggerID) {
+		return decimal.Zero, 0, ErrTriggerProcessed
+	}
+	acc := db.account(user)
+	field := triggerField(stock, triggerID)
+	reserved, ok := acc.buyTriggers[field]
+	if !ok {
+		return decimal.Zero, 0, ErrNoTrigger
+	}
+	if acc.reservedFunds.LessThan(reserved) {
+		return decimal.Zero, 0, ErrInsufficientReserve
+	}
+	price = price.Truncate(2)
+	shares = reserved.Div(price).IntPart()
+	refund := reserved.Sub(price.Mul(decimal.New(shares, 0)))
+	acc.reservedFunds = acc.reservedFunds.Sub(reserved)
+	acc.funds = acc.funds.Add(refund)
+	acc.stocks[stock] += shares
+	delete(acc.buyTriggers, field)
+	db.processedTriggers[triggerID] = time.Now()
+	db.record(acc, stock, refund, shares, price)
+	return reserved, shares, nil
+}
+
+// ExecuteSellTrigger settles a fired sell trigger with the shares recorded for it
+func (db *MemoryDatabase) ExecuteSellTrigger(user string, stock string, triggerID string,
+	price decimal.Decimal) (int64, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.processed(triggerID) {
+		return 0, ErrTriggerProcessed
+	}
+	acc := db.account(user)
+	field := triggerField(stock, triggerID)
+	shares, ok := acc.sellTriggers[field]
+	if !ok {
+		return 0, ErrNoTrigger
+	}
+	if acc.reservedStock[stock] < shares {
+		return 0, ErrInsufficientReserve
+	}
+	price = price.Truncate(2)
+	proceeds := price.Mul(decimal.New(shares, 0))
+	acc.reservedStock[stock] -= shares
+	acc.funds = acc.funds.Add(proceeds)
+	delete(acc.sellTriggers, field)
+	db.processedTriggers[triggerID] = time.Now()
+	db.record(acc, stock, proceeds, 0, price)
+	return shares, nil
+}
+
+// sortedLimitOrders returns the account's limit orders oldest first.
+// The caller must hold db.lock.
+func (acc *memoryAccount) sortedLimitOrders() []LimitOrder {
+	orders := make([]LimitOrder, 0, len(acc.limitOrders))
+	for _, order := range acc.limitOrders {
+		orders = append(orders, order)
+	}
+	sortLimitOrders(orders)
+	return orders
+}
+
+// PlaceLimitOrder moves the order's funds or shares into the user's reserve
+// account and records the order under its ID
+func (db *MemoryDatabase) PlaceLimitOrder(order LimitOrder) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(order.User)
+	order.Funds = limitOrderFunds(order.Side, order.Shares, order.Price)
+	if order.Side == "BUY" {
+		if acc.funds.LessThan(order.Funds) {
+			return ErrInsufficientFunds
+		}
+		acc.funds = acc.funds.Sub(order.Funds)
+		acc.reservedFunds = acc.reservedFunds.Add(order.Funds)
+		db.record(acc, order.Stock, order.Funds.Neg(), 0, decimal.Zero)
+	} else {
+		if acc.stocks[order.Stock] < order.Shares {
+			return ErrInsufficientStock
+		}
+		acc.stocks[order.Stock] -= order.Shares
+		acc.reservedStock[order.Stock] += order.Shares
+		db.record(acc, order.Stock, decimal.Zero, -order.Shares, decimal.Zero)
+	}
+	acc.limitOrders[order.ID] = order
+	return nil
+}
+
+// GetLimitOrder returns one of the user's limit orders, or ErrNoLimitOrder
+func (db *MemoryDatabase) GetLimitOrder(user string, id string) (LimitOrder, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	order, ok := db.account(user).limitOrders[id]
+	if !ok {
+		return LimitOrder{}, ErrNoLimitOrder
+	}
+	return order, nil
+}
+
+// GetLimitOrders returns every limit order the user has waiting, oldest first
+func (db *MemoryDatabase) GetLimitOrders(user string) ([]LimitOrder, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	return db.account(user).sortedLimitOrders(), nil
+}
+
+// AmendLimitOrder changes the shares and price of a limit order, moving the
+// difference in what it holds between the user's account and their reserve
+func (db *MemoryDatabase) AmendLimitOrder(user string, id string, shares int64,
+	price decimal.Decimal) (LimitOrder, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := acc.limitOrders[id]
+	if !ok {
+		return LimitOrder{}, ErrNoLimitOrder
+	}
+	funds := limitOrderFunds(order.Side, shares, price)
+	if order.Side == "BUY" {
+		change := funds.Sub(order.Funds)
+		if acc.funds.LessThan(change) {
+			return LimitOrder{}, ErrInsufficientFunds
+		}
+		acc.funds = acc.funds.Sub(change)
+		acc.reservedFunds = acc.reservedFunds.Add(change)
+		if !change.IsZero() {
+			db.record(acc, order.Stock, change.Neg(), 0, decimal.Zero)
+		}
+	} else {
+		change := shares - order.Shares
+		if acc.stocks[order.Stock] < change {
+			return LimitOrder{}, ErrInsufficientStock
+		}
+		acc.stocks[order.Stock] -= change
+		acc.reservedStock[order.Stock] += change
+		if change != 0 {
+			db.record(acc, order.Stock, decimal.Zero, -change, decimal.Zero)
+		}
+	}
+	order.Shares = shares
+	order.Price = price.Truncate(2)
+	order.Funds = funds
+	acc.limitOrders[id] = order
+	return order, nil
+}
+
+// ReleaseLimitOrder returns the funds or shares held for a limit order to the
+// user's account and removes the order
+func (db *MemoryDatabase) ReleaseLimitOrder(user string, id string) (LimitOrder, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	acc := db.account(user)
+	order, ok := acc.limitOrders[id]
+	if !ok {
+		return LimitOrder{}, ErrNoLimitOrder
+	}
+	if order.Side == "BUY" {
+		if acc.reservedFunds.LessThan(order.Funds) {
+			return LimitOrder{}, ErrInsufficientReserve
+		}
+		acc.reservedFunds = acc.reservedFunds.Sub(order.Funds)
+		acc.funds = acc.funds.Add(order.Funds)
+		db.record(acc, order.Stock, order.Funds, 0, decimal.Zero)
+	} else {
+		if acc.reservedStock[order.Stock] < order.Shares {
+			return LimitOrder{}, ErrInsufficientReserve
+		}
+		acc.reservedStock[order.Stock] -= order.Shares
+		acc.stocks[order.Stock] += order.Shares
+		db.record(acc, order.Stock, decimal.Zero, order.Shares, decimal.Zero)
+	}
+	delete(acc.limitOrders, id)
+	return order, nil
+}
+
+// ExecuteLimitOrder fills a limit order at price and removes it, returning the
+// order with the shares filled and the funds they cost or raised. A leg of an
+// OCO group or bracket fills the group, as RedisDatabase.ExecuteLimitOrder does.
+func (db *MemoryDatabase) ExecuteLimitOrder(user string, id string, price decimal.Decimal) (order LimitOrder,
+	shares int64, amount decimal.Decimal, err error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.processed(id) {
+		return order, 0, decimal.Zero, ErrTriggerProcessed
+	}
+	acc := db.account(user)
+	orderID, leg := SplitLegID(id)
+	order, ok := acc.limitOrders[orderID]
+	if !ok || (leg == LegEntry && order.Side != "BUY") || (leg != "" && leg != LegEntry && order.Side != "SELL") {
+		return order, 0, decimal.Zero, ErrNoLimitOrder
+	}
+	shares, amount = limitOrderFill(order, price)
+	if leg == LegEntry {
+		if acc.reservedFunds.LessThan(order.Funds) {
+			return order, 0, decimal.Zero, ErrInsufficientReserve
+		}
+		refund := order.Funds.Sub(amount)
+		acc.reservedFunds = acc.reservedFunds.Sub(order.Funds)
+		acc.funds = acc.funds.Add(refund)
+		acc.reservedStock[order.Stock] += shares
+		db.record(acc, order.Stock, refund, 0, price.Truncate(2))
+		order.Side = "SELL"
+		order.Shares = shares
+		order.Price = price.Truncate(2)
+		order.Funds = decimal.Zero
+		acc.limitOrders[orderID] = order
+		db.processedTriggers[id] = time.Now()
+		return order, shares, amount, nil
+	}
+	if order.Side == "BUY" {
+		if acc.reservedFunds.LessThan(order.Funds) {
+			return order, 0, decimal.Zero, ErrInsufficientReserve
+		}
+		refund := order.Funds.Sub(amount)
+		acc.reservedFunds = acc.reservedFunds.Sub(order.Funds)
+		acc.funds = acc.funds.Add(refund)
+		acc.stocks[order.Stock] += shares
+		db.record(acc, order.Stock, refund, shares, price.Truncate(2))
+	} else {
+		if acc.reservedStock[order.Stock] < shares {
+			return order, 0, decimal.Zero, ErrInsufficientReserve
+		}
+		acc.reservedStock[order.Stock] -= shares
+		acc.funds = acc.funds.Add(amount)
+		db.record(acc, order.Stock, amount, 0, price.Truncate(2))
+	}
+	delete(acc.limitOrders, orderID)
+	db.processedTriggers[id] = time.Now()
+	return order, shares, amount, nil
+}
+
+// ExpiredLimitOrders returns every limit order whose time limit has passed by now
+func (db *MemoryDatabase) ExpiredLimitOrders(now time.Time) ([]LimitOrder, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	var expired []LimitOrder
+	for _, acc := range db.users {
+		for _, order := range acc.sortedLimitOrders() {
+			if order.expired(now) {
+				expired = append(expired, order)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// CreateCredentials registers user with a salted password hash, unless they
+// are already registered or already have an account
+func (db *MemoryDatabase) CreateCredentials(user string, passwordHash string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if _, ok := db.credentials[user]; ok {
+		return ErrAccountExists
+	} else if _, ok := db.users[user]; ok {
+		return ErrAccountExists
+	}
+	db.credentials[user] = passwordHash
+	return nil
+}
+
+// GetCredentials returns the password hash user registered with
+func (db *MemoryDatabase) GetCredentials(user string) (string, error) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	hash, ok := db.credentials[user]
+	if !ok {
+		return "", ErrNoAccount
+	}
+	return hash, nil
+}
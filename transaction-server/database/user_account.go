@@ -0,0 +1,158 @@
+package database
+
+import (
+	"sort"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// Account is a structured snapshot of a user's account, the same
+// information GetUserInfo formats as text for DISPLAY_SUMMARY.
+// BuyTriggers holds the funds reserved for the buy triggers on each stock
+// and SellTriggers the shares reserved for the sell triggers on each stock,
+// summed over a stock's triggers, and Triggers lists each trigger by its ID,
+// sorted by stock and ID. LimitOrders are oldest first.
+type Account struct {
+	User           string                     `json:"user"`
+	Funds          decimal.Decimal            `json:"funds"`
+	ReservedFunds  decimal.Decimal            `json:"reservedFunds"`
+	Stocks         map[string]int64           `json:"stocks"`
+	ReservedStocks map[string]int64           `json:"reservedStocks"`
+	BuyOrders      []Order                    `json:"buyOrders"`
+	SellOrders     []Order                    `json:"sellOrders"`
+	BuyTriggers    map[string]decimal.Decimal `json:"buyTriggers"`
+	SellTriggers   map[string]int64           `json:"sellTriggers"`
+	Triggers       []TriggerStatus            `json:"triggers"`
+	LimitOrders    []LimitOrder               `json:"limitOrders"`
+	History        []HistoryEntry             `json:"history"`
+}
+
+// newAccount returns an empty account for user
+func newAccount(user string) Account {
+	return Account{
+		User:           user,
+		Stocks:         make(map[string]int64),
+		ReservedStocks: make(map[string]int64),
+		BuyOrders:      []Order{},
+		SellOrders:     []Order{},
+		BuyTriggers:    make(map[string]decimal.Decimal),
+		SellTriggers:   make(map[string]int64),
+		Triggers:       []TriggerStatus{},
+		LimitOrders:    []LimitOrder{},
+	}
+}
+
+// TriggerStatus is what one of the user's buy or sell triggers has reserved.
+// Funds is set for BUY triggers and Shares for SELL triggers.
+type TriggerStatus struct {
+	Side   string          `json:"side"`
+	Stock  string          `json:"stock"`
+	ID     string          `json:"id"`
+	Funds  decimal.Decimal `json:"funds"`
+	Shares int64           `json:"shares"`
+}
+
+// addBuyTrigger adds the buy trigger recorded under field to the account
+func (a *Account) addBuyTrigger(field string, funds decimal.Decimal) {
+	stock, id := splitTriggerField(field)
+	a.BuyTriggers[stock] = a.BuyTriggers[stock].Add(funds)
+	a.Triggers = append(a.Triggers, TriggerStatus{Side: "BUY", Stock: stock, ID: id, Funds: funds})
+}
+
+// addSellTrigger adds the sell trigger recorded under field to the account
+func (a *Account) addSellTrigger(field string, shares int64) {
+	stock, id := splitTriggerField(field)
+	a.SellTriggers[stock] += shares
+	a.Triggers = append(a.Triggers, TriggerStatus{Side: "SELL", Stock: stock, ID: id, Shares: shares})
+}
+
+// sortTriggers orders Triggers by stock and ID, as they are read from maps
+func (a *Account) sortTriggers() {
+	sort.Slice(a.Triggers, func(i, j int) bool {
+		if a.Triggers[i].Stock != a.Triggers[j].Stock {
+			return a.Triggers[i].Stock < a.Triggers[j].Stock
+		}
+		return a.Triggers[i].ID < a.Triggers[j].ID
+	})
+}
+
+// GetAccount reads all of the user's account in one transaction.
+// Only stocks the user holds shares of are included.
+func (u RedisDatabase) GetAccount(user string) (Account, error) {
+	c := u.DbPool.Get()
+	defer c.Close()
+	c.Send("MULTI")
+	c.Send("GET", user+":Balance")
+	c.Send("GET", user+":BalanceReserve")
+	c.Send("HGETALL", user+":Stocks")
+	c.Send("HGETALL", user+":StocksReserve")
+	c.Send("LRANGE", user+":BuyOrders", 0, -1)
+	c.Send("LRANGE", user+":SellOrders", 0, -1)
+	c.Send("HGETALL", user+":BuyTriggers")
+	c.Send("HGETALL", user+":SellTriggers")
+	c.Send("HVALS", user+":LimitOrders")
+	c.Send("LRANGE", user+":History", 0, SummaryHistorySize-1)
+	values, err := redis.Values(c.Do("EXEC"))
+	if err != nil {
+		return Account{}, err
+	}
+
+	var balance, reserve int64
+	var stocks, stocksReserve, buyTriggers, sellTriggers interface{}
+	var buyOrders, sellOrders, limitOrders, history []string
+	if _, err := redis.Scan(values, &balance, &reserve, &stocks, &stocksReserve,
+		&buyOrders, &sellOrders, &buyTriggers, &sellTriggers, &limitOrders, &history); err != nil {
+		return Account{}, err
+	}
+
+	account := newAccount(user)
+	account.Funds = u.centsToDollar(balance)
+	account.ReservedFunds = u.centsToDollar(reserve)
+	if err := copyShares(account.Stocks, stocks); err != nil {
+		return Account{}, err
+	}
+	if err := copyShares(account.ReservedStocks, stocksReserve); err != nil {
+		return Account{}, err
+	}
+	sellShares, err := redis.Int64Map(sellTriggers, nil)
+	if err != nil {
+		return Account{}, err
+	}
+	for field, shares := range sellShares {
+		account.addSellTrigger(field, shares)
+	}
+	buyCents, err := redis.Int64Map(buyTriggers, nil)
+	if err != nil {
+		return Account{}, err
+	}
+	for field, cents := range buyCents {
+		account.addBuyTrigger(field, u.centsToDollar(cents))
+	}
+	account.sortTriggers()
+	for _, order := range buyOrders {
+		account.BuyOrders = append(account.BuyOrders, decodeFullOrder(user, "Buy", order))
+	}
+	for _, order := range sellOrders {
+		account.SellOrders = append(account.SellOrders, decodeFullOrder(user, "Sell", order))
+	}
+	if account.LimitOrders, err = decodeLimitOrders(limitOrders); err != nil {
+		return Account{}, err
+	}
+	account.History, err = decodeHistory(history)
+	return account, err
+}
+
+// copyShares adds the positive share counts of a HGETALL reply to shares
+func copyShares(shares map[string]int64, reply interface{}) error {
+	counts, err := redis.Int64Map(reply, nil)
+	if err != nil {
+		return err
+	}
+	for stock, count := range counts {
+		if count > 0 {
+			shares[stock] = count
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Errors returned when registering or looking up a user's credentials
+var (
+	ErrAccountExists = errors.New("account already registered")
+	ErrNoAccount     = errors.New("no registered account")
+)
+
+// credentialsKey holds the password hash of a registered user
+func credentialsKey(user string) string {
+	return user + ":Credentials"
+}
+
+// userKeySuffixes are the keys, after the user's name, that make up their account
+var userKeySuffixes = []string{":Balance", ":BalanceReserve", ":Stocks", ":StocksReserve",
+	":BuyOrders", ":SellOrders", ":BuyTriggers", ":SellTriggers", ":LimitOrders", ":History"}
+
+// KEYS: credentials, then each of userKeySuffixes
+// ARGV: password hash
+var createCredentialsScript = redis.NewScript(1+len(userKeySuffixes), `
+if redis.call("EXISTS", unpack(KEYS)) > 0 then
+	return redis.error_reply("ACCOUNT_EXISTS")
+end
+return redis.call("SET", KEYS[1], ARGV[1])
+`)
+
+// CreateCredentials registers user with a salted password hash.
+// Returns ErrAccountExists without changing anything if the user is already
+// registered or already has an account, so registering can't take one over.
+func (u RedisDatabase) CreateCredentials(user string, passwordHash string) error {
+	keysAndArgs := []interface{}{credentialsKey(user)}
+	for _, suffix := range userKeySuffixes {
+		keysAndArgs = append(keysAndArgs, user+suffix)
+	}
+	_, err := u.runScript(createCredentialsScript, append(keysAndArgs, passwordHash)...)
+	return err
+}
+
+// GetCredentials returns the password hash user registered with, or ErrNoAccount
+func (u RedisDatabase) GetCredentials(user string) (string, error) {
+	resp := u.makeQuery(NewQuery("GET", credentialsKey(user)))
+	hash, err := redis.String(resp.r, resp.err)
+	if err != nil && err.Error() == ErrNil.Error() {
+		return "", ErrNoAccount
+	}
+	return hash, err
+}
@@ -13,45 +13,144 @@ import (
 
 var ErrNil = errors.New("redigo: nil returned")
 
+// ErrTimeout is returned when the batch worker doesn't take a query within the
+// database's Timeout. The query was never queued so it has not run. Once it is
+// queued, the pool's read and write timeouts bound the wait, see NewPool.
+var ErrTimeout = errors.New("database request timed out")
+
+// PendingOrderTimeout is how long a BUY or SELL can wait for its COMMIT
+const PendingOrderTimeout = time.Minute
+
+// pendingOrdersKey is a set of every user who may have uncommitted orders
+const pendingOrdersKey = "PendingOrders"
+
+// triggerUsersKey is a set of every user who may have trigger records or limit orders
+const triggerUsersKey = "TriggerUsers"
+
+// TriggerRecord is what a user has set aside in reserve for a buy or sell trigger,
+// or for a limit order, under its ID. Funds is set for BUY triggers and Shares for
+// SELL triggers. Limit and stop orders have their LimitOrder.Action and the shares
+// they are for along with any funds they hold.
+type TriggerRecord struct {
+	User   string
+	Stock  string
+	Action string
+	ID     string
+	Funds  decimal.Decimal
+	Shares int64
+}
+
+// triggerField is the field a trigger's record is kept under in the user's
+// BuyTriggers or SellTriggers hash. A user can have any number of triggers on
+// a stock, each with its own ID.
+func triggerField(stock string, id string) string {
+	return stock + ":" + id
+}
+
+// splitTriggerField returns the stock and trigger ID of a BuyTriggers or
+// SellTriggers field. Records kept before triggers had IDs are just the stock.
+func splitTriggerField(field string) (stock string, id string) {
+	if i := strings.LastIndex(field, ":"); i >= 0 {
+		return field[:i], field[i+1:]
+	}
+	return field, ""
+}
+
+// triggerRecord returns the record of what the limit order holds in reserve
+func (o LimitOrder) triggerRecord() TriggerRecord {
+	return TriggerRecord{User: o.User, Stock: o.Stock, Action: o.Action(), ID: o.ID, Funds: o.Funds,
+		Shares: o.Shares}
+}
+
+// Order is a pending buy or sell waiting to be committed.
+// Type is "Buy" or "Sell".
+type Order struct {
+	User    string          `json:"user"`
+	Type    string          `json:"type"`
+	Stock   string          `json:"stock"`
+	Cost    decimal.Decimal `json:"cost"`
+	Shares  int64           `json:"shares"`
+	Created time.Time       `json:"created"`
+}
+
 // UserDatabase holds all of the supported database commands
 type UserDatabase interface {
 	GetUserInfo(user string) (info string, err error)
+	GetAccount(user string) (Account, error)
 
 	AddFunds(string, decimal.Decimal) error
 	GetFunds(string) (decimal.Decimal, error)
 	RemoveFunds(string, decimal.Decimal) error
 
-	AddStock(user string, stock string, shares decimal.Decimal) error
-	GetStock(user string, stock string) (decimal.Decimal, error)
-	RemoveStock(user string, stock string, amount decimal.Decimal) error
+	AddStock(user string, stock string, shares int64) error
+	GetStock(user string, stock string) (int64, error)
+	RemoveStock(user string, stock string, shares int64) error
 
 	AddReserveFunds(string, decimal.Decimal) error
 	GetReserveFunds(string) (decimal.Decimal, error)
 	RemoveReserveFunds(string, decimal.Decimal) error
 
-	AddReserveStock(user string, stock string, shares decimal.Decimal) error
-	GetReserveStock(user string, stock string) (decimal.Decimal, error)
-	RemoveReserveStock(user string, stock string, amount decimal.Decimal) error
-
-	AddSellTrigger(user string, stock string, shares decimal.Decimal) error
-	RemoveSellTrigger(user string, stock string, shares decimal.Decimal) error
-	AddBuyTrigger(user string, stock string, amount decimal.Decimal) error
-	RemoveBuyTrigger(user string, stock string) error
-
-	PushBuy(user string, stock string, cost decimal.Decimal, shares decimal.Decimal) error
-	PopBuy(user string) (stock string, cost decimal.Decimal, shares decimal.Decimal, err error)
-	PushSell(user string, stock string, cost decimal.Decimal, shares decimal.Decimal) error
-	PopSell(user string) (stock string, cost decimal.Decimal, shares decimal.Decimal, err error)
-
-	DbRequestWorker()
-	MakeDbRequests([]*Query)
+	AddReserveStock(user string, stock string, shares int64) error
+	GetReserveStock(user string, stock string) (int64, error)
+	RemoveReserveStock(user string, stock string, shares int64) error
+
+	AddSellTrigger(user string, stock string, id string, shares int64) error
+	GetSellTrigger(user string, stock string, id string) (int64, error)
+	RemoveSellTrigger(user string, stock string, id string) error
+	AddBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error
+	GetBuyTrigger(user string, stock string, id string) (decimal.Decimal, error)
+	RemoveBuyTrigger(user string, stock string, id string) error
+	GetTriggerRecords() ([]TriggerRecord, error)
+
+	PushBuy(user string, stock string, cost decimal.Decimal, shares int64) error
+	PopBuy(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+	PushSell(user string, stock string, cost decimal.Decimal, shares int64) error
+	PopSell(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+
+	PushBuyWithFunds(user string, stock string, cost decimal.Decimal, shares int64) error
+	PushSellWithStock(user string, stock string, cost decimal.Decimal, shares int64) error
+	CommitBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+	CancelBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+	CommitSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+	CancelSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error)
+
+	ExpireOrders(now time.Time) ([]Order, error)
+
+	MoveFundsToReserve(user string, amount decimal.Decimal) error
+	MoveReserveToFunds(user string, amount decimal.Decimal) error
+	MoveStockToReserve(user string, stock string, shares int64) error
+	MoveReserveToStock(user string, stock string, shares int64) error
+	ExecuteBuyTrigger(user string, stock string, triggerID string, price decimal.Decimal) (reserved decimal.Decimal,
+		shares int64, err error)
+	ExecuteSellTrigger(user string, stock string, triggerID string, price decimal.Decimal) (int64, error)
+	ReserveBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error
+	ReleaseBuyTrigger(user string, stock string, id string) (decimal.Decimal, error)
+	ReserveSellTrigger(user string, stock string, id string, shares int64) error
+	ReleaseSellTrigger(user string, stock string, id string) (int64, error)
+
+	PlaceLimitOrder(order LimitOrder) error
+	GetLimitOrder(user string, id string) (LimitOrder, error)
+	GetLimitOrders(user string) ([]LimitOrder, error)
+	AmendLimitOrder(user string, id string, shares int64, price decimal.Decimal) (LimitOrder, error)
+	ReleaseLimitOrder(user string, id string) (LimitOrder, error)
+	ExecuteLimitOrder(user string, id string, price decimal.Decimal) (order LimitOrder, shares int64,
+		amount decimal.Decimal, err error)
+	ExpiredLimitOrders(now time.Time) ([]LimitOrder, error)
+
+	WithTransaction(transNum int, command string) UserDatabase
+	GetHistory(user string, page int) ([]HistoryEntry, error)
+
+	CreateCredentials(user string, passwordHash string) error
+	GetCredentials(user string) (passwordHash string, err error)
 }
 
 // Typical structure of a redis command
+// Result receives exactly one Response once the batch holding the query runs.
 type Query struct {
 	Command    string
 	UserString string
 	Params     []interface{}
+	Result     chan Response
 }
 
 // Represents a response from a redis database
@@ -62,35 +161,59 @@ type Response struct {
 
 // RedisDatabase holds the address of the redisDB
 type RedisDatabase struct {
-	Addr         string
-	Port         string
-	DbRequests   chan *Query
-	BatchSize    int
-	PollRate     time.Duration
-	BatchResults chan Response
-	DbPool       *redis.Pool
+	Addr       string
+	Port       string
+	DbRequests chan *Query
+	BatchSize  int
+	PollRate   time.Duration
+	Timeout    time.Duration
+	DbPool     *redis.Pool
+
+	// Recorded in the history of every change, see WithTransaction
+	transNum int
+	command  string
+}
+
+// NewQuery builds a query with its own buffered result channel, so the worker
+// can always deliver the reply even if the caller has given up waiting.
+func NewQuery(command string, userString string, params ...interface{}) *Query {
+	return &Query{
+		Command:    command,
+		UserString: userString,
+		Params:     params,
+		Result:     make(chan Response, 1),
+	}
 }
 
 func (u RedisDatabase) getConn() redis.Conn {
-	c, err := redis.Dial(u.Addr, u.Port)
+	c, err := dial(u.Addr, u.Port, u.Timeout)
 	if err != nil {
 		panic(err)
 	}
 	return c
 }
 
-func NewPool(addr string, port string) *redis.Pool {
+// NewPool returns a pool of redis connections whose reads and writes fail after
+// timeout, so a stalled redis fails the queries waiting on it instead of hanging
+// them. A zero timeout waits forever.
+func NewPool(addr string, port string, timeout time.Duration) *redis.Pool {
 	return &redis.Pool{
 		MaxIdle:     100,
 		MaxActive:   0,
 		IdleTimeout: 0,
-		Dial:        func() (redis.Conn, error) { return redis.Dial(addr, port) },
+		Dial:        func() (redis.Conn, error) { return dial(addr, port, timeout) },
 	}
 }
 
+func dial(addr string, port string, timeout time.Duration) (redis.Conn, error) {
+	return redis.Dial(addr, port, redis.DialConnectTimeout(timeout),
+		redis.DialReadTimeout(timeout), redis.DialWriteTimeout(timeout))
+}
+
 // GetUserInfo returns all of a users information in the database
 func (u RedisDatabase) GetUserInfo(user string) (info string, err error) {
 	c := u.DbPool.Get()
+	defer c.Close()
 	c.Send("MULTI")
 	c.Send("GET", user+":Balance")
 	c.Send("HGETALL", user+":Stocks")
@@ -101,12 +224,11 @@ func (u RedisDatabase) GetUserInfo(user string) (info string, err error) {
 	//c.Send("GET", user+":BuyTriggers")
 	c.Send("GET", user+":BalanceReserve")
 	c.Send("HGETALL", user+":StocksReserve")
-	// TODO history
+	c.Send("LRANGE", user+":History", 0, SummaryHistorySize-1)
 	r, err := c.Do("EXEC")
 	if err != nil {
 		return "", err
 	}
-	defer c.Close()
 	userInfo, err := GetUserInfoFromReply(user, r)
 	if err != nil {
 		return "", err
@@ -146,18 +268,15 @@ func (u RedisDatabase) pushOrder(transType string, user string,
 		return errors.New("Bad transaction type of " + transType)
 	}
 
-	query := new(Query)
-	query.Command = "RPUSH"
-	query.UserString = user + accountSuffix
-	query.Params = append(query.Params, encodeOrder(stock, cost, shares))
-	u.DbRequests <- query
-	resp := <-u.BatchResults
+	resp := u.makeQuery(NewQuery("RPUSH", user+accountSuffix, encodeOrder(stock, cost, shares, time.Now())))
 
 	_, err := redis.Int64(resp.r, resp.err)
 	if err != nil && err.Error() != ErrNil.Error() {
 		return err
 	}
-	return nil
+
+	resp = u.makeQuery(NewQuery("SADD", pendingOrdersKey, user))
+	return resp.err
 }
 
 func (u RedisDatabase) popOrder(transType string, user string) (stock string, cost decimal.Decimal, shares int64, err error) {
@@ -169,13 +288,7 @@ func (u RedisDatabase) popOrder(transType string, user string) (stock string, co
 	} else {
 		return stock, cost, shares, errors.New("Bad transaction type of " + transType)
 	}
-	query := new(Query)
-	query.Command = "RPOP"
-	query.UserString = user + accountSuffix
-
-	u.DbRequests <- query
-
-	resp := <-u.BatchResults
+	resp := u.makeQuery(NewQuery("RPOP", user+accountSuffix))
 
 	recv, err := redis.String(resp.r, resp.err)
 	if err != nil && err.Error() == ErrNil.Error() {
@@ -187,15 +300,18 @@ func (u RedisDatabase) popOrder(transType string, user string) (stock string, co
 
 // Encodes a buy or sell order into a string, to be pushed onto the pending orders stack
 // Returns a string following the format of:
-//		"stock:cost:shares"
-func encodeOrder(stock string, cost decimal.Decimal, shares int64) string {
-	return stock + ":" + cost.String() + ":" + strconv.FormatInt(shares, 10)
+//		"stock:cost:shares:created"
+// where created is the unix time in milliseconds
+func encodeOrder(stock string, cost decimal.Decimal, shares int64, created time.Time) string {
+	return stock + ":" + cost.String() + ":" + strconv.FormatInt(shares, 10) +
+		":" + strconv.FormatInt(toMillis(created), 10)
 }
 
 // Performs the opposite of encodeOrder
+// Orders pushed before creation times were recorded only have three fields
 func decodeOrder(order string) (stock string, cost decimal.Decimal, shares int64) {
 	split := strings.Split(order, ":")
-	if len(split) == 3 {
+	if len(split) == 3 || len(split) == 4 {
 		stock = split[0]
 		cost, _ = decimal.NewFromString(split[1])
 		shares, _ = strconv.ParseInt(split[2], 10, 64)
@@ -208,10 +324,59 @@ func decodeOrder(order string) (stock string, cost decimal.Decimal, shares int64
 	return stock, cost, shares
 }
 
+// orderCreated returns when an encoded order was pushed.
+// Orders without a creation time report the zero time, so they always count as expired.
+func orderCreated(order string) time.Time {
+	split := strings.Split(order, ":")
+	if len(split) != 4 {
+		return time.Time{}
+	}
+	millis, err := strconv.ParseInt(split[3], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return fromMillis(millis)
+}
+
+// decodeFullOrder decodes an order into an Order belonging to user
+func decodeFullOrder(user string, transType string, order string) Order {
+	stock, cost, shares := decodeOrder(order)
+	return Order{
+		User:    user,
+		Type:    transType,
+		Stock:   stock,
+		Cost:    cost,
+		Shares:  shares,
+		Created: orderCreated(order),
+	}
+}
+
+// orderCutoff is the creation time before which a pending order has expired
+func orderCutoff(now time.Time) time.Time {
+	return now.Add(-PendingOrderTimeout)
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
+
 // AddFunds adds amount dollars to the user account
 func (u RedisDatabase) AddFunds(user string, amount decimal.Decimal) error {
 	_, err := u.fundAction("Add", user, ":Balance", amount)
-	return err
+	if err != nil {
+		return err
+	}
+	return u.recordHistory(user, "", amount, 0, decimal.Zero)
 }
 
 // GetFunds returns the amount of available funds in a users account
@@ -224,7 +389,10 @@ func (u RedisDatabase) GetFunds(user string) (decimal.Decimal, error) {
 // amount is the absolute value of the funds being removed
 func (u RedisDatabase) RemoveFunds(user string, amount decimal.Decimal) error {
 	_, err := u.fundAction("Remove", user, ":Balance", amount)
-	return err
+	if err != nil {
+		return err
+	}
+	return u.recordHistory(user, "", amount.Neg(), 0, decimal.Zero)
 }
 
 // AddReserveFunds adds funds to a user's reserve account
@@ -260,15 +428,12 @@ func (u RedisDatabase) fundAction(action string, user string,
 		return decimal.Decimal{}, errors.New("Bad action attempt on funds")
 	}
 
-	query := new(Query)
-	query.Command = command
-	query.UserString = user + accountSuffix
+	query := NewQuery(command, user+accountSuffix)
 	if action != "Get" {
 		query.Params = append(query.Params, u.dollarToCents(amount))
 	}
 
-	u.DbRequests <- query
-	resp := <-u.BatchResults
+	resp := u.makeQuery(query)
 
 	r, err := redis.Int64(resp.r, resp.err)
 	if err != nil && err.Error() == ErrNil.Error() {
@@ -287,13 +452,19 @@ func (u RedisDatabase) GetStock(user string, stock string) (int64, error) {
 // Send the absolute value of the stock being removed
 func (u RedisDatabase) RemoveStock(user string, stock string, shares int64) error {
 	_, err := u.stockAction("Remove", user, ":Stocks", stock, shares)
-	return err
+	if err != nil {
+		return err
+	}
+	return u.recordHistory(user, stock, decimal.Zero, -shares, decimal.Zero)
 }
 
 // AddStock adds shares to the user account
 func (u RedisDatabase) AddStock(user string, stock string, shares int64) error {
 	_, err := u.stockAction("Add", user, ":Stocks", stock, shares)
-	return err
+	if err != nil {
+		return err
+	}
+	return u.recordHistory(user, stock, decimal.Zero, shares, decimal.Zero)
 }
 
 // AddReserveStock adds n shares of stock to a user's account
@@ -328,29 +499,117 @@ func (u RedisDatabase) stockAction(action string, user string,
 		return 0, errors.New("Bad action attempt on stocks")
 	}
 
-	query := new(Query)
-	query.Command = command
-	query.UserString = user + accountSuffix
-	query.Params = append(query.Params, stock)
+	query := NewQuery(command, user+accountSuffix, stock)
 	if action != "Get" {
 		query.Params = append(query.Params, amount)
 	}
 
-	u.DbRequests <- query
-
-	var r int64
-	var err error
-	resp := <-u.BatchResults
+	resp := u.makeQuery(query)
 
+	r, err := redis.Int64(resp.r, resp.err)
+	if err != nil && err.Error() == ErrNil.Error() {
+		err = nil
+	}
 	if action == "Get" {
-		r, err = redis.Int64(resp.r, resp.err)
-		if err != nil && err.Error() == ErrNil.Error() {
-			err = nil
-		}
-		return r, nil
+		return r, err
+	}
+	return 0, err
+}
+
+// AddBuyTrigger records the dollar amount set aside for a user's buy trigger on stock with id
+func (u RedisDatabase) AddBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error {
+	resp := u.makeQuery(NewQuery("HSET", user+":BuyTriggers", triggerField(stock, id), u.dollarToCents(amount)))
+	if resp.err != nil {
+		return resp.err
+	}
+	resp = u.makeQuery(NewQuery("SADD", triggerUsersKey, user))
+	return resp.err
+}
+
+// GetBuyTrigger returns the dollar amount set aside for a user's buy trigger on stock with id
+func (u RedisDatabase) GetBuyTrigger(user string, stock string, id string) (decimal.Decimal, error) {
+	resp := u.makeQuery(NewQuery("HGET", user+":BuyTriggers", triggerField(stock, id)))
+	r, err := redis.Int64(resp.r, resp.err)
+	if err != nil && err.Error() == ErrNil.Error() {
+		err = nil
+	}
+	return u.centsToDollar(r), err
+}
+
+// RemoveBuyTrigger removes the record of a user's buy trigger on stock with id
+func (u RedisDatabase) RemoveBuyTrigger(user string, stock string, id string) error {
+	resp := u.makeQuery(NewQuery("HDEL", user+":BuyTriggers", triggerField(stock, id)))
+	return resp.err
+}
+
+// AddSellTrigger records the shares set aside for a user's sell trigger on stock with id
+func (u RedisDatabase) AddSellTrigger(user string, stock string, id string, shares int64) error {
+	resp := u.makeQuery(NewQuery("HSET", user+":SellTriggers", triggerField(stock, id), shares))
+	if resp.err != nil {
+		return resp.err
+	}
+	resp = u.makeQuery(NewQuery("SADD", triggerUsersKey, user))
+	return resp.err
+}
+
+// GetSellTrigger returns the shares set aside for a user's sell trigger on stock with id
+func (u RedisDatabase) GetSellTrigger(user string, stock string, id string) (int64, error) {
+	resp := u.makeQuery(NewQuery("HGET", user+":SellTriggers", triggerField(stock, id)))
+	r, err := redis.Int64(resp.r, resp.err)
+	if err != nil && err.Error() == ErrNil.Error() {
+		err = nil
+	}
+	return r, err
+}
+
+// RemoveSellTrigger removes the record of a user's sell trigger on stock with id
+func (u RedisDatabase) RemoveSellTrigger(user string, stock string, id string) error {
+	resp := u.makeQuery(NewQuery("HDEL", user+":SellTriggers", triggerField(stock, id)))
+	return resp.err
+}
+
+// GetTriggerRecords returns the buy and sell trigger records and the limit orders of every user
+func (u RedisDatabase) GetTriggerRecords() ([]TriggerRecord, error) {
+	resp := u.makeQuery(NewQuery("SMEMBERS", triggerUsersKey))
+	users, err := redis.Strings(resp.r, resp.err)
+	if err != nil {
+		return nil, err
 	}
 
-	return 0, nil
+	var records []TriggerRecord
+	for _, user := range users {
+		resp = u.makeQuery(NewQuery("HGETALL", user+":BuyTriggers"))
+		buys, err := redis.Int64Map(resp.r, resp.err)
+		if err != nil {
+			return records, err
+		}
+		resp = u.makeQuery(NewQuery("HGETALL", user+":SellTriggers"))
+		sells, err := redis.Int64Map(resp.r, resp.err)
+		if err != nil {
+			return records, err
+		}
+		limitOrders, err := u.GetLimitOrders(user)
+		if err != nil {
+			return records, err
+		}
+
+		for field, cents := range buys {
+			stock, id := splitTriggerField(field)
+			records = append(records, TriggerRecord{User: user, Stock: stock, Action: "BUY", ID: id,
+				Funds: u.centsToDollar(cents)})
+		}
+		for field, shares := range sells {
+			stock, id := splitTriggerField(field)
+			records = append(records, TriggerRecord{User: user, Stock: stock, Action: "SELL", ID: id, Shares: shares})
+		}
+		for _, order := range limitOrders {
+			records = append(records, order.triggerRecord())
+		}
+		if len(buys) == 0 && len(sells) == 0 && len(limitOrders) == 0 {
+			u.makeQuery(NewQuery("SREM", triggerUsersKey, user))
+		}
+	}
+	return records, nil
 }
 
 // DeleteKey deletes a key in the database
@@ -361,6 +620,28 @@ func (u RedisDatabase) DeleteKey(key string) {
 	conn.Close()
 }
 
+// makeQuery hands the query to the batch worker and waits for its reply.
+// Timeout only bounds the wait for a place in the worker's queue: once the
+// worker has the query it will be sent to redis, so giving up on the reply
+// would report a failure for a write that still happens. If redis stalls, the
+// pool's read and write timeouts fail the worker's batch instead.
+func (u RedisDatabase) makeQuery(query *Query) Response {
+	var timeout <-chan time.Time
+	if u.Timeout > 0 {
+		timer := time.NewTimer(u.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case u.DbRequests <- query:
+	case <-timeout:
+		return Response{nil, ErrTimeout}
+	}
+
+	return <-query.Result
+}
+
 func (u RedisDatabase) DbRequestWorker() {
 	reqQue := []*Query{}
 	for {
@@ -380,8 +661,10 @@ func (u RedisDatabase) DbRequestWorker() {
 				u.PollRate = u.PollRate / 2
 			}
 
-			u.MakeDbRequests(reqQue)
-			reqQue = nil
+			if len(reqQue) > 0 {
+				u.MakeDbRequests(reqQue)
+				reqQue = nil
+			}
 		}
 	}
 }
@@ -391,25 +674,37 @@ func (u RedisDatabase) MakeDbRequests(requestQue []*Query) {
 	conn := u.DbPool.Get()
 	defer conn.Close()
 	for _, query := range requestQue {
-		if len(query.Params) == 0 {
-			conn.Send(query.Command, query.UserString)
-		} else if len(query.Params) == 1 {
-			conn.Send(query.Command, query.UserString, query.Params[0])
-		} else if len(query.Params) == 2 {
-			conn.Send(query.Command, query.UserString, query.Params[0], query.Params[1])
-		} else {
-			// Should never happen...
-			panic("More params then 3!")
+		args := append([]interface{}{query.UserString}, query.Params...)
+		err := conn.Send(query.Command, args...)
+		if err != nil {
+			// Nothing in the batch will be answered, fail every query
+			u.failDbRequests(requestQue, err)
+			return
 		}
 	}
-	conn.Flush()
+	err := conn.Flush()
+	if err != nil {
+		u.failDbRequests(requestQue, err)
+		return
+	}
 
-	for i := 0; i < len(requestQue); i++ {
+	// Replies arrive in the order the queries were sent
+	for i, query := range requestQue {
 		r, err := conn.Receive()
-		// Recieve results from queries
-		resp := Response{r, err}
-		// Notify waiting processes of batch execution
-		u.BatchResults <- resp
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				// The connection is broken so the rest of the replies are lost
+				u.failDbRequests(requestQue[i:], err)
+				return
+			}
+		}
+		query.Result <- Response{r, err}
+	}
+}
+
+func (u RedisDatabase) failDbRequests(requestQue []*Query, err error) {
+	for _, query := range requestQue {
+		query.Result <- Response{nil, err}
 	}
 }
 
@@ -2,13 +2,17 @@ package database
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 func TestAddUser(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
+	db := newTestDatabase()
 	_, err := db.GetUserInfo("AAA")
 	if err != nil {
 		t.Error(err)
@@ -16,7 +20,7 @@ func TestAddUser(t *testing.T) {
 }
 
 func TestAddFunds(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
+	db := newTestDatabase()
 	dollar, err := decimal.NewFromString("23.01")
 	err2 := db.AddFunds("AAA", dollar)
 	if err != nil || err2 != nil {
@@ -26,7 +30,7 @@ func TestAddFunds(t *testing.T) {
 }
 
 func TestGetUserInfo(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
+	db := newTestDatabase()
 	dollar, _ := decimal.NewFromString("23.01")
 	db.AddFunds("AAA", dollar)
 	r, error := db.GetUserInfo("AAA")
@@ -39,7 +43,7 @@ func TestGetUserInfo(t *testing.T) {
 }
 
 func TestRemoveFunds(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
+	db := newTestDatabase()
 	dollar, err := decimal.NewFromString("23.01")
 	err2 := db.AddFunds("F", dollar)
 	if err != nil || err2 != nil {
@@ -55,7 +59,7 @@ func TestRemoveFunds(t *testing.T) {
 }
 
 func TestGetFunds(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
+	db := newTestDatabase()
 	dollar, err := decimal.NewFromString("23.01")
 
 	err2 := db.AddFunds("fundGetter", dollar)
@@ -72,11 +76,11 @@ func TestGetFunds(t *testing.T) {
 }
 
 func TestStocks(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
-	db.AddStock("F", "stockname", decimal.NewFromFloat(22.00))
+	db := newTestDatabase()
+	db.AddStock("F", "stockname", 22)
 
 	amt, err := db.GetStock("F", "stockname")
-	if !amt.Equals(decimal.NewFromFloat(22.00)) {
+	if amt != 22 {
 		t.Error("Wrong value for stocks, should be 22, is ", amt)
 	}
 	if err != nil {
@@ -84,17 +88,17 @@ func TestStocks(t *testing.T) {
 	}
 
 	amt, err = db.GetStock("F", "wrongstockname")
-	if !amt.Equals(decimal.NewFromFloat(0.0)) {
+	if amt != 0 {
 		t.Error("Should get no value for stocks")
 	}
 
-	err = db.RemoveStock("F", "stockname", decimal.NewFromFloat(2.0))
+	err = db.RemoveStock("F", "stockname", 2)
 	if err != nil {
 		t.Error(err)
 	}
 
 	amt, err = db.GetStock("F", "stockname")
-	if !amt.Equals(decimal.NewFromFloat(20.0)) {
+	if amt != 20 {
 		t.Error("Failed to remove stock")
 	} else if err != nil {
 		t.Error(err)
@@ -104,14 +108,557 @@ func TestStocks(t *testing.T) {
 }
 
 func TestOrders(t *testing.T) {
-	db := RedisDatabase{"tcp", ":6379"}
-	err := db.PushSell("SELLER", "AAA", decimal.NewFromFloat(11.11), decimal.NewFromFloat(3))
+	db := newTestDatabase()
+	err := db.PushSell("SELLER", "AAA", decimal.NewFromFloat(11.11), 3)
 	if err != nil {
 		t.Error(err)
 	}
-	err = db.PushSell("SELLER", "BBB", decimal.NewFromFloat(11.11), decimal.NewFromFloat(3))
+	err = db.PushSell("SELLER", "BBB", decimal.NewFromFloat(11.11), 3)
 	if err != nil {
 		t.Error(err)
 	}
 
 }
+
+func newTestDatabase() RedisDatabase {
+	db := RedisDatabase{
+		Addr:       "tcp",
+		Port:       ":6379",
+		DbRequests: make(chan *Query, 1000),
+		BatchSize:  100,
+		PollRate:   20,
+		Timeout:    time.Second * 5,
+		DbPool:     NewPool("tcp", ":6379", time.Second*5),
+	}
+	go db.DbRequestWorker()
+	return db
+}
+
+func TestStalledConnection(t *testing.T) {
+	// Accepts connections but never replies, like a stalled redis
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	db := RedisDatabase{
+		Addr:       "tcp",
+		Port:       listener.Addr().String(),
+		DbRequests: make(chan *Query, 1000),
+		BatchSize:  100,
+		PollRate:   20,
+		Timeout:    time.Millisecond * 100,
+		DbPool:     NewPool("tcp", listener.Addr().String(), time.Millisecond*100),
+	}
+	go db.DbRequestWorker()
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := db.GetFunds("STALLED")
+		done <- err
+	}()
+	go func() {
+		done <- db.ReserveBuyTrigger("STALLED", "ABC", "id1", decimal.NewFromFloat(1.00))
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("A query to a stalled connection should fail")
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("A query to a stalled connection should time out")
+		}
+	}
+}
+
+func TestPushBuyWithFunds(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("ATOMIC", decimal.NewFromFloat(10.00))
+
+	err := db.PushBuyWithFunds("ATOMIC", "ABC", decimal.NewFromFloat(6.00), 3)
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.PushBuyWithFunds("ATOMIC", "ABC", decimal.NewFromFloat(6.00), 3)
+	if err != ErrInsufficientFunds {
+		t.Error("Expected insufficient funds, got ", err)
+	}
+
+	funds, _ := db.GetFunds("ATOMIC")
+	if !funds.Equal(decimal.NewFromFloat(4.00)) {
+		t.Error("Failed buy should not touch the balance, have ", funds)
+	}
+
+	stock, cost, shares, err := db.CommitBuyOrder("ATOMIC")
+	if err != nil || stock != "ABC" || !cost.Equal(decimal.NewFromFloat(6.00)) || shares != 3 {
+		t.Error("Wrong order committed ", stock, cost, shares, err)
+	}
+	held, _ := db.GetStock("ATOMIC", "ABC")
+	if held != 3 {
+		t.Error("Commit should credit 3 shares, have ", held)
+	}
+
+	_, _, _, err = db.CommitBuyOrder("ATOMIC")
+	if err != ErrNoPendingOrder {
+		t.Error("Expected no pending order, got ", err)
+	}
+
+	db.DeleteKey("ATOMIC:Balance")
+	db.DeleteKey("ATOMIC:Stocks")
+	db.DeleteKey("ATOMIC:BuyOrders")
+}
+
+func TestMoveFundsToReserve(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("RESERVER", decimal.NewFromFloat(5.50))
+
+	err := db.MoveFundsToReserve("RESERVER", decimal.NewFromFloat(6.00))
+	if err != ErrInsufficientFunds {
+		t.Error("Expected insufficient funds, got ", err)
+	}
+	err = db.MoveFundsToReserve("RESERVER", decimal.NewFromFloat(5.00))
+	if err != nil {
+		t.Error(err)
+	}
+
+	reserved, _ := db.GetReserveFunds("RESERVER")
+	if !reserved.Equal(decimal.NewFromFloat(5.00)) {
+		t.Error("Reserve should hold 5.00, has ", reserved)
+	}
+
+	// Funds in the reserve without a trigger record can't be settled
+	_, _, err = db.ExecuteBuyTrigger("RESERVER", "ABC", "reserver-trigger", decimal.NewFromFloat(2.25))
+	if err != ErrNoTrigger {
+		t.Error("Expected no trigger, got ", err)
+	}
+	db.MoveReserveToFunds("RESERVER", decimal.NewFromFloat(5.00))
+	db.ReserveBuyTrigger("RESERVER", "ABC", "reserver-trigger", decimal.NewFromFloat(5.00))
+
+	// Bought 2 shares at 2.25, the remaining 0.50 goes back to the balance
+	reserved, shares, err := db.ExecuteBuyTrigger("RESERVER", "ABC", "reserver-trigger", decimal.NewFromFloat(2.25))
+	if err != nil || !reserved.Equal(decimal.NewFromFloat(5.00)) || shares != 2 {
+		t.Error("Expected 2 shares from 5.00, got ", shares, reserved, err)
+	}
+	funds, _ := db.GetFunds("RESERVER")
+	if !funds.Equal(decimal.NewFromFloat(1.00)) {
+		t.Error("Balance should be 1.00, is ", funds)
+	}
+
+	// A redelivered success for the same trigger changes nothing
+	db.AddFunds("RESERVER", decimal.NewFromFloat(5.00))
+	db.MoveFundsToReserve("RESERVER", decimal.NewFromFloat(5.00))
+	_, _, err = db.ExecuteBuyTrigger("RESERVER", "ABC", "reserver-trigger", decimal.NewFromFloat(2.25))
+	if err != ErrTriggerProcessed {
+		t.Error("Expected ErrTriggerProcessed, got ", err)
+	}
+	reserved, _ = db.GetReserveFunds("RESERVER")
+	if !reserved.Equal(decimal.NewFromFloat(5.00)) {
+		t.Error("Reserve should still hold 5.00, has ", reserved)
+	}
+
+	db.DeleteKey(processedTriggerKey("reserver-trigger"))
+	db.DeleteKey("RESERVER:Balance")
+	db.DeleteKey("RESERVER:BalanceReserve")
+	db.DeleteKey("RESERVER:Stocks")
+	db.DeleteKey("RESERVER:History")
+}
+
+// Every caller works on its own key, so any reply routed to the wrong
+// goroutine shows up as a value that doesn't match what it wrote.
+func TestConcurrentQueries(t *testing.T) {
+	db := newTestDatabase()
+	callers := 5000
+
+	var wg sync.WaitGroup
+	for i := 1; i <= callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := "stress" + strconv.Itoa(i)
+			defer db.DeleteKey(user + ":Balance")
+			defer db.DeleteKey(user + ":Stocks")
+
+			err := db.AddFunds(user, decimal.New(int64(i), -2))
+			if err != nil {
+				t.Error(user, err)
+				return
+			}
+			err = db.AddStock(user, "ABC", int64(i))
+			if err != nil {
+				t.Error(user, err)
+				return
+			}
+
+			funds, err := db.GetFunds(user)
+			if err != nil {
+				t.Error(user, err)
+			} else if !funds.Equal(decimal.New(int64(i), -2)) {
+				t.Error(user, "received someone else's funds ", funds)
+			}
+
+			shares, err := db.GetStock(user, "ABC")
+			if err != nil {
+				t.Error(user, err)
+			} else if shares != int64(i) {
+				t.Error(user, "received someone else's shares ", shares)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Interleaved pushes, commits and cancels must each take a distinct order,
+// with the funds of every order either spent on shares or refunded
+func TestConcurrentOrders(t *testing.T) {
+	db := newTestDatabase()
+	user := "INTERLEAVE"
+	defer db.DeleteKey(user + ":Balance")
+	defer db.DeleteKey(user + ":Stocks")
+	defer db.DeleteKey(user + ":BuyOrders")
+	defer db.DeleteKey(user + ":History")
+	db.AddFunds(user, decimal.NewFromFloat(1000.00))
+
+	callers := 100
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	pushed, popped := 0, 0
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := db.PushBuyWithFunds(user, "ABC", decimal.NewFromFloat(5.00), 1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if i%2 == 0 {
+				_, _, _, err = db.CommitBuyOrder(user)
+			} else {
+				_, _, _, err = db.CancelBuyOrder(user)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			pushed++
+			if err == nil {
+				popped++
+			} else if err != ErrNoPendingOrder {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for {
+		if _, _, _, err := db.CancelBuyOrder(user); err != nil {
+			break
+		}
+		popped++
+	}
+	if pushed != popped {
+		t.Errorf("%d orders were pushed but %d popped", pushed, popped)
+	}
+	funds, _ := db.GetFunds(user)
+	shares, _ := db.GetStock(user, "ABC")
+	if !funds.Add(decimal.New(shares*5, 0)).Equal(decimal.NewFromFloat(1000.00)) {
+		t.Errorf("Funds %s and %d shares should add up to the 1000 added", funds, shares)
+	}
+}
+
+func TestExpireOrders(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("EXPIRER", decimal.NewFromFloat(10.00))
+	db.PushBuyWithFunds("EXPIRER", "ABC", decimal.NewFromFloat(4.00), 2)
+
+	expired, err := db.ExpireOrders(time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Error("Nothing should expire yet ", expired, err)
+	}
+
+	expired, err = db.ExpireOrders(time.Now().Add(PendingOrderTimeout + time.Second))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(expired) != 1 || expired[0].User != "EXPIRER" || expired[0].Stock != "ABC" || expired[0].Shares != 2 {
+		t.Error("Expected the ABC buy to expire, got ", expired)
+	}
+
+	funds, _ := db.GetFunds("EXPIRER")
+	if !funds.Equal(decimal.NewFromFloat(10.00)) {
+		t.Error("Expired buy should be refunded, balance is ", funds)
+	}
+
+	db.DeleteKey("EXPIRER:Balance")
+}
+
+func TestHistory(t *testing.T) {
+	db := newTestDatabase()
+	db.WithTransaction(1, "ADD").AddFunds("HISTORIAN", decimal.NewFromFloat(10.00))
+	db.WithTransaction(2, "BUY").PushBuyWithFunds("HISTORIAN", "ABC", decimal.NewFromFloat(4.00), 2)
+	db.WithTransaction(3, "CANCEL_BUY").CancelBuyOrder("HISTORIAN")
+
+	history, err := db.GetHistory("HISTORIAN", 0)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(history) != 3 {
+		t.Fatal("Expected 3 history entries, got ", history)
+	}
+	if history[0].TransNum != 3 || history[0].Command != "CANCEL_BUY" || !history[0].Funds.Equal(decimal.NewFromFloat(4.00)) ||
+		!history[0].Price.Equal(decimal.NewFromFloat(2.00)) {
+		t.Error("Unexpected CANCEL_BUY entry ", history[0])
+	}
+	if history[1].Command != "BUY" || !history[1].Funds.Equal(decimal.NewFromFloat(-4.00)) {
+		t.Error("Unexpected BUY entry ", history[1])
+	}
+	if history[2].Command != "ADD" || !history[2].Funds.Equal(decimal.NewFromFloat(10.00)) {
+		t.Error("Unexpected ADD entry ", history[2])
+	}
+
+	history, _ = db.GetHistory("HISTORIAN", 1)
+	if len(history) != 0 {
+		t.Error("Second page should be empty, got ", history)
+	}
+
+	db.DeleteKey("HISTORIAN:Balance")
+	db.DeleteKey("HISTORIAN:History")
+}
+
+func TestReserveBuyTrigger(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("TRIGGERER", decimal.NewFromFloat(10.00))
+
+	err := db.ReserveBuyTrigger("TRIGGERER", "ABC", "low", decimal.NewFromFloat(6.00))
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.ReserveBuyTrigger("TRIGGERER", "ABC", "low", decimal.NewFromFloat(1.00))
+	if err != ErrTriggerExists {
+		t.Error("Expected trigger exists, got ", err)
+	}
+	// Another trigger on the same stock holds its own reserve
+	err = db.ReserveBuyTrigger("TRIGGERER", "ABC", "lower", decimal.NewFromFloat(3.00))
+	if err != nil {
+		t.Error(err)
+	}
+
+	records, _ := db.GetTriggerRecords()
+	found := 0
+	for _, record := range records {
+		if record.User == "TRIGGERER" && record.Stock == "ABC" &&
+			(record.ID == "low" && record.Funds.Equal(decimal.NewFromFloat(6.00)) ||
+				record.ID == "lower" && record.Funds.Equal(decimal.NewFromFloat(3.00))) {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Error("Expected a record for each buy trigger, got ", records)
+	}
+
+	released, err := db.ReleaseBuyTrigger("TRIGGERER", "ABC", "low")
+	if err != nil || !released.Equal(decimal.NewFromFloat(6.00)) {
+		t.Error("Expected 6.00 released, got ", released, err)
+	}
+	_, err = db.ReleaseBuyTrigger("TRIGGERER", "ABC", "low")
+	if err != ErrNoTrigger {
+		t.Error("Expected no trigger, got ", err)
+	}
+	reserved, _ := db.GetReserveFunds("TRIGGERER")
+	if !reserved.Equal(decimal.NewFromFloat(3.00)) {
+		t.Error("The other trigger should still hold 3.00, reserve has ", reserved)
+	}
+	db.ReleaseBuyTrigger("TRIGGERER", "ABC", "lower")
+	funds, _ := db.GetFunds("TRIGGERER")
+	if !funds.Equal(decimal.NewFromFloat(10.00)) {
+		t.Error("Balance should be 10.00, is ", funds)
+	}
+
+	db.DeleteKey("TRIGGERER:Balance")
+	db.DeleteKey("TRIGGERER:BalanceReserve")
+	db.DeleteKey("TRIGGERER:History")
+}
+
+func TestCredentials(t *testing.T) {
+	db := newTestDatabase()
+	db.DeleteKey("REGISTERED:Credentials")
+
+	if _, err := db.GetCredentials("REGISTERED"); err != ErrNoAccount {
+		t.Error("Expected no account, got ", err)
+	}
+	if err := db.CreateCredentials("REGISTERED", "hash1"); err != nil {
+		t.Error(err)
+	}
+	if err := db.CreateCredentials("REGISTERED", "hash2"); err != ErrAccountExists {
+		t.Error("Expected the account to exist, got ", err)
+	}
+	if hash, err := db.GetCredentials("REGISTERED"); err != nil || hash != "hash1" {
+		t.Error("Expected the first hash to be kept, got ", hash, err)
+	}
+
+	// An account that was never registered can't be taken over by registering its name
+	db.DeleteKey("UNREGISTERED:Credentials")
+	db.AddFunds("UNREGISTERED", decimal.NewFromFloat(20.00))
+	if err := db.CreateCredentials("UNREGISTERED", "hash"); err != ErrAccountExists {
+		t.Error("Expected the existing account to be refused, got ", err)
+	}
+	if _, err := db.GetCredentials("UNREGISTERED"); err != ErrNoAccount {
+		t.Error("Expected no credentials to be set, got ", err)
+	}
+
+	db.DeleteKey("REGISTERED:Credentials")
+	db.DeleteKey("UNREGISTERED:Balance")
+	db.DeleteKey("UNREGISTERED:History")
+}
+
+func TestGetAccount(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("SUMMARIZED", decimal.NewFromFloat(20.00))
+	db.AddStock("SUMMARIZED", "ABC", 3)
+	db.PushBuyWithFunds("SUMMARIZED", "XYZ", decimal.NewFromFloat(5.00), 1)
+	db.ReserveBuyTrigger("SUMMARIZED", "DEF", "first", decimal.NewFromFloat(4.00))
+	db.ReserveBuyTrigger("SUMMARIZED", "DEF", "second", decimal.NewFromFloat(2.00))
+
+	account, err := db.GetAccount("SUMMARIZED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.Funds.Equal(decimal.NewFromFloat(9.00)) || !account.ReservedFunds.Equal(decimal.NewFromFloat(6.00)) {
+		t.Error("Unexpected funds ", account.Funds, account.ReservedFunds)
+	}
+	if account.Stocks["ABC"] != 3 {
+		t.Error("Expected 3 ABC, got ", account.Stocks)
+	}
+	if len(account.BuyOrders) != 1 || account.BuyOrders[0].Stock != "XYZ" || account.BuyOrders[0].Created.IsZero() {
+		t.Error("Unexpected buy orders ", account.BuyOrders)
+	}
+	if !account.BuyTriggers["DEF"].Equal(decimal.NewFromFloat(6.00)) {
+		t.Error("Unexpected buy triggers ", account.BuyTriggers)
+	}
+	if len(account.Triggers) != 2 || account.Triggers[0].ID != "first" ||
+		!account.Triggers[0].Funds.Equal(decimal.NewFromFloat(4.00)) || account.Triggers[1].ID != "second" {
+		t.Error("Expected each trigger listed by ID, got ", account.Triggers)
+	}
+	if len(account.History) == 0 {
+		t.Error("Expected recent history")
+	}
+
+	db.ReleaseBuyTrigger("SUMMARIZED", "DEF", "first")
+	db.ReleaseBuyTrigger("SUMMARIZED", "DEF", "second")
+	db.CancelBuyOrder("SUMMARIZED")
+	for _, key := range []string{"Balance", "BalanceReserve", "Stocks", "History", "BuyOrders"} {
+		db.DeleteKey("SUMMARIZED:" + key)
+	}
+}
+
+func TestLimitOrders(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("LIMITER", decimal.NewFromFloat(100.00))
+	db.AddStock("LIMITER", "ABC", 5)
+
+	buy := LimitOrder{ID: "buy1", User: "LIMITER", Stock: "ABC", Side: "BUY", Shares: 4,
+		Price: decimal.NewFromFloat(10.00), TIF: "GTC", Created: time.Now()}
+	if err := db.PlaceLimitOrder(buy); err != nil {
+		t.Fatal(err)
+	}
+	sell := LimitOrder{ID: "sell1", User: "LIMITER", Stock: "ABC", Side: "SELL", Shares: 6,
+		Price: decimal.NewFromFloat(20.00), TIF: "GTC", Created: time.Now()}
+	if err := db.PlaceLimitOrder(sell); err != ErrInsufficientStock {
+		t.Error("Expected insufficient stock, got ", err)
+	}
+	sell.Shares = 5
+	if err := db.PlaceLimitOrder(sell); err != nil {
+		t.Fatal(err)
+	}
+	if reserve, _ := db.GetReserveFunds("LIMITER"); !reserve.Equal(decimal.NewFromFloat(40.00)) {
+		t.Error("Reserve should be 40.00, is ", reserve)
+	}
+
+	amended, err := db.AmendLimitOrder("LIMITER", "buy1", 5, decimal.NewFromFloat(9.00))
+	if err != nil || amended.Shares != 5 || !amended.Funds.Equal(decimal.NewFromFloat(45.00)) {
+		t.Error("Unexpected amended order ", amended, err)
+	}
+	if funds, _ := db.GetFunds("LIMITER"); !funds.Equal(decimal.NewFromFloat(55.00)) {
+		t.Error("Balance should be 55.00, is ", funds)
+	}
+
+	order, shares, amount, err := db.ExecuteLimitOrder("LIMITER", "buy1", decimal.NewFromFloat(9.00))
+	if err != nil || order.ID != "buy1" || shares != 5 || !amount.Equal(decimal.NewFromFloat(45.00)) {
+		t.Error("Unexpected fill ", order, shares, amount, err)
+	}
+	if _, _, _, err = db.ExecuteLimitOrder("LIMITER", "buy1", decimal.NewFromFloat(9.00)); err != ErrTriggerProcessed {
+		t.Error("Expected trigger processed, got ", err)
+	}
+	if held, _ := db.GetStock("LIMITER", "ABC"); held != 5 {
+		t.Error("Expected 5 ABC, got ", held)
+	}
+
+	released, err := db.ReleaseLimitOrder("LIMITER", "sell1")
+	if err != nil || released.Shares != 5 {
+		t.Error("Unexpected released order ", released, err)
+	}
+	if _, err = db.ReleaseLimitOrder("LIMITER", "sell1"); err != ErrNoLimitOrder {
+		t.Error("Expected no limit order, got ", err)
+	}
+	if held, _ := db.GetStock("LIMITER", "ABC"); held != 10 {
+		t.Error("Expected 10 ABC, got ", held)
+	}
+
+	for _, key := range []string{"Balance", "BalanceReserve", "Stocks", "StocksReserve", "LimitOrders", "History"} {
+		db.DeleteKey("LIMITER:" + key)
+	}
+	db.DeleteKey(processedTriggerKey("buy1"))
+}
+
+func TestOrderGroupLegs(t *testing.T) {
+	db := newTestDatabase()
+	db.AddFunds("GROUPER", decimal.NewFromFloat(100.00))
+	exits := &OrderExits{TakeProfit: decimal.NewFromFloat(12.00), StopLoss: decimal.NewFromFloat(8.00)}
+
+	bracket := LimitOrder{ID: "br1", User: "GROUPER", Stock: "ABC", Type: OrderBracket, Side: "BUY", Shares: 4,
+		Price: decimal.NewFromFloat(10.00), Exits: exits, TIF: "GTC", Created: time.Now()}
+	if err := db.PlaceLimitOrder(bracket); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := db.ExecuteLimitOrder("GROUPER", "br1.TP", decimal.NewFromFloat(12.00)); err != ErrNoLimitOrder {
+		t.Error("An exit shouldn't fill before the entry, got ", err)
+	}
+
+	// The entry buys the shares into reserve and leaves the bracket to sell them
+	order, shares, _, err := db.ExecuteLimitOrder("GROUPER", "br1.ENTRY", decimal.NewFromFloat(9.00))
+	if err != nil || shares != 4 || order.Side != "SELL" || order.Shares != 4 || !order.Funds.IsZero() {
+		t.Error("Unexpected entry fill ", order, shares, err)
+	}
+	if funds, _ := db.GetFunds("GROUPER"); !funds.Equal(decimal.NewFromFloat(64.00)) {
+		t.Error("Balance should be 64.00, is ", funds)
+	}
+	if held, _ := db.GetStock("GROUPER", "ABC"); held != 0 {
+		t.Error("The bought shares should be held in reserve, got ", held)
+	}
+
+	order, shares, amount, err := db.ExecuteLimitOrder("GROUPER", "br1.SL", decimal.NewFromFloat(8.00))
+	if err != nil || order.ID != "br1" || shares != 4 || !amount.Equal(decimal.NewFromFloat(32.00)) {
+		t.Error("Unexpected exit fill ", order, shares, amount, err)
+	}
+	if _, _, _, err = db.ExecuteLimitOrder("GROUPER", "br1.TP", decimal.NewFromFloat(12.00)); err != ErrNoLimitOrder {
+		t.Error("The other exit shouldn't fill, got ", err)
+	}
+	if funds, _ := db.GetFunds("GROUPER"); !funds.Equal(decimal.NewFromFloat(96.00)) {
+		t.Error("Balance should be 96.00, is ", funds)
+	}
+
+	for _, key := range []string{"Balance", "BalanceReserve", "Stocks", "StocksReserve", "LimitOrders", "History"} {
+		db.DeleteKey("GROUPER:" + key)
+	}
+	db.DeleteKey(processedTriggerKey("br1.ENTRY"))
+	db.DeleteKey(processedTriggerKey("br1.SL"))
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// HistoryPageSize is the number of entries returned per page of GetHistory
+const HistoryPageSize = 20
+
+// SummaryHistorySize is the number of recent entries included in GetUserInfo
+const SummaryHistorySize = 5
+
+// HistoryEntry is one change to a user's available balance or stock holdings.
+// Funds and Shares are signed deltas. Price is the price per share the change
+// happened at, or zero when no stock was involved.
+// Timestamp is the unix time in milliseconds.
+type HistoryEntry struct {
+	TransNum  int             `json:"transNum"`
+	Command   string          `json:"command"`
+	Stock     string          `json:"stock"`
+	Funds     decimal.Decimal `json:"funds"`
+	Shares    int64           `json:"shares"`
+	Price     decimal.Decimal `json:"price"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// Time returns when the entry was recorded
+func (e HistoryEntry) Time() time.Time {
+	return fromMillis(e.Timestamp)
+}
+
+// String formats the entry as a single line for DISPLAY_SUMMARY and HISTORY
+func (e HistoryEntry) String() string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\t%s\t%d\t%s",
+		e.Time().UTC().Format(time.RFC3339), e.TransNum, e.Command, e.Stock,
+		e.Funds.StringFixed(2), e.Shares, e.Price.StringFixed(2))
+}
+
+func newHistoryEntry(transNum int, command string, stock string, funds decimal.Decimal,
+	shares int64, price decimal.Decimal, now time.Time) HistoryEntry {
+	return HistoryEntry{
+		TransNum:  transNum,
+		Command:   command,
+		Stock:     stock,
+		Funds:     funds.Truncate(2),
+		Shares:    shares,
+		Price:     price.Truncate(2),
+		Timestamp: toMillis(now),
+	}
+}
+
+// orderPrice is the price per share paid for shares costing cost in total
+func orderPrice(cost decimal.Decimal, shares int64) decimal.Decimal {
+	if shares == 0 {
+		return decimal.Zero
+	}
+	return cost.Div(decimal.New(shares, 0))
+}
+
+func encodeHistory(entry HistoryEntry) string {
+	encoded, _ := json.Marshal(entry)
+	return string(encoded)
+}
+
+func decodeHistory(entries []string) ([]HistoryEntry, error) {
+	history := make([]HistoryEntry, 0, len(entries))
+	for _, encoded := range entries {
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(encoded), &entry); err != nil {
+			return history, err
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// historyRange returns the first and last index of a page, newest entries first
+func historyRange(page int) (int, int) {
+	if page < 0 {
+		page = 0
+	}
+	start := page * HistoryPageSize
+	return start, start + HistoryPageSize - 1
+}
+
+// WithTransaction returns a copy of the database that records transNum and
+// command in the history entries of every change it makes
+func (u RedisDatabase) WithTransaction(transNum int, command string) UserDatabase {
+	u.transNum = transNum
+	u.command = command
+	return u
+}
+
+// GetHistory returns a page of the user's history, newest entries first.
+// Page 0 holds the most recent HistoryPageSize entries.
+func (u RedisDatabase) GetHistory(user string, page int) ([]HistoryEntry, error) {
+	start, stop := historyRange(page)
+	resp := u.makeQuery(NewQuery("LRANGE", user+":History", start, stop))
+	entries, err := redis.Strings(resp.r, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHistory(entries)
+}
+
+// historyEntry builds an encoded entry for a change made under the database's transaction
+func (u RedisDatabase) historyEntry(stock string, funds decimal.Decimal, shares int64, price decimal.Decimal) string {
+	return encodeHistory(newHistoryEntry(u.transNum, u.command, stock, funds, shares, price, time.Now()))
+}
+
+// recordHistory appends an entry to the user's history
+func (u RedisDatabase) recordHistory(user string, stock string, funds decimal.Decimal,
+	shares int64, price decimal.Decimal) error {
+	resp := u.makeQuery(NewQuery("LPUSH", user+":History", u.historyEntry(stock, funds, shares, price)))
+	return resp.err
+}
@@ -14,6 +14,7 @@ type UserInfo struct {
 	stock map[string]string
 	sellOrders []string
 	buyOrders []string
+	history []HistoryEntry
 }
 
 func GetUserInfoFromReply(user string, reply interface{}) (UserInfo, error) {
@@ -25,12 +26,13 @@ func GetUserInfoFromReply(user string, reply interface{}) (UserInfo, error) {
 	var stockReserve interface{}
 	var stockMap map[string]string
 	var stockReserveMap map[string]string
+	var history []string
 
 	values, err := redis.Values(reply, nil); if err != nil {
 		return UserInfo{}, err
 	}
 
-	if _, err := redis.Scan(values, &balance, &stock, &sellOrders, &buyOrders, &reservedFunds, &stockReserve); err != nil {
+	if _, err := redis.Scan(values, &balance, &stock, &sellOrders, &buyOrders, &reservedFunds, &stockReserve, &history); err != nil {
 		return UserInfo{}, err
 	}
 
@@ -42,6 +44,10 @@ func GetUserInfoFromReply(user string, reply interface{}) (UserInfo, error) {
 		return UserInfo{}, err
 	}
 
+	historyEntries, err := decodeHistory(history); if err != nil {
+		return UserInfo{}, err
+	}
+
 	return UserInfo{
 		user: user,
 		funds: balance,
@@ -50,6 +56,7 @@ func GetUserInfoFromReply(user string, reply interface{}) (UserInfo, error) {
 		stock: stockMap,
 		sellOrders: sellOrders,
 		buyOrders: buyOrders,
+		history: historyEntries,
 	}, nil
 }
 
@@ -94,6 +101,12 @@ func (info UserInfo) getString() string {
 			str += fmt.Sprintf("\t%s:\t%s;", key, value)
 		}
 	}
+	if len(info.history) > 0 {
+		str += "Recent History:;"
+	}
+	for _, entry := range info.history {
+		str += fmt.Sprintf("\t%s;", entry.String())
+	}
 	str += "\n"
 	return str
 }
\ No newline at end of file
@@ -0,0 +1,442 @@
+package database
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// LimitOrder is a buy or sell of Shares at Price or better, waiting in the
+// triggerserver for the price to reach it. Side is "BUY" or "SELL".
+// A buy holds Funds, the shares at the limit price, in the user's reserve
+// account and a sell holds its shares in the stock reserve account.
+// Expires is zero for an order that is good until cancelled.
+// Stop orders are held the same way, with Type set and Price the stop price.
+// A trailing stop also has a Trail, and its Price is the mark it started from.
+// An OCO group or bracket also has Exits, see OrderOCO and OrderBracket.
+type LimitOrder struct {
+	ID      string          `json:"id"`
+	User    string          `json:"user"`
+	Stock   string          `json:"stock"`
+	Type    string          `json:"type,omitempty"`
+	Side    string          `json:"side"`
+	Shares  int64           `json:"shares"`
+	Price   decimal.Decimal `json:"price"`
+	Trail   string          `json:"trail,omitempty"`
+	Exits   *OrderExits     `json:"exits,omitempty"`
+	Funds   decimal.Decimal `json:"funds"`
+	TIF     string          `json:"tif"`
+	Expires time.Time       `json:"expires"`
+	Created time.Time       `json:"created"`
+}
+
+// Order types of a LimitOrder. An order with no type is a limit order.
+// An OCO group is a SELL of shares held in reserve at its take-profit or its
+// stop-loss, with Price the take-profit. A bracket is a BUY at its entry Price
+// until its entry fills, when it becomes a SELL of the shares it filled.
+const (
+	OrderLimit        = "LIMIT"
+	OrderStop         = "STOP"
+	OrderTrailingStop = "TRAILING_STOP"
+	OrderOCO          = "OCO"
+	OrderBracket      = "BRACKET"
+)
+
+// OrderExits are the prices an OCO group or bracket sells its shares at
+type OrderExits struct {
+	TakeProfit decimal.Decimal `json:"takeProfit"`
+	StopLoss   decimal.Decimal `json:"stopLoss"`
+}
+
+// Legs of an OCO group or bracket. The triggerserver fires each leg with the
+// group's ID followed by the leg, such as "<id>.TP", see SplitLegID.
+const (
+	LegEntry      = "ENTRY"
+	LegTakeProfit = "TP"
+	LegStopLoss   = "SL"
+)
+
+// SplitLegID returns the ID of the order a trigger ID fires, and which leg of
+// it fired if the order is an OCO group or bracket
+func SplitLegID(id string) (order string, leg string) {
+	if i := strings.LastIndex(id, "."); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return id, ""
+}
+
+// IsGroup reports whether the order is an OCO group or bracket
+func (o LimitOrder) IsGroup() bool {
+	return o.Type == OrderOCO || o.Type == OrderBracket
+}
+
+// Action is the triggerserver action of the order, such as LIMIT_BUY or TRAILING_STOP_SELL
+func (o LimitOrder) Action() string {
+	if o.Type == "" {
+		return OrderLimit + "_" + o.Side
+	}
+	return o.Type + "_" + o.Side
+}
+
+// limitOrderFunds returns the funds a buy of shares at price holds in reserve
+func limitOrderFunds(side string, shares int64, price decimal.Decimal) decimal.Decimal {
+	if side != "BUY" {
+		return decimal.Zero
+	}
+	return price.Truncate(2).Mul(decimal.New(shares, 0))
+}
+
+// expired reports whether the order has a time limit that has passed by now
+func (o LimitOrder) expired(now time.Time) bool {
+	return !o.Expires.IsZero() && !o.Expires.After(now)
+}
+
+// limitOrderFill is how much of a limit order was filled at price. A buy fills
+// as many of its shares as its reserve pays for, so a buy amended while it
+// fired can never spend more than it holds.
+func limitOrderFill(order LimitOrder, price decimal.Decimal) (shares int64, amount decimal.Decimal) {
+	price = price.Truncate(2)
+	shares = order.Shares
+	if order.Side == "BUY" {
+		if affordable := order.Funds.Div(price).IntPart(); affordable < shares {
+			shares = affordable
+		}
+	}
+	return shares, price.Mul(decimal.New(shares, 0))
+}
+
+// sortLimitOrders orders limit orders oldest first
+func sortLimitOrders(orders []LimitOrder) {
+	sort.Slice(orders, func(i, j int) bool {
+		if orders[i].Created.Equal(orders[j].Created) {
+			return orders[i].ID < orders[j].ID
+		}
+		return orders[i].Created.Before(orders[j].Created)
+	})
+}
+
+// Lua helpers shared by the limit order scripts, which need luaOrderHelpers too.
+// Orders are stored as the JSON encoding of LimitOrder, so funds and price are decimal strings.
+// move takes amount from one account and puts it in another, where an account
+// is a balance key or a stock hash, and fails with short if the source can't cover it.
+const luaLimitOrderHelpers = `
+local function available(key, stock)
+	if stock then
+		return tonumber(redis.call("HGET", key, stock) or "0")
+	end
+	return tonumber(redis.call("GET", key) or "0")
+end
+local function move(from, to, stock, amount, short)
+	if amount > 0 and available(from, stock) < amount then
+		return redis.error_reply(short)
+	end
+	if stock then
+		redis.call("HINCRBY", from, stock, -amount)
+		redis.call("HINCRBY", to, stock, amount)
+	else
+		redis.call("DECRBY", from, amount)
+		redis.call("INCRBY", to, amount)
+	end
+end
+local function dollars(cents)
+	return string.format("%.2f", cents / 100)
+end
+`
+
+// The limit order scripts share their keys, so a buy and a sell run the same way.
+// KEYS: balance, balance reserve, stocks, stocks reserve, limit orders, trigger users, history
+// ARGV: encoded order, id, side, stock, shares, cents reserved, user, history entry
+var placeLimitOrderScript = redis.NewScript(7, luaOrderHelpers+luaLimitOrderHelpers+`
+local failed
+if ARGV[3] == "BUY" then
+	failed = move(KEYS[1], KEYS[2], nil, tonumber(ARGV[6]), "INSUFFICIENT_FUNDS")
+else
+	failed = move(KEYS[3], KEYS[4], ARGV[4], tonumber(ARGV[5]), "INSUFFICIENT_STOCK")
+end
+if failed then
+	return failed
+end
+redis.call("HSET", KEYS[5], ARGV[2], ARGV[1])
+redis.call("SADD", KEYS[6], ARGV[7])
+return redis.call("LPUSH", KEYS[7], ARGV[8])
+`)
+
+// KEYS: balance, balance reserve, stocks, stocks reserve, limit orders, history
+// ARGV: id, shares, price, funds, transNum, command, now in unix millis
+// Returns the amended order
+var amendLimitOrderScript = redis.NewScript(6, luaOrderHelpers+luaLimitOrderHelpers+`
+local encoded = redis.call("HGET", KEYS[5], ARGV[1])
+if not encoded then
+	return redis.error_reply("NO_LIMIT_ORDER")
+end
+local order = cjson.decode(encoded)
+local failed
+if order.side == "BUY" then
+	local change = tonumber(cents(ARGV[4])) - tonumber(cents(order.funds))
+	failed = move(KEYS[1], KEYS[2], nil, change, "INSUFFICIENT_FUNDS")
+	if not failed and change ~= 0 then
+		record(KEYS[6], ARGV[5], ARGV[6], order.stock, dollars(-change), 0, "0", ARGV[7])
+	end
+else
+	local change = tonumber(ARGV[2]) - order.shares
+	failed = move(KEYS[3], KEYS[4], order.stock, change, "INSUFFICIENT_STOCK")
+	if not failed and change ~= 0 then
+		record(KEYS[6], ARGV[5], ARGV[6], order.stock, "0", -change, "0", ARGV[7])
+	end
+end
+if failed then
+	return failed
+end
+order.shares = tonumber(ARGV[2])
+order.price = ARGV[3]
+order.funds = ARGV[4]
+encoded = cjson.encode(order)
+redis.call("HSET", KEYS[5], ARGV[1], encoded)
+return encoded
+`)
+
+// KEYS: balance, balance reserve, stocks, stocks reserve, limit orders, history
+// ARGV: id, transNum, command, now in unix millis
+// Returns the released order
+var releaseLimitOrderScript = redis.NewScript(6, luaOrderHelpers+luaLimitOrderHelpers+`
+local encoded = redis.call("HGET", KEYS[5], ARGV[1])
+if not encoded then
+	return redis.error_reply("NO_LIMIT_ORDER")
+end
+local order = cjson.decode(encoded)
+local failed
+if order.side == "BUY" then
+	failed = move(KEYS[2], KEYS[1], nil, tonumber(cents(order.funds)), "INSUFFICIENT_RESERVE")
+	if not failed then
+		record(KEYS[6], ARGV[2], ARGV[3], order.stock, order.funds, 0, "0", ARGV[4])
+	end
+else
+	failed = move(KEYS[4], KEYS[3], order.stock, order.shares, "INSUFFICIENT_RESERVE")
+	if not failed then
+		record(KEYS[6], ARGV[2], ARGV[3], order.stock, "0", order.shares, "0", ARGV[4])
+	end
+end
+if failed then
+	return failed
+end
+redis.call("HDEL", KEYS[5], ARGV[1])
+return encoded
+`)
+
+// A buy spends the shares it fills at the price and refunds the rest of its
+// reserve, see limitOrderFill. A sell is credited its shares at the price.
+// A bracket's entry buys its shares into the stock reserve instead, and the
+// bracket is kept as a sell of them. A group's exits only fill once it is a sell.
+// KEYS: balance, balance reserve, stocks, stocks reserve, limit orders, history, processed trigger
+// ARGV: order id, price in cents, transNum, command, now in unix millis, processed TTL in seconds, leg
+// Returns the filled order, the shares filled and the cents spent or credited
+var executeLimitOrderScript = redis.NewScript(7, luaOrderHelpers+luaLimitOrderHelpers+`
+if redis.call("EXISTS", KEYS[7]) == 1 then
+	return redis.error_reply("TRIGGER_PROCESSED")
+end
+local encoded = redis.call("HGET", KEYS[5], ARGV[1])
+if not encoded then
+	return redis.error_reply("NO_LIMIT_ORDER")
+end
+local order = cjson.decode(encoded)
+local price = tonumber(ARGV[2])
+local shares = order.shares
+local amount
+if (ARGV[7] == "ENTRY" and order.side ~= "BUY") or (ARGV[7] ~= "" and ARGV[7] ~= "ENTRY" and order.side ~= "SELL") then
+	return redis.error_reply("NO_LIMIT_ORDER")
+end
+if ARGV[7] == "ENTRY" then
+	local reserved = tonumber(cents(order.funds))
+	if available(KEYS[2]) < reserved then
+		return redis.error_reply("INSUFFICIENT_RESERVE")
+	end
+	shares = math.min(shares, math.floor(reserved / price))
+	amount = shares * price
+	redis.call("DECRBY", KEYS[2], reserved)
+	redis.call("INCRBY", KEYS[1], reserved - amount)
+	redis.call("HINCRBY", KEYS[4], order.stock, shares)
+	record(KEYS[6], ARGV[3], ARGV[4], order.stock, dollars(reserved - amount), 0, dollars(price), ARGV[5])
+	order.side = "SELL"
+	order.shares = shares
+	order.price = dollars(price)
+	order.funds = "0"
+	encoded = cjson.encode(order)
+	redis.call("HSET", KEYS[5], ARGV[1], encoded)
+	redis.call("SET", KEYS[7], "1", "EX", ARGV[6])
+	return {encoded, shares, amount}
+end
+if order.side == "BUY" then
+	local reserved = tonumber(cents(order.funds))
+	if available(KEYS[2]) < reserved then
+		return redis.error_reply("INSUFFICIENT_RESERVE")
+	end
+	shares = math.min(shares, math.floor(reserved / price))
+	amount = shares * price
+	redis.call("DECRBY", KEYS[2], reserved)
+	redis.call("INCRBY", KEYS[1], reserved - amount)
+	redis.call("HINCRBY", KEYS[3], order.stock, shares)
+	record(KEYS[6], ARGV[3], ARGV[4], order.stock, dollars(reserved - amount), shares, dollars(price), ARGV[5])
+else
+	if available(KEYS[4], order.stock) < shares then
+		return redis.error_reply("INSUFFICIENT_RESERVE")
+	end
+	amount = shares * price
+	redis.call("HINCRBY", KEYS[4], order.stock, -shares)
+	redis.call("INCRBY", KEYS[1], amount)
+	record(KEYS[6], ARGV[3], ARGV[4], order.stock, dollars(amount), 0, dollars(price), ARGV[5])
+end
+redis.call("HDEL", KEYS[5], ARGV[1])
+redis.call("SET", KEYS[7], "1", "EX", ARGV[6])
+return {encoded, shares, amount}
+`)
+
+// limitOrderKeys are the keys every limit order script but placing one starts with
+func limitOrderKeys(user string) []interface{} {
+	return []interface{}{user + ":Balance", user + ":BalanceReserve", user + ":Stocks", user + ":StocksReserve",
+		user + ":LimitOrders", user + ":History"}
+}
+
+// PlaceLimitOrder moves the order's funds or shares into the user's reserve
+// account and records the order under its ID. Returns ErrInsufficientFunds
+// or ErrInsufficientStock if the user can't cover it.
+func (u RedisDatabase) PlaceLimitOrder(order LimitOrder) error {
+	order.Funds = limitOrderFunds(order.Side, order.Shares, order.Price)
+	encoded, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+	user := order.User
+	entry := u.historyEntry(order.Stock, order.Funds.Neg(), 0, decimal.Zero)
+	if order.Side != "BUY" {
+		entry = u.historyEntry(order.Stock, decimal.Zero, -order.Shares, decimal.Zero)
+	}
+	_, err = u.runScript(placeLimitOrderScript,
+		user+":Balance", user+":BalanceReserve", user+":Stocks", user+":StocksReserve", user+":LimitOrders",
+		triggerUsersKey, user+":History",
+		encoded, order.ID, order.Side, order.Stock, order.Shares, u.dollarToCents(order.Funds), user, entry)
+	return err
+}
+
+// GetLimitOrder returns one of the user's limit orders, or ErrNoLimitOrder
+func (u RedisDatabase) GetLimitOrder(user string, id string) (LimitOrder, error) {
+	resp := u.makeQuery(NewQuery("HGET", user+":LimitOrders", id))
+	encoded, err := redis.Bytes(resp.r, resp.err)
+	if err == redis.ErrNil {
+		return LimitOrder{}, ErrNoLimitOrder
+	} else if err != nil {
+		return LimitOrder{}, err
+	}
+	var order LimitOrder
+	err = json.Unmarshal(encoded, &order)
+	return order, err
+}
+
+// GetLimitOrders returns every limit order the user has waiting, oldest first
+func (u RedisDatabase) GetLimitOrders(user string) ([]LimitOrder, error) {
+	resp := u.makeQuery(NewQuery("HVALS", user+":LimitOrders"))
+	encoded, err := redis.Strings(resp.r, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLimitOrders(encoded)
+}
+
+// decodeLimitOrders decodes a user's limit orders, oldest first
+func decodeLimitOrders(encoded []string) ([]LimitOrder, error) {
+	orders := make([]LimitOrder, 0, len(encoded))
+	for _, e := range encoded {
+		var order LimitOrder
+		if err := json.Unmarshal([]byte(e), &order); err != nil {
+			return orders, err
+		}
+		orders = append(orders, order)
+	}
+	sortLimitOrders(orders)
+	return orders, nil
+}
+
+// AmendLimitOrder changes the shares and price of a limit order, moving the
+// difference in what it holds between the user's account and their reserve.
+// Returns ErrNoLimitOrder if there is no such order, or ErrInsufficientFunds
+// or ErrInsufficientStock if the user can't cover a larger order.
+func (u RedisDatabase) AmendLimitOrder(user string, id string, shares int64, price decimal.Decimal) (LimitOrder, error) {
+	current, err := u.GetLimitOrder(user, id)
+	if err != nil {
+		return LimitOrder{}, err
+	}
+	funds := limitOrderFunds(current.Side, shares, price)
+	keysAndArgs := append(limitOrderKeys(user),
+		id, shares, price.Truncate(2).String(), funds.String(), u.transNum, u.command, toMillis(time.Now()))
+	return u.limitOrderScript(amendLimitOrderScript, keysAndArgs...)
+}
+
+// ReleaseLimitOrder returns the funds or shares held for a limit order to the
+// user's account and removes the order. Returns ErrNoLimitOrder if there is none.
+func (u RedisDatabase) ReleaseLimitOrder(user string, id string) (LimitOrder, error) {
+	keysAndArgs := append(limitOrderKeys(user), id, u.transNum, u.command, toMillis(time.Now()))
+	return u.limitOrderScript(releaseLimitOrderScript, keysAndArgs...)
+}
+
+// ExecuteLimitOrder fills a limit order at price and removes it, returning the
+// order with the shares filled and the funds they cost or raised. Returns
+// ErrTriggerProcessed if the order's ID has already executed, or ErrNoLimitOrder
+// if the order was released first.
+// The id of a leg of an OCO group or bracket fills the group, see SplitLegID. A
+// bracket's entry leaves the bracket in place as a sell of the shares it bought,
+// which stay in reserve for its exits, and returns it as it now is.
+func (u RedisDatabase) ExecuteLimitOrder(user string, id string, price decimal.Decimal) (order LimitOrder,
+	shares int64, amount decimal.Decimal, err error) {
+	orderID, leg := SplitLegID(id)
+	keysAndArgs := append(limitOrderKeys(user), processedTriggerKey(id),
+		orderID, u.dollarToCents(price), u.transNum, u.command, toMillis(time.Now()),
+		int64(ProcessedTriggerTTL/time.Second), leg)
+	reply, err := redis.Values(u.runScript(executeLimitOrderScript, keysAndArgs...))
+	if err != nil {
+		return order, 0, decimal.Zero, err
+	}
+	var encoded []byte
+	var cents int64
+	if _, err = redis.Scan(reply, &encoded, &shares, &cents); err != nil {
+		return order, 0, decimal.Zero, err
+	}
+	err = json.Unmarshal(encoded, &order)
+	return order, shares, u.centsToDollar(cents), err
+}
+
+// ExpiredLimitOrders returns every limit order whose time limit has passed by now
+func (u RedisDatabase) ExpiredLimitOrders(now time.Time) ([]LimitOrder, error) {
+	resp := u.makeQuery(NewQuery("SMEMBERS", triggerUsersKey))
+	users, err := redis.Strings(resp.r, resp.err)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []LimitOrder
+	for _, user := range users {
+		orders, err := u.GetLimitOrders(user)
+		if err != nil {
+			return expired, err
+		}
+		for _, order := range orders {
+			if order.expired(now) {
+				expired = append(expired, order)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// limitOrderScript runs a script that replies with an encoded limit order
+func (u RedisDatabase) limitOrderScript(script *redis.Script, keysAndArgs ...interface{}) (LimitOrder, error) {
+	encoded, err := redis.Bytes(u.runScript(script, keysAndArgs...))
+	if err != nil {
+		return LimitOrder{}, err
+	}
+	var order LimitOrder
+	err = json.Unmarshal(encoded, &order)
+	return order, err
+}
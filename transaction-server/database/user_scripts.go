@@ -0,0 +1,577 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// Errors returned by the compound operations when redis refuses to apply them.
+// None of the keys touched by a script are modified when one of these is returned.
+var (
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrInsufficientReserve = errors.New("insufficient reserve")
+	ErrNoPendingOrder      = errors.New("no pending order")
+	ErrNoTrigger           = errors.New("no trigger record")
+	ErrTriggerExists       = errors.New("trigger record already exists")
+	ErrTriggerProcessed    = errors.New("trigger already processed")
+	ErrNoLimitOrder        = errors.New("no limit order")
+)
+
+// ProcessedTriggerTTL is how long a trigger ID passed to ExecuteBuyTrigger,
+// ExecuteSellTrigger or ExecuteLimitOrder is remembered, so a redelivered TRIGGER_SUCCESS is not applied twice
+const ProcessedTriggerTTL = 7 * 24 * time.Hour
+
+// processedTriggerKey is the key remembering that the trigger with id has executed
+func processedTriggerKey(id string) string {
+	return "ProcessedTriggers:" + id
+}
+
+// ErrOrderExpired is returned when a commit finds the newest pending order has
+// outlived PendingOrderTimeout. Unlike the errors above the order is not left in
+// place: it is removed and its funds or shares are returned to the user.
+var ErrOrderExpired = errors.New("pending order expired")
+
+var scriptErrors = map[string]error{
+	"INSUFFICIENT_FUNDS":   ErrInsufficientFunds,
+	"INSUFFICIENT_STOCK":   ErrInsufficientStock,
+	"INSUFFICIENT_RESERVE": ErrInsufficientReserve,
+	"NO_PENDING_ORDER":     ErrNoPendingOrder,
+	"NO_TRIGGER":           ErrNoTrigger,
+	"TRIGGER_EXISTS":       ErrTriggerExists,
+	"TRIGGER_PROCESSED":    ErrTriggerProcessed,
+	"NO_LIMIT_ORDER":       ErrNoLimitOrder,
+	"ACCOUNT_EXISTS":       ErrAccountExists,
+}
+
+// Lua scripts backing the compound operations. Redis runs each script to
+// completion before serving any other client, so the balance checks and the
+// writes that depend on them can never interleave with another request.
+
+// Helpers shared by the scripts that read encoded orders, see encodeOrder
+const luaOrderHelpers = `
+local function decode(order)
+	local stock, cost, shares, created = string.match(order, "^([^:]*):([^:]*):([^:]*):?([^:]*)$")
+	return stock, cost, shares, tonumber(created) or 0
+end
+local function cents(cost)
+	return string.format("%d", math.floor(tonumber(cost) * 100 + 0.5))
+end
+local function price(cost, shares)
+	if tonumber(shares) == 0 then
+		return "0"
+	end
+	return string.format("%.2f", math.floor(tonumber(cost) * 100 / tonumber(shares)) / 100)
+end
+local function record(history, transNum, command, stock, funds, shares, price, timestamp)
+	redis.call("LPUSH", history, cjson.encode({
+		transNum = tonumber(transNum), command = command, stock = stock, funds = funds,
+		shares = tonumber(shares), price = price, timestamp = tonumber(timestamp)}))
+end
+`
+
+// Scripts that change a user's balance or stock holdings also push a history
+// entry, see HistoryEntry. When the caller knows the change up front the
+// entry is encoded in Go, otherwise the script builds it with record.
+
+// KEYS: balance, orders, pending order users, history
+// ARGV: cost in cents, encoded order, user, history entry
+var pushOrderWithFundsScript = redis.NewScript(4, `
+local balance = tonumber(redis.call("GET", KEYS[1]) or "0")
+if balance < tonumber(ARGV[1]) then
+	return redis.error_reply("INSUFFICIENT_FUNDS")
+end
+redis.call("DECRBY", KEYS[1], ARGV[1])
+redis.call("SADD", KEYS[3], ARGV[3])
+redis.call("LPUSH", KEYS[4], ARGV[4])
+return redis.call("RPUSH", KEYS[2], ARGV[2])
+`)
+
+// KEYS: stocks, orders, pending order users, history
+// ARGV: stock, shares, encoded order, user, history entry
+var pushOrderWithStockScript = redis.NewScript(4, `
+local held = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if held < tonumber(ARGV[2]) then
+	return redis.error_reply("INSUFFICIENT_STOCK")
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], -tonumber(ARGV[2]))
+redis.call("SADD", KEYS[3], ARGV[4])
+redis.call("LPUSH", KEYS[4], ARGV[5])
+return redis.call("RPUSH", KEYS[2], ARGV[3])
+`)
+
+// KEYS: buy orders, stocks, balance, history
+// ARGV: cutoff in unix millis, transNum, command, now in unix millis
+var commitBuyScript = redis.NewScript(4, luaOrderHelpers+`
+local order = redis.call("RPOP", KEYS[1])
+if not order then
+	return redis.error_reply("NO_PENDING_ORDER")
+end
+local stock, cost, shares, created = decode(order)
+if created < tonumber(ARGV[1]) then
+	redis.call("INCRBY", KEYS[3], cents(cost))
+	record(KEYS[4], ARGV[2], ARGV[3], stock, cost, 0, price(cost, shares), ARGV[4])
+	return {"ORDER_EXPIRED", order}
+end
+redis.call("HINCRBY", KEYS[2], stock, shares)
+record(KEYS[4], ARGV[2], ARGV[3], stock, "0", shares, price(cost, shares), ARGV[4])
+return order
+`)
+
+// KEYS: sell orders, balance, stocks, history
+// ARGV: cutoff in unix millis, transNum, command, now in unix millis
+var commitSellScript = redis.NewScript(4, luaOrderHelpers+`
+local order = redis.call("RPOP", KEYS[1])
+if not order then
+	return redis.error_reply("NO_PENDING_ORDER")
+end
+local stock, cost, shares, created = decode(order)
+if created < tonumber(ARGV[1]) then
+	redis.call("HINCRBY", KEYS[3], stock, shares)
+	record(KEYS[4], ARGV[2], ARGV[3], stock, "0", shares, price(cost, shares), ARGV[4])
+	return {"ORDER_EXPIRED", order}
+end
+redis.call("INCRBY", KEYS[2], cents(cost))
+record(KEYS[4], ARGV[2], ARGV[3], stock, cost, 0, price(cost, shares), ARGV[4])
+return order
+`)
+
+// KEYS: orders, stocks, history
+// ARGV: transNum, command, now in unix millis
+var popOrderCreditStockScript = redis.NewScript(3, luaOrderHelpers+`
+local order = redis.call("RPOP", KEYS[1])
+if not order then
+	return redis.error_reply("NO_PENDING_ORDER")
+end
+local stock, cost, shares = decode(order)
+redis.call("HINCRBY", KEYS[2], stock, shares)
+record(KEYS[3], ARGV[1], ARGV[2], stock, "0", shares, price(cost, shares), ARGV[3])
+return order
+`)
+
+// KEYS: orders, balance, history
+// ARGV: transNum, command, now in unix millis
+var popOrderCreditFundsScript = redis.NewScript(3, luaOrderHelpers+`
+local order = redis.call("RPOP", KEYS[1])
+if not order then
+	return redis.error_reply("NO_PENDING_ORDER")
+end
+local stock, cost, shares = decode(order)
+redis.call("INCRBY", KEYS[2], cents(cost))
+record(KEYS[3], ARGV[1], ARGV[2], stock, cost, 0, price(cost, shares), ARGV[3])
+return order
+`)
+
+// Orders are pushed on the right, so the oldest (and first to expire) are on the left.
+// KEYS: buy orders, sell orders, balance, stocks, pending order users, history
+// ARGV: cutoff in unix millis, user, now in unix millis
+// Returns a flat list of transaction type and encoded order for every expired order
+var expireOrdersScript = redis.NewScript(6, luaOrderHelpers+`
+local expired = {}
+local function reap(orders, transType)
+	while true do
+		local order = redis.call("LINDEX", orders, 0)
+		if not order then
+			return
+		end
+		local stock, cost, shares, created = decode(order)
+		if created >= tonumber(ARGV[1]) then
+			return
+		end
+		redis.call("LPOP", orders)
+		if transType == "Buy" then
+			redis.call("INCRBY", KEYS[3], cents(cost))
+			record(KEYS[6], 0, "CANCEL_BUY", stock, cost, 0, price(cost, shares), ARGV[3])
+		else
+			redis.call("HINCRBY", KEYS[4], stock, shares)
+			record(KEYS[6], 0, "CANCEL_SELL", stock, "0", shares, price(cost, shares), ARGV[3])
+		end
+		table.insert(expired, transType)
+		table.insert(expired, order)
+	end
+end
+reap(KEYS[1], "Buy")
+reap(KEYS[2], "Sell")
+if redis.call("LLEN", KEYS[1]) == 0 and redis.call("LLEN", KEYS[2]) == 0 then
+	redis.call("SREM", KEYS[5], ARGV[2])
+end
+return expired
+`)
+
+// KEYS: source balance, destination balance, history
+// ARGV: amount in cents, error reply if the source is short, history entry
+var moveFundsScript = redis.NewScript(3, `
+local available = tonumber(redis.call("GET", KEYS[1]) or "0")
+if available < tonumber(ARGV[1]) then
+	return redis.error_reply(ARGV[2])
+end
+redis.call("DECRBY", KEYS[1], ARGV[1])
+redis.call("LPUSH", KEYS[3], ARGV[3])
+return redis.call("INCRBY", KEYS[2], ARGV[1])
+`)
+
+// KEYS: source stocks, destination stocks, history
+// ARGV: stock, shares, error reply if the source is short, history entry
+var moveStockScript = redis.NewScript(3, `
+local available = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if available < tonumber(ARGV[2]) then
+	return redis.error_reply(ARGV[3])
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], -tonumber(ARGV[2]))
+redis.call("LPUSH", KEYS[3], ARGV[4])
+return redis.call("HINCRBY", KEYS[2], ARGV[1], ARGV[2])
+`)
+
+// The trigger reserve scripts keep a user's BuyTriggers and SellTriggers records
+// in step with their reserve accounts, so a record always means its reserve is held.
+// Each trigger's record is kept under its field, see triggerField.
+
+// KEYS: balance, balance reserve, buy triggers, trigger users, history
+// ARGV: cents, trigger field, user, history entry
+var reserveBuyTriggerScript = redis.NewScript(5, `
+if redis.call("HEXISTS", KEYS[3], ARGV[2]) == 1 then
+	return redis.error_reply("TRIGGER_EXISTS")
+end
+local available = tonumber(redis.call("GET", KEYS[1]) or "0")
+if available < tonumber(ARGV[1]) then
+	return redis.error_reply("INSUFFICIENT_FUNDS")
+end
+redis.call("DECRBY", KEYS[1], ARGV[1])
+redis.call("INCRBY", KEYS[2], ARGV[1])
+redis.call("HSET", KEYS[3], ARGV[2], ARGV[1])
+redis.call("SADD", KEYS[4], ARGV[3])
+return redis.call("LPUSH", KEYS[5], ARGV[4])
+`)
+
+// KEYS: balance reserve, balance, buy triggers, history
+// ARGV: stock, transNum, command, now in unix millis, trigger field
+// Returns the released cents
+var releaseBuyTriggerScript = redis.NewScript(4, luaOrderHelpers+`
+local reserved = redis.call("HGET", KEYS[3], ARGV[5])
+if not reserved then
+	return redis.error_reply("NO_TRIGGER")
+end
+if tonumber(redis.call("GET", KEYS[1]) or "0") < tonumber(reserved) then
+	return redis.error_reply("INSUFFICIENT_RESERVE")
+end
+redis.call("DECRBY", KEYS[1], reserved)
+redis.call("INCRBY", KEYS[2], reserved)
+redis.call("HDEL", KEYS[3], ARGV[5])
+record(KEYS[4], ARGV[2], ARGV[3], ARGV[1], string.format("%.2f", tonumber(reserved) / 100), 0, "0", ARGV[4])
+return tonumber(reserved)
+`)
+
+// KEYS: stocks, stocks reserve, sell triggers, trigger users, history
+// ARGV: stock, shares, user, history entry, trigger field
+var reserveSellTriggerScript = redis.NewScript(5, `
+if redis.call("HEXISTS", KEYS[3], ARGV[5]) == 1 then
+	return redis.error_reply("TRIGGER_EXISTS")
+end
+local available = tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0")
+if available < tonumber(ARGV[2]) then
+	return redis.error_reply("INSUFFICIENT_STOCK")
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], -tonumber(ARGV[2]))
+redis.call("HINCRBY", KEYS[2], ARGV[1], ARGV[2])
+redis.call("HSET", KEYS[3], ARGV[5], ARGV[2])
+redis.call("SADD", KEYS[4], ARGV[3])
+return redis.call("LPUSH", KEYS[5], ARGV[4])
+`)
+
+// KEYS: stocks reserve, stocks, sell triggers, history
+// ARGV: stock, transNum, command, now in unix millis, trigger field
+// Returns the released shares
+var releaseSellTriggerScript = redis.NewScript(4, luaOrderHelpers+`
+local reserved = redis.call("HGET", KEYS[3], ARGV[5])
+if not reserved then
+	return redis.error_reply("NO_TRIGGER")
+end
+if tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0") < tonumber(reserved) then
+	return redis.error_reply("INSUFFICIENT_RESERVE")
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], -tonumber(reserved))
+redis.call("HINCRBY", KEYS[2], ARGV[1], reserved)
+redis.call("HDEL", KEYS[3], ARGV[5])
+record(KEYS[4], ARGV[2], ARGV[3], ARGV[1], "0", reserved, "0", ARGV[4])
+return tonumber(reserved)
+`)
+
+// The execute scripts settle the amount recorded for the trigger, not whatever
+// the triggerserver sent, so a trigger without a record can't spend another
+// trigger's share of the reserve.
+
+// KEYS: balance reserve, balance, stocks, history, buy triggers, processed trigger
+// ARGV: stock, price in cents, transNum, command, now in unix millis, processed TTL in seconds, trigger field
+// Returns the reserved cents and the shares bought
+var executeBuyScript = redis.NewScript(6, luaOrderHelpers+`
+if redis.call("EXISTS", KEYS[6]) == 1 then
+	return redis.error_reply("TRIGGER_PROCESSED")
+end
+local reserved = redis.call("HGET", KEYS[5], ARGV[7])
+if not reserved then
+	return redis.error_reply("NO_TRIGGER")
+end
+reserved = tonumber(reserved)
+if tonumber(redis.call("GET", KEYS[1]) or "0") < reserved then
+	return redis.error_reply("INSUFFICIENT_RESERVE")
+end
+local quote = tonumber(ARGV[2])
+local shares = math.floor(reserved / quote)
+local refund = reserved - shares * quote
+redis.call("DECRBY", KEYS[1], reserved)
+if refund > 0 then
+	redis.call("INCRBY", KEYS[2], refund)
+end
+redis.call("HINCRBY", KEYS[3], ARGV[1], shares)
+record(KEYS[4], ARGV[3], ARGV[4], ARGV[1], string.format("%.2f", refund / 100), shares,
+	string.format("%.2f", quote / 100), ARGV[5])
+redis.call("HDEL", KEYS[5], ARGV[7])
+redis.call("SET", KEYS[6], "1", "EX", ARGV[6])
+return {reserved, shares}
+`)
+
+// KEYS: stocks reserve, balance, history, sell triggers, processed trigger
+// ARGV: stock, price in cents, transNum, command, now in unix millis, processed TTL in seconds, trigger field
+// Returns the shares sold
+var executeSellScript = redis.NewScript(5, luaOrderHelpers+`
+if redis.call("EXISTS", KEYS[5]) == 1 then
+	return redis.error_reply("TRIGGER_PROCESSED")
+end
+local shares = redis.call("HGET", KEYS[4], ARGV[7])
+if not shares then
+	return redis.error_reply("NO_TRIGGER")
+end
+shares = tonumber(shares)
+if tonumber(redis.call("HGET", KEYS[1], ARGV[1]) or "0") < shares then
+	return redis.error_reply("INSUFFICIENT_RESERVE")
+end
+local proceeds = shares * tonumber(ARGV[2])
+redis.call("HINCRBY", KEYS[1], ARGV[1], -shares)
+redis.call("INCRBY", KEYS[2], proceeds)
+record(KEYS[3], ARGV[3], ARGV[4], ARGV[1], string.format("%.2f", proceeds / 100), 0,
+	string.format("%.2f", tonumber(ARGV[2]) / 100), ARGV[5])
+redis.call("HDEL", KEYS[4], ARGV[7])
+redis.call("SET", KEYS[5], "1", "EX", ARGV[6])
+return shares
+`)
+
+// PushBuyWithFunds removes cost from the user's balance and records the pending
+// buy in one step. Returns ErrInsufficientFunds if the balance can't cover it.
+func (u RedisDatabase) PushBuyWithFunds(user string, stock string, cost decimal.Decimal, shares int64) error {
+	_, err := u.runScript(pushOrderWithFundsScript,
+		user+":Balance", user+":BuyOrders", pendingOrdersKey, user+":History",
+		u.dollarToCents(cost), encodeOrder(stock, cost, shares, time.Now()), user,
+		u.historyEntry(stock, cost.Neg(), 0, orderPrice(cost, shares)))
+	return err
+}
+
+// PushSellWithStock removes shares from the user's stock account and records the
+// pending sell in one step. Returns ErrInsufficientStock if the user holds too few.
+func (u RedisDatabase) PushSellWithStock(user string, stock string, cost decimal.Decimal, shares int64) error {
+	_, err := u.runScript(pushOrderWithStockScript,
+		user+":Stocks", user+":SellOrders", pendingOrdersKey, user+":History",
+		stock, shares, encodeOrder(stock, cost, shares, time.Now()), user,
+		u.historyEntry(stock, decimal.Zero, -shares, orderPrice(cost, shares)))
+	return err
+}
+
+// CommitBuyOrder pops the user's most recent buy and credits its shares.
+// An expired buy is refunded instead and ErrOrderExpired is returned.
+func (u RedisDatabase) CommitBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	now := time.Now()
+	return u.popOrderScript(commitBuyScript, user+":BuyOrders", user+":Stocks", user+":Balance", user+":History",
+		toMillis(orderCutoff(now)), u.transNum, u.command, toMillis(now))
+}
+
+// CancelBuyOrder pops the user's most recent buy and refunds its cost
+func (u RedisDatabase) CancelBuyOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	return u.popOrderScript(popOrderCreditFundsScript, user+":BuyOrders", user+":Balance", user+":History",
+		u.transNum, u.command, toMillis(time.Now()))
+}
+
+// CommitSellOrder pops the user's most recent sell and credits its proceeds.
+// An expired sell has its shares returned instead and ErrOrderExpired is returned.
+func (u RedisDatabase) CommitSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	now := time.Now()
+	return u.popOrderScript(commitSellScript, user+":SellOrders", user+":Balance", user+":Stocks", user+":History",
+		toMillis(orderCutoff(now)), u.transNum, u.command, toMillis(now))
+}
+
+// CancelSellOrder pops the user's most recent sell and returns its shares
+func (u RedisDatabase) CancelSellOrder(user string) (stock string, cost decimal.Decimal, shares int64, err error) {
+	return u.popOrderScript(popOrderCreditStockScript, user+":SellOrders", user+":Stocks", user+":History",
+		u.transNum, u.command, toMillis(time.Now()))
+}
+
+// MoveFundsToReserve moves amount dollars from the user's balance into their reserve account
+func (u RedisDatabase) MoveFundsToReserve(user string, amount decimal.Decimal) error {
+	_, err := u.runScript(moveFundsScript,
+		user+":Balance", user+":BalanceReserve", user+":History",
+		u.dollarToCents(amount), "INSUFFICIENT_FUNDS", u.historyEntry("", amount.Neg(), 0, decimal.Zero))
+	return err
+}
+
+// MoveReserveToFunds moves amount dollars from the user's reserve account back into their balance
+func (u RedisDatabase) MoveReserveToFunds(user string, amount decimal.Decimal) error {
+	_, err := u.runScript(moveFundsScript,
+		user+":BalanceReserve", user+":Balance", user+":History",
+		u.dollarToCents(amount), "INSUFFICIENT_RESERVE", u.historyEntry("", amount, 0, decimal.Zero))
+	return err
+}
+
+// MoveStockToReserve moves shares of stock from the user's account into their reserve account
+func (u RedisDatabase) MoveStockToReserve(user string, stock string, shares int64) error {
+	_, err := u.runScript(moveStockScript,
+		user+":Stocks", user+":StocksReserve", user+":History",
+		stock, shares, "INSUFFICIENT_STOCK", u.historyEntry(stock, decimal.Zero, -shares, decimal.Zero))
+	return err
+}
+
+// MoveReserveToStock moves shares of stock from the user's reserve account back into their account
+func (u RedisDatabase) MoveReserveToStock(user string, stock string, shares int64) error {
+	_, err := u.runScript(moveStockScript,
+		user+":StocksReserve", user+":Stocks", user+":History",
+		stock, shares, "INSUFFICIENT_RESERVE", u.historyEntry(stock, decimal.Zero, shares, decimal.Zero))
+	return err
+}
+
+// ReserveBuyTrigger moves amount dollars from the user's balance into their
+// reserve account and records them as held for the buy trigger on stock with id.
+// Returns ErrTriggerExists if the user already has a record for that trigger.
+func (u RedisDatabase) ReserveBuyTrigger(user string, stock string, id string, amount decimal.Decimal) error {
+	_, err := u.runScript(reserveBuyTriggerScript,
+		user+":Balance", user+":BalanceReserve", user+":BuyTriggers", triggerUsersKey, user+":History",
+		u.dollarToCents(amount), triggerField(stock, id), user, u.historyEntry(stock, amount.Neg(), 0, decimal.Zero))
+	return err
+}
+
+// ReleaseBuyTrigger returns the dollars held for the user's buy trigger on stock
+// with id to their balance and removes the record. Returns ErrNoTrigger if there is none.
+func (u RedisDatabase) ReleaseBuyTrigger(user string, stock string, id string) (decimal.Decimal, error) {
+	cents, err := redis.Int64(u.runScript(releaseBuyTriggerScript,
+		user+":BalanceReserve", user+":Balance", user+":BuyTriggers", user+":History",
+		stock, u.transNum, u.command, toMillis(time.Now()), triggerField(stock, id)))
+	return u.centsToDollar(cents), err
+}
+
+// ReserveSellTrigger moves shares of stock into the user's reserve account and
+// records them as held for the sell trigger with id.
+// Returns ErrTriggerExists if the user already has a record for that trigger.
+func (u RedisDatabase) ReserveSellTrigger(user string, stock string, id string, shares int64) error {
+	_, err := u.runScript(reserveSellTriggerScript,
+		user+":Stocks", user+":StocksReserve", user+":SellTriggers", triggerUsersKey, user+":History",
+		stock, shares, user, u.historyEntry(stock, decimal.Zero, -shares, decimal.Zero), triggerField(stock, id))
+	return err
+}
+
+// ReleaseSellTrigger returns the shares held for the user's sell trigger on stock
+// with id to their account and removes the record. Returns ErrNoTrigger if there is none.
+func (u RedisDatabase) ReleaseSellTrigger(user string, stock string, id string) (int64, error) {
+	return redis.Int64(u.runScript(releaseSellTriggerScript,
+		user+":StocksReserve", user+":Stocks", user+":SellTriggers", user+":History",
+		stock, u.transNum, u.command, toMillis(time.Now()), triggerField(stock, id)))
+}
+
+// ExecuteBuyTrigger settles a fired buy trigger at price: the dollars recorded
+// for the trigger with triggerID leave the reserve account, buy as many shares
+// as they cover, and whatever wasn't spent goes back to the balance. The record
+// is removed. Returns the dollars that were reserved and the shares bought,
+// ErrNoTrigger if there is no record, or ErrTriggerProcessed if triggerID has
+// already been executed.
+func (u RedisDatabase) ExecuteBuyTrigger(user string, stock string, triggerID string,
+	price decimal.Decimal) (reserved decimal.Decimal, shares int64, err error) {
+	reply, err := redis.Values(u.runScript(executeBuyScript,
+		user+":BalanceReserve", user+":Balance", user+":Stocks", user+":History", user+":BuyTriggers",
+		processedTriggerKey(triggerID),
+		stock, u.dollarToCents(price), u.transNum, u.command, toMillis(time.Now()),
+		int64(ProcessedTriggerTTL/time.Second), triggerField(stock, triggerID)))
+	if err != nil {
+		return decimal.Zero, 0, err
+	}
+	var cents int64
+	if _, err = redis.Scan(reply, &cents, &shares); err != nil {
+		return decimal.Zero, 0, err
+	}
+	return u.centsToDollar(cents), shares, nil
+}
+
+// ExecuteSellTrigger settles a fired sell trigger at price: the shares recorded
+// for the trigger with triggerID leave the reserve account and the proceeds are
+// credited to the user's balance. The record is removed. Returns the shares
+// sold, ErrNoTrigger if there is no record, or ErrTriggerProcessed if triggerID
+// has already been executed.
+func (u RedisDatabase) ExecuteSellTrigger(user string, stock string, triggerID string,
+	price decimal.Decimal) (int64, error) {
+	return redis.Int64(u.runScript(executeSellScript,
+		user+":StocksReserve", user+":Balance", user+":History", user+":SellTriggers",
+		processedTriggerKey(triggerID),
+		stock, u.dollarToCents(price), u.transNum, u.command, toMillis(time.Now()),
+		int64(ProcessedTriggerTTL/time.Second), triggerField(stock, triggerID)))
+}
+
+// ExpireOrders removes every pending order created more than PendingOrderTimeout
+// before now, returning its funds or shares to the user.
+func (u RedisDatabase) ExpireOrders(now time.Time) ([]Order, error) {
+	resp := u.makeQuery(NewQuery("SMEMBERS", pendingOrdersKey))
+	users, err := redis.Strings(resp.r, resp.err)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := toMillis(orderCutoff(now))
+	var expired []Order
+	for _, user := range users {
+		reply, err := redis.Strings(u.runScript(expireOrdersScript,
+			user+":BuyOrders", user+":SellOrders", user+":Balance", user+":Stocks", pendingOrdersKey, user+":History",
+			cutoff, user, toMillis(now)))
+		if err != nil {
+			return expired, err
+		}
+		for i := 0; i+1 < len(reply); i += 2 {
+			expired = append(expired, decodeFullOrder(user, reply[i], reply[i+1]))
+		}
+	}
+	return expired, nil
+}
+
+func (u RedisDatabase) popOrderScript(script *redis.Script, keysAndArgs ...interface{}) (stock string,
+	cost decimal.Decimal, shares int64, err error) {
+	r, err := u.runScript(script, keysAndArgs...)
+	if err != nil {
+		return stock, cost, shares, err
+	}
+
+	// Expired orders come back as a status and the order that was refunded
+	if values, ok := r.([]interface{}); ok {
+		reply, err := redis.Strings(values, nil)
+		if err != nil || len(reply) != 2 {
+			return stock, cost, shares, errors.New("unexpected reply popping order")
+		}
+		stock, cost, shares = decodeOrder(reply[1])
+		return stock, cost, shares, ErrOrderExpired
+	}
+
+	recv, err := redis.String(r, nil)
+	if err != nil {
+		return stock, cost, shares, err
+	}
+	stock, cost, shares = decodeOrder(recv)
+	return stock, cost, shares, nil
+}
+
+// runScript executes a script on a pooled connection. Scripts bypass the batch
+// worker since a Query can only carry a key and two params.
+func (u RedisDatabase) runScript(script *redis.Script, keysAndArgs ...interface{}) (interface{}, error) {
+	c := u.DbPool.Get()
+	defer c.Close()
+	r, err := script.Do(c, keysAndArgs...)
+	if redisErr, ok := err.(redis.Error); ok {
+		if mapped, ok := scriptErrors[string(redisErr)]; ok {
+			return r, mapped
+		}
+	}
+	return r, err
+}
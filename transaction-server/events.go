@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"seng468/transaction-server/database"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// eventsChannel is the redis channel every web server subscribes to for user events
+const eventsChannel = "UserEvents"
+
+// eventBuffer is how many events can wait to be published before new ones are dropped
+const eventBuffer = 1000
+
+// UserEvent is pushed to the web servers, which forward it to the user's
+// browsers. Quotes are for anyone watching the stock, so they have no user.
+type UserEvent struct {
+	Type     string      `json:"type"`
+	User     string      `json:"user,omitempty"`
+	TransNum int         `json:"transNum"`
+	Time     int64       `json:"time"` // Milliseconds since the Unix epoch
+	Data     interface{} `json:"data"`
+}
+
+// Event types and the Data they carry
+const (
+	EventBalance     = "balance"      // balanceEvent
+	EventOrder       = "order"        // orderEvent
+	EventTriggerFill = "trigger_fill" // TriggerFill
+	EventQuote       = "quote"        // quoteEvent
+)
+
+type balanceEvent struct {
+	Funds decimal.Decimal `json:"funds"`
+}
+
+// Order states
+const (
+	OrderPending   = "pending"
+	OrderCommitted = "committed"
+	OrderCancelled = "cancelled"
+	OrderExpired   = "expired"
+
+	// Limit orders are open until they are filled, cancelled or expire
+	OrderOpen    = "open"
+	OrderAmended = "amended"
+	OrderFilled  = "filled"
+
+	// A bracket has entered once its entry has bought the shares its exits sell
+	OrderEntered = "entered"
+)
+
+// orderEvent is a change to a pending order, or to a limit order, which
+// also has an ID and a limit price
+type orderEvent struct {
+	ID      string               `json:"id,omitempty"`
+	Type    string               `json:"type,omitempty"` // Set for stop and trailing stop orders and order groups
+	Side    string               `json:"side"`
+	State   string               `json:"state"`
+	Stock   string               `json:"stock"`
+	Cost    decimal.Decimal      `json:"cost"`
+	Shares  int64                `json:"shares"`
+	Price   *decimal.Decimal     `json:"price,omitempty"`
+	Exits   *database.OrderExits `json:"exits,omitempty"`   // Set for OCO groups and brackets
+	Expires int64                `json:"expires,omitempty"` // When a pending order must be committed by, or a limit order expires, in milliseconds
+}
+
+type quoteEvent struct {
+	Stock string          `json:"stock"`
+	Price decimal.Decimal `json:"price"`
+}
+
+// EventPublisher sends user events to the web servers. Publish must not block the command publishing.
+type EventPublisher interface {
+	Publish(event UserEvent)
+}
+
+// RedisEvents publishes events on a redis channel from a single worker.
+// Events that don't fit in its buffer are dropped.
+type RedisEvents struct {
+	Pool    *redis.Pool
+	Channel string
+	queue   chan UserEvent
+}
+
+func NewRedisEvents(pool *redis.Pool, channel string) *RedisEvents {
+	return &RedisEvents{Pool: pool, Channel: channel, queue: make(chan UserEvent, eventBuffer)}
+}
+
+func (r *RedisEvents) Publish(event UserEvent) {
+	select {
+	case r.queue <- event:
+	default:
+	}
+}
+
+// Run publishes queued events, forever
+func (r *RedisEvents) Run() {
+	for event := range r.queue {
+		message, err := json.Marshal(event)
+		if err != nil {
+			fmt.Println("Error encoding event: ", err)
+			continue
+		}
+		c := r.Pool.Get()
+		_, err = c.Do("PUBLISH", r.Channel, message)
+		c.Close()
+		if err != nil {
+			fmt.Println("Error publishing event: ", err)
+		}
+	}
+}
+
+// publish sends an event about user, if the server has somewhere to send events
+func (ts TransactionServer) publish(eventType string, transNum int, user string, data interface{}) {
+	if ts.Events == nil {
+		return
+	}
+	ts.Events.Publish(UserEvent{
+		Type:     eventType,
+		User:     user,
+		TransNum: transNum,
+		Time:     time.Now().UnixNano() / int64(time.Millisecond),
+		Data:     data,
+	})
+}
+
+// publishBalance sends the user's funds after a command changed them. The
+// funds are read in the background so the command isn't held up.
+func (ts TransactionServer) publishBalance(transNum int, user string) {
+	if ts.Events == nil {
+		return
+	}
+	go func() {
+		funds, err := ts.UserDatabase.GetFunds(user)
+		if err != nil {
+			fmt.Println("Error getting funds for balance event: ", err)
+			return
+		}
+		ts.publish(EventBalance, transNum, user, balanceEvent{Funds: funds})
+	}()
+}
+
+// publishOrder sends a change to one of the user's pending BUY or SELL orders
+func (ts TransactionServer) publishOrder(transNum int, user string, side string, state string, stock string,
+	cost decimal.Decimal, shares int64, expires time.Time) {
+	event := orderEvent{Side: side, State: state, Stock: stock, Cost: cost, Shares: shares}
+	if !expires.IsZero() {
+		event.Expires = expires.UnixNano() / int64(time.Millisecond)
+	}
+	ts.publish(EventOrder, transNum, user, event)
+}
+
+// publishLimitOrder sends a change to one of the user's limit orders
+func (ts TransactionServer) publishLimitOrder(transNum int, order database.LimitOrder, state string) {
+	event := orderEvent{ID: order.ID, Type: order.Type, Side: order.Side, State: state, Stock: order.Stock,
+		Cost: order.Price.Mul(decimal.New(order.Shares, 0)), Shares: order.Shares, Price: &order.Price,
+		Exits: order.Exits}
+	if !order.Expires.IsZero() {
+		event.Expires = order.Expires.UnixNano() / int64(time.Millisecond)
+	}
+	ts.publish(EventOrder, transNum, order.User, event)
+}
+
+// publishFill sends an executed trigger or limit order to the fill feed and
+// the user, along with their new balance
+func (ts TransactionServer) publishFill(fill TriggerFill) {
+	ts.Fills.Publish(fill)
+	ts.publish(EventTriggerFill, fill.TransNum, fill.User, fill)
+	ts.publishBalance(fill.TransNum, fill.User)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// fillBuffer is how many fills a subscriber can fall behind before new ones are dropped
+const fillBuffer = 100
+
+// TriggerFill is a trigger the transaction server has executed
+type TriggerFill struct {
+	TransNum  int             `json:"transNum"`
+	TriggerID string          `json:"triggerId"`
+	User      string          `json:"user"`
+	Stock     string          `json:"stock"`
+	Action    string          `json:"action"`
+	Price     decimal.Decimal `json:"price"`
+	Amount    decimal.Decimal `json:"amount"`
+	Time      time.Time       `json:"time"`
+}
+
+// FillFeed fans executed triggers out to subscribers, such as gRPC TriggerFills streams.
+// Publishing never blocks on a slow subscriber; fills that don't fit in its buffer are dropped.
+type FillFeed struct {
+	lock        sync.Mutex
+	subscribers map[chan TriggerFill]string
+}
+
+func NewFillFeed() *FillFeed {
+	return &FillFeed{subscribers: make(map[chan TriggerFill]string)}
+}
+
+// Subscribe returns a channel of fills for user, or for every user if user is empty,
+// and a function that ends the subscription and closes the channel
+func (f *FillFeed) Subscribe(user string) (<-chan TriggerFill, func()) {
+	fills := make(chan TriggerFill, fillBuffer)
+	f.lock.Lock()
+	f.subscribers[fills] = user
+	f.lock.Unlock()
+
+	var once sync.Once
+	return fills, func() {
+		once.Do(func() {
+			f.lock.Lock()
+			delete(f.subscribers, fills)
+			f.lock.Unlock()
+			close(fills)
+		})
+	}
+}
+
+// Publish sends fill to every interested subscriber. A nil feed publishes nothing.
+func (f *FillFeed) Publish(fill TriggerFill) {
+	if f == nil {
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for fills, user := range f.subscribers {
+		if user != "" && user != fill.User {
+			continue
+		}
+		select {
+		case fills <- fill:
+		default:
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"seng468/transaction-server/database"
+	"seng468/transaction-server/socketserver"
+	"seng468/transaction-server/transactionpb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcErrorDomain is the ErrorInfo domain attached to failed RPCs
+const grpcErrorDomain = "transaction-server"
+
+// grpcCodes maps socket protocol error codes to gRPC status codes.
+// Codes missing from the map are returned as Internal.
+var grpcCodes = map[string]codes.Code{
+	socketserver.CodeBadRequest:          codes.InvalidArgument,
+	socketserver.CodeInsufficientFunds:   codes.FailedPrecondition,
+	socketserver.CodeInsufficientStock:   codes.FailedPrecondition,
+	socketserver.CodeInsufficientReserve: codes.FailedPrecondition,
+	socketserver.CodeNoPendingBuy:        codes.NotFound,
+	socketserver.CodeNoPendingSell:       codes.NotFound,
+	socketserver.CodeNoTrigger:           codes.NotFound,
+	socketserver.CodeOrderExpired:        codes.FailedPrecondition,
+	socketserver.CodeTriggerExists:       codes.AlreadyExists,
+	socketserver.CodeTriggerFired:        codes.FailedPrecondition,
+	socketserver.CodeNoLimitOrder:        codes.NotFound,
+	socketserver.CodeLimitNotMet:         codes.FailedPrecondition,
+	socketserver.CodeQuoteUnavailable:    codes.Unavailable,
+	socketserver.CodeTriggerUnavailable:  codes.Unavailable,
+	socketserver.CodeDatabaseUnavailable: codes.Unavailable,
+	socketserver.CodeAccountExists:       codes.AlreadyExists,
+	socketserver.CodeBadCredentials:      codes.Unauthenticated,
+}
+
+// grpcServer exposes the transaction server's commands over gRPC. Each RPC
+// checks its required fields, then calls the same TransactionServer method
+// as the socket route.
+type grpcServer struct {
+	transactionpb.UnimplementedTransactionServer
+	ts *TransactionServer
+}
+
+// serveGRPC serves the gRPC interface on addr alongside the socket server
+func serveGRPC(addr string, ts *TransactionServer) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println("Error listening for gRPC:", err.Error())
+		return
+	}
+	server := grpc.NewServer()
+	transactionpb.RegisterTransactionServer(server, grpcServer{ts: ts})
+	fmt.Println("Serving gRPC on " + addr)
+	if err := server.Serve(l); err != nil {
+		fmt.Println("Error serving gRPC:", err.Error())
+	}
+}
+
+// resultError converts a failed Result into a gRPC status error whose
+// ErrorInfo reason is the Result's code. Returns nil for a successful Result.
+func resultError(res socketserver.Result) error {
+	if res.Succeeded() {
+		return nil
+	}
+	code, ok := grpcCodes[res.Code]
+	if !ok {
+		code = codes.Internal
+	}
+	st := status.New(code, res.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: res.Code, Domain: grpcErrorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// required rejects a request missing a field, as the socket router rejects empty parameters
+func required(fields ...string) error {
+	for _, field := range fields {
+		if field == "" {
+			return resultError(socketserver.Error(socketserver.CodeBadRequest, "Missing a required field"))
+		}
+	}
+	return nil
+}
+
+// done finishes an RPC with no reply body
+func done(res socketserver.Result) (*emptypb.Empty, error) {
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (g grpcServer) Register(ctx context.Context, req *transactionpb.CredentialsRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Password); err != nil {
+		return nil, err
+	}
+	return done(g.ts.Register(int(req.TransNum), req.User, req.Password))
+}
+
+func (g grpcServer) Authenticate(ctx context.Context, req *transactionpb.CredentialsRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Password); err != nil {
+		return nil, err
+	}
+	return done(g.ts.Authenticate(int(req.TransNum), req.User, req.Password))
+}
+
+func (g grpcServer) Add(ctx context.Context, req *transactionpb.AddRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Amount); err != nil {
+		return nil, err
+	}
+	return done(g.ts.Add(int(req.TransNum), req.User, req.Amount))
+}
+
+func (g grpcServer) Quote(ctx context.Context, req *transactionpb.StockRequest) (*transactionpb.QuoteReply, error) {
+	if err := required(req.User, req.Stock); err != nil {
+		return nil, err
+	}
+	res := g.ts.Quote(int(req.TransNum), req.User, req.Stock)
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	price, _ := res.Payload.(string)
+	return &transactionpb.QuoteReply{Price: price}, nil
+}
+
+func (g grpcServer) Buy(ctx context.Context, req *transactionpb.OrderRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return done(g.ts.Buy(int(req.TransNum), req.User, req.Stock, req.Amount))
+}
+
+func (g grpcServer) CommitBuy(ctx context.Context, req *transactionpb.UserRequest) (*emptypb.Empty, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CommitBuy(int(req.TransNum), req.User))
+}
+
+func (g grpcServer) CancelBuy(ctx context.Context, req *transactionpb.UserRequest) (*emptypb.Empty, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CancelBuy(int(req.TransNum), req.User))
+}
+
+func (g grpcServer) Sell(ctx context.Context, req *transactionpb.OrderRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return done(g.ts.Sell(int(req.TransNum), req.User, req.Stock, req.Amount))
+}
+
+func (g grpcServer) CommitSell(ctx context.Context, req *transactionpb.UserRequest) (*emptypb.Empty, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CommitSell(int(req.TransNum), req.User))
+}
+
+func (g grpcServer) CancelSell(ctx context.Context, req *transactionpb.UserRequest) (*emptypb.Empty, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CancelSell(int(req.TransNum), req.User))
+}
+
+// triggerIDReply finishes an RPC whose Result carries a new trigger's ID
+func triggerIDReply(res socketserver.Result) (*transactionpb.TriggerIDReply, error) {
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	id, _ := res.Payload.(string)
+	return &transactionpb.TriggerIDReply{TriggerId: id}, nil
+}
+
+func (g grpcServer) SetBuyAmount(ctx context.Context,
+	req *transactionpb.OrderRequest) (*transactionpb.TriggerIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return triggerIDReply(g.ts.SetBuyAmount(int(req.TransNum), req.User, req.Stock, req.Amount))
+}
+
+func (g grpcServer) CancelSetBuy(ctx context.Context, req *transactionpb.StockRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CancelSetBuy(int(req.TransNum), req.User, req.Stock, req.TriggerId))
+}
+
+func (g grpcServer) SetBuyTrigger(ctx context.Context, req *transactionpb.OrderRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return done(g.ts.SetBuyTrigger(int(req.TransNum), req.User, req.Stock, req.Amount, req.TriggerId, req.Expires))
+}
+
+func (g grpcServer) SetSellAmount(ctx context.Context,
+	req *transactionpb.OrderRequest) (*transactionpb.TriggerIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return triggerIDReply(g.ts.SetSellAmount(int(req.TransNum), req.User, req.Stock, req.Amount))
+}
+
+func (g grpcServer) SetSellTrigger(ctx context.Context, req *transactionpb.OrderRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock, req.Amount); err != nil {
+		return nil, err
+	}
+	return done(g.ts.SetSellTrigger(int(req.TransNum), req.User, req.Stock, req.Amount, req.TriggerId,
+		req.Expires))
+}
+
+func (g grpcServer) CancelSetSell(ctx context.Context, req *transactionpb.StockRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CancelSetSell(int(req.TransNum), req.User, req.Stock, req.TriggerId))
+}
+
+func (g grpcServer) TriggerSuccess(ctx context.Context,
+	req *transactionpb.TriggerSuccessRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.Stock, req.Price, req.Amount, req.Action, req.TriggerId); err != nil {
+		return nil, err
+	}
+	return done(g.ts.TriggerSuccess(int(req.TransNum), req.User, req.Stock, req.Price, req.Amount,
+		req.Action, req.TriggerId))
+}
+
+func (g grpcServer) ReconcileTriggers(ctx context.Context,
+	req *transactionpb.ReconcileTriggersRequest) (*emptypb.Empty, error) {
+	return done(g.ts.ReconcileTriggers(int(req.TransNum)))
+}
+
+// limitOrderParams are the socket params of an order request, the expiry
+// only being sent when there is one as the socket router requires
+func limitOrderParams(req *transactionpb.LimitOrderRequest) []string {
+	params := []string{req.User, req.Stock, req.Amount, req.Price, req.TimeInForce}
+	if req.Expires != "" {
+		params = append(params, req.Expires)
+	}
+	return params
+}
+
+// orderIDReply finishes an RPC whose Result carries a new order's ID
+func orderIDReply(res socketserver.Result) (*transactionpb.OrderIDReply, error) {
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	id, _ := res.Payload.(string)
+	return &transactionpb.OrderIDReply{OrderId: id}, nil
+}
+
+func (g grpcServer) LimitBuy(ctx context.Context,
+	req *transactionpb.LimitOrderRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Price, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.LimitBuy(int(req.TransNum), limitOrderParams(req)...))
+}
+
+func (g grpcServer) LimitSell(ctx context.Context,
+	req *transactionpb.LimitOrderRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Price, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.LimitSell(int(req.TransNum), limitOrderParams(req)...))
+}
+
+func (g grpcServer) StopBuy(ctx context.Context,
+	req *transactionpb.LimitOrderRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Price, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.StopBuy(int(req.TransNum), limitOrderParams(req)...))
+}
+
+func (g grpcServer) StopSell(ctx context.Context,
+	req *transactionpb.LimitOrderRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Price, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.StopSell(int(req.TransNum), limitOrderParams(req)...))
+}
+
+// trailingStopParams are the socket params of a trailing stop request
+func trailingStopParams(req *transactionpb.TrailingStopRequest) []string {
+	params := []string{req.User, req.Stock, req.Amount, req.Trail, req.TimeInForce}
+	if req.Expires != "" {
+		params = append(params, req.Expires)
+	}
+	return params
+}
+
+func (g grpcServer) TrailingStopBuy(ctx context.Context,
+	req *transactionpb.TrailingStopRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Trail, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.TrailingStopBuy(int(req.TransNum), trailingStopParams(req)...))
+}
+
+func (g grpcServer) TrailingStopSell(ctx context.Context,
+	req *transactionpb.TrailingStopRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Trail, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	return orderIDReply(g.ts.TrailingStopSell(int(req.TransNum), trailingStopParams(req)...))
+}
+
+func (g grpcServer) OCOSell(ctx context.Context,
+	req *transactionpb.OCOSellRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Shares, req.TakeProfit, req.StopLoss, req.TimeInForce); err != nil {
+		return nil, err
+	}
+	params := []string{req.User, req.Stock, req.Shares, req.TakeProfit, req.StopLoss, req.TimeInForce}
+	if req.Expires != "" {
+		params = append(params, req.Expires)
+	}
+	return orderIDReply(g.ts.OCOSell(int(req.TransNum), params...))
+}
+
+func (g grpcServer) BracketBuy(ctx context.Context,
+	req *transactionpb.BracketBuyRequest) (*transactionpb.OrderIDReply, error) {
+	if err := required(req.User, req.Stock, req.Amount, req.Entry, req.TakeProfit, req.StopLoss,
+		req.TimeInForce); err != nil {
+		return nil, err
+	}
+	params := []string{req.User, req.Stock, req.Amount, req.Entry, req.TakeProfit, req.StopLoss, req.TimeInForce}
+	if req.Expires != "" {
+		params = append(params, req.Expires)
+	}
+	return orderIDReply(g.ts.BracketBuy(int(req.TransNum), params...))
+}
+
+func (g grpcServer) ListLimitOrders(ctx context.Context,
+	req *transactionpb.UserRequest) (*transactionpb.LimitOrdersReply, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	res := g.ts.ListLimitOrders(int(req.TransNum), req.User)
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	orders, _ := res.Payload.([]database.LimitOrder)
+	reply := &transactionpb.LimitOrdersReply{Orders: make([]*transactionpb.LimitOrder, len(orders))}
+	for i, order := range orders {
+		reply.Orders[i] = limitOrder(order)
+	}
+	return reply, nil
+}
+
+func (g grpcServer) AmendLimitOrder(ctx context.Context,
+	req *transactionpb.AmendLimitOrderRequest) (*transactionpb.LimitOrder, error) {
+	if err := required(req.User, req.OrderId, req.Amount, req.Price); err != nil {
+		return nil, err
+	}
+	res := g.ts.AmendLimitOrder(int(req.TransNum), req.User, req.OrderId, req.Amount, req.Price)
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	order, _ := res.Payload.(database.LimitOrder)
+	return limitOrder(order), nil
+}
+
+func (g grpcServer) CancelLimitOrder(ctx context.Context,
+	req *transactionpb.LimitOrderIDRequest) (*emptypb.Empty, error) {
+	if err := required(req.User, req.OrderId); err != nil {
+		return nil, err
+	}
+	return done(g.ts.CancelLimitOrder(int(req.TransNum), req.User, req.OrderId))
+}
+
+func (g grpcServer) DumpLog(ctx context.Context, req *transactionpb.DumpLogRequest) (*emptypb.Empty, error) {
+	if err := required(req.Filename); err != nil {
+		return nil, err
+	}
+	if req.User == "" {
+		return done(g.ts.DumpLogUser(int(req.TransNum), req.Filename))
+	}
+	return done(g.ts.DumpLogUser(int(req.TransNum), req.User, req.Filename))
+}
+
+func (g grpcServer) DisplaySummary(req *transactionpb.UserRequest,
+	stream transactionpb.Transaction_DisplaySummaryServer) error {
+	if err := required(req.User); err != nil {
+		return err
+	}
+	res := g.ts.DisplaySummary(int(req.TransNum), req.User)
+	if err := resultError(res); err != nil {
+		return err
+	}
+	info, _ := res.Payload.(string)
+	for _, line := range strings.Split(info, ";") {
+		if err := stream.Send(&transactionpb.SummaryLine{Line: line}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g grpcServer) History(ctx context.Context, req *transactionpb.HistoryRequest) (*transactionpb.HistoryReply, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	if req.Page < 0 {
+		return nil, resultError(socketserver.Error(socketserver.CodeBadRequest, "History page can't be negative"))
+	}
+	res := g.ts.History(int(req.TransNum), req.User, fmt.Sprint(req.Page))
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	history, _ := res.Payload.([]database.HistoryEntry)
+	return &transactionpb.HistoryReply{Entries: historyEntries(history)}, nil
+}
+
+func (g grpcServer) Account(ctx context.Context, req *transactionpb.UserRequest) (*transactionpb.AccountReply, error) {
+	if err := required(req.User); err != nil {
+		return nil, err
+	}
+	res := g.ts.Account(int(req.TransNum), req.User)
+	if err := resultError(res); err != nil {
+		return nil, err
+	}
+	account, _ := res.Payload.(database.Account)
+	reply := &transactionpb.AccountReply{
+		User:           account.User,
+		Funds:          account.Funds.StringFixed(2),
+		ReservedFunds:  account.ReservedFunds.StringFixed(2),
+		Stocks:         account.Stocks,
+		ReservedStocks: account.ReservedStocks,
+		BuyOrders:      pendingOrders(account.BuyOrders),
+		SellOrders:     pendingOrders(account.SellOrders),
+		BuyTriggers:    make(map[string]string),
+		SellTriggers:   account.SellTriggers,
+		History:        historyEntries(account.History),
+	}
+	for stock, funds := range account.BuyTriggers {
+		reply.BuyTriggers[stock] = funds.StringFixed(2)
+	}
+	for _, trigger := range account.Triggers {
+		reply.Triggers = append(reply.Triggers, &transactionpb.TriggerStatus{TriggerId: trigger.ID,
+			Side: trigger.Side, Stock: trigger.Stock, Funds: trigger.Funds.StringFixed(2), Shares: trigger.Shares})
+	}
+	return reply, nil
+}
+
+func historyEntries(history []database.HistoryEntry) []*transactionpb.HistoryEntry {
+	entries := make([]*transactionpb.HistoryEntry, len(history))
+	for i, entry := range history {
+		entries[i] = &transactionpb.HistoryEntry{
+			TransNum:  int32(entry.TransNum),
+			Command:   entry.Command,
+			Stock:     entry.Stock,
+			Funds:     entry.Funds.StringFixed(2),
+			Shares:    entry.Shares,
+			Price:     entry.Price.StringFixed(2),
+			Timestamp: entry.Timestamp,
+		}
+	}
+	return entries
+}
+
+func limitOrder(order database.LimitOrder) *transactionpb.LimitOrder {
+	reply := &transactionpb.LimitOrder{
+		OrderId:     order.ID,
+		Stock:       order.Stock,
+		Type:        order.Type,
+		Side:        order.Side,
+		Shares:      order.Shares,
+		Price:       order.Price.StringFixed(2),
+		Funds:       order.Funds.StringFixed(2),
+		TimeInForce: order.TIF,
+		Created:     order.Created.UnixNano() / int64(1e6),
+		Trail:       order.Trail,
+	}
+	if !order.Expires.IsZero() {
+		reply.Expires = order.Expires.UnixNano() / int64(1e6)
+	}
+	if order.Exits != nil {
+		reply.TakeProfit = order.Exits.TakeProfit.StringFixed(2)
+		reply.StopLoss = order.Exits.StopLoss.StringFixed(2)
+	}
+	return reply
+}
+
+func pendingOrders(orders []database.Order) []*transactionpb.PendingOrder {
+	pending := make([]*transactionpb.PendingOrder, len(orders))
+	for i, order := range orders {
+		pending[i] = &transactionpb.PendingOrder{
+			Type:    order.Type,
+			Stock:   order.Stock,
+			Cost:    order.Cost.StringFixed(2),
+			Shares:  order.Shares,
+			Created: order.Created.UnixNano() / int64(1e6),
+		}
+	}
+	return pending
+}
+
+func (g grpcServer) TriggerFills(req *transactionpb.TriggerFillsRequest,
+	stream transactionpb.Transaction_TriggerFillsServer) error {
+	if g.ts.Fills == nil {
+		return status.Error(codes.Unimplemented, "This transaction server does not publish trigger fills")
+	}
+	fills, unsubscribe := g.ts.Fills.Subscribe(req.User)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case fill := <-fills:
+			err := stream.Send(&transactionpb.TriggerFill{
+				TransNum:  int32(fill.TransNum),
+				TriggerId: fill.TriggerID,
+				User:      fill.User,
+				Stock:     fill.Stock,
+				Action:    fill.Action,
+				Price:     fill.Price.StringFixed(2),
+				Amount:    fill.Amount.StringFixed(2),
+				Timestamp: fill.Time.UnixNano() / int64(1e6),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
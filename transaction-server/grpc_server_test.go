@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"seng468/transaction-server/socketserver"
+	"seng468/transaction-server/transactionpb"
+
+	"github.com/shopspring/decimal"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves ts over an in-memory connection and returns a client for it
+func newGRPCClient(t *testing.T, ts *TransactionServer) transactionpb.TransactionClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	transactionpb.RegisterTransactionServer(server, grpcServer{ts: ts})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return transactionpb.NewTransactionClient(conn)
+}
+
+// expectStatus checks that err is a gRPC status with code and the socket protocol's reason
+func expectStatus(t *testing.T, rpc string, err error, code codes.Code, reason string) {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code {
+		t.Errorf("%s returned %v, expected %s", rpc, err, code)
+		return
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Reason == reason {
+			return
+		}
+	}
+	t.Errorf("%s returned %v without reason %s", rpc, err, reason)
+}
+
+func TestGRPC_BuyAndSummary(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(15.00))
+	client := newGRPCClient(t, &ts)
+	ctx := context.Background()
+
+	if _, err := client.Add(ctx, &transactionpb.AddRequest{TransNum: 1, User: "user1", Amount: "100.00"}); err != nil {
+		t.Fatal(err)
+	}
+	quote, err := client.Quote(ctx, &transactionpb.StockRequest{TransNum: 2, User: "user1", Stock: "ABC"})
+	if err != nil || quote.Price != "15.00" {
+		t.Errorf("QUOTE returned %v, %v", quote, err)
+	}
+
+	_, err = client.Buy(ctx, &transactionpb.OrderRequest{TransNum: 3, User: "user1", Stock: "ABC", Amount: "500.00"})
+	expectStatus(t, "BUY", err, codes.FailedPrecondition, socketserver.CodeInsufficientFunds)
+	_, err = client.CommitBuy(ctx, &transactionpb.UserRequest{TransNum: 4, User: "user1"})
+	expectStatus(t, "COMMIT_BUY", err, codes.NotFound, socketserver.CodeNoPendingBuy)
+	_, err = client.Buy(ctx, &transactionpb.OrderRequest{TransNum: 5, User: "user1", Stock: "ABC"})
+	expectStatus(t, "BUY", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+
+	if _, err := client.Buy(ctx, &transactionpb.OrderRequest{TransNum: 6, User: "user1", Stock: "ABC",
+		Amount: "45.00"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CommitBuy(ctx, &transactionpb.UserRequest{TransNum: 7, User: "user1"}); err != nil {
+		t.Fatal(err)
+	}
+	expectStock(t, ts, "user1", "ABC", 3)
+
+	summary, err := client.DisplaySummary(ctx, &transactionpb.UserRequest{TransNum: 8, User: "user1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for {
+		_, err := summary.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		lines++
+	}
+	if lines == 0 {
+		t.Error("DISPLAY_SUMMARY streamed no lines")
+	}
+
+	history, err := client.History(ctx, &transactionpb.HistoryRequest{TransNum: 9, User: "user1"})
+	if err != nil || len(history.Entries) == 0 || history.Entries[0].Command != "COMMIT_BUY" {
+		t.Errorf("HISTORY returned %v, %v", history, err)
+	}
+
+	account, err := client.Account(ctx, &transactionpb.UserRequest{TransNum: 10, User: "user1"})
+	if err != nil || account.Funds != "55.00" || account.Stocks["ABC"] != 3 || len(account.History) == 0 {
+		t.Errorf("ACCOUNT returned %v, %v", account, err)
+	}
+}
+
+func TestGRPC_TriggerFills(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Fills = NewFillFeed()
+	client := newGRPCClient(t, &ts)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts.Add(1, "user1", "100.00")
+	id, _ := ts.SetBuyAmount(2, "user1", "ABC", "50.00").Payload.(string)
+	ts.SetBuyTrigger(3, "user1", "ABC", "20.00")
+
+	feed, err := client.TriggerFills(ctx, &transactionpb.TriggerFillsRequest{User: "user1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fills are only sent to streams subscribed when they happen
+	for deadline := time.Now().Add(time.Second); len(subscribers(ts.Fills)) == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("TriggerFills never subscribed")
+		}
+	}
+
+	if _, err := client.TriggerSuccess(ctx, &transactionpb.TriggerSuccessRequest{TransNum: 5, User: "user1",
+		Stock: "ABC", Price: "12.00", Amount: "50.00", Action: "BUY", TriggerId: id}); err != nil {
+		t.Fatal(err)
+	}
+
+	fill, err := feed.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fill.TriggerId != id || fill.User != "user1" || fill.Price != "12.00" || fill.TransNum != 5 {
+		t.Error("Unexpected fill ", fill)
+	}
+}
+
+func TestGRPC_TriggerIDs(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	client := newGRPCClient(t, &ts)
+	ctx := context.Background()
+	ts.Add(1, "user1", "100.00")
+
+	first, err := client.SetBuyAmount(ctx, &transactionpb.OrderRequest{TransNum: 2, User: "user1", Stock: "ABC",
+		Amount: "10.00"})
+	if err != nil || first.TriggerId == "" {
+		t.Fatal("SET_BUY_AMOUNT should reply with the trigger's ID, got ", first, err)
+	}
+	second, err := client.SetBuyAmount(ctx, &transactionpb.OrderRequest{TransNum: 3, User: "user1", Stock: "ABC",
+		Amount: "20.00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.SetBuyTrigger(ctx, &transactionpb.OrderRequest{TransNum: 4, User: "user1", Stock: "ABC",
+		Amount: "5.00", TriggerId: first.TriggerId, Expires: "1"})
+	expectStatus(t, "SET_BUY_TRIGGER", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+
+	// The first trigger is picked out by its ID, not the latest one
+	if _, err := client.SetBuyTrigger(ctx, &transactionpb.OrderRequest{TransNum: 4, User: "user1", Stock: "ABC",
+		Amount: "5.00", TriggerId: first.TriggerId,
+		Expires: fmt.Sprint(time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CancelSetBuy(ctx, &transactionpb.StockRequest{TransNum: 5, User: "user1", Stock: "ABC",
+		TriggerId: first.TriggerId}); err != nil {
+		t.Fatal(err)
+	}
+	account, err := client.Account(ctx, &transactionpb.UserRequest{TransNum: 6, User: "user1"})
+	if err != nil || len(account.Triggers) != 1 || account.Triggers[0].TriggerId != second.TriggerId ||
+		account.Triggers[0].Funds != "20.00" {
+		t.Errorf("ACCOUNT should list only the second trigger, got %v, %v", account, err)
+	}
+	expectFunds(t, ts, "user1", 80.00)
+}
+
+func TestGRPC_LimitOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(12.00))
+	client := newGRPCClient(t, &ts)
+	ctx := context.Background()
+	ts.Add(1, "user1", "100.00")
+
+	_, err := client.LimitBuy(ctx, &transactionpb.LimitOrderRequest{TransNum: 2, User: "user1", Stock: "ABC",
+		Amount: "50.00", Price: "10.00", TimeInForce: "IOC"})
+	expectStatus(t, "LIMIT_BUY", err, codes.FailedPrecondition, socketserver.CodeLimitNotMet)
+	_, err = client.LimitBuy(ctx, &transactionpb.LimitOrderRequest{TransNum: 3, User: "user1", Stock: "ABC",
+		Amount: "50.00", Price: "10.00"})
+	expectStatus(t, "LIMIT_BUY", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+
+	expiry := time.Now().Add(time.Hour)
+	buy, err := client.LimitBuy(ctx, &transactionpb.LimitOrderRequest{TransNum: 4, User: "user1", Stock: "ABC",
+		Amount: "40.00", Price: "10.00", TimeInForce: "GTD",
+		Expires: fmt.Sprint(expiry.UnixNano() / int64(time.Millisecond))})
+	if err != nil || buy.OrderId == "" {
+		t.Fatal("LIMIT_BUY should reply with the order's ID, got ", buy, err)
+	}
+	_, err = client.LimitSell(ctx, &transactionpb.LimitOrderRequest{TransNum: 5, User: "user1", Stock: "ABC",
+		Amount: "1", Price: "20.00", TimeInForce: "GTC"})
+	expectStatus(t, "LIMIT_SELL", err, codes.FailedPrecondition, socketserver.CodeInsufficientStock)
+
+	orders, err := client.ListLimitOrders(ctx, &transactionpb.UserRequest{TransNum: 6, User: "user1"})
+	if err != nil || len(orders.Orders) != 1 || orders.Orders[0].OrderId != buy.OrderId ||
+		orders.Orders[0].Shares != 4 || orders.Orders[0].Funds != "40.00" ||
+		orders.Orders[0].Expires != expiry.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("LIST_LIMIT_ORDERS returned %v, %v", orders, err)
+	}
+
+	amended, err := client.AmendLimitOrder(ctx, &transactionpb.AmendLimitOrderRequest{TransNum: 7, User: "user1",
+		OrderId: buy.OrderId, Amount: "20.00", Price: "5.00"})
+	if err != nil || amended.Shares != 4 || amended.Price != "5.00" || amended.Side != "BUY" {
+		t.Errorf("AMEND_LIMIT_ORDER returned %v, %v", amended, err)
+	}
+	expectFunds(t, ts, "user1", 80.00)
+	_, err = client.AmendLimitOrder(ctx, &transactionpb.AmendLimitOrderRequest{TransNum: 8, User: "user1",
+		OrderId: "missing", Amount: "20.00", Price: "5.00"})
+	expectStatus(t, "AMEND_LIMIT_ORDER", err, codes.NotFound, socketserver.CodeNoLimitOrder)
+
+	if _, err := client.CancelLimitOrder(ctx, &transactionpb.LimitOrderIDRequest{TransNum: 9, User: "user1",
+		OrderId: buy.OrderId}); err != nil {
+		t.Fatal(err)
+	}
+	expectFunds(t, ts, "user1", 100.00)
+	_, err = client.CancelLimitOrder(ctx, &transactionpb.LimitOrderIDRequest{TransNum: 10, User: "user1",
+		OrderId: buy.OrderId})
+	expectStatus(t, "CANCEL_LIMIT_ORDER", err, codes.NotFound, socketserver.CodeNoLimitOrder)
+}
+
+func TestGRPC_StopOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	client := newGRPCClient(t, &ts)
+	ctx := context.Background()
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 5)
+
+	_, err := client.StopSell(ctx, &transactionpb.LimitOrderRequest{TransNum: 2, User: "user1", Stock: "ABC",
+		Amount: "5", Price: "8.00", TimeInForce: "IOC"})
+	expectStatus(t, "STOP_SELL", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	stop, err := client.StopSell(ctx, &transactionpb.LimitOrderRequest{TransNum: 3, User: "user1", Stock: "ABC",
+		Amount: "3", Price: "8.00", TimeInForce: "GTC"})
+	if err != nil || stop.OrderId == "" {
+		t.Fatal("STOP_SELL should reply with the order's ID, got ", stop, err)
+	}
+	expectStock(t, ts, "user1", "ABC", 2)
+	stopBuy, err := client.StopBuy(ctx, &transactionpb.LimitOrderRequest{TransNum: 4, User: "user1", Stock: "ABC",
+		Amount: "24.00", Price: "12.00", TimeInForce: "GTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFunds(t, ts, "user1", 76.00)
+
+	_, err = client.TrailingStopSell(ctx, &transactionpb.TrailingStopRequest{TransNum: 5, User: "user1",
+		Stock: "ABC", Amount: "2", Trail: "100%", TimeInForce: "GTC"})
+	expectStatus(t, "TRAILING_STOP_SELL", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	_, err = client.TrailingStopSell(ctx, &transactionpb.TrailingStopRequest{TransNum: 6, User: "user1",
+		Stock: "ABC", Amount: "2", TimeInForce: "GTC"})
+	expectStatus(t, "TRAILING_STOP_SELL", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	trailing, err := client.TrailingStopSell(ctx, &transactionpb.TrailingStopRequest{TransNum: 7, User: "user1",
+		Stock: "ABC", Amount: "2", Trail: "10%", TimeInForce: "GTC"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trailingBuy, err := client.TrailingStopBuy(ctx, &transactionpb.TrailingStopRequest{TransNum: 8, User: "user1",
+		Stock: "ABC", Amount: "20.00", Trail: "1.00", TimeInForce: "DAY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStock(t, ts, "user1", "ABC", 0)
+	expectFunds(t, ts, "user1", 56.00)
+
+	orders, err := client.ListLimitOrders(ctx, &transactionpb.UserRequest{TransNum: 9, User: "user1"})
+	if err != nil || len(orders.Orders) != 4 {
+		t.Fatalf("LIST_LIMIT_ORDERS returned %v, %v", orders, err)
+	}
+	types := map[string]string{stop.OrderId: "STOP", stopBuy.OrderId: "STOP", trailing.OrderId: "TRAILING_STOP",
+		trailingBuy.OrderId: "TRAILING_STOP"}
+	for _, order := range orders.Orders {
+		if order.Type != types[order.OrderId] {
+			t.Error("Unexpected order ", order)
+		}
+		if order.OrderId == trailing.OrderId && (order.Trail != "10%" || order.Price != "10.00") {
+			t.Error("Expected a trailing stop from a mark of 10.00, have ", order)
+		}
+	}
+
+	_, err = client.AmendLimitOrder(ctx, &transactionpb.AmendLimitOrderRequest{TransNum: 10, User: "user1",
+		OrderId: trailing.OrderId, Amount: "1", Price: "9.00"})
+	expectStatus(t, "AMEND_LIMIT_ORDER", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	if _, err := client.CancelLimitOrder(ctx, &transactionpb.LimitOrderIDRequest{TransNum: 11, User: "user1",
+		OrderId: stopBuy.OrderId}); err != nil {
+		t.Fatal(err)
+	}
+	expectFunds(t, ts, "user1", 80.00)
+}
+
+func TestGRPC_OrderGroups(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	client := newGRPCClient(t, &ts)
+	ctx := context.Background()
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+
+	_, err := client.OCOSell(ctx, &transactionpb.OCOSellRequest{TransNum: 2, User: "user1", Stock: "ABC",
+		Shares: "4", TakeProfit: "8.00", StopLoss: "12.00", TimeInForce: "GTC"})
+	expectStatus(t, "OCO_SELL", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	oco, err := client.OCOSell(ctx, &transactionpb.OCOSellRequest{TransNum: 3, User: "user1", Stock: "ABC",
+		Shares: "4", TakeProfit: "12.00", StopLoss: "8.00", TimeInForce: "GTC"})
+	if err != nil || oco.OrderId == "" {
+		t.Fatal("OCO_SELL should reply with the group's ID, got ", oco, err)
+	}
+	expectStock(t, ts, "user1", "ABC", 6)
+
+	_, err = client.BracketBuy(ctx, &transactionpb.BracketBuyRequest{TransNum: 4, User: "user1", Stock: "ABC",
+		Amount: "40.00", Entry: "13.00", TakeProfit: "12.00", StopLoss: "8.00", TimeInForce: "GTC"})
+	expectStatus(t, "BRACKET_BUY", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	bracket, err := client.BracketBuy(ctx, &transactionpb.BracketBuyRequest{TransNum: 5, User: "user1",
+		Stock: "ABC", Amount: "40.00", Entry: "10.00", TakeProfit: "12.00", StopLoss: "8.00", TimeInForce: "GTC"})
+	if err != nil || bracket.OrderId == "" {
+		t.Fatal("BRACKET_BUY should reply with the bracket's ID, got ", bracket, err)
+	}
+	expectFunds(t, ts, "user1", 60.00)
+
+	orders, err := client.ListLimitOrders(ctx, &transactionpb.UserRequest{TransNum: 6, User: "user1"})
+	if err != nil || len(orders.Orders) != 2 {
+		t.Fatalf("LIST_LIMIT_ORDERS returned %v, %v", orders, err)
+	}
+	for _, order := range orders.Orders {
+		if order.TakeProfit != "12.00" || order.StopLoss != "8.00" ||
+			(order.OrderId == bracket.OrderId && (order.Type != "BRACKET" || order.Price != "10.00")) ||
+			(order.OrderId == oco.OrderId && order.Type != "OCO") {
+			t.Error("Unexpected order group ", order)
+		}
+	}
+
+	_, err = client.AmendLimitOrder(ctx, &transactionpb.AmendLimitOrderRequest{TransNum: 7, User: "user1",
+		OrderId: oco.OrderId, Amount: "2", Price: "11.00"})
+	expectStatus(t, "AMEND_LIMIT_ORDER", err, codes.InvalidArgument, socketserver.CodeBadRequest)
+	for i, id := range []string{oco.OrderId, bracket.OrderId} {
+		if _, err := client.CancelLimitOrder(ctx, &transactionpb.LimitOrderIDRequest{TransNum: int32(8 + i),
+			User: "user1", OrderId: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectStock(t, ts, "user1", "ABC", 10)
+	expectFunds(t, ts, "user1", 100.00)
+}
+
+func subscribers(f *FillFeed) map[chan TriggerFill]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	copied := make(map[chan TriggerFill]string)
+	for fills, user := range f.subscribers {
+		copied[fills] = user
+	}
+	return copied
+}
+
+func TestFillFeed(t *testing.T) {
+	feed := NewFillFeed()
+	user1, unsubscribe1 := feed.Subscribe("user1")
+	everyone, unsubscribe2 := feed.Subscribe("")
+	defer unsubscribe2()
+
+	feed.Publish(TriggerFill{User: "user2", TriggerID: "a"})
+	feed.Publish(TriggerFill{User: "user1", TriggerID: "b"})
+	if fill := <-user1; fill.TriggerID != "b" {
+		t.Error("user1 should only see its own fill, got ", fill)
+	}
+	if first, second := <-everyone, <-everyone; first.TriggerID != "a" || second.TriggerID != "b" {
+		t.Error("An unfiltered subscriber should see every fill, got ", first, second)
+	}
+
+	unsubscribe1()
+	if _, ok := <-user1; ok {
+		t.Error("Unsubscribing should close the channel")
+	}
+	feed.Publish(TriggerFill{User: "user1"})
+
+	var nilFeed *FillFeed
+	nilFeed.Publish(TriggerFill{User: "user1"})
+}
@@ -0,0 +1,646 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"seng468/transaction-server/database"
+	"seng468/transaction-server/socketserver"
+	"seng468/transaction-server/trigger"
+
+	"github.com/shopspring/decimal"
+)
+
+// Time in force of a limit order
+const (
+	TIFImmediateOrCancel = "IOC" // Fills right away at the current quote or not at all
+	TIFDay               = "DAY" // Expires at the end of the day it was placed
+	TIFGoodTillCancelled = "GTC" // Waits until it fills or is cancelled
+	TIFGoodTillDate      = "GTD" // Expires at the time given with the order
+)
+
+// newOrderID returns a random ID for a limit order or trigger. The triggerserver and
+// the database both know the order by it, and it is the trigger ID the order fires with.
+func newOrderID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// orderTrigger is the order as it is placed with the triggerserver
+func orderTrigger(transNum int, order database.LimitOrder) triggerclient.Trigger {
+	if order.Type == database.OrderTrailingStop {
+		return triggerclient.NewTrailingStop(transNum, order.ID, order.User, order.Stock, order.Shares, order.Price,
+			order.Trail, order.Action())
+	}
+	return triggerclient.NewLimitOrder(transNum, order.ID, order.User, order.Stock, order.Shares, order.Price,
+		order.Action())
+}
+
+// parseExpiry returns when an order placed at now with the time in force tif
+// expires, or the zero time if it doesn't. Only GTD takes an expiry, in unix milliseconds.
+func parseExpiry(tif string, expiry []string, now time.Time) (time.Time, error) {
+	if tif != TIFGoodTillDate && len(expiry) != 0 {
+		return time.Time{}, fmt.Errorf("%s orders take no expiry", tif)
+	}
+	switch tif {
+	case TIFImmediateOrCancel, TIFGoodTillCancelled:
+		return time.Time{}, nil
+	case TIFDay:
+		year, month, day := now.Date()
+		return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()), nil
+	case TIFGoodTillDate:
+		if len(expiry) == 0 {
+			return time.Time{}, fmt.Errorf("GTD orders need an expiry")
+		}
+		millis, err := strconv.ParseInt(expiry[0], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("could not parse expiry")
+		}
+		expires := time.Unix(0, millis*int64(time.Millisecond))
+		if !expires.After(now) {
+			return time.Time{}, fmt.Errorf("expiry has already passed")
+		}
+		return expires, nil
+	}
+	return time.Time{}, fmt.Errorf("time in force must be IOC, DAY, GTC or GTD")
+}
+
+// parseLimitPrice parses a limit or stop price, which must be positive
+func parseLimitPrice(price string) (decimal.Decimal, error) {
+	limit, err := decimal.NewFromString(price)
+	if err != nil || !limit.Truncate(2).GreaterThan(decimal.Zero) {
+		return decimal.Zero, fmt.Errorf("could not parse price to a positive decimal")
+	}
+	return limit.Truncate(2), nil
+}
+
+// parseLimitShares parses the amount of an order on side into shares.
+// A buy's amount is dollars to spend at the price and a sell's is shares.
+func parseLimitShares(side string, amount string, price decimal.Decimal) (int64, error) {
+	var shares int64
+	if side == "BUY" {
+		dollars, err := decimal.NewFromString(amount)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse buy amount to decimal")
+		}
+		shares = dollars.Div(price).IntPart()
+	} else {
+		var err error
+		shares, err = strconv.ParseInt(amount, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse sell amount to shares")
+		}
+	}
+	if shares <= 0 {
+		return 0, fmt.Errorf("amount is not enough for a single share at the price")
+	}
+	return shares, nil
+}
+
+// parseTrail parses a trailing stop's trail, either a positive dollar amount
+// such as "1.50" or a percentage of the mark under 100 such as "5%"
+func parseTrail(trail string) (string, error) {
+	offset, err := decimal.NewFromString(strings.TrimSuffix(trail, "%"))
+	if err != nil || !offset.GreaterThan(decimal.Zero) {
+		return "", fmt.Errorf("could not parse trail to a positive amount or percentage")
+	}
+	if strings.HasSuffix(trail, "%") {
+		if offset.GreaterThanOrEqual(decimal.New(100, 0)) {
+			return "", fmt.Errorf("trail percentage must be under 100")
+		}
+		return offset.String() + "%", nil
+	}
+	return offset.String(), nil
+}
+
+// LimitBuy buys the dollar amount of the stock at the limit price or lower.
+// Params: user, stock, amount, price, time in force[, expiry]
+// The time in force is IOC, DAY, GTC or GTD, and GTD orders are given an
+// expiry in unix milliseconds.
+// Pre-condition: The user's cash account must be greater than or equal to the
+//		cost of the shares at the limit price
+// Post-conditions:
+// 		(a) the cost of the shares at the limit price is held in reserve until
+//			the order fills, is cancelled or expires
+// 		(b) the order ID is returned
+func (ts TransactionServer) LimitBuy(transNum int, params ...string) socketserver.Result {
+	return ts.placeLimitOrder(transNum, "LIMIT_BUY", "BUY", params...)
+}
+
+// LimitSell sells shares of the stock at the limit price or higher.
+// Params: user, stock, shares, price, time in force[, expiry]
+// Pre-condition: The user must hold the shares being sold
+// Post-conditions:
+// 		(a) the shares are held in reserve until the order fills, is
+//			cancelled or expires
+// 		(b) the order ID is returned
+func (ts TransactionServer) LimitSell(transNum int, params ...string) socketserver.Result {
+	return ts.placeLimitOrder(transNum, "LIMIT_SELL", "SELL", params...)
+}
+
+func (ts TransactionServer) placeLimitOrder(transNum int, command string, side string,
+	params ...string) socketserver.Result {
+	user := params[0]
+	stock := params[1]
+	price, err := parseLimitPrice(params[3])
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	shares, err := parseLimitShares(side, params[2], price)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, params[2])
+	}
+	now := time.Now()
+	expires, err := parseExpiry(params[4], params[5:], now)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	order := database.LimitOrder{User: user, Stock: stock, Side: side, Shares: shares, Price: price,
+		TIF: params[4], Expires: expires, Created: now}
+	return ts.placeOrder(transNum, command, order)
+}
+
+// StopBuy buys the dollar amount of the stock once its price rises to the stop price.
+// Params: user, stock, amount, stop price, time in force[, expiry]
+// The time in force is DAY, GTC or GTD, as for LimitBuy.
+// Pre-condition: The user's cash account must be greater than or equal to the
+//		cost of the shares at the stop price
+// Post-conditions:
+// 		(a) the cost of the shares at the stop price is held in reserve until
+//			the order fills, is cancelled or expires
+// 		(b) the order ID is returned, which LIST_LIMIT_ORDERS, AMEND_LIMIT_ORDER
+//			and CANCEL_LIMIT_ORDER take as they do a limit order's
+func (ts TransactionServer) StopBuy(transNum int, params ...string) socketserver.Result {
+	return ts.placeStopOrder(transNum, "STOP_BUY", "BUY", params...)
+}
+
+// StopSell sells shares of the stock once its price falls to the stop price,
+// protecting a position from falling further.
+// Params: user, stock, shares, stop price, time in force[, expiry]
+// Pre-condition: The user must hold the shares being sold
+// Post-conditions:
+// 		(a) the shares are held in reserve until the order fills, is
+//			cancelled or expires
+// 		(b) the order ID is returned
+func (ts TransactionServer) StopSell(transNum int, params ...string) socketserver.Result {
+	return ts.placeStopOrder(transNum, "STOP_SELL", "SELL", params...)
+}
+
+func (ts TransactionServer) placeStopOrder(transNum int, command string, side string,
+	params ...string) socketserver.Result {
+	user := params[0]
+	stock := params[1]
+	if params[4] == TIFImmediateOrCancel {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest,
+			"Stop orders can't be IOC", stock, nil, nil)
+	}
+	price, err := parseLimitPrice(params[3])
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	shares, err := parseLimitShares(side, params[2], price)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, params[2])
+	}
+	now := time.Now()
+	expires, err := parseExpiry(params[4], params[5:], now)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	order := database.LimitOrder{User: user, Stock: stock, Type: database.OrderStop, Side: side, Shares: shares,
+		Price: price, TIF: params[4], Expires: expires, Created: now}
+	return ts.placeOrder(transNum, command, order)
+}
+
+// TrailingStopBuy buys the dollar amount of the stock once its price rises
+// the trail above the lowest price quoted since the order was placed.
+// Params: user, stock, amount, trail, time in force[, expiry]
+// The trail is a dollar amount such as 1.50 or a percentage such as 5%.
+// The order starts from the current quote and buys as many shares as the
+// amount pays for at that quote.
+// Pre-condition: The user's cash account must be greater than or equal to the
+//		cost of the shares at the quote
+// Post-conditions:
+// 		(a) the cost of the shares at the quote is held in reserve until the
+//			order fills, is cancelled or expires. It fills as many of the
+//			shares as that pays for at the stop price.
+// 		(b) the order ID is returned. Trailing stops can't be amended.
+func (ts TransactionServer) TrailingStopBuy(transNum int, params ...string) socketserver.Result {
+	return ts.placeTrailingStop(transNum, "TRAILING_STOP_BUY", "BUY", params...)
+}
+
+// TrailingStopSell sells shares of the stock once its price falls the trail
+// below the highest price quoted since the order was placed.
+// Params: user, stock, shares, trail, time in force[, expiry]
+// Pre-condition: The user must hold the shares being sold
+// Post-conditions:
+// 		(a) the shares are held in reserve until the order fills, is
+//			cancelled or expires
+// 		(b) the order ID is returned. Trailing stops can't be amended.
+func (ts TransactionServer) TrailingStopSell(transNum int, params ...string) socketserver.Result {
+	return ts.placeTrailingStop(transNum, "TRAILING_STOP_SELL", "SELL", params...)
+}
+
+func (ts TransactionServer) placeTrailingStop(transNum int, command string, side string,
+	params ...string) socketserver.Result {
+	user := params[0]
+	stock := params[1]
+	if params[4] == TIFImmediateOrCancel {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest,
+			"Stop orders can't be IOC", stock, nil, nil)
+	}
+	trail, err := parseTrail(params[3])
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	now := time.Now()
+	expires, err := parseExpiry(params[4], params[5:], now)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	quote, err := ts.QuoteClient.Query(user, stock, transNum)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeQuoteUnavailable,
+			"Could not connect to the quote server: "+err.Error(), stock, nil, nil)
+	}
+	mark := quote.Truncate(2)
+	shares, err := parseLimitShares(side, params[2], mark)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, params[2])
+	}
+	order := database.LimitOrder{User: user, Stock: stock, Type: database.OrderTrailingStop, Side: side,
+		Shares: shares, Price: mark, Trail: trail, TIF: params[4], Expires: expires, Created: now}
+	return ts.placeOrder(transNum, command, order)
+}
+
+// OCOSell sells shares of the stock at a take-profit or a stop-loss, whichever
+// the price reaches first, as a one-cancels-other group.
+// Params: user, stock, shares, take-profit, stop-loss, time in force[, expiry]
+// The time in force is DAY, GTC or GTD, as for LimitBuy.
+// Pre-condition: The user must hold the shares being sold, and the stop-loss
+//		must be below the take-profit
+// Post-conditions:
+// 		(a) the shares are held in reserve once, for both exits, until one of
+//			them fills or the group is cancelled or expires
+// 		(b) the group's ID is returned, which LIST_LIMIT_ORDERS and
+//			CANCEL_LIMIT_ORDER take as they do a limit order's
+func (ts TransactionServer) OCOSell(transNum int, params ...string) socketserver.Result {
+	return ts.placeOrderGroup(transNum, "OCO_SELL", database.OrderOCO, "SELL", params[0], params[1], params[2],
+		params[3], params[3], params[4], params[5], params[6:])
+}
+
+// BracketBuy buys the dollar amount of the stock at the entry price or lower,
+// then sells the shares it bought at a take-profit or a stop-loss, whichever
+// the price reaches first.
+// Params: user, stock, amount, entry price, take-profit, stop-loss, time in force[, expiry]
+// Pre-condition: The user's cash account must be greater than or equal to the
+//		cost of the shares at the entry price, and the entry price must be
+//		between the stop-loss and the take-profit
+// Post-conditions:
+// 		(a) the cost of the shares is held in reserve until the entry fills,
+//			then the shares it bought are held for the exits
+// 		(b) the bracket's ID is returned, which LIST_LIMIT_ORDERS and
+//			CANCEL_LIMIT_ORDER take as they do a limit order's
+func (ts TransactionServer) BracketBuy(transNum int, params ...string) socketserver.Result {
+	return ts.placeOrderGroup(transNum, "BRACKET_BUY", database.OrderBracket, "BUY", params[0], params[1], params[2],
+		params[3], params[4], params[5], params[6], params[7:])
+}
+
+// placeOrderGroup places an OCO group or bracket, price being a bracket's entry
+// price or an OCO group's take-profit
+func (ts TransactionServer) placeOrderGroup(transNum int, command string, orderType string, side string,
+	user string, stock string, amount string, price string, takeProfit string, stopLoss string, tif string,
+	expiry []string) socketserver.Result {
+	if tif == TIFImmediateOrCancel {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest,
+			"Order groups can't be IOC", stock, nil, nil)
+	}
+	exits, err := parseOrderExits(takeProfit, stopLoss)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	entry, err := parseLimitPrice(price)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	if orderType == database.OrderBracket &&
+		(!exits.StopLoss.LessThan(entry) || !entry.LessThan(exits.TakeProfit)) {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest,
+			"entry price must be between the stop-loss and the take-profit", stock, nil, nil)
+	}
+	shares, err := parseLimitShares(side, amount, entry)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, amount)
+	}
+	now := time.Now()
+	expires, err := parseExpiry(tif, expiry, now)
+	if err != nil {
+		return ts.reportError(transNum, command, user, socketserver.CodeBadRequest, err.Error(), stock, nil, nil)
+	}
+	order := database.LimitOrder{User: user, Stock: stock, Type: orderType, Side: side, Shares: shares,
+		Price: entry, Exits: &exits, TIF: tif, Expires: expires, Created: now}
+	return ts.placeOrder(transNum, command, order)
+}
+
+// parseOrderExits parses the take-profit and stop-loss of an order group,
+// where the stop-loss must be below the take-profit
+func parseOrderExits(takeProfit string, stopLoss string) (database.OrderExits, error) {
+	var exits database.OrderExits
+	var err error
+	if exits.TakeProfit, err = parseLimitPrice(takeProfit); err != nil {
+		return exits, err
+	}
+	if exits.StopLoss, err = parseLimitPrice(stopLoss); err != nil {
+		return exits, err
+	}
+	if !exits.StopLoss.LessThan(exits.TakeProfit) {
+		return exits, fmt.Errorf("stop-loss must be below the take-profit")
+	}
+	return exits, nil
+}
+
+// placeWithTriggerServer places an order, or each leg of an order group, with the triggerserver
+func (ts TransactionServer) placeWithTriggerServer(transNum int, order database.LimitOrder) error {
+	if !order.IsGroup() {
+		return ts.TriggerClient.PlaceLimitOrder(transNum, orderTrigger(transNum, order))
+	}
+	_, err := ts.TriggerClient.PlaceOrderGroup(transNum, triggerclient.OrderGroup{
+		ID:         order.ID,
+		Type:       order.Type,
+		User:       order.User,
+		Stock:      order.Stock,
+		Shares:     order.Shares,
+		Price:      order.Price,
+		TakeProfit: order.Exits.TakeProfit,
+		StopLoss:   order.Exits.StopLoss,
+	})
+	return err
+}
+
+// cancelWithTriggerServer cancels an order, or every leg of an order group, on the triggerserver
+func (ts TransactionServer) cancelWithTriggerServer(transNum int, order database.LimitOrder) error {
+	if order.IsGroup() {
+		_, err := ts.TriggerClient.CancelOrderGroup(transNum, order.User, order.Stock, order.ID)
+		return err
+	}
+	_, err := ts.TriggerClient.CancelLimitOrder(transNum, order.User, order.Stock, order.Action(), order.ID)
+	return err
+}
+
+// placeOrder reserves what a limit or stop order holds and places it with the
+// triggerserver, returning its new ID
+func (ts TransactionServer) placeOrder(transNum int, command string, order database.LimitOrder) socketserver.Result {
+	var err error
+	order.ID, err = newOrderID()
+	if err != nil {
+		return ts.reportError(transNum, command, order.User, socketserver.CodeInternal,
+			"Could not generate an order ID: "+err.Error(), order.Stock, nil, nil)
+	}
+
+	if order.TIF == TIFImmediateOrCancel {
+		return ts.fillImmediately(transNum, command, order)
+	}
+
+	db := ts.UserDatabase.WithTransaction(transNum, command)
+	err = db.PlaceLimitOrder(order)
+	if err != nil {
+		return ts.reportError(transNum, command, order.User, errorCode(err),
+			"Could not reserve the order: "+err.Error(), order.Stock, nil, order.Price.String())
+	}
+
+	err = ts.placeWithTriggerServer(transNum, order)
+	if err != nil {
+		result := ts.reportError(transNum, command, order.User, socketserver.CodeTriggerUnavailable,
+			"Error placing the order: "+err.Error(), order.Stock, nil, order.Price.String())
+		// Hand the reserve back so it isn't stranded without an order to fill it
+		_, err = db.ReleaseLimitOrder(order.User, order.ID)
+		if err != nil {
+			ts.reportError(transNum, command, order.User, errorCode(err),
+				"Error returning the order's reserve: "+err.Error(), order.Stock, nil, order.Price.String())
+		}
+		return result
+	}
+
+	go ts.Logger.SystemEvent(ts.Name, transNum, command, order.User, order.Stock, nil, order.Price)
+	ts.publishLimitOrder(transNum, order, OrderOpen)
+	ts.publishBalance(transNum, order.User)
+	return socketserver.OK(order.ID)
+}
+
+// fillImmediately fills an IOC order at the current quote if it meets the
+// limit, without ever placing it with the triggerserver
+func (ts TransactionServer) fillImmediately(transNum int, command string, order database.LimitOrder) socketserver.Result {
+	quote, err := ts.QuoteClient.Query(order.User, order.Stock, transNum)
+	if err != nil {
+		return ts.reportError(transNum, command, order.User, socketserver.CodeQuoteUnavailable,
+			"Could not connect to the quote server: "+err.Error(), order.Stock, nil, order.Price.String())
+	}
+	if (order.Side == "BUY" && quote.GreaterThan(order.Price)) || (order.Side == "SELL" && quote.LessThan(order.Price)) {
+		return ts.reportError(transNum, command, order.User, socketserver.CodeLimitNotMet,
+			"The stock is quoted at "+quote.StringFixed(2), order.Stock, nil, order.Price.String())
+	}
+
+	db := ts.UserDatabase.WithTransaction(transNum, command)
+	err = db.PlaceLimitOrder(order)
+	if err != nil {
+		return ts.reportError(transNum, command, order.User, errorCode(err),
+			"Could not reserve the limit order: "+err.Error(), order.Stock, nil, order.Price.String())
+	}
+	_, shares, _, err := db.ExecuteLimitOrder(order.User, order.ID, quote)
+	if err != nil {
+		result := ts.reportError(transNum, command, order.User, errorCode(err),
+			"Could not fill the limit order: "+err.Error(), order.Stock, nil, order.Price.String())
+		// The order never reaches the triggerserver, so nothing else would release its reserve
+		_, err = db.ReleaseLimitOrder(order.User, order.ID)
+		if err != nil {
+			ts.reportError(transNum, command, order.User, errorCode(err),
+				"Error returning the order's reserve: "+err.Error(), order.Stock, nil, order.Price.String())
+		}
+		return result
+	}
+
+	go ts.Logger.SystemEvent(ts.Name, transNum, command, order.User, order.Stock, nil, order.Price)
+	ts.publishLimitOrder(transNum, order, OrderFilled)
+	ts.publishFill(TriggerFill{
+		TransNum:  transNum,
+		TriggerID: order.ID,
+		User:      order.User,
+		Stock:     order.Stock,
+		Action:    order.Action(),
+		Price:     quote,
+		Amount:    decimal.New(shares, 0),
+		Time:      time.Now(),
+	})
+	return socketserver.OK(order.ID)
+}
+
+// ListLimitOrders returns every limit and stop order the user has waiting, oldest first.
+// The payload is a list of database.LimitOrder.
+// Params: user
+func (ts TransactionServer) ListLimitOrders(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	orders, err := ts.UserDatabase.GetLimitOrders(user)
+	if err != nil {
+		return ts.reportError(transNum, "LIST_LIMIT_ORDERS", user, errorCode(err),
+			"Error getting limit orders from database: "+err.Error(), nil, nil, nil)
+	}
+	return socketserver.OK(orders)
+}
+
+// AmendLimitOrder changes the amount and price of a waiting limit or stop order.
+// Params: user, order ID, amount, price
+// The amount is dollars for a buy and shares for a sell, as when the order was placed.
+// A stop order's price is its stop price. Trailing stops and order groups can't be amended.
+// Post-conditions:
+// 		(a) the difference in what the order holds is moved between the user's
+//			account and their reserve
+// 		(b) the amended order is returned
+func (ts TransactionServer) AmendLimitOrder(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	id := params[1]
+	current, err := ts.UserDatabase.GetLimitOrder(user, id)
+	if err != nil {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, errorCode(err),
+			"Could not find the limit order: "+err.Error(), nil, nil, nil)
+	}
+	if current.Type == database.OrderTrailingStop {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, socketserver.CodeBadRequest,
+			"Trailing stops can't be amended", current.Stock, nil, nil)
+	} else if current.IsGroup() {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, socketserver.CodeBadRequest,
+			"Order groups can't be amended", current.Stock, nil, nil)
+	}
+	price, err := parseLimitPrice(params[3])
+	if err != nil {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, socketserver.CodeBadRequest, err.Error(),
+			current.Stock, nil, nil)
+	}
+	shares, err := parseLimitShares(current.Side, params[2], price)
+	if err != nil {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, socketserver.CodeBadRequest, err.Error(),
+			current.Stock, nil, params[2])
+	}
+
+	db := ts.UserDatabase.WithTransaction(transNum, "AMEND_LIMIT_ORDER")
+	amended, err := db.AmendLimitOrder(user, id, shares, price)
+	if err != nil {
+		return ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, errorCode(err),
+			"Could not amend the limit order's reserve: "+err.Error(), current.Stock, nil, price.String())
+	}
+
+	_, err = ts.TriggerClient.AmendLimitOrder(transNum,
+		triggerclient.NewLimitOrder(transNum, id, user, current.Stock, shares, price, current.Action()))
+	if err != nil {
+		result := ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, triggerErrorCode(err),
+			"Error amending the limit order: "+err.Error(), current.Stock, nil, price.String())
+		// The triggerserver still has the order as it was, so its reserve must be too
+		_, err = db.AmendLimitOrder(user, id, current.Shares, current.Price)
+		if err != nil && err != database.ErrNoLimitOrder {
+			ts.reportError(transNum, "AMEND_LIMIT_ORDER", user, errorCode(err),
+				"Error restoring the limit order's reserve: "+err.Error(), current.Stock, nil, price.String())
+		}
+		return result
+	}
+
+	ts.publishLimitOrder(transNum, amended, OrderAmended)
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(amended)
+}
+
+// CancelLimitOrder cancels a waiting limit or stop order, or every leg of an order group
+// Params: user, order ID
+// Pre-condition: The order must not have filled
+// Post-condition: The funds or shares held for the order are returned to the user
+func (ts TransactionServer) CancelLimitOrder(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	id := params[1]
+	order, err := ts.UserDatabase.GetLimitOrder(user, id)
+	if err != nil {
+		return ts.reportError(transNum, "CANCEL_LIMIT_ORDER", user, errorCode(err),
+			"Could not find the limit order: "+err.Error(), nil, nil, nil)
+	}
+
+	// An order the triggerserver has lost can't fill, so its reserve is still released
+	err = ts.cancelWithTriggerServer(transNum, order)
+	if err != nil && err != triggerclient.ErrNoTrigger {
+		return ts.reportError(transNum, "CANCEL_LIMIT_ORDER", user, triggerErrorCode(err),
+			"Error cancelling the limit order: "+err.Error(), order.Stock, nil, nil)
+	}
+
+	_, err = ts.UserDatabase.WithTransaction(transNum, "CANCEL_LIMIT_ORDER").ReleaseLimitOrder(user, id)
+	if err != nil {
+		return ts.reportError(transNum, "CANCEL_LIMIT_ORDER", user, errorCode(err),
+			"Error returning the limit order's reserve: "+err.Error(), order.Stock, nil, nil)
+	}
+	ts.publishLimitOrder(transNum, order, OrderCancelled)
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
+}
+
+// triggerErrorCode maps an error amending or cancelling a limit order on the
+// triggerserver to the error code sent to clients
+func triggerErrorCode(err error) string {
+	switch err {
+	case triggerclient.ErrNoTrigger:
+		return socketserver.CodeNoLimitOrder
+	case triggerclient.ErrTriggerFired:
+		return socketserver.CodeTriggerFired
+	}
+	return socketserver.CodeTriggerUnavailable
+}
+
+// limitExecute settles a limit or stop order the triggerserver fired at price,
+// returning the shares it filled. A bracket's entry leaves the bracket open for its exits.
+func (ts TransactionServer) limitExecute(transNum int, user string, price decimal.Decimal,
+	triggerID string) (decimal.Decimal, error) {
+	order, shares, _, err := ts.UserDatabase.WithTransaction(transNum, "TRIGGER_SUCCESS").ExecuteLimitOrder(user,
+		triggerID, price)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if _, leg := database.SplitLegID(triggerID); leg == database.LegEntry {
+		ts.publishLimitOrder(transNum, order, OrderEntered)
+	} else {
+		ts.publishLimitOrder(transNum, order, OrderFilled)
+	}
+	return decimal.New(shares, 0), nil
+}
+
+// expireLimitOrders cancels limit orders whose time in force has run out and
+// returns their reserves. An order the triggerserver can't cancel right now is
+// left for the next check, and one that has fired is left to TRIGGER_SUCCESS.
+func (ts TransactionServer) expireLimitOrders(now time.Time) {
+	expired, err := ts.UserDatabase.ExpiredLimitOrders(now)
+	if err != nil {
+		fmt.Println("Error finding expired limit orders: ", err.Error())
+		return
+	}
+
+	db := ts.UserDatabase.WithTransaction(0, "CANCEL_LIMIT_ORDER")
+	for _, order := range expired {
+		err = ts.cancelWithTriggerServer(0, order)
+		if err == triggerclient.ErrTriggerFired {
+			continue
+		} else if err != nil && err != triggerclient.ErrNoTrigger {
+			fmt.Println("Error cancelling expired limit order: ", err.Error())
+			continue
+		}
+		_, err = db.ReleaseLimitOrder(order.User, order.ID)
+		if err == database.ErrNoLimitOrder {
+			continue
+		} else if err != nil {
+			fmt.Println("Error releasing expired limit order: ", err.Error())
+			continue
+		}
+		go ts.Logger.SystemEvent(ts.Name, 0, "CANCEL_LIMIT_ORDER", order.User, order.Stock, nil, order.Price)
+		ts.publishLimitOrder(0, order, OrderExpired)
+		ts.publishBalance(0, order.User)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"seng468/transaction-server/database"
+	"seng468/transaction-server/trigger"
+
+	"github.com/shopspring/decimal"
+)
+
+type MockLogger struct {
+}
+
+func (MockLogger) QuoteServer(server string, transNum int,
+	price string, stock string, user string, qsTime uint64, key string) {
+
+}
+
+func (MockLogger) AccountTransaction(server string, transNum int, action string, user interface{}, funds interface{}) {
+
+}
+
+func (MockLogger) SystemError(server string, transNum int, command string, user interface{}, stock interface{}, filename interface{},
+	funds interface{}, errorMsg interface{}) {
+
+}
+
+func (MockLogger) SystemEvent(server string, transNum int, command string, username interface{}, stock interface{},
+	filename interface{}, funds interface{}) {
+
+}
+
+func (MockLogger) DumpLog(filename string, username interface{}) {
+
+}
+
+type MockQuoteClient struct {
+	lock     sync.Mutex
+	stockMap map[string]decimal.Decimal
+}
+
+func NewMockQuoteClient() *MockQuoteClient {
+	return &MockQuoteClient{
+		stockMap: make(map[string]decimal.Decimal),
+	}
+}
+
+func (qc *MockQuoteClient) Query(user string, stock string, transNum int) (decimal.Decimal, error) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+	if val, ok := qc.stockMap[stock]; ok {
+		return val, nil
+	}
+	return decimal.Decimal{}, errors.New("stock not mocked")
+}
+
+func (qc *MockQuoteClient) addRule(stock string, amount decimal.Decimal) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+	qc.stockMap[stock] = amount
+}
+
+type mockTriggerKey struct {
+	action, stock, user, id string
+}
+
+// MockTriggerClient keeps triggers in memory in place of the triggerserver.
+// Triggers never fire on their own, tests call TRIGGER_SUCCESS directly.
+// Triggers without an ID resolve to the user's latest on the stock, as they
+// do on the triggerserver. Limit orders are kept by ID, and fire marks one as firing.
+type MockTriggerClient struct {
+	lock    sync.Mutex
+	seq     int
+	setAt   map[mockTriggerKey]int
+	waiting map[mockTriggerKey]triggerclient.Trigger
+	running map[mockTriggerKey]triggerclient.Trigger
+	limits  map[string]triggerclient.Trigger
+}
+
+func NewMockTriggerClient() *MockTriggerClient {
+	return &MockTriggerClient{
+		setAt:   make(map[mockTriggerKey]int),
+		waiting: make(map[mockTriggerKey]triggerclient.Trigger),
+		running: make(map[mockTriggerKey]triggerclient.Trigger),
+		limits:  make(map[string]triggerclient.Trigger),
+	}
+}
+
+func (tc *MockTriggerClient) SetNewSellTrigger(transNum int, id string, username string, stock string, amount int64) error {
+	return tc.set(mockTriggerKey{"SELL", stock, username, id}, decimal.New(amount, 0))
+}
+
+func (tc *MockTriggerClient) SetSellTrigger(transNum int, trig triggerclient.Trigger) error {
+	return tc.set(mockTriggerKey{"SELL", trig.GetStock(), trig.GetUsername(), trig.GetID()}, trig.GetAmount())
+}
+
+func (tc *MockTriggerClient) StartSellTrigger(transNum int, trig triggerclient.Trigger) (triggerclient.Trigger, error) {
+	return tc.start(mockTriggerKey{"SELL", trig.GetStock(), trig.GetUsername(), trig.GetID()}, trig.GetPrice(),
+		trig.GetExpiry())
+}
+
+func (tc *MockTriggerClient) StartNewSellTrigger(transNum int, username string, stock string,
+	price decimal.Decimal, id string, expires time.Time) (triggerclient.Trigger, error) {
+	return tc.start(mockTriggerKey{"SELL", stock, username, id}, price, expires)
+}
+
+func (tc *MockTriggerClient) CancelSellTrigger(transNum int, username string, stock string,
+	id string) (triggerclient.Trigger, error) {
+	return tc.cancel(mockTriggerKey{"SELL", stock, username, id})
+}
+
+func (tc *MockTriggerClient) SetNewBuyTrigger(transNum int, id string, username string, stock string,
+	amount decimal.Decimal) error {
+	return tc.set(mockTriggerKey{"BUY", stock, username, id}, amount)
+}
+
+func (tc *MockTriggerClient) SetBuyTrigger(transNum int, trig triggerclient.Trigger) error {
+	return tc.set(mockTriggerKey{"BUY", trig.GetStock(), trig.GetUsername(), trig.GetID()}, trig.GetAmount())
+}
+
+func (tc *MockTriggerClient) StartBuyTrigger(transNum int, trig triggerclient.Trigger) (triggerclient.Trigger, error) {
+	return tc.start(mockTriggerKey{"BUY", trig.GetStock(), trig.GetUsername(), trig.GetID()}, trig.GetPrice(),
+		trig.GetExpiry())
+}
+
+func (tc *MockTriggerClient) StartNewBuyTrigger(transNum int, username string, stock string,
+	price decimal.Decimal, id string, expires time.Time) (triggerclient.Trigger, error) {
+	return tc.start(mockTriggerKey{"BUY", stock, username, id}, price, expires)
+}
+
+func (tc *MockTriggerClient) CancelBuyTrigger(transNum int, username string, stock string,
+	id string) (triggerclient.Trigger, error) {
+	return tc.cancel(mockTriggerKey{"BUY", stock, username, id})
+}
+
+func (tc *MockTriggerClient) PlaceLimitOrder(transNum int, order triggerclient.Trigger) error {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	if _, ok := tc.limits[order.GetID()]; ok {
+		return triggerclient.ErrTriggerFired
+	}
+	tc.limits[order.GetID()] = order.WithState(triggerclient.StateRunning)
+	return nil
+}
+
+func (tc *MockTriggerClient) AmendLimitOrder(transNum int, order triggerclient.Trigger) (triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	current, ok := tc.limits[order.GetID()]
+	if !ok {
+		return triggerclient.Trigger{}, triggerclient.ErrNoTrigger
+	} else if current.GetState() == triggerclient.StateFiring {
+		return triggerclient.Trigger{}, triggerclient.ErrTriggerFired
+	}
+	tc.limits[order.GetID()] = order.WithState(triggerclient.StateRunning)
+	return order, nil
+}
+
+func (tc *MockTriggerClient) CancelLimitOrder(transNum int, username string, stock string, action string,
+	id string) (triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	order, ok := tc.limits[id]
+	if !ok {
+		return triggerclient.Trigger{}, triggerclient.ErrNoTrigger
+	} else if order.GetState() == triggerclient.StateFiring {
+		return triggerclient.Trigger{}, triggerclient.ErrTriggerFired
+	}
+	delete(tc.limits, id)
+	return order, nil
+}
+
+// PlaceOrderGroup keeps each leg as a limit order under its leg ID, with a
+// bracket's exits waiting on its entry
+func (tc *MockTriggerClient) PlaceOrderGroup(transNum int,
+	group triggerclient.OrderGroup) ([]triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	legs := []triggerclient.Trigger{
+		triggerclient.NewLimitOrder(transNum, group.ID+"."+database.LegTakeProfit, group.User, group.Stock,
+			group.Shares, group.TakeProfit, triggerclient.ActionLimitSell),
+		triggerclient.NewLimitOrder(transNum, group.ID+"."+database.LegStopLoss, group.User, group.Stock,
+			group.Shares, group.StopLoss, triggerclient.ActionStopSell),
+	}
+	state := triggerclient.StateRunning
+	if group.Type == triggerclient.GroupBracket {
+		state = triggerclient.StateWaiting
+		entry := triggerclient.NewLimitOrder(transNum, group.ID+"."+database.LegEntry, group.User, group.Stock,
+			group.Shares, group.Price, triggerclient.ActionLimitBuy)
+		tc.limits[entry.GetID()] = entry.WithGroup(group.ID).WithState(triggerclient.StateRunning)
+	}
+	for _, leg := range legs {
+		if _, ok := tc.limits[leg.GetID()]; ok {
+			return nil, triggerclient.ErrTriggerFired
+		}
+		tc.limits[leg.GetID()] = leg.WithGroup(group.ID).WithState(state)
+	}
+	return legs, nil
+}
+
+func (tc *MockTriggerClient) CancelOrderGroup(transNum int, username string, stock string,
+	id string) ([]triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	var legs []triggerclient.Trigger
+	for _, order := range tc.limits {
+		if order.GetGroup() != id {
+			continue
+		} else if order.GetState() == triggerclient.StateFiring {
+			return nil, triggerclient.ErrTriggerFired
+		}
+		legs = append(legs, order)
+	}
+	if len(legs) == 0 {
+		return nil, triggerclient.ErrNoTrigger
+	}
+	for _, leg := range legs {
+		delete(tc.limits, leg.GetID())
+	}
+	return legs, nil
+}
+
+// fire marks a limit order as firing, so it can no longer be amended or cancelled
+func (tc *MockTriggerClient) fire(id string) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	tc.limits[id] = tc.limits[id].WithState(triggerclient.StateFiring)
+}
+
+// limitOrder returns the limit order placed with id, and whether there is one
+func (tc *MockTriggerClient) limitOrder(id string) (triggerclient.Trigger, bool) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	order, ok := tc.limits[id]
+	return order, ok
+}
+
+func (tc *MockTriggerClient) ListRunningTriggers() {
+}
+
+func (tc *MockTriggerClient) ListTriggers() ([]triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	var triggers []triggerclient.Trigger
+	for _, trig := range tc.waiting {
+		triggers = append(triggers, trig.WithState(triggerclient.StateWaiting))
+	}
+	for _, trig := range tc.running {
+		triggers = append(triggers, trig.WithState(triggerclient.StateRunning))
+	}
+	for _, order := range tc.limits {
+		triggers = append(triggers, order)
+	}
+	return triggers, nil
+}
+
+func (tc *MockTriggerClient) set(key mockTriggerKey, amount decimal.Decimal) error {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	if _, ok := tc.waiting[key]; ok {
+		return triggerclient.ErrTriggerExists
+	} else if _, ok := tc.running[key]; ok {
+		return triggerclient.ErrTriggerExists
+	}
+	tc.seq++
+	tc.setAt[key] = tc.seq
+	tc.waiting[key] = triggerclient.NewTrigger(0, key.id, key.user, key.stock, amount, decimal.Zero, key.action)
+	return nil
+}
+
+// resolve fills in the ID of the user's latest trigger in pools when key has none
+func (tc *MockTriggerClient) resolve(key mockTriggerKey, pools ...map[mockTriggerKey]triggerclient.Trigger) mockTriggerKey {
+	if key.id != "" {
+		return key
+	}
+	latest, found := key, 0
+	for _, pool := range pools {
+		for k := range pool {
+			if k.action == key.action && k.stock == key.stock && k.user == key.user && tc.setAt[k] > found {
+				latest, found = k, tc.setAt[k]
+			}
+		}
+	}
+	return latest
+}
+
+func (tc *MockTriggerClient) start(key mockTriggerKey, price decimal.Decimal,
+	expires time.Time) (triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	key = tc.resolve(key, tc.waiting)
+	trig, ok := tc.waiting[key]
+	if !ok {
+		return triggerclient.Trigger{}, triggerclient.ErrNoTrigger
+	}
+	delete(tc.waiting, key)
+	trig = triggerclient.NewTrigger(0, key.id, key.user, key.stock, trig.GetAmount(), price,
+		key.action).WithExpiry(expires)
+	tc.running[key] = trig
+	return trig, nil
+}
+
+func (tc *MockTriggerClient) cancel(key mockTriggerKey) (triggerclient.Trigger, error) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	key = tc.resolve(key, tc.waiting, tc.running)
+	if trig, ok := tc.running[key]; ok {
+		delete(tc.running, key)
+		return trig, nil
+	}
+	if trig, ok := tc.waiting[key]; ok {
+		delete(tc.waiting, key)
+		return trig, nil
+	}
+	return triggerclient.Trigger{}, triggerclient.ErrNoTrigger
+}
+
+// recordingPublisher collects published events in place of redis
+type recordingPublisher struct {
+	events chan UserEvent
+}
+
+func newRecordingPublisher() *recordingPublisher {
+	return &recordingPublisher{events: make(chan UserEvent, 100)}
+}
+
+func (p *recordingPublisher) Publish(event UserEvent) {
+	p.events <- event
+}
+
+// next waits for the next event of eventType, skipping any of other types
+func (p *recordingPublisher) next(t *testing.T, eventType string) UserEvent {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-p.events:
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("No %s event was published", eventType)
+			return UserEvent{}
+		}
+	}
+}
+
+// failingFillDatabase fails every limit order fill as a lost redis connection would
+type failingFillDatabase struct {
+	database.UserDatabase
+}
+
+func (db failingFillDatabase) WithTransaction(transNum int, command string) database.UserDatabase {
+	return failingFillDatabase{db.UserDatabase.WithTransaction(transNum, command)}
+}
+
+func (failingFillDatabase) ExecuteLimitOrder(user string, id string, price decimal.Decimal) (database.LimitOrder,
+	int64, decimal.Decimal, error) {
+	return database.LimitOrder{}, 0, decimal.Zero, database.ErrTimeout
+}
@@ -1,16 +1,31 @@
 package quoteclient
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/shopspring/decimal"
 )
 
+// QuoteClient looks up the current price of a stock
+type QuoteClient interface {
+	Query(user string, stock string, transNum int) (decimal.Decimal, error)
+}
+
+// HTTPQuoteClient queries the quoteserver over http
+type HTTPQuoteClient struct{}
+
+// Query returns the quoteserver's current price for stock
+func (HTTPQuoteClient) Query(user string, stock string, transNum int) (decimal.Decimal, error) {
+	return Query(user, stock, transNum)
+}
+
 func Query(user string, stock string, transNum int) (decimal.Decimal, error) {
 	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = 100
 	req, err := http.NewRequest("GET", "http://"+os.Getenv("quoteaddr")+":"+os.Getenv("quoteport")+"/quote", nil)
@@ -42,5 +57,9 @@ func Query(user string, stock string, transNum int) (decimal.Decimal, error) {
 		return decimal.Decimal{}, err
 	}
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// The quoteserver explains what went wrong, starting with an error code
+		return decimal.Decimal{}, errors.New(strings.TrimSpace(string(amount)))
+	}
 	return decimal.NewFromString(string(amount))
 }
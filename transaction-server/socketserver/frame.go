@@ -0,0 +1,129 @@
+package socketserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Framed protocol
+//
+// A client opts into framing by opening the connection with a preface: the
+// bytes "TSF" followed by the highest frame version it speaks. The server
+// answers with "TSF" and the version both sides will use. Clients that send
+// "transNum;COMMAND,args\n" straight away keep the original text protocol.
+//
+// After the preface every request and reply is a frame:
+//
+//	length    uint32  number of bytes after this field
+//	requestID uint32  chosen by the client and echoed in the reply
+//	body
+//
+// A request body is the transaction number (int32), a field count (uint16),
+// then each field as a uint16 length and its bytes. The first field is the
+// command and the rest are its arguments, so arguments may contain commas.
+// A reply body is the JSON encoded Result.
+//
+// All integers are big endian.
+
+// FrameVersion is the highest frame version the server speaks
+const FrameVersion = 1
+
+// maxFrameSize bounds the length of a single frame so a bad length can't exhaust memory
+const maxFrameSize = 1 << 20
+
+var framePreface = []byte("TSF")
+
+var (
+	errFrameTooLarge = errors.New("frame exceeds maximum size")
+	errBadFrame      = errors.New("malformed frame")
+)
+
+// request is one decoded request frame
+type request struct {
+	id       uint32
+	transNum int
+	command  string
+	args     []string
+}
+
+// negotiate reads the client's preface and replies with the version to use
+func negotiate(rw io.ReadWriter) (byte, error) {
+	preface := make([]byte, len(framePreface)+1)
+	if _, err := io.ReadFull(rw, preface); err != nil {
+		return 0, err
+	}
+	if string(preface[:len(framePreface)]) != string(framePreface) {
+		return 0, errBadFrame
+	}
+	version := preface[len(framePreface)]
+	if version > FrameVersion {
+		version = FrameVersion
+	}
+	if _, err := rw.Write(append(append([]byte{}, framePreface...), version)); err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, errors.New("client does not speak any frame version")
+	}
+	return version, nil
+}
+
+// readRequest reads one request frame. When the frame was read but its body
+// is malformed it returns errBadFrame along with the request ID, so the
+// client can still be answered.
+func readRequest(r io.Reader) (request, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return request{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || length > maxFrameSize {
+		return request{}, errFrameTooLarge
+	}
+	req := request{id: binary.BigEndian.Uint32(header[4:])}
+
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return req, err
+	}
+	if len(body) < 6 {
+		return req, errBadFrame
+	}
+	req.transNum = int(int32(binary.BigEndian.Uint32(body)))
+	count := int(binary.BigEndian.Uint16(body[4:]))
+	body = body[6:]
+
+	fields := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(body) < 2 {
+			return req, errBadFrame
+		}
+		size := int(binary.BigEndian.Uint16(body))
+		if len(body) < 2+size {
+			return req, errBadFrame
+		}
+		fields = append(fields, string(body[2:2+size]))
+		body = body[2+size:]
+	}
+	if len(fields) == 0 || len(body) != 0 {
+		return req, errBadFrame
+	}
+	req.command = fields[0]
+	req.args = fields[1:]
+	return req, nil
+}
+
+// writeReply writes res as a reply frame for request id
+func writeReply(w io.Writer, id uint32, res Result) error {
+	encoded, err := json.Marshal(res)
+	if err != nil {
+		encoded, _ = json.Marshal(Error(CodeInternal, "Could not encode result: "+err.Error()))
+	}
+	frame := make([]byte, 8, 8+len(encoded))
+	binary.BigEndian.PutUint32(frame, uint32(4+len(encoded)))
+	binary.BigEndian.PutUint32(frame[4:], id)
+	_, err = w.Write(append(frame, encoded...))
+	return err
+}
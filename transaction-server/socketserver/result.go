@@ -0,0 +1,79 @@
+package socketserver
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Result statuses, matching the bare "1" and "-1" replies the protocol used to send
+const (
+	StatusOK    = 1
+	StatusError = -1
+)
+
+// Error codes sent in a failed Result, so clients can act on the reason
+// without parsing the message
+const (
+	CodeBadRequest          = "BAD_REQUEST"
+	CodeInsufficientFunds   = "INSUFFICIENT_FUNDS"
+	CodeInsufficientStock   = "INSUFFICIENT_STOCK"
+	CodeInsufficientReserve = "INSUFFICIENT_RESERVE"
+	CodeNoPendingBuy        = "NO_PENDING_BUY"
+	CodeNoPendingSell       = "NO_PENDING_SELL"
+	CodeOrderExpired        = "ORDER_EXPIRED"
+	CodeQuoteUnavailable    = "QUOTE_UNAVAILABLE"
+	CodeTriggerExists       = "TRIGGER_EXISTS"
+	CodeNoTrigger           = "NO_TRIGGER"
+	CodeTriggerUnavailable  = "TRIGGER_UNAVAILABLE"
+	CodeTriggerFired        = "TRIGGER_FIRED"
+	CodeNoLimitOrder        = "NO_LIMIT_ORDER"
+	CodeLimitNotMet         = "LIMIT_NOT_MET"
+	CodeAccountExists       = "ACCOUNT_EXISTS"
+	CodeBadCredentials      = "BAD_CREDENTIALS"
+	CodeDatabaseUnavailable = "DATABASE_UNAVAILABLE"
+	CodeInternal            = "INTERNAL"
+)
+
+// Result is what every routed command returns. It is written back to the
+// client as a single line of JSON:
+//
+//	{"status":-1,"code":"INSUFFICIENT_FUNDS","message":"Not enough funds to issue buy order"}
+//	{"status":1,"payload":"12.50"}
+type Result struct {
+	Status  int         `json:"status"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// OK returns a successful Result carrying payload, which may be nil
+func OK(payload interface{}) Result {
+	return Result{Status: StatusOK, Payload: payload}
+}
+
+// Error returns a failed Result with a machine-readable code and a message for the user
+func Error(code string, message string) Result {
+	return Result{Status: StatusError, Code: code, Message: message}
+}
+
+// Succeeded reports whether the command completed
+func (r Result) Succeeded() bool {
+	return r.Status == StatusOK
+}
+
+// String formats the result for logging
+func (r Result) String() string {
+	if r.Succeeded() {
+		return fmt.Sprintf("OK %v", r.Payload)
+	}
+	return fmt.Sprintf("%s: %s", r.Code, r.Message)
+}
+
+// encode serializes the result as one newline terminated line
+func (r Result) encode() []byte {
+	encoded, err := json.Marshal(r)
+	if err != nil {
+		encoded, _ = json.Marshal(Error(CodeInternal, "Could not encode result: "+err.Error()))
+	}
+	return append(encoded, '\n')
+}
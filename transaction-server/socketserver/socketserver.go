@@ -4,25 +4,35 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// maxInFlightPerConn bounds how many requests from one framed connection run at once.
+// Reading further requests waits until one finishes.
+const maxInFlightPerConn = 64
+
 type SocketServer struct {
 	addr     string
-	funcMap  map[string]func(transNum int, args ...string) string
-	paramMap map[string]int
+	funcMap  map[string]func(transNum int, args ...string) Result
+	paramMap map[string][]int
+	hidden   map[string]bool
+	empty    map[string]map[int]bool
 	transNum int64
 }
 
 func NewSocketServer(addr string) SocketServer {
 	return SocketServer{
 		addr:     addr,
-		funcMap:  make(map[string]func(transNum int, args ...string) string),
-		paramMap: make(map[string]int),
+		funcMap:  make(map[string]func(transNum int, args ...string) Result),
+		paramMap: make(map[string][]int),
+		hidden:   make(map[string]bool),
+		empty:    make(map[string]map[int]bool),
 		transNum: 0,
 	}
 }
@@ -32,8 +42,11 @@ func (s SocketServer) buildRoutePattern(pattern string) string {
 	return re.ReplaceAllString(pattern, `(.+)`) // `(?P\1.+)`
 }
 
-func (s SocketServer) Route(key string, f func(transNum int, args ...string) string) {
+// Route registers f to handle the command key. paramCounts lists the numbers
+// of parameters the command accepts; any other count is rejected before f is called.
+func (s SocketServer) Route(key string, f func(transNum int, args ...string) Result, paramCounts ...int) {
 	s.funcMap[key] = f
+	s.paramMap[key] = paramCounts
 }
 
 func (s SocketServer) Run() {
@@ -55,47 +68,144 @@ func (s SocketServer) Run() {
 	}
 }
 
-func (s SocketServer) getRoute(command string) (func(transNum int, args ...string) string, []string) {
+// HideParams keeps the parameters of the given commands, such as passwords, out of the request log
+func (s SocketServer) HideParams(keys ...string) {
+	for _, key := range keys {
+		s.hidden[key] = true
+	}
+}
+
+// AllowEmpty lets the parameters of key at the given indexes be empty, such as an
+// optional parameter left out to give one after it. Every other parameter must be set.
+func (s SocketServer) AllowEmpty(key string, indexes ...int) {
+	if s.empty[key] == nil {
+		s.empty[key] = make(map[int]bool)
+	}
+	for _, i := range indexes {
+		s.empty[key][i] = true
+	}
+}
+
+// route returns the handler for command, or nil if the command is unknown,
+// has the wrong number of parameters, or has an empty parameter it doesn't allow
+func (s SocketServer) route(command string, params []string) func(transNum int, args ...string) Result {
+	function, ok := s.funcMap[command]
+	if !ok {
+		return nil
+	}
+	for i, param := range params {
+		if param == "" && !s.empty[command][i] {
+			return nil
+		}
+	}
+	for _, count := range s.paramMap[command] {
+		if len(params) == count {
+			return function
+		}
+	}
+	return nil
+}
+
+// getRoute parses a text protocol command line into its handler and parameters
+func (s SocketServer) getRoute(command string) (func(transNum int, args ...string) Result, []string) {
 	command = string(bytes.Trim([]byte(command), "\x00"))
 	result := strings.Split(strings.TrimSpace(command), ",")
-	function := s.funcMap[result[0]]
 	params := result[1:]
-	if result[len(result)-1] == "" {
+	function := s.route(result[0], params)
+	if function == nil {
 		return nil, nil
 	}
-	switch result[0] {
-	case "COMMIT_BUY", "CANCEL_BUY", "COMMIT_SELL", "CANCEL_SELL", "DISPLAY_SUMMARY":
-		if len(params) != 1 {
-			return nil, nil
-		}
-		break
-	case "ADD", "QUOTE", "CANCEL_SET_BUY", "CANCEL_SET_SELL":
-		if len(params) != 2 {
-			return nil, nil
-		}
-		break
-	case "BUY", "SELL", "SET_BUY_AMOUNT", "SET_BUY_TRIGGER", "SET_SELL_TRIGGER", "SET_SELL_AMOUNT":
-		if len(params) != 3 {
-			return nil, nil
+	return function, params
+}
+
+// dispatch runs the handler for command, or returns a BAD_REQUEST result if there is none
+func (s SocketServer) dispatch(transNum int, command string, params []string) Result {
+	function := s.route(command, params)
+	if function == nil {
+		fmt.Printf("Error: command not implemented '%s' with %d parameters\n", command, len(params))
+		return Error(CodeBadRequest, "Unknown command or wrong number of parameters")
+	}
+	return function(transNum, params...)
+}
+
+// Handles incoming requests. A connection that opens with the frame preface
+// speaks the framed protocol, anything else speaks the text protocol.
+func (s SocketServer) handleRequest(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		fmt.Println("ERROR1: ", err)
+		return
+	}
+	if first[0] == framePreface[0] {
+		s.serveFramed(conn, reader)
+	} else {
+		s.serveText(conn, reader)
+	}
+}
+
+// serveFramed negotiates a frame version, then answers request frames until the client disconnects
+func (s SocketServer) serveFramed(conn net.Conn, reader *bufio.Reader) {
+	version, err := negotiate(struct {
+		io.Reader
+		io.Writer
+	}{reader, conn})
+	if err != nil {
+		fmt.Println("ERROR negotiating frame version: ", err)
+		return
+	}
+	fmt.Println("Speaking frame version ", version, " with ", conn.RemoteAddr())
+
+	// Requests run concurrently and reply as they finish, so replies can come
+	// back out of order; clients match them up by request ID
+	var writeLock sync.Mutex
+	var inFlight sync.WaitGroup
+	slots := make(chan struct{}, maxInFlightPerConn)
+	defer inFlight.Wait()
+
+	reply := func(id uint32, res Result) {
+		fmt.Println(res)
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		if err := writeReply(conn, id, res); err != nil {
+			fmt.Println("ERROR3 writing back response ", err)
 		}
-		break
-	case "DUMPLOG":
-		if len(params) != 1 || len(params) != 2 {
-			return nil, nil
+	}
+
+	for {
+		req, err := readRequest(reader)
+		switch err {
+		case nil:
+		case errBadFrame:
+			reply(req.id, Error(CodeBadRequest, "Malformed request frame"))
+			continue
+		default:
+			if err != io.EOF {
+				fmt.Println("ERROR reading frame: ", err)
+			}
+			return
 		}
-	case "TRIGGER_SUCCESS":
-		if len(params) != 5 {
-			return nil, nil
+		if s.hidden[req.command] {
+			fmt.Println("recvd: ", req.id, req.transNum, req.command, "with hidden parameters")
+		} else {
+			fmt.Println("recvd: ", req.id, req.transNum, req.command, req.args)
 		}
-	default:
-		return nil, nil
+
+		slots <- struct{}{}
+		inFlight.Add(1)
+		go func(req request) {
+			defer func() {
+				<-slots
+				inFlight.Done()
+			}()
+			reply(req.id, s.dispatch(req.transNum, req.command, req.args))
+		}(req)
 	}
-	return function, params
 }
 
-// Handles incoming requests.
-func (s SocketServer) handleRequest(conn net.Conn) {
-	reader := bufio.NewReader(conn)
+// serveText answers "transNum;COMMAND,args\n" lines until the client disconnects
+func (s SocketServer) serveText(conn net.Conn, reader *bufio.Reader) {
 	for {
 		recv, err := reader.ReadString('\n')
 		if err != nil {
@@ -106,30 +216,36 @@ func (s SocketServer) handleRequest(conn net.Conn) {
 			fmt.Println("Received only a newline")
 			continue
 		}
-		fmt.Println("recvd: ", recv)
-
-		sepTransCommand := strings.Split(recv, ";")
-		transNum, _ := strconv.Atoi(sepTransCommand[0])
-		command := sepTransCommand[1]
-		function, params := s.getRoute(command)
-
-		if function == nil {
-			fmt.Printf("Error: command not implemented '%s'\n", command)
-			n, err := conn.Write([]byte("-1"))
-			if err != nil {
-				fmt.Println("ERROR2 writing back response ", err)
+		line := strings.SplitN(recv, ";", 2)
+		command := strings.SplitN(line[len(line)-1], ",", 2)[0]
+		if s.hidden[command] {
+			fmt.Println("recvd: ", command, " with hidden parameters")
+		} else {
+			fmt.Println("recvd: ", recv)
+		}
+
+		var res Result
+		sepTransCommand := strings.SplitN(recv, ";", 2)
+		if len(sepTransCommand) != 2 {
+			res = Error(CodeBadRequest, "Expected transNum;COMMAND,args")
+		} else {
+			transNum, _ := strconv.Atoi(sepTransCommand[0])
+			function, params := s.getRoute(sepTransCommand[1])
+			if function == nil {
+				if s.hidden[command] {
+					fmt.Printf("Error: command not implemented '%s' with hidden parameters\n", command)
+				} else {
+					fmt.Printf("Error: command not implemented '%s'\n", sepTransCommand[1])
+				}
+				res = Error(CodeBadRequest, "Unknown command or wrong number of parameters")
 			} else {
-				fmt.Println("Wrote back ", n, " bytes")
+				res = function(transNum, params...)
 			}
-			return
 		}
-
-		res := function(transNum, params...)
-		res += "\n"
 		fmt.Println(res)
 
 		// Send a response back to person contacting us.
-		n, err := conn.Write([]byte(res))
+		n, err := conn.Write(res.encode())
 		if err != nil {
 			fmt.Println("ERROR3 writing back response ", err)
 		} else {
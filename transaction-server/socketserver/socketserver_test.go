@@ -0,0 +1,214 @@
+package socketserver
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestResultEncode(t *testing.T) {
+	tests := []struct {
+		result   Result
+		expected string
+	}{
+		{OK(nil), `{"status":1}` + "\n"},
+		{OK("12.50"), `{"status":1,"payload":"12.50"}` + "\n"},
+		{Error(CodeInsufficientFunds, "Not enough funds"),
+			`{"status":-1,"code":"INSUFFICIENT_FUNDS","message":"Not enough funds"}` + "\n"},
+	}
+	for _, test := range tests {
+		if encoded := string(test.result.encode()); encoded != test.expected {
+			t.Errorf("Encoded %v as %s, expected %s", test.result, encoded, test.expected)
+		}
+	}
+
+	var decoded Result
+	if err := json.Unmarshal(Error(CodeNoPendingBuy, "none").encode(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Succeeded() || decoded.Code != CodeNoPendingBuy {
+		t.Error("Decoded the wrong result ", decoded)
+	}
+}
+
+func TestGetRoute(t *testing.T) {
+	s := NewSocketServer(":0")
+	s.Route("ADD", func(transNum int, args ...string) Result { return OK(nil) }, 2)
+	s.Route("DUMPLOG", func(transNum int, args ...string) Result { return OK(nil) }, 1, 2)
+
+	if f, params := s.getRoute("ADD,user1,10.00\n"); f == nil || len(params) != 2 {
+		t.Error("ADD with two parameters should route, got ", params)
+	}
+	if f, _ := s.getRoute("ADD,user1\n"); f != nil {
+		t.Error("ADD with one parameter should not route")
+	}
+	if f, _ := s.getRoute("ADD,user1,\n"); f != nil {
+		t.Error("ADD with an empty parameter should not route")
+	}
+	if f, _ := s.getRoute("DUMPLOG,logfile\n"); f == nil {
+		t.Error("DUMPLOG with only a filename should route")
+	}
+	if f, _ := s.getRoute("DUMPLOG,user1,logfile\n"); f == nil {
+		t.Error("DUMPLOG with a user and filename should route")
+	}
+	if f, _ := s.getRoute("UNKNOWN,user1\n"); f != nil {
+		t.Error("Unknown commands should not route")
+	}
+}
+
+func TestRouteAllowEmpty(t *testing.T) {
+	s := NewSocketServer(":0")
+	s.Route("SET_BUY_TRIGGER", func(transNum int, args ...string) Result { return OK(nil) }, 3, 4, 5)
+	s.AllowEmpty("SET_BUY_TRIGGER", 3)
+
+	if f := s.route("SET_BUY_TRIGGER", []string{"user1", "ABC", "20.00", "", "1700000000000"}); f == nil {
+		t.Error("SET_BUY_TRIGGER with an empty ID and an expiry should route")
+	}
+	if f := s.route("SET_BUY_TRIGGER", []string{"user1", "ABC", "20.00", "id1", "1700000000000"}); f == nil {
+		t.Error("SET_BUY_TRIGGER with an ID and an expiry should route")
+	}
+	if f := s.route("SET_BUY_TRIGGER", []string{"user1", "", "20.00", "", "1700000000000"}); f != nil {
+		t.Error("SET_BUY_TRIGGER with an empty stock should not route")
+	}
+	if f := s.route("SET_BUY_TRIGGER", []string{"user1", "ABC", "20.00", "id1", ""}); f != nil {
+		t.Error("SET_BUY_TRIGGER with an empty expiry should not route")
+	}
+}
+
+// writeRequest encodes a request frame the way a framed client would
+func writeRequest(w io.Writer, id uint32, transNum int, fields ...string) error {
+	body := make([]byte, 6)
+	binary.BigEndian.PutUint32(body, uint32(transNum))
+	binary.BigEndian.PutUint16(body[4:], uint16(len(fields)))
+	for _, field := range fields {
+		size := make([]byte, 2)
+		binary.BigEndian.PutUint16(size, uint16(len(field)))
+		body = append(append(body, size...), field...)
+	}
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(header[4:], id)
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+// readReply decodes a reply frame the way a framed client would
+func readReply(r io.Reader) (uint32, Result, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, Result{}, err
+	}
+	body := make([]byte, binary.BigEndian.Uint32(header[:4])-4)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, Result{}, err
+	}
+	var res Result
+	err := json.Unmarshal(body, &res)
+	return binary.BigEndian.Uint32(header[4:]), res, err
+}
+
+func echoServer() SocketServer {
+	s := NewSocketServer(":0")
+	s.Route("ECHO", func(transNum int, args ...string) Result {
+		return OK(strings.Join(args, "|"))
+	}, 1, 2)
+	return s
+}
+
+func TestFramedProtocol(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go echoServer().handleRequest(server)
+
+	if _, err := client.Write(append([]byte("TSF"), 7)); err != nil {
+		t.Fatal(err)
+	}
+	preface := make([]byte, 4)
+	if _, err := io.ReadFull(client, preface); err != nil {
+		t.Fatal(err)
+	}
+	if string(preface[:3]) != "TSF" || preface[3] != FrameVersion {
+		t.Fatalf("Expected the server to settle on version %d, got %q", FrameVersion, preface)
+	}
+
+	tests := []struct {
+		id       uint32
+		fields   []string
+		expected Result
+	}{
+		{1, []string{"ECHO", "user,with,commas"}, OK("user,with,commas")},
+		{2, []string{"ECHO", "a;b", "c"}, OK("a;b|c")},
+		{3, []string{"ECHO", "a", "b", "c"}, Error(CodeBadRequest, "Unknown command or wrong number of parameters")},
+		{4, []string{"MISSING"}, Error(CodeBadRequest, "Unknown command or wrong number of parameters")},
+	}
+	for _, test := range tests {
+		go writeRequest(client, test.id, 100+int(test.id), test.fields...)
+		id, res, err := readReply(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != test.id {
+			t.Errorf("Reply to request %d carried ID %d", test.id, id)
+		}
+		if res.Status != test.expected.Status || res.Code != test.expected.Code || res.Payload != test.expected.Payload {
+			t.Errorf("%v: expected %v, got %v", test.fields, test.expected, res)
+		}
+	}
+}
+
+func TestTextProtocolStillServed(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go echoServer().handleRequest(server)
+
+	go client.Write([]byte("1;ECHO,hello\n"))
+	var res Result
+	line, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(line), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Succeeded() || res.Payload != "hello" {
+		t.Error("Expected the text protocol to echo hello, got ", res)
+	}
+}
+
+func TestFramedRequestsRunConcurrently(t *testing.T) {
+	s := NewSocketServer(":0")
+	release := make(chan struct{})
+	s.Route("SLOW", func(transNum int, args ...string) Result {
+		<-release
+		return OK("slow")
+	}, 0)
+	s.Route("FAST", func(transNum int, args ...string) Result {
+		close(release)
+		return OK("fast")
+	}, 0)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go s.handleRequest(server)
+	go func() {
+		client.Write(append([]byte("TSF"), FrameVersion))
+		writeRequest(client, 1, 1, "SLOW")
+		writeRequest(client, 2, 2, "FAST")
+	}()
+	io.ReadFull(client, make([]byte, 4))
+
+	// SLOW can only finish after FAST has run, so FAST must reply first
+	for _, expected := range []uint32{2, 1} {
+		id, res, err := readReply(client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != expected || !res.Succeeded() {
+			t.Errorf("Expected a reply to request %d, got %d: %v", expected, id, res)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -10,10 +11,11 @@ import (
 	"seng468/transaction-server/socketserver"
 	"seng468/transaction-server/trigger"
 	"strconv"
-
-	"errors"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // TransactionServer holds the main components of the module itself
@@ -22,11 +24,20 @@ type TransactionServer struct {
 	Addr          string
 	Server        socketserver.SocketServer
 	Logger        logger.Logger
-	UserDatabase  database.RedisDatabase
-	TriggerClient triggerclient.TriggerClient
+	UserDatabase  database.UserDatabase
+	QuoteClient   quoteclient.QuoteClient
+	TriggerClient triggerclient.TriggerFunctions
+	Fills         *FillFeed
+	Events        EventPublisher
+
+	// Admin accounts are only created by createAdmins, never by REGISTER
+	Admins map[string]bool
 }
 
 func main() {
+	inMemory := flag.Bool("inmemory", false, "keep user accounts in memory instead of redis")
+	flag.Parse()
+
 	serverAddr := ":" + os.Getenv("transport")
 	databaseAddr := "tcp"
 	databasePort := os.Getenv("dbaddr") + ":" + os.Getenv("dbport")
@@ -34,14 +45,26 @@ func main() {
 	triggerURL := "http://" + os.Getenv("triggeraddr") + ":" + os.Getenv("triggerport")
 
 	server := socketserver.NewSocketServer(serverAddr)
-	database := database.RedisDatabase{
-		Addr:         databaseAddr,
-		Port:         databasePort,
-		DbRequests:   make(chan *database.Query, 1000),
-		BatchSize:    100,
-		PollRate:     20,
-		BatchResults: make(chan database.Response, 1000),
-		DbPool:       database.NewPool(databaseAddr, databasePort),
+	var userDatabase database.UserDatabase
+	// Without redis there is no channel to the web servers, so events aren't published
+	var events EventPublisher
+	if *inMemory {
+		userDatabase = database.NewMemoryDatabase()
+	} else {
+		redisDatabase := database.RedisDatabase{
+			Addr:       databaseAddr,
+			Port:       databasePort,
+			DbRequests: make(chan *database.Query, 1000),
+			BatchSize:  100,
+			PollRate:   20,
+			Timeout:    time.Second * 5,
+			DbPool:     database.NewPool(databaseAddr, databasePort, time.Second*5),
+		}
+		go redisDatabase.DbRequestWorker()
+		userDatabase = redisDatabase
+		redisEvents := NewRedisEvents(database.NewPool(databaseAddr, databasePort, time.Second*5), eventsChannel)
+		go redisEvents.Run()
+		events = redisEvents
 	}
 	logger := logger.AuditLogger{Addr: auditAddr}
 	triggerclient := triggerclient.TriggerClient{TriggerURL: triggerURL}
@@ -51,115 +74,136 @@ func main() {
 		Addr:          serverAddr,
 		Server:        server,
 		Logger:        logger,
-		UserDatabase:  database,
+		UserDatabase:  userDatabase,
+		QuoteClient:   quoteclient.HTTPQuoteClient{},
 		TriggerClient: triggerclient,
+		Fills:         NewFillFeed(),
+		Events:        events,
+		Admins:        make(map[string]bool),
+	}
+	for _, admin := range strings.Split(os.Getenv("adminusers"), ",") {
+		if admin != "" {
+			ts.Admins[admin] = true
+		}
+	}
+	if err := ts.createAdmins(os.Getenv("adminpassword")); err != nil {
+		panic(err)
 	}
 
-	server.Route("ADD", ts.Add)
-	server.Route("QUOTE", ts.Quote)
-	server.Route("BUY", ts.Buy)
-	server.Route("COMMIT_BUY", ts.CommitBuy)
-	server.Route("CANCEL_BUY", ts.CancelBuy)
-	server.Route("SELL", ts.Sell)
-	server.Route("COMMIT_SELL", ts.CommitBuy)
-	server.Route("CANCEL_SELL", ts.CancelBuy)
-	server.Route("SET_BUY_AMOUNT", ts.SetBuyAmount)
-	server.Route("CANCEL_SET_BUY", ts.CancelSetBuy)
-	server.Route("SET_BUY_TRIGGER", ts.SetBuyTrigger)
-	server.Route("SET_SELL_AMOUNT", ts.SetSellAmount)
-	server.Route("SET_SELL_TRIGGER", ts.SetSellTrigger)
-	server.Route("TRIGGER_SUCCESS", ts.TriggerSuccess)
-	server.Route("CANCEL_SET_SELL", ts.CancelSetSell)
-	server.Route("DUMPLOG", ts.DumpLogUser)
-	server.Route("DISPLAY_SUMMARY", ts.DisplaySummary)
-	go ts.UserDatabase.DbRequestWorker()
+	server.Route("ADD", ts.Add, 2)
+	server.Route("QUOTE", ts.Quote, 2)
+	server.Route("BUY", ts.Buy, 3)
+	server.Route("COMMIT_BUY", ts.CommitBuy, 1)
+	server.Route("CANCEL_BUY", ts.CancelBuy, 1)
+	server.Route("SELL", ts.Sell, 3)
+	server.Route("COMMIT_SELL", ts.CommitSell, 1)
+	server.Route("CANCEL_SELL", ts.CancelSell, 1)
+	server.Route("SET_BUY_AMOUNT", ts.SetBuyAmount, 3)
+	server.Route("CANCEL_SET_BUY", ts.CancelSetBuy, 2, 3)
+	server.Route("SET_BUY_TRIGGER", ts.SetBuyTrigger, 3, 4, 5)
+	server.Route("SET_SELL_AMOUNT", ts.SetSellAmount, 3)
+	server.Route("SET_SELL_TRIGGER", ts.SetSellTrigger, 3, 4, 5)
+	server.Route("TRIGGER_SUCCESS", ts.TriggerSuccess, 6)
+	server.Route("TRIGGER_EXPIRED", ts.TriggerExpired, 4)
+	server.Route("CANCEL_SET_SELL", ts.CancelSetSell, 2, 3)
+	server.Route("DUMPLOG", ts.DumpLogUser, 1, 2)
+	server.Route("DISPLAY_SUMMARY", ts.DisplaySummary, 1)
+	server.Route("ACCOUNT", ts.Account, 1)
+	server.Route("HISTORY", ts.History, 1, 2)
+	server.Route("RECONCILE_TRIGGERS", ts.ReconcileTriggers, 0)
+	server.Route("LIMIT_BUY", ts.LimitBuy, 5, 6)
+	server.Route("LIMIT_SELL", ts.LimitSell, 5, 6)
+	server.Route("STOP_BUY", ts.StopBuy, 5, 6)
+	server.Route("STOP_SELL", ts.StopSell, 5, 6)
+	server.Route("TRAILING_STOP_BUY", ts.TrailingStopBuy, 5, 6)
+	server.Route("TRAILING_STOP_SELL", ts.TrailingStopSell, 5, 6)
+	server.Route("OCO_SELL", ts.OCOSell, 6, 7)
+	server.Route("BRACKET_BUY", ts.BracketBuy, 7, 8)
+	server.Route("LIST_LIMIT_ORDERS", ts.ListLimitOrders, 1)
+	server.Route("AMEND_LIMIT_ORDER", ts.AmendLimitOrder, 4)
+	server.Route("CANCEL_LIMIT_ORDER", ts.CancelLimitOrder, 2)
+	server.Route("REGISTER", ts.Register, 2)
+	server.Route("AUTHENTICATE", ts.Authenticate, 2)
+	server.HideParams("REGISTER", "AUTHENTICATE")
+	// The trigger ID can be left empty to start the latest trigger with an expiry
+	server.AllowEmpty("SET_BUY_TRIGGER", 3)
+	server.AllowEmpty("SET_SELL_TRIGGER", 3)
+	go ts.ExpireOrders(time.Second * 5)
+	if grpcPort := os.Getenv("transgrpcport"); grpcPort != "" {
+		go serveGRPC(":"+grpcPort, ts)
+	}
 	server.Run()
 }
 
 // Add the given amount of money to the user's account
 // Params: user, amount
 // PostCondition: the user's account is increased by the amount of money specified
-func (ts TransactionServer) Add(transNum int, params ...string) string {
+func (ts TransactionServer) Add(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	amount, err := decimal.NewFromString(params[1])
 	if err != nil {
-		ts.reportError(transNum, "ADD", user, "Could not parse add amount to decimal",
-			nil, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "ADD", user, socketserver.CodeBadRequest,
+			"Could not parse add amount to decimal", nil, nil, nil)
 	}
 
-	err = ts.UserDatabase.AddFunds(user, amount)
+	err = ts.UserDatabase.WithTransaction(transNum, "ADD").AddFunds(user, amount)
 	if err != nil {
-		ts.reportError(transNum, "ADD", user, "Failed to add amount to the database for user: "+err.Error(),
-			nil, nil, amount.String())
-		return "-1"
+		return ts.reportError(transNum, "ADD", user, errorCode(err),
+			"Failed to add amount to the database for user: "+err.Error(), nil, nil, amount.String())
 	}
 	go ts.Logger.AccountTransaction(ts.Name, transNum, "ADD", user, amount)
-	return "1"
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
 }
 
 // Quote gets the current quote for the stock for the specified user
 // Params: user, stock
 // PostCondition: the current price of the specified stock is displayed to the user
-func (ts TransactionServer) Quote(transNum int, params ...string) string {
+func (ts TransactionServer) Quote(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
-	dec, err := quoteclient.Query(user, stock, transNum)
+	dec, err := ts.QuoteClient.Query(user, stock, transNum)
 	if err != nil {
-		ts.reportError(transNum, "QUOTE", user, err.Error(),
+		return ts.reportError(transNum, "QUOTE", user, socketserver.CodeQuoteUnavailable, err.Error(),
 			stock, nil, nil)
-		return "-1"
 	}
-	return dec.StringFixed(2)
+	ts.publish(EventQuote, transNum, "", quoteEvent{Stock: stock, Price: dec})
+	return socketserver.OK(dec.StringFixed(2))
 }
 
 // Buy the dollar amount of the stock for the specified user at the current price.
 // Params: user, stock, amount
 // PreCondition: The user's account must be greater or equal to the amount of the purchase.
 // PostCondition: The user is asked to confirm or cancel the transaction
-func (ts TransactionServer) Buy(transNum int, params ...string) string {
+func (ts TransactionServer) Buy(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	amount, err := decimal.NewFromString(params[2])
 	if err != nil {
-		ts.reportError(transNum, "BUY", user, "Could not parse buy amount to decimal", stock, nil, nil)
-		return "-1"
-	}
-
-	curr, err := ts.UserDatabase.GetFunds(user)
-	if err != nil {
-		ts.reportError(transNum, "BUY", user, fmt.Sprintf("Error connecting to the database to get funds: %s", err.Error()),
-			stock, nil, amount.String())
-		return "-1"
-	}
-
-	if curr.LessThan(amount) {
-		ts.reportError(transNum, "BUY", user, "Not enough funds to issue buy order", stock, nil, amount.String())
-		return "-1"
+		return ts.reportError(transNum, "BUY", user, socketserver.CodeBadRequest,
+			"Could not parse buy amount to decimal", stock, nil, nil)
 	}
 
 	cost, shares, err := ts.getMaxPurchase(user, stock, amount, nil, transNum)
 	if err != nil {
-		ts.reportError(transNum, "BUY", user, fmt.Sprintf("Error connecting to the quote server: %s", err.Error()),
-			stock, nil, amount.String())
-		return "-1"
+		return ts.reportError(transNum, "BUY", user, socketserver.CodeQuoteUnavailable,
+			fmt.Sprintf("Error connecting to the quote server: %s", err.Error()), stock, nil, amount.String())
 	}
 
-	err = ts.UserDatabase.RemoveFunds(user, cost)
-	if err != nil {
-		ts.reportError(transNum, "BUY", user, fmt.Sprintf("Error removing funds: %s", err.Error()),
-			stock, nil, amount.String())
-		return "-1"
-	}
-	err = ts.UserDatabase.PushBuy(user, stock, cost, shares)
-	if err != nil {
-		ts.reportError(transNum, "BUY", user, fmt.Sprintf("Error pushing buy command: %s", err.Error()),
-			stock, nil, amount.String())
-		return "-1"
+	err = ts.UserDatabase.WithTransaction(transNum, "BUY").PushBuyWithFunds(user, stock, cost, shares)
+	if err == database.ErrInsufficientFunds {
+		return ts.reportError(transNum, "BUY", user, socketserver.CodeInsufficientFunds,
+			"Not enough funds to issue buy order", stock, nil, amount.String())
+	} else if err != nil {
+		return ts.reportError(transNum, "BUY", user, errorCode(err),
+			fmt.Sprintf("Error pushing buy command: %s", err.Error()), stock, nil, amount.String())
 	}
 
 	go ts.Logger.AccountTransaction(ts.Name, transNum, "remove", user, amount)
-	return "1"
+	ts.publishOrder(transNum, user, "BUY", OrderPending, stock, cost, shares,
+		time.Now().Add(database.PendingOrderTimeout))
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
 }
 
 // CommitBuy commits the most recently executed BUY command
@@ -168,50 +212,44 @@ func (ts TransactionServer) Buy(transNum int, params ...string) string {
 // Post-Conditions:
 // 		(a) the user's cash account is decreased by the amount user to purchase the stock
 // 		(b) the user's account for the given stock is increased by the purchase amount
-func (ts TransactionServer) CommitBuy(transNum int, params ...string) string {
+func (ts TransactionServer) CommitBuy(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	go ts.Logger.SystemEvent(ts.Name, transNum, "COMMIT_BUY", user, nil, nil, nil)
-	stock, _, shares, err := ts.UserDatabase.PopBuy(user)
-	if err != nil {
-		ts.reportError(transNum, "COMMIT_BUY", user, "Error popping command in commit buy: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
-	}
-
-	err = ts.UserDatabase.AddStock(user, stock, shares)
-	if err != nil {
-		ts.reportError(transNum, "COMMIT_BUY", user, "Error connecting to database to add stock: "+err.Error(),
-			stock, nil, string(shares))
-		return "-1"
+	stock, cost, shares, err := ts.UserDatabase.WithTransaction(transNum, "COMMIT_BUY").CommitBuyOrder(user)
+	if err == database.ErrOrderExpired {
+		go ts.Logger.AccountTransaction(ts.Name, transNum, "add", user, cost)
+		ts.publishOrder(transNum, user, "BUY", OrderExpired, stock, cost, shares, time.Time{})
+		ts.publishBalance(transNum, user)
+		return ts.reportError(transNum, "COMMIT_BUY", user, socketserver.CodeOrderExpired,
+			"Most recent buy has expired, its funds were returned", stock, nil, cost.String())
+	} else if err == database.ErrNoPendingOrder {
+		return ts.reportError(transNum, "COMMIT_BUY", user, socketserver.CodeNoPendingBuy,
+			"No pending buy orders to commit", nil, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "COMMIT_BUY", user, errorCode(err), "Error committing buy order: "+err.Error(),
+			nil, nil, nil)
 	}
-	return "1"
+	ts.publishOrder(transNum, user, "BUY", OrderCommitted, stock, cost, shares, time.Time{})
+	return socketserver.OK(nil)
 }
 
 // CancelBuy cancels the most recently executed BUY Command
 // Param: user
 // Pre-Condition: The user must have executed a BUY command within the previous 60 seconds
 // Post-Condition: The last BUY command is canceled and any allocated system resources are reset and released.
-func (ts TransactionServer) CancelBuy(transNum int, params ...string) string {
+func (ts TransactionServer) CancelBuy(transNum int, params ...string) socketserver.Result {
 	user := params[0]
-	stock, cost, _, err := ts.UserDatabase.PopBuy(user)
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_BUY", user, "Error popping command in cancel buy: "+err.Error(),
+	stock, cost, shares, err := ts.UserDatabase.WithTransaction(transNum, "CANCEL_BUY").CancelBuyOrder(user)
+	if err == database.ErrNoPendingOrder {
+		return ts.reportError(transNum, "CANCEL_BUY", user, socketserver.CodeNoPendingBuy,
+			"No pending buy orders to pop", nil, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "CANCEL_BUY", user, errorCode(err), "Error cancelling buy order: "+err.Error(),
 			nil, nil, nil)
-		return "-1"
-	}
-
-	if stock == "" {
-		ts.reportError(transNum, "CANCEL_BUY", user, "No pending buy orders to pop", nil, nil, nil)
-		return "-1"
-	}
-
-	err = ts.UserDatabase.AddFunds(user, cost)
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_BUY", user, "Error connecting to database to add funds: "+err.Error(),
-			stock, nil, cost.String())
-		return "-1"
 	}
-	return "1"
+	ts.publishOrder(transNum, user, "BUY", OrderCancelled, stock, cost, shares, time.Time{})
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
 }
 
 // Sell the specified dollar mount of the stock currently held by the specified
@@ -220,42 +258,31 @@ func (ts TransactionServer) CancelBuy(transNum int, params ...string) string {
 // Pre-condition: The user's account for the given stock must be greater than
 // 		or equal to the amount being sold.
 // Post-condition: The user is asked to confirm or cancel the given transaction
-func (ts TransactionServer) Sell(transNum int, params ...string) string {
+func (ts TransactionServer) Sell(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	amount, err := decimal.NewFromString(params[2])
 	if err != nil {
-		ts.reportError(transNum, "SELL", user, "Could not parse sell amount to decimal", stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "SELL", user, socketserver.CodeBadRequest,
+			"Could not parse sell amount to decimal", stock, nil, nil)
 	}
 	cost, shares, err := ts.getMaxPurchase(user, stock, amount, nil, transNum)
 	if err != nil {
-		ts.reportError(transNum, "SELL", user, "Could not connect to the quote server: "+err.Error(),
-			stock, nil, amount.String())
-		return "-1"
+		return ts.reportError(transNum, "SELL", user, socketserver.CodeQuoteUnavailable,
+			"Could not connect to the quote server: "+err.Error(), stock, nil, amount.String())
 	}
 
-	curr, err := ts.UserDatabase.GetStock(user, stock)
-	if curr < shares {
-		ts.reportError(transNum, "SELL", user, "Cannot sell more stock than you own", stock,
-			nil, amount.String())
-		return "-1"
+	err = ts.UserDatabase.WithTransaction(transNum, "SELL").PushSellWithStock(user, stock, cost, shares)
+	if err == database.ErrInsufficientStock {
+		return ts.reportError(transNum, "SELL", user, socketserver.CodeInsufficientStock,
+			"Cannot sell more stock than you own", stock, nil, amount.String())
+	} else if err != nil {
+		return ts.reportError(transNum, "SELL", user, errorCode(err),
+			"Error pushing sell command to database: "+err.Error(), stock, nil, amount.String())
 	}
-
-	err = ts.UserDatabase.RemoveStock(user, stock, shares)
-	if err != nil {
-		ts.reportError(transNum, "SELL", user, "Error removing stock from database: "+err.Error(), stock, nil,
-			string(shares))
-		return "-1"
-	}
-
-	err = ts.UserDatabase.PushSell(user, stock, cost, shares)
-	if err != nil {
-		ts.reportError(transNum, "SELL", user, "Error pushing sell command to database: "+err.Error(),
-			stock, nil, amount.String())
-		return "-1"
-	}
-	return "-1"
+	ts.publishOrder(transNum, user, "SELL", OrderPending, stock, cost, shares,
+		time.Now().Add(database.PendingOrderTimeout))
+	return socketserver.OK(nil)
 }
 
 // CommitSell commits the most recently executed SELL command
@@ -264,24 +291,25 @@ func (ts TransactionServer) Sell(transNum int, params ...string) string {
 // Post-Conditions:
 // 		(a) the user's account for the given stock is decremented by the sale amount
 // 		(b) the user's cash account is increased by the sell amount
-func (ts TransactionServer) CommitSell(transNum int, params ...string) string {
+func (ts TransactionServer) CommitSell(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	go ts.Logger.SystemEvent(ts.Name, transNum, "COMMIT_SELL", user, nil, nil, nil)
 
-	stock, cost, _, err := ts.UserDatabase.PopSell(user)
-	if err != nil {
-		ts.reportError(transNum, "COMMIT_SELL", user, "Error connecting to database to pop command: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
+	stock, cost, shares, err := ts.UserDatabase.WithTransaction(transNum, "COMMIT_SELL").CommitSellOrder(user)
+	if err == database.ErrOrderExpired {
+		ts.publishOrder(transNum, user, "SELL", OrderExpired, stock, cost, shares, time.Time{})
+		return ts.reportError(transNum, "COMMIT_SELL", user, socketserver.CodeOrderExpired,
+			"Most recent sell has expired, its shares were returned", stock, nil, cost.String())
+	} else if err == database.ErrNoPendingOrder {
+		return ts.reportError(transNum, "COMMIT_SELL", user, socketserver.CodeNoPendingSell,
+			"No pending sell orders to commit", nil, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "COMMIT_SELL", user, errorCode(err),
+			"Error committing sell order: "+err.Error(), nil, nil, nil)
 	}
-
-	err = ts.UserDatabase.AddFunds(user, cost)
-	if err != nil {
-		ts.reportError(transNum, "COMMIT_SELL", user, "Error connecting to database to add funds: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
-	}
-	return "1"
+	ts.publishOrder(transNum, user, "SELL", OrderCommitted, stock, cost, shares, time.Time{})
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
 
 }
 
@@ -289,22 +317,18 @@ func (ts TransactionServer) CommitSell(transNum int, params ...string) string {
 // Params: user
 // Pre-conditions: The user must have executed a SELL command within the previous 60 seconds
 // Post-conditions: The last SELL command is canceled and any allocated system resources are reset and released.
-func (ts TransactionServer) CancelSell(transNum int, params ...string) string {
+func (ts TransactionServer) CancelSell(transNum int, params ...string) socketserver.Result {
 	user := params[0]
-	stock, _, shares, err := ts.UserDatabase.PopSell(user)
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SELL", user, "Error connecting to database to pop command: "+err.Error(),
-			nil, nil, nil)
-		return "-1"
+	stock, cost, shares, err := ts.UserDatabase.WithTransaction(transNum, "CANCEL_SELL").CancelSellOrder(user)
+	if err == database.ErrNoPendingOrder {
+		return ts.reportError(transNum, "CANCEL_SELL", user, socketserver.CodeNoPendingSell,
+			"No pending sell orders to pop", nil, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "CANCEL_SELL", user, errorCode(err),
+			"Error cancelling sell order: "+err.Error(), nil, nil, nil)
 	}
-
-	err = ts.UserDatabase.AddStock(user, stock, shares)
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SELL", user, "Error connecting to database to add stock: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
-	}
-	return "1"
+	ts.publishOrder(transNum, user, "SELL", OrderCancelled, stock, cost, shares, time.Time{})
+	return socketserver.OK(nil)
 }
 
 // SetBuyAmount sets a defined amount of the given stock to buy when the
@@ -318,113 +342,136 @@ func (ts TransactionServer) CancelSell(transNum int, params ...string) string {
 // 		(b) the user's cash account is decremented by the specified amount
 // 		(c) when the trigger point is reached the user's stock account is
 //			updated to reflect the BUY transaction.
-func (ts TransactionServer) SetBuyAmount(transNum int, params ...string) string {
+// A user can set several buy triggers on a stock. The payload is the new
+// trigger's ID, which SET_BUY_TRIGGER and CANCEL_SET_BUY take to pick it out.
+func (ts TransactionServer) SetBuyAmount(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	amount, err := decimal.NewFromString(params[2])
 	if err != nil {
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Could not parse set buy amount to decimal",
-			stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "SET_BUY_AMOUNT", user, socketserver.CodeBadRequest,
+			"Could not parse set buy amount to decimal", stock, nil, nil)
 	}
 
-	curr, err := ts.UserDatabase.GetFunds(user)
-	if err != nil {
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Could not get funds from database: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
+	db := ts.UserDatabase.WithTransaction(transNum, "SET_BUY_AMOUNT")
+	var id string
+	for attempt := 0; attempt < triggerIDAttempts; attempt++ {
+		id, err = newOrderID()
+		if err != nil {
+			return ts.reportError(transNum, "SET_BUY_AMOUNT", user, socketserver.CodeInternal,
+				"Could not generate a trigger ID: "+err.Error(), stock, nil, amount.String())
+		}
+		err = db.ReserveBuyTrigger(user, stock, id, amount)
+		if err != database.ErrTriggerExists {
+			break
+		}
 	}
-
-	if curr.LessThan(amount) {
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Not enough funds to execute command", stock,
-			nil, amount.String())
-		return "-1"
+	if err == database.ErrInsufficientFunds {
+		return ts.reportError(transNum, "SET_BUY_AMOUNT", user, socketserver.CodeInsufficientFunds,
+			"Not enough funds to execute command", stock, nil, amount.String())
+	} else if err == database.ErrTriggerExists {
+		return ts.reportError(transNum, "SET_BUY_AMOUNT", user, socketserver.CodeTriggerExists,
+			"Could not find an unused trigger ID", stock, nil, amount.String())
+	} else if err != nil {
+		return ts.reportError(transNum, "SET_BUY_AMOUNT", user, errorCode(err),
+			"Error moving funds to reserve: "+err.Error(), stock, nil, amount.String())
 	}
 
-	err = ts.UserDatabase.RemoveFunds(user, amount)
+	err = ts.TriggerClient.SetNewBuyTrigger(transNum, id, user, stock, amount)
 	if err != nil {
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Error removing funds from database: "+err.Error(),
-			stock, nil, amount.String())
-		return "-1"
+		result := ts.reportError(transNum, "SET_BUY_AMOUNT", user, socketserver.CodeTriggerUnavailable,
+			"Error setting a new buy trigger: "+err.Error(), stock, nil, amount.String())
+		// Hand the reserve back so the funds aren't stranded without a trigger
+		_, err = db.ReleaseBuyTrigger(user, stock, id)
+		if err != nil {
+			ts.reportError(transNum, "SET_BUY_AMOUNT", user, errorCode(err),
+				"Error returning reserved funds: "+err.Error(), stock, nil, amount.String())
+		}
+		return result
 	}
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(id)
+}
 
-	err = ts.UserDatabase.AddReserveFunds(user, amount)
-	if err != nil {
-		// TODO: add funds back into database
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Error adding funds to reserve:  "+err.Error(),
-			stock, nil, amount.String())
-		return "-1"
+// triggerIDAttempts is how many random IDs SET_BUY_AMOUNT and SET_SELL_AMOUNT
+// try before giving up. An ID is only taken if it happens to collide with
+// another of the user's triggers on the stock.
+const triggerIDAttempts = 3
+
+// triggerID returns the optional trigger ID at params[i]. Without one the
+// triggerserver acts on the user's latest trigger for the stock.
+func triggerID(params []string, i int) string {
+	if len(params) > i {
+		return params[i]
 	}
+	return ""
+}
 
-	err = ts.TriggerClient.SetNewBuyTrigger(transNum, user, stock, amount)
-	if err != nil {
-		ts.reportError(transNum, "SET_BUY_AMOUNT", user, "Error setting a new buy trigger: "+err.Error(),
-			stock, nil, amount.String())
-		return "-1"
+// triggerExpiry returns when a trigger started with the optional expiry at
+// params[i], in unix milliseconds, expires. Without one it runs until it fires.
+func triggerExpiry(params []string, i int) (time.Time, error) {
+	if len(params) <= i || params[i] == "" {
+		return time.Time{}, nil
 	}
-	// TODO: add trigger to database
-	return "1"
+	return parseExpiry(TIFGoodTillDate, params[i:i+1], time.Now())
 }
 
 // CancelSetBuy cancels a SET_BUY command issued for the given stock
-// Params: user, stock
+// Params: user, stock, trigger ID (optional, defaults to the latest buy trigger)
 // The must have been a SET_BUY Command issued for the given stock by the user
 // Post-condition:
 // 		(a) All accounts are reset to the values they would have had had the
 //			SET_BUY Command not been issued
 // 		(b) the BUY_TRIGGER for the given user and stock is also canceled.
-func (ts TransactionServer) CancelSetBuy(transNum int, params ...string) string {
+func (ts TransactionServer) CancelSetBuy(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 
-	cancelled, err := ts.TriggerClient.CancelBuyTrigger(transNum, user, stock)
+	trig, err := ts.TriggerClient.CancelBuyTrigger(transNum, user, stock, triggerID(params, 2))
 	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_BUY", user, "Error cancelling a trigger: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
-	}
-
-	err = ts.UserDatabase.RemoveReserveFunds(user, cancelled.GetAmount())
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_BUY", user, "Error removing funds from reserve: "+err.Error(),
-			stock, nil, cancelled.GetCost().String())
-		return "-1"
+		return ts.reportError(transNum, "CANCEL_SET_BUY", user, socketserver.CodeNoTrigger,
+			"Error cancelling a trigger: "+err.Error(), stock, nil, nil)
 	}
 
-	err = ts.UserDatabase.AddFunds(user, cancelled.GetAmount())
+	released, err := ts.UserDatabase.WithTransaction(transNum, "CANCEL_SET_BUY").ReleaseBuyTrigger(user, stock,
+		trig.GetID())
 	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_BUY", user, "Error adding funds: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "CANCEL_SET_BUY", user, errorCode(err),
+			"Error moving funds out of reserve: "+err.Error(), stock, nil, released.String())
 	}
-
-	return "1"
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
 }
 
 // SetBuyTrigger sets the trigger point base on the current stock price when
 // any SET_BUY will execute.
-// Params: user, stock, amount
+// Params: user, stock, amount, trigger ID (optional, defaults to the latest buy
+//		trigger, may be empty to give an expiry), expiry (optional, unix milliseconds)
 // Pre-conditions: The user must have specified a SET_BUY_AMOUNT prior to
-//		 setting a SET_BUY_TRIGGER
+//		 setting a SET_BUY_TRIGGER, within the triggerserver's waiting timeout
 // Post-conditions: The set of the user's buy triggers is updated to
-//		include the specified trigger
-func (ts TransactionServer) SetBuyTrigger(transNum int, params ...string) string {
+//		include the specified trigger. Once it expires its reserve is
+//		returned, see TriggerExpired.
+func (ts TransactionServer) SetBuyTrigger(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	triggerAmount, err := decimal.NewFromString(params[2])
 	if err != nil {
-		go ts.Logger.SystemError(ts.Name, transNum, "SET_BUY_TRIGGER", user, stock, nil, nil,
-			"Could not parse set buy trigger amount to decimal")
-		return "-1"
+		return ts.reportError(transNum, "SET_BUY_TRIGGER", user, socketserver.CodeBadRequest,
+			"Could not parse set buy trigger amount to decimal", stock, nil, nil)
 	}
-
-	_, err = ts.TriggerClient.StartNewBuyTrigger(transNum, user, stock, triggerAmount)
+	expires, err := triggerExpiry(params, 4)
 	if err != nil {
-		ts.reportError(transNum, "SET_BUY_TRIGGER", user, "No existing buy trigger for this user and stock",
+		return ts.reportError(transNum, "SET_BUY_TRIGGER", user, socketserver.CodeBadRequest, err.Error(),
 			stock, nil, triggerAmount.String())
-		return "-1"
 	}
-	return "1"
+
+	_, err = ts.TriggerClient.StartNewBuyTrigger(transNum, user, stock, triggerAmount, triggerID(params, 3), expires)
+	if err != nil {
+		return ts.reportError(transNum, "SET_BUY_TRIGGER", user, socketserver.CodeNoTrigger,
+			"No existing buy trigger for this user and stock", stock, nil, triggerAmount.String())
+	}
+	return socketserver.OK(nil)
 }
 
 // SetSellAmount sets a defined amount of the specified stock to sell when
@@ -434,43 +481,56 @@ func (ts TransactionServer) SetBuyTrigger(transNum int, params ...string) string
 //		account for that stock.
 // Post-conditions: A trigger is initialized for this username/stock symbol
 //		combination, but is not complete until SET_SELL_TRIGGER is executed.
-func (ts TransactionServer) SetSellAmount(transNum int, params ...string) string {
+// A user can set several sell triggers on a stock. The payload is the new
+// trigger's ID, which SET_SELL_TRIGGER and CANCEL_SET_SELL take to pick it out.
+func (ts TransactionServer) SetSellAmount(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	amount, err := strconv.ParseInt(params[2], 10, 64)
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_AMOUNT", user, "Could not parse set sell amount to decimal",
-			stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "SET_SELL_AMOUNT", user, socketserver.CodeBadRequest,
+			"Could not parse set sell amount to decimal", stock, nil, nil)
 	}
 
 	curr, err := ts.UserDatabase.GetStock(user, stock)
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_AMOUNT", user, "Could not get stock from database: "+err.Error(),
-			stock, nil, string(amount))
-		return "-1"
+		return ts.reportError(transNum, "SET_SELL_AMOUNT", user, errorCode(err),
+			"Could not get stock from database: "+err.Error(), stock, nil, strconv.FormatInt(amount, 10))
 	}
 
 	if amount > curr {
-		ts.reportError(transNum, "SET_SELL_AMOUNT", user, "Cannot set sell trigger for more stock than you own",
-			stock, nil, string(amount))
-		return "-1"
+		return ts.reportError(transNum, "SET_SELL_AMOUNT", user, socketserver.CodeInsufficientStock,
+			"Cannot set sell trigger for more stock than you own", stock, nil, strconv.FormatInt(amount, 10))
 	}
 
-	err = ts.TriggerClient.SetNewSellTrigger(transNum, user, stock, amount)
-	if err != nil {
-		ts.reportError(transNum, "SET_SELL_AMOUNT", user, "Failed to make new sell trigger: "+err.Error(),
-			stock, nil, string(amount))
-		return "-1"
+	var id string
+	for attempt := 0; attempt < triggerIDAttempts; attempt++ {
+		id, err = newOrderID()
+		if err != nil {
+			return ts.reportError(transNum, "SET_SELL_AMOUNT", user, socketserver.CodeInternal,
+				"Could not generate a trigger ID: "+err.Error(), stock, nil, strconv.FormatInt(amount, 10))
+		}
+		err = ts.TriggerClient.SetNewSellTrigger(transNum, id, user, stock, amount)
+		if err != triggerclient.ErrTriggerExists {
+			break
+		}
+	}
+	if err == triggerclient.ErrTriggerExists {
+		return ts.reportError(transNum, "SET_SELL_AMOUNT", user, socketserver.CodeTriggerExists,
+			"Could not find an unused trigger ID", stock, nil, strconv.FormatInt(amount, 10))
+	} else if err != nil {
+		return ts.reportError(transNum, "SET_SELL_AMOUNT", user, socketserver.CodeTriggerUnavailable,
+			"Failed to make new sell trigger: "+err.Error(), stock, nil, strconv.FormatInt(amount, 10))
 	}
-	return "1"
+	return socketserver.OK(id)
 }
 
 // SetSellTrigger sets the stock price trigger point for executing any
 // SET_SELL triggers associated with the given stock and user
-// Params: user, stock, amount
+// Params: user, stock, amount, trigger ID (optional, defaults to the latest sell
+//		trigger, may be empty to give an expiry), expiry (optional, unix milliseconds)
 // Pre-Conditions: The user must have specified a SET_SELL_AMOUNT prior to
-//		setting a SET_SELL_TRIGGER
+//		setting a SET_SELL_TRIGGER, within the triggerserver's waiting timeout
 // Post-Conditions:
 // 		(a) a reserve account is created for the specified amount of the
 //			given stock
@@ -478,204 +538,511 @@ func (ts TransactionServer) SetSellAmount(transNum int, params ...string) string
 //			of stocks that could be purchased and
 // 		(c) the set of the user's sell triggers is updated to include the
 //			specified trigger.
-func (ts TransactionServer) SetSellTrigger(transNum int, params ...string) string {
+func (ts TransactionServer) SetSellTrigger(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	price, err := decimal.NewFromString(params[2])
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_TRIGGER", user, "Could not parse set sell trigger price to decimal",
-			stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "SET_SELL_TRIGGER", user, socketserver.CodeBadRequest,
+			"Could not parse set sell trigger price to decimal", stock, nil, nil)
 	}
-
-	trig, err := ts.TriggerClient.StartNewSellTrigger(transNum, user, stock, price)
+	expires, err := triggerExpiry(params, 4)
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_TRIGGER", user, "No existing sell trigger for this user and stock",
+		return ts.reportError(transNum, "SET_SELL_TRIGGER", user, socketserver.CodeBadRequest, err.Error(),
 			stock, nil, price.String())
-		return "-1"
 	}
 
-	err = ts.UserDatabase.RemoveStock(user, stock, trig.GetAmount().IntPart())
+	trig, err := ts.TriggerClient.StartNewSellTrigger(transNum, user, stock, price, triggerID(params, 3), expires)
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_TRIGGER", user, "Could not remove stock from database: "+err.Error(),
-			stock, nil, price.String())
-		return "-1"
+		return ts.reportError(transNum, "SET_SELL_TRIGGER", user, socketserver.CodeNoTrigger,
+			"No existing sell trigger for this user and stock", stock, nil, price.String())
 	}
 
-	err = ts.UserDatabase.AddReserveStock(user, stock, trig.GetAmount().IntPart())
+	err = ts.UserDatabase.WithTransaction(transNum, "SET_SELL_TRIGGER").ReserveSellTrigger(user, stock, trig.GetID(),
+		trig.GetAmount().IntPart())
 	if err != nil {
-		ts.reportError(transNum, "SET_SELL_TRIGGER", user, "Could not add stock to reserve: "+err.Error(),
-			stock, nil, price.String())
-		return "-1"
+		result := ts.reportError(transNum, "SET_SELL_TRIGGER", user, errorCode(err),
+			"Could not move stock to reserve: "+err.Error(), stock, nil, price.String())
+		// The trigger is already running; stop it so it can't sell shares that were never reserved
+		_, err = ts.TriggerClient.CancelSellTrigger(transNum, user, stock, trig.GetID())
+		if err != nil {
+			ts.reportError(transNum, "SET_SELL_TRIGGER", user, socketserver.CodeTriggerUnavailable,
+				"Could not cancel unreserved sell trigger: "+err.Error(), stock, nil, price.String())
+		}
+		return result
 	}
 
 	go ts.Logger.SystemEvent(ts.Name, transNum, "SET_SELL_TRIGGER", user, stock, nil, price)
-	return "1"
+	return socketserver.OK(nil)
 
 }
 
 // CancelSetSell cancels the SET_SELL associated with the given stock and user
+// Params: user, stock, trigger ID (optional, defaults to the latest sell trigger)
 // Pre-Conditions: The user must have had a previously set SET_SELL for the given stock
 // Post-Conditions:
 // 		(a) The set of the user's sell triggers is updated to remove the sell trigger associated with the specified stock
 // 		(b) all user account information is reset to the values they would have been if the given SET_SELL command had not been issued
-func (ts TransactionServer) CancelSetSell(transNum int, params ...string) string {
+func (ts TransactionServer) CancelSetSell(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 
-	trig, err := ts.TriggerClient.CancelSellTrigger(transNum, user, stock)
+	trig, err := ts.TriggerClient.CancelSellTrigger(transNum, user, stock, triggerID(params, 2))
 	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_SELL", user, "No existing sell trigger for this user and stock",
-			stock, nil, nil)
-		return "-1"
-	}
-
-	reserved, err := ts.UserDatabase.GetReserveStock(user, stock)
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_SELL", user, "Error getting reserved stock from database: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
-	}
-
-	if reserved < trig.GetAmount().IntPart() {
-		ts.reportError(transNum, "CANCEL_SET_SELL", user, "Should not have less that a trigger amount in your reserve account",
-			stock, nil, nil)
-		return "-1"
-	}
-
-	err = ts.UserDatabase.RemoveReserveStock(user, stock, trig.GetAmount().IntPart())
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_SELL", user, "Error removing reserved stock from database: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
+		return ts.reportError(transNum, "CANCEL_SET_SELL", user, socketserver.CodeNoTrigger,
+			"No existing sell trigger for this user and stock", stock, nil, nil)
 	}
 
-	err = ts.UserDatabase.AddStock(user, stock, trig.GetAmount().IntPart())
-	if err != nil {
-		ts.reportError(transNum, "CANCEL_SET_SELL", user, "Error adding stock to database: "+err.Error(),
-			stock, nil, nil)
-		return "-1"
+	// A sell trigger that was never started has no shares in reserve
+	_, err = ts.UserDatabase.WithTransaction(transNum, "CANCEL_SET_SELL").ReleaseSellTrigger(user, stock, trig.GetID())
+	if err == database.ErrNoTrigger {
+		return socketserver.OK(nil)
+	} else if err == database.ErrInsufficientReserve {
+		return ts.reportError(transNum, "CANCEL_SET_SELL", user, socketserver.CodeInsufficientReserve,
+			"Should not have less that a trigger amount in your reserve account", stock, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "CANCEL_SET_SELL", user, errorCode(err),
+			"Error moving stock out of reserve: "+err.Error(), stock, nil, nil)
 	}
 
-	return "1"
+	return socketserver.OK(nil)
 }
 
 // TriggerSuccess listens for incoming successfully executed triggers from the
 // triggerserver.
-// Params: TRIGGER_SUCCESS,<user>,<stock>,<price>,<amount>,<action>
-// t.username, t.stockname, t.price, t.amount, t.action
+// Params: TRIGGER_SUCCESS,<user>,<stock>,<price>,<amount>,<action>,<triggerID>
+// t.username, t.stockname, t.price, t.amount, t.action, t.id
 // Once a successfully completed trigger is received, complete the transaction
-// from a user's reserve account to their main account.
-func (ts TransactionServer) TriggerSuccess(transNum int, params ...string) string {
+// from a user's reserve account to their main account. What is settled is the
+// amount recorded when the reserve was taken, not the amount sent, and a trigger
+// with no record is acknowledged without settling anything. A limit order's action is
+// LIMIT_BUY or LIMIT_SELL, its amount is in shares and its trigger ID is the order ID.
+// Stop orders are the same, with actions such as STOP_SELL or TRAILING_STOP_BUY.
+// The legs of an order group fire as limit and stop orders, with the group's ID
+// followed by the leg as their trigger ID, such as "<id>.TP".
+// The triggerserver resends a trigger until it succeeds, so a trigger ID that
+// has already executed is acknowledged without being applied again.
+func (ts TransactionServer) TriggerSuccess(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	stock := params[1]
 	price := params[2]
 	amount := params[3]
 	action := params[4]
+	triggerID := params[5]
+	if triggerID == "" {
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeBadRequest,
+			"Trigger success has no trigger ID", stock, nil, nil)
+	}
 	amountDec, err := decimal.NewFromString(amount)
 	if err != nil {
-		return "-1"
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeBadRequest,
+			"Could not parse trigger amount to decimal", stock, nil, nil)
 	}
 	priceDec, err := decimal.NewFromString(price)
+	if err != nil {
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeBadRequest,
+			"Could not parse trigger price to decimal", stock, nil, nil)
+	}
+	if !priceDec.Truncate(2).GreaterThan(decimal.Zero) {
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeBadRequest,
+			"Trigger price must be at least a cent", stock, nil, nil)
+	}
 	if action == "BUY" {
-		err = ts.buyExecute(user, stock, amountDec, priceDec)
-		if err != nil {
-			return "-1"
-		}
-		return "1"
+		amountDec, err = ts.buyExecute(transNum, user, stock, priceDec, triggerID)
 	} else if action == "SELL" {
-		err = ts.sellExecute(user, stock, amountDec, priceDec)
-		if err != nil {
-			return "-1"
-		}
-		return "1"
+		amountDec, err = ts.sellExecute(transNum, user, stock, priceDec, triggerID)
+	} else if triggerclient.IsOrder(action) {
+		amountDec, err = ts.limitExecute(transNum, user, priceDec, triggerID)
+	} else {
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeBadRequest,
+			"Trigger action must be BUY, SELL or a limit or stop order's action", stock, nil, nil)
+	}
+
+	if err == database.ErrTriggerProcessed {
+		go ts.Logger.SystemEvent(ts.Name, transNum, "TRIGGER_SUCCESS", user, stock, nil, nil)
+		return socketserver.OK(nil)
+	} else if err == database.ErrNoLimitOrder {
+		// The order was cancelled or expired as it fired and its reserve is already
+		// returned, so there is nothing to fill and no point in the trigger resending
+		ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeNoLimitOrder,
+			"Limit order fired after it was released", stock, nil, nil)
+		return socketserver.OK(nil)
+	} else if err == database.ErrNoTrigger {
+		// Likewise for a trigger released or expired as it fired
+		ts.reportError(transNum, "TRIGGER_SUCCESS", user, socketserver.CodeNoTrigger,
+			"Trigger fired after it was released", stock, nil, nil)
+		return socketserver.OK(nil)
+	} else if err != nil {
+		// The triggerserver resends a DATABASE_UNAVAILABLE fill rather than giving it up
+		return ts.reportError(transNum, "TRIGGER_SUCCESS", user, errorCode(err),
+			"Error executing trigger: "+err.Error(), stock, nil, nil)
 	}
-	return "-1"
+	ts.publishFill(TriggerFill{
+		TransNum:  transNum,
+		TriggerID: triggerID,
+		User:      user,
+		Stock:     stock,
+		Action:    action,
+		Price:     priceDec,
+		Amount:    amountDec,
+		Time:      time.Now(),
+	})
+	return socketserver.OK(nil)
 }
 
-func (ts TransactionServer) reportError(transNum int, command string, user string, errorMsg string, stock interface{}, filename interface{}, funds interface{}) {
-	go ts.Logger.SystemError(ts.Name, transNum, command, user, stock, filename, funds,
-		errorMsg)
-	fmt.Println(errorMsg)
+// TriggerExpired returns the reserve of a trigger the triggerserver expired,
+// either because it passed the expiry it was started with or because it waited
+// too long for its SET_BUY_TRIGGER or SET_SELL_TRIGGER.
+// Params: TRIGGER_EXPIRED,<user>,<stock>,<action>,<triggerID>
+// The triggerserver resends an expiry until it succeeds, so a trigger whose
+// reserve has already been returned is acknowledged without doing anything,
+// as is a sell trigger that expired before it was started and so held nothing.
+func (ts TransactionServer) TriggerExpired(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	stock := params[1]
+	action := params[2]
+	id := params[3]
+	if id == "" {
+		return ts.reportError(transNum, "TRIGGER_EXPIRED", user, socketserver.CodeBadRequest,
+			"Trigger expiry has no trigger ID", stock, nil, nil)
+	}
+
+	db := ts.UserDatabase.WithTransaction(transNum, "TRIGGER_EXPIRED")
+	var err error
+	if action == "BUY" {
+		_, err = db.ReleaseBuyTrigger(user, stock, id)
+	} else if action == "SELL" {
+		_, err = db.ReleaseSellTrigger(user, stock, id)
+	} else {
+		return ts.reportError(transNum, "TRIGGER_EXPIRED", user, socketserver.CodeBadRequest,
+			"Trigger action must be BUY or SELL", stock, nil, nil)
+	}
+	if err == database.ErrNoTrigger {
+		return socketserver.OK(nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "TRIGGER_EXPIRED", user, errorCode(err),
+			"Error returning the trigger's reserve: "+err.Error(), stock, nil, nil)
+	}
+
+	go ts.Logger.SystemEvent(ts.Name, transNum, "TRIGGER_EXPIRED", user, stock, nil, nil)
+	ts.publishBalance(transNum, user)
+	return socketserver.OK(nil)
+}
+
+// triggerKey follows the triggerserver's [action][stock][user] indexing,
+// where each trigger and limit or stop order is told apart by its ID
+type triggerKey struct {
+	action, stock, user, id string
 }
 
-func (ts TransactionServer) sellExecute(user string, stock string, amount decimal.Decimal, price decimal.Decimal) error {
-	amountShares := amount.IntPart()
+func newTriggerKey(action string, stock string, user string, id string) triggerKey {
+	return triggerKey{action, stock, user, id}
+}
 
-	reserved, err := ts.UserDatabase.GetReserveStock(user, stock)
+// groupKey stands for every leg of an OCO group or bracket, whose reserve is
+// recorded once under the group's ID
+func groupKey(stock string, user string, id string) triggerKey {
+	return triggerKey{"GROUP", stock, user, id}
+}
+
+// ReconcileTriggers compares the trigger records in the database against the
+// triggers held by the triggerserver, which sends this once it has restored its
+// triggers after a restart.
+// Params: none
+// Post-conditions:
+// 		(a) reserves recorded for a trigger the triggerserver no longer has are
+//			returned to the user
+// 		(b) triggers holding a reserve with no record here are cancelled
+func (ts TransactionServer) ReconcileTriggers(transNum int, params ...string) socketserver.Result {
+	triggers, err := ts.TriggerClient.ListTriggers()
 	if err != nil {
-		return fmt.Errorf("error getting reserved stock from database:  %s", err.Error())
+		return ts.reportError(transNum, "RECONCILE_TRIGGERS", "", socketserver.CodeTriggerUnavailable,
+			"Could not list triggers: "+err.Error(), nil, nil, nil)
+	}
+	records, err := ts.UserDatabase.GetTriggerRecords()
+	if err != nil {
+		return ts.reportError(transNum, "RECONCILE_TRIGGERS", "", errorCode(err),
+			"Could not get trigger records: "+err.Error(), nil, nil, nil)
 	}
 
-	if reserved < amountShares {
-		return errors.New("reserved stock is less than trigger amount")
+	// A group is held while any of its legs is, and is firing while any of them is
+	held := make(map[triggerKey]triggerclient.Trigger)
+	for _, trig := range triggers {
+		key := newTriggerKey(trig.GetAction(), trig.GetStock(), trig.GetUsername(), trig.GetID())
+		if trig.GetGroup() != "" {
+			key = groupKey(trig.GetStock(), trig.GetUsername(), trig.GetGroup())
+			if current, ok := held[key]; ok && current.GetState() == triggerclient.StateFiring {
+				continue
+			}
+		}
+		held[key] = trig
 	}
 
-	err = ts.UserDatabase.RemoveReserveStock(user, stock, amountShares)
-	if err != nil {
-		return fmt.Errorf("error removing reserved stock from database:  %s", err.Error())
+	db := ts.UserDatabase.WithTransaction(transNum, "RECONCILE_TRIGGERS")
+	for _, record := range records {
+		key := newTriggerKey(record.Action, record.Stock, record.User, record.ID)
+		if triggerclient.IsOrderGroup(record.Action) {
+			key = groupKey(record.Stock, record.User, record.ID)
+		}
+		if _, ok := held[key]; ok {
+			delete(held, key)
+			continue
+		}
+		if record.Action == "BUY" {
+			_, err = db.ReleaseBuyTrigger(record.User, record.Stock, record.ID)
+		} else if record.Action == "SELL" {
+			_, err = db.ReleaseSellTrigger(record.User, record.Stock, record.ID)
+		} else {
+			_, err = db.ReleaseLimitOrder(record.User, record.ID)
+		}
+		if err != nil {
+			ts.reportError(transNum, "RECONCILE_TRIGGERS", record.User, errorCode(err),
+				"Could not release trigger reserve: "+err.Error(), record.Stock, nil, nil)
+			continue
+		}
+		go ts.Logger.SystemEvent(ts.Name, transNum, "RECONCILE_TRIGGERS", record.User, record.Stock, nil, nil)
 	}
 
-	err = ts.UserDatabase.AddFunds(user, amount.Mul(price))
-	if err != nil {
-		return fmt.Errorf("error adding difference between stock cost and reserved:  %s", err.Error())
+	// Sell triggers only reserve shares once they are started, and a firing
+	// trigger is already on its way to TRIGGER_SUCCESS
+	for key, trig := range held {
+		if trig.GetState() == triggerclient.StateFiring {
+			continue
+		} else if trig.GetGroup() != "" {
+			_, err = ts.TriggerClient.CancelOrderGroup(transNum, key.user, key.stock, key.id)
+		} else if triggerclient.IsOrder(key.action) {
+			_, err = ts.TriggerClient.CancelLimitOrder(transNum, key.user, key.stock, key.action, key.id)
+		} else if key.action == "BUY" {
+			_, err = ts.TriggerClient.CancelBuyTrigger(transNum, key.user, key.stock, key.id)
+		} else if trig.GetState() == triggerclient.StateRunning {
+			_, err = ts.TriggerClient.CancelSellTrigger(transNum, key.user, key.stock, key.id)
+		} else {
+			continue
+		}
+		if err != nil {
+			ts.reportError(transNum, "RECONCILE_TRIGGERS", key.user, socketserver.CodeTriggerUnavailable,
+				"Could not cancel unreserved trigger: "+err.Error(), key.stock, nil, nil)
+		}
 	}
-	return nil
+	return socketserver.OK(nil)
 }
 
-func (ts TransactionServer) buyExecute(user string, stock string, amount decimal.Decimal, price decimal.Decimal) error {
-	cost, shares, _ := ts.getMaxPurchase(user, stock, amount, price, nil)
+// ExpireOrders refunds pending BUY and SELL orders that were never committed,
+// and limit orders whose time in force has run out, checking once per interval
+func (ts TransactionServer) ExpireOrders(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		ts.expireOrders(now)
+	}
+}
 
-	reserved, err := ts.UserDatabase.GetReserveFunds(user)
+func (ts TransactionServer) expireOrders(now time.Time) {
+	defer ts.expireLimitOrders(now)
+	expired, err := ts.UserDatabase.ExpireOrders(now)
 	if err != nil {
-		return fmt.Errorf("error getting reserved funds from database:  %s", err.Error())
+		fmt.Println("Error expiring pending orders: ", err.Error())
 	}
 
-	if reserved.LessThan(amount) {
-		return errors.New("should not have less than the trigger amount in your reserve account")
+	// Expiry isn't part of any user transaction, so it is audited under transaction 0
+	for _, order := range expired {
+		if order.Type == "Buy" {
+			go ts.Logger.SystemEvent(ts.Name, 0, "CANCEL_BUY", order.User, order.Stock, nil, order.Cost)
+			go ts.Logger.AccountTransaction(ts.Name, 0, "add", order.User, order.Cost)
+			ts.publishOrder(0, order.User, "BUY", OrderExpired, order.Stock, order.Cost, order.Shares, time.Time{})
+			ts.publishBalance(0, order.User)
+		} else {
+			go ts.Logger.SystemEvent(ts.Name, 0, "CANCEL_SELL", order.User, order.Stock, nil, order.Cost)
+			ts.publishOrder(0, order.User, "SELL", OrderExpired, order.Stock, order.Cost, order.Shares, time.Time{})
+		}
 	}
+}
 
-	err = ts.UserDatabase.RemoveReserveFunds(user, amount)
-	if err != nil {
-		return fmt.Errorf("error removing reserved funds: %s", err.Error())
+// reportError audits a failed command and returns the failed Result to send back,
+// code being one of the socketserver error codes
+func (ts TransactionServer) reportError(transNum int, command string, user string, code string, errorMsg string,
+	stock interface{}, filename interface{}, funds interface{}) socketserver.Result {
+	go ts.Logger.SystemError(ts.Name, transNum, command, user, stock, filename, funds,
+		errorMsg)
+	fmt.Println(errorMsg)
+	return socketserver.Error(code, errorMsg)
+}
+
+// errorCode maps an error from the database to the error code sent to clients.
+// Any error the database doesn't define comes from redis itself, such as a lost
+// connection or ErrTimeout, so the command may succeed if it is sent again.
+func errorCode(err error) string {
+	switch err {
+	case database.ErrInsufficientFunds:
+		return socketserver.CodeInsufficientFunds
+	case database.ErrInsufficientStock:
+		return socketserver.CodeInsufficientStock
+	case database.ErrInsufficientReserve:
+		return socketserver.CodeInsufficientReserve
+	case database.ErrOrderExpired:
+		return socketserver.CodeOrderExpired
+	case database.ErrNoTrigger:
+		return socketserver.CodeNoTrigger
+	case database.ErrTriggerExists:
+		return socketserver.CodeTriggerExists
+	case database.ErrAccountExists:
+		return socketserver.CodeAccountExists
+	case database.ErrNoLimitOrder:
+		return socketserver.CodeNoLimitOrder
 	}
+	return socketserver.CodeDatabaseUnavailable
+}
 
-	// Price was lower than the buy trigger
-	if amount.GreaterThan(cost) {
-		err = ts.UserDatabase.AddFunds(user, amount.Sub(cost))
-		if err != nil {
-			return fmt.Errorf("error adding difference between stock cost and reserve amount: %s", err.Error())
-		}
+// sellExecute settles a fired sell trigger, returning the shares it sold
+func (ts TransactionServer) sellExecute(transNum int, user string, stock string, price decimal.Decimal,
+	triggerID string) (decimal.Decimal, error) {
+	shares, err := ts.UserDatabase.WithTransaction(transNum, "TRIGGER_SUCCESS").ExecuteSellTrigger(user, stock,
+		triggerID, price)
+	if err != nil {
+		return decimal.Zero, err
 	}
+	return decimal.New(shares, 0), nil
+}
 
-	err = ts.UserDatabase.AddStock(user, stock, shares)
+// buyExecute settles a fired buy trigger, returning the funds it had reserved.
+// Any difference between the reserve and the cost is refunded when the price
+// was lower than the buy trigger.
+func (ts TransactionServer) buyExecute(transNum int, user string, stock string, price decimal.Decimal,
+	triggerID string) (decimal.Decimal, error) {
+	reserved, _, err := ts.UserDatabase.WithTransaction(transNum, "TRIGGER_SUCCESS").ExecuteBuyTrigger(user, stock,
+		triggerID, price)
 	if err != nil {
-		return fmt.Errorf("error adding stock to database: %s", err.Error())
+		return decimal.Zero, err
 	}
-	return nil
+	return reserved, nil
 }
 
 // DumpLogUser Print out the history of the users transactions
 // to the user specified file
-func (ts TransactionServer) DumpLogUser(transNum int, params ...string) string {
+// Params: [user,] filename. Without a user every transaction is dumped.
+func (ts TransactionServer) DumpLogUser(transNum int, params ...string) socketserver.Result {
+	if len(params) == 1 {
+		go ts.Logger.DumpLog(params[0], nil)
+		return socketserver.OK(nil)
+	}
 	user := params[0]
 	filename := params[1]
 	go ts.Logger.DumpLog(filename, user)
-	return "1"
+	return socketserver.OK(nil)
 }
 
 // DisplaySummary provides a summary to the client of the given user's
 // transaction history and the current status of their accounts as well
 // as any set buy or sell triggers and their parameters.
-func (ts TransactionServer) DisplaySummary(transNum int, params ...string) string {
+func (ts TransactionServer) DisplaySummary(transNum int, params ...string) socketserver.Result {
 	user := params[0]
 	info, err := ts.UserDatabase.GetUserInfo(user)
 	if err != nil {
-		ts.reportError(transNum, "DISPLAY_SUMMARY", user,
+		return ts.reportError(transNum, "DISPLAY_SUMMARY", user, errorCode(err),
 			fmt.Sprintf("Error getting user information from database:  %s", err.Error()), nil, nil, nil)
-		return "-1"
 	}
-	return info
+	return socketserver.OK(info)
+}
+
+// Account returns the same information as DisplaySummary as a database.Account payload
+// Params: user
+func (ts TransactionServer) Account(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	account, err := ts.UserDatabase.GetAccount(user)
+	if err != nil {
+		return ts.reportError(transNum, "ACCOUNT", user, errorCode(err),
+			fmt.Sprintf("Error getting user account from database:  %s", err.Error()), nil, nil, nil)
+	}
+	return socketserver.OK(account)
+}
+
+// History returns a page of the changes made to the user's funds and stocks,
+// most recent first. The payload is a list of database.HistoryEntry.
+// Params: user, page (optional, defaults to the first page)
+func (ts TransactionServer) History(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	page := 0
+	if len(params) > 1 {
+		var err error
+		page, err = strconv.Atoi(params[1])
+		if err != nil || page < 0 {
+			return ts.reportError(transNum, "HISTORY", user, socketserver.CodeBadRequest,
+				"Could not parse history page", nil, nil, nil)
+		}
+	}
+
+	history, err := ts.UserDatabase.GetHistory(user, page)
+	if err != nil {
+		return ts.reportError(transNum, "HISTORY", user, errorCode(err),
+			fmt.Sprintf("Error getting user history from database:  %s", err.Error()), nil, nil, nil)
+	}
+
+	return socketserver.OK(history)
+}
+
+// Register creates credentials for a new user. Only a salted hash of the password is stored.
+// Admin names are refused, see createAdmins.
+// Params: user, password
+func (ts TransactionServer) Register(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	if ts.Admins[user] {
+		return ts.reportError(transNum, "REGISTER", user, socketserver.CodeAccountExists,
+			"Admin accounts can't be registered", nil, nil, nil)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(params[1]), bcrypt.DefaultCost)
+	if err != nil {
+		return ts.reportError(transNum, "REGISTER", user, socketserver.CodeBadRequest,
+			"Could not hash password: "+err.Error(), nil, nil, nil)
+	}
+	err = ts.UserDatabase.CreateCredentials(user, string(hash))
+	if err != nil {
+		return ts.reportError(transNum, "REGISTER", user, errorCode(err),
+			"Could not register user: "+err.Error(), nil, nil, nil)
+	}
+	go ts.Logger.SystemEvent(ts.Name, transNum, "REGISTER", user, nil, nil, nil)
+	return socketserver.OK(nil)
+}
+
+// createAdmins registers every admin with password, unless they are already
+// registered. It's the only way to create an admin account, since anyone could
+// register an admin's name before them otherwise. Without a password no admin
+// accounts are created.
+func (ts TransactionServer) createAdmins(password string) error {
+	if password == "" {
+		fmt.Println("No adminpassword set, not creating admin accounts")
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	for admin := range ts.Admins {
+		err := ts.UserDatabase.CreateCredentials(admin, string(hash))
+		if err != nil && err != database.ErrAccountExists {
+			return fmt.Errorf("could not create admin %s: %s", admin, err)
+		}
+	}
+	return nil
+}
+
+// Authenticate checks a user's password against their registered credentials.
+// Unknown users and wrong passwords fail with the same code.
+// Params: user, password
+func (ts TransactionServer) Authenticate(transNum int, params ...string) socketserver.Result {
+	user := params[0]
+	hash, err := ts.UserDatabase.GetCredentials(user)
+	if err == database.ErrNoAccount {
+		return ts.reportError(transNum, "AUTHENTICATE", user, socketserver.CodeBadCredentials,
+			"Unknown user or wrong password", nil, nil, nil)
+	} else if err != nil {
+		return ts.reportError(transNum, "AUTHENTICATE", user, errorCode(err),
+			"Could not get credentials: "+err.Error(), nil, nil, nil)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(params[1])) != nil {
+		return ts.reportError(transNum, "AUTHENTICATE", user, socketserver.CodeBadCredentials,
+			"Unknown user or wrong password", nil, nil, nil)
+	}
+	return socketserver.OK(nil)
 }
 
 // Work with whole numbers for now
@@ -688,12 +1055,15 @@ func (ts TransactionServer) getMaxPurchase(user string, stock string, availableF
 	if stockPrice != nil {
 		price = stockPrice.(decimal.Decimal)
 	} else {
-		resp, err := quoteclient.Query(user, stock, transNum.(int))
+		resp, err := ts.QuoteClient.Query(user, stock, transNum.(int))
 		if err != nil {
 			return decimal.Decimal{}, 0, err
 		}
 		price = resp
 	}
+	if !price.GreaterThan(decimal.Zero) {
+		return decimal.Decimal{}, 0, fmt.Errorf("cannot buy at a price of %s", price.String())
+	}
 	shares := availableFunds.Div(price).IntPart()
 	money := price.Mul(decimal.New(shares, 0))
 	return money.Round(2), shares, nil
@@ -0,0 +1,807 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"seng468/transaction-server/database"
+	"seng468/transaction-server/socketserver"
+	"seng468/transaction-server/trigger"
+
+	"github.com/shopspring/decimal"
+)
+
+func NewMockTransactionServer() (TransactionServer, *MockQuoteClient) {
+	mockQuote := NewMockQuoteClient()
+	return TransactionServer{
+		Name:          "mock_transaction_serve",
+		Addr:          "mock_addr",
+		Logger:        MockLogger{},
+		UserDatabase:  database.NewMemoryDatabase(),
+		QuoteClient:   mockQuote,
+		TriggerClient: NewMockTriggerClient(),
+	}, mockQuote
+}
+
+func expectFunds(t *testing.T, ts TransactionServer, user string, expected float64) {
+	actual, _ := ts.UserDatabase.GetFunds(user)
+	if !actual.Equal(decimal.NewFromFloat(expected)) {
+		t.Errorf("%s should have %.2f funds, has %s", user, expected, actual)
+	}
+}
+
+func expectStock(t *testing.T, ts TransactionServer, user string, stock string, expected int64) {
+	actual, _ := ts.UserDatabase.GetStock(user, stock)
+	if actual != expected {
+		t.Errorf("%s should have %d shares of %s, has %d", user, expected, stock, actual)
+	}
+}
+
+// expectResult checks for success with "1", failure with "-1", or else success with expected as the payload
+func expectResult(t *testing.T, command string, actual socketserver.Result, expected string) {
+	switch expected {
+	case "1":
+		if !actual.Succeeded() {
+			t.Errorf("%s returned %s, expected success", command, actual)
+		}
+	case "-1":
+		if actual.Succeeded() {
+			t.Errorf("%s returned %s, expected failure", command, actual)
+		}
+	default:
+		if !actual.Succeeded() || actual.Payload != expected {
+			t.Errorf("%s returned %s, expected %s", command, actual, expected)
+		}
+	}
+}
+
+func expectError(t *testing.T, command string, actual socketserver.Result, code string) {
+	if actual.Succeeded() || actual.Code != code {
+		t.Errorf("%s returned %s, expected %s", command, actual, code)
+	}
+}
+
+// expectID checks for success with an order or trigger ID as the payload and returns it
+func expectID(t *testing.T, command string, result socketserver.Result) string {
+	t.Helper()
+	id, ok := result.Payload.(string)
+	if !result.Succeeded() || !ok || id == "" {
+		t.Fatalf("%s returned %s, expected an ID", command, result)
+	}
+	return id
+}
+
+func TestTransactionServer_Add(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	expectResult(t, "ADD", ts.Add(1, "user1", "50.00"), "1")
+	expectFunds(t, ts, "user1", 50.00)
+	expectError(t, "ADD", ts.Add(2, "user1", "fifty"), socketserver.CodeBadRequest)
+}
+
+func TestTransactionServer_Quote(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(12.5))
+	expectResult(t, "QUOTE", ts.Quote(1, "user1", "ABC"), "12.50")
+	expectError(t, "QUOTE", ts.Quote(2, "user1", "XYZ"), socketserver.CodeQuoteUnavailable)
+}
+
+func TestTransactionServer_Buy(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(15.00))
+	ts.Add(1, "user1", "100.00")
+
+	// 50 dollars buys 3 shares at 15
+	expectResult(t, "BUY", ts.Buy(2, "user1", "ABC", "50.00"), "1")
+	expectFunds(t, ts, "user1", 55.00)
+	expectError(t, "BUY", ts.Buy(3, "user1", "ABC", "60.00"), socketserver.CodeInsufficientFunds)
+	expectFunds(t, ts, "user1", 55.00)
+
+	expectResult(t, "COMMIT_BUY", ts.CommitBuy(4, "user1"), "1")
+	expectStock(t, ts, "user1", "ABC", 3)
+	expectError(t, "COMMIT_BUY", ts.CommitBuy(5, "user1"), socketserver.CodeNoPendingBuy)
+
+	expectResult(t, "BUY", ts.Buy(6, "user1", "ABC", "30.00"), "1")
+	expectFunds(t, ts, "user1", 25.00)
+	expectResult(t, "CANCEL_BUY", ts.CancelBuy(7, "user1"), "1")
+	expectFunds(t, ts, "user1", 55.00)
+	expectStock(t, ts, "user1", "ABC", 3)
+	expectError(t, "CANCEL_BUY", ts.CancelBuy(8, "user1"), socketserver.CodeNoPendingBuy)
+}
+
+func TestTransactionServer_Sell(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.UserDatabase.AddStock("user1", "ABC", 5)
+
+	expectError(t, "SELL", ts.Sell(1, "user1", "ABC", "60.00"), socketserver.CodeInsufficientStock)
+	expectResult(t, "SELL", ts.Sell(2, "user1", "ABC", "30.00"), "1")
+	expectStock(t, ts, "user1", "ABC", 2)
+
+	expectResult(t, "COMMIT_SELL", ts.CommitSell(3, "user1"), "1")
+	expectFunds(t, ts, "user1", 30.00)
+	expectError(t, "COMMIT_SELL", ts.CommitSell(4, "user1"), socketserver.CodeNoPendingSell)
+
+	expectResult(t, "SELL", ts.Sell(5, "user1", "ABC", "20.00"), "1")
+	expectStock(t, ts, "user1", "ABC", 0)
+	expectResult(t, "CANCEL_SELL", ts.CancelSell(6, "user1"), "1")
+	expectStock(t, ts, "user1", "ABC", 2)
+	expectFunds(t, ts, "user1", 30.00)
+}
+
+func TestTransactionServer_BuyTrigger(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Add(1, "user1", "100.00")
+
+	expectError(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(2, "user1", "ABC", "150.00"), socketserver.CodeInsufficientFunds)
+	id := expectID(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(3, "user1", "ABC", "50.00"))
+	expectFunds(t, ts, "user1", 50.00)
+	expectResult(t, "SET_BUY_TRIGGER", ts.SetBuyTrigger(4, "user1", "ABC", "20.00"), "1")
+
+	// A price of zero can't be settled and leaves the reserve alone
+	expectError(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(5, "user1", "ABC", "0", "50.00", "BUY", id), socketserver.CodeBadRequest)
+	expectError(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(5, "user1", "ABC", "0.001", "50.00", "BUY", id), socketserver.CodeBadRequest)
+	expectFunds(t, ts, "user1", 50.00)
+
+	// A trigger without a record is acknowledged but settles nothing
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(5, "user1", "ABC", "12.00", "50.00", "BUY", "unknown"), "1")
+	expectStock(t, ts, "user1", "ABC", 0)
+
+	// Fires at 12.00 with the 50 dollars recorded, not the amount sent: 4 shares
+	// cost 48 and the remaining 2 dollars are refunded
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(5, "user1", "ABC", "12.00", "500.00", "BUY", id), "1")
+	expectStock(t, ts, "user1", "ABC", 4)
+	expectFunds(t, ts, "user1", 52.00)
+
+	// Redelivery of the same trigger is acknowledged but not applied again
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(5, "user1", "ABC", "12.00", "50.00", "BUY", id), "1")
+	expectStock(t, ts, "user1", "ABC", 4)
+	expectFunds(t, ts, "user1", 52.00)
+	reserved, _ := ts.UserDatabase.GetReserveFunds("user1")
+	if !reserved.Equal(decimal.Zero) {
+		t.Error("Reserve should be empty after the trigger fires, has ", reserved)
+	}
+
+	expectResult(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(6, "user1", "XYZ", "25.00"), "1")
+	expectFunds(t, ts, "user1", 27.00)
+	expectResult(t, "CANCEL_SET_BUY", ts.CancelSetBuy(7, "user1", "XYZ"), "1")
+	expectFunds(t, ts, "user1", 52.00)
+	expectResult(t, "CANCEL_SET_BUY", ts.CancelSetBuy(8, "user1", "XYZ"), "-1")
+}
+
+func TestTransactionServer_SellTrigger(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+
+	expectResult(t, "SET_SELL_AMOUNT", ts.SetSellAmount(1, "user1", "ABC", "11"), "-1")
+	id := expectID(t, "SET_SELL_AMOUNT", ts.SetSellAmount(2, "user1", "ABC", "4"))
+	expectResult(t, "SET_SELL_TRIGGER", ts.SetSellTrigger(3, "user1", "ABC", "30.00"), "1")
+	expectStock(t, ts, "user1", "ABC", 6)
+
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(4, "user1", "ABC", "31.00", "4", "SELL", "unknown"), "1")
+	expectFunds(t, ts, "user1", 0)
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(4, "user1", "ABC", "31.00", "10", "SELL", id), "1")
+	expectFunds(t, ts, "user1", 124.00)
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(4, "user1", "ABC", "31.00", "4", "SELL", id), "1")
+	expectFunds(t, ts, "user1", 124.00)
+	reserved, _ := ts.UserDatabase.GetReserveStock("user1", "ABC")
+	if reserved != 0 {
+		t.Error("Reserved stock should be empty after the trigger fires, has ", reserved)
+	}
+
+	expectResult(t, "SET_SELL_AMOUNT", ts.SetSellAmount(5, "user1", "ABC", "6"), "1")
+	expectResult(t, "SET_SELL_TRIGGER", ts.SetSellTrigger(6, "user1", "ABC", "40.00"), "1")
+	expectStock(t, ts, "user1", "ABC", 0)
+	expectResult(t, "CANCEL_SET_SELL", ts.CancelSetSell(7, "user1", "ABC"), "1")
+	expectStock(t, ts, "user1", "ABC", 6)
+}
+
+func TestTransactionServer_TriggerExpiry(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	triggers := ts.TriggerClient.(*MockTriggerClient)
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+	millis := func(at time.Time) string {
+		return strconv.FormatInt(at.UnixNano()/int64(time.Millisecond), 10)
+	}
+
+	// A buy trigger started with an expiry has its reserve returned once it expires
+	buy := expectID(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(2, "user1", "ABC", "40.00"))
+	expectError(t, "SET_BUY_TRIGGER",
+		ts.SetBuyTrigger(3, "user1", "ABC", "10.00", buy, millis(time.Now().Add(-time.Minute))),
+		socketserver.CodeBadRequest)
+	expires := time.Now().Add(time.Hour)
+	expectResult(t, "SET_BUY_TRIGGER", ts.SetBuyTrigger(4, "user1", "ABC", "10.00", "", millis(expires)), "1")
+	if running, _ := triggers.ListTriggers(); len(running) != 1 ||
+		running[0].GetExpiry().Unix() != expires.Unix() {
+		t.Error("Expected the trigger to be started with its expiry, have ", running)
+	}
+	expectFunds(t, ts, "user1", 60.00)
+	expectResult(t, "TRIGGER_EXPIRED", ts.TriggerExpired(5, "user1", "ABC", "BUY", buy), "1")
+	expectFunds(t, ts, "user1", 100.00)
+	expectResult(t, "TRIGGER_EXPIRED", ts.TriggerExpired(5, "user1", "ABC", "BUY", buy), "1")
+	expectFunds(t, ts, "user1", 100.00)
+
+	// A sell trigger that expires waiting held no shares, one that expires running returns them
+	waiting := expectID(t, "SET_SELL_AMOUNT", ts.SetSellAmount(6, "user1", "ABC", "4"))
+	expectResult(t, "TRIGGER_EXPIRED", ts.TriggerExpired(7, "user1", "ABC", "SELL", waiting), "1")
+	expectStock(t, ts, "user1", "ABC", 10)
+	sell := expectID(t, "SET_SELL_AMOUNT", ts.SetSellAmount(8, "user1", "ABC", "4"))
+	expectResult(t, "SET_SELL_TRIGGER", ts.SetSellTrigger(9, "user1", "ABC", "30.00", sell, millis(expires)), "1")
+	expectStock(t, ts, "user1", "ABC", 6)
+	expectResult(t, "TRIGGER_EXPIRED", ts.TriggerExpired(10, "user1", "ABC", "SELL", sell), "1")
+	expectStock(t, ts, "user1", "ABC", 10)
+
+	expectError(t, "TRIGGER_EXPIRED", ts.TriggerExpired(11, "user1", "ABC", "LIMIT_BUY", sell),
+		socketserver.CodeBadRequest)
+}
+
+func TestTransactionServer_TriggerLadder(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+
+	// Two buy levels on one stock, each holding its own reserve
+	high := expectID(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(2, "user1", "ABC", "30.00"))
+	low := expectID(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(3, "user1", "ABC", "20.00"))
+	expectFunds(t, ts, "user1", 50.00)
+	expectResult(t, "SET_BUY_TRIGGER", ts.SetBuyTrigger(4, "user1", "ABC", "15.00", high), "1")
+	expectResult(t, "SET_BUY_TRIGGER", ts.SetBuyTrigger(5, "user1", "ABC", "10.00", low), "1")
+	expectError(t, "SET_BUY_TRIGGER", ts.SetBuyTrigger(6, "user1", "ABC", "10.00", low), socketserver.CodeNoTrigger)
+
+	// Cancelling one level leaves the other's reserve in place
+	expectResult(t, "CANCEL_SET_BUY", ts.CancelSetBuy(7, "user1", "ABC", high), "1")
+	expectFunds(t, ts, "user1", 80.00)
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(8, "user1", "ABC", "10.00", "20.00", "BUY", low), "1")
+	expectStock(t, ts, "user1", "ABC", 12)
+	expectFunds(t, ts, "user1", 80.00)
+	expectError(t, "CANCEL_SET_BUY", ts.CancelSetBuy(9, "user1", "ABC", low), socketserver.CodeNoTrigger)
+
+	// Without an ID the latest sell trigger is the one started and cancelled
+	first := expectID(t, "SET_SELL_AMOUNT", ts.SetSellAmount(10, "user1", "ABC", "4"))
+	expectID(t, "SET_SELL_AMOUNT", ts.SetSellAmount(11, "user1", "ABC", "6"))
+	expectResult(t, "SET_SELL_TRIGGER", ts.SetSellTrigger(12, "user1", "ABC", "40.00"), "1")
+	expectStock(t, ts, "user1", "ABC", 6)
+	expectResult(t, "SET_SELL_TRIGGER", ts.SetSellTrigger(13, "user1", "ABC", "30.00", first), "1")
+	expectStock(t, ts, "user1", "ABC", 2)
+	expectResult(t, "CANCEL_SET_SELL", ts.CancelSetSell(14, "user1", "ABC"), "1")
+	expectStock(t, ts, "user1", "ABC", 8)
+	reserved, _ := ts.UserDatabase.GetReserveStock("user1", "ABC")
+	if reserved != 4 {
+		t.Error("The first sell trigger should still reserve 4 shares, have ", reserved)
+	}
+}
+
+func TestTransactionServer_ReconcileTriggers(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+	ts.SetBuyAmount(2, "user1", "ABC", "40.00")
+	ts.SetBuyTrigger(3, "user1", "ABC", "10.00")
+	ts.SetSellAmount(4, "user1", "ABC", "4")
+	ts.SetSellTrigger(5, "user1", "ABC", "30.00")
+	ts.SetSellAmount(6, "user1", "XYZ", "1")
+
+	// Nothing to do while both sides agree
+	expectResult(t, "RECONCILE_TRIGGERS", ts.ReconcileTriggers(7), "1")
+	expectFunds(t, ts, "user1", 60.00)
+	expectStock(t, ts, "user1", "ABC", 6)
+
+	// The triggerserver restarted without its triggers and gained a buy trigger with no reserve
+	triggers := NewMockTriggerClient()
+	triggers.SetNewBuyTrigger(8, "t1", "user1", "XYZ", decimal.NewFromFloat(5.00))
+	ts.TriggerClient = triggers
+	expectResult(t, "RECONCILE_TRIGGERS", ts.ReconcileTriggers(9), "1")
+	expectFunds(t, ts, "user1", 100.00)
+	expectStock(t, ts, "user1", "ABC", 10)
+	if remaining, _ := triggers.ListTriggers(); len(remaining) != 0 {
+		t.Error("Unreserved buy trigger should be cancelled, have ", remaining)
+	}
+	if records, _ := ts.UserDatabase.GetTriggerRecords(); len(records) != 0 {
+		t.Error("Released trigger records should be removed, have ", records)
+	}
+}
+
+func TestTransactionServer_DisplaySummary(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Add(1, "user1", "10.00")
+	if !ts.DisplaySummary(2, "user1").Succeeded() {
+		t.Error("DISPLAY_SUMMARY failed for a known user")
+	}
+}
+
+func TestTransactionServer_Account(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(15.00))
+	ts.Add(1, "user1", "100.00")
+	ts.Buy(2, "user1", "ABC", "50.00")
+
+	account, ok := ts.Account(3, "user1").Payload.(database.Account)
+	if !ok || !account.Funds.Equal(decimal.NewFromFloat(55.00)) {
+		t.Fatal("Unexpected ACCOUNT payload ", account)
+	}
+	if len(account.BuyOrders) != 1 || account.BuyOrders[0].Shares != 3 || account.BuyOrders[0].Type != "Buy" {
+		t.Error("ACCOUNT should list the pending buy, got ", account.BuyOrders)
+	}
+
+	first, _ := ts.SetBuyAmount(4, "user1", "ABC", "10.00").Payload.(string)
+	second, _ := ts.SetBuyAmount(5, "user1", "ABC", "5.00").Payload.(string)
+	account, _ = ts.Account(6, "user1").Payload.(database.Account)
+	if len(account.Triggers) != 2 || !account.BuyTriggers["ABC"].Equal(decimal.NewFromFloat(15.00)) {
+		t.Fatal("ACCOUNT should list both buy triggers, got ", account.Triggers)
+	}
+	for _, trigger := range account.Triggers {
+		if (trigger.ID != first || !trigger.Funds.Equal(decimal.NewFromFloat(10.00))) &&
+			(trigger.ID != second || !trigger.Funds.Equal(decimal.NewFromFloat(5.00))) {
+			t.Error("Unexpected trigger ", trigger, ", set ", first, " and ", second)
+		}
+	}
+}
+
+func TestTransactionServer_History(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(15.00))
+	ts.Add(1, "user1", "100.00")
+	ts.Buy(2, "user1", "ABC", "50.00")
+	ts.CommitBuy(3, "user1")
+
+	history, _ := ts.UserDatabase.GetHistory("user1", 0)
+	if len(history) != 3 {
+		t.Fatal("Expected 3 history entries, got ", history)
+	}
+	commit, buy, add := history[0], history[1], history[2]
+	if add.TransNum != 1 || add.Command != "ADD" || !add.Funds.Equal(decimal.NewFromFloat(100.00)) {
+		t.Error("Unexpected ADD entry ", add)
+	}
+	if buy.TransNum != 2 || buy.Command != "BUY" || buy.Stock != "ABC" ||
+		!buy.Funds.Equal(decimal.NewFromFloat(-45.00)) || !buy.Price.Equal(decimal.NewFromFloat(15.00)) {
+		t.Error("Unexpected BUY entry ", buy)
+	}
+	if commit.TransNum != 3 || commit.Command != "COMMIT_BUY" || commit.Shares != 3 || !commit.Funds.Equal(decimal.Zero) {
+		t.Error("Unexpected COMMIT_BUY entry ", commit)
+	}
+
+	page, ok := ts.History(4, "user1").Payload.([]database.HistoryEntry)
+	if !ok || len(page) != 3 || page[0].TransNum != commit.TransNum {
+		t.Error("HISTORY should list the newest entry first, got ", page)
+	}
+	if page, _ = ts.History(5, "user1", "1").Payload.([]database.HistoryEntry); len(page) != 0 {
+		t.Error("The second page of HISTORY should be empty, got ", page)
+	}
+	expectError(t, "HISTORY", ts.History(6, "user1", "first"), socketserver.CodeBadRequest)
+	if summary, _ := ts.DisplaySummary(7, "user1").Payload.(string); !strings.Contains(summary, commit.String()) {
+		t.Error("DISPLAY_SUMMARY should include recent history")
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	if code := errorCode(database.ErrInsufficientReserve); code != socketserver.CodeInsufficientReserve {
+		t.Error("Expected INSUFFICIENT_RESERVE, got ", code)
+	}
+	// Failures of redis itself may succeed if the command is sent again
+	for _, err := range []error{database.ErrTimeout, errors.New("use of closed network connection")} {
+		if code := errorCode(err); code != socketserver.CodeDatabaseUnavailable {
+			t.Error("Expected DATABASE_UNAVAILABLE for ", err, ", got ", code)
+		}
+	}
+}
+
+func TestTransactionServer_Credentials(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	expectError(t, "AUTHENTICATE", ts.Authenticate(1, "user1", "hunter2"), socketserver.CodeBadCredentials)
+	expectResult(t, "REGISTER", ts.Register(2, "user1", "hunter2"), "1")
+	expectError(t, "REGISTER", ts.Register(3, "user1", "other"), socketserver.CodeAccountExists)
+
+	hash, _ := ts.UserDatabase.GetCredentials("user1")
+	if strings.Contains(hash, "hunter2") {
+		t.Error("The password should not be stored in the clear")
+	}
+	expectResult(t, "AUTHENTICATE", ts.Authenticate(4, "user1", "hunter2"), "1")
+	expectError(t, "AUTHENTICATE", ts.Authenticate(5, "user1", "other"), socketserver.CodeBadCredentials)
+
+	// Registering can't take over an account that was never registered
+	ts.Add(6, "user2", "100.00")
+	expectError(t, "REGISTER", ts.Register(7, "user2", "hunter2"), socketserver.CodeAccountExists)
+	expectError(t, "AUTHENTICATE", ts.Authenticate(8, "user2", "hunter2"), socketserver.CodeBadCredentials)
+}
+
+func TestTransactionServer_CreateAdmins(t *testing.T) {
+	ts, _ := NewMockTransactionServer()
+	ts.Admins = map[string]bool{"admin": true}
+	expectError(t, "REGISTER", ts.Register(1, "admin", "guess"), socketserver.CodeAccountExists)
+
+	if err := ts.createAdmins(""); err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, "AUTHENTICATE", ts.Authenticate(2, "admin", ""), socketserver.CodeBadCredentials)
+
+	if err := ts.createAdmins("secret"); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, "AUTHENTICATE", ts.Authenticate(3, "admin", "secret"), "1")
+	// Restarting with another password keeps the admin's first one
+	if err := ts.createAdmins("other"); err != nil {
+		t.Fatal(err)
+	}
+	expectResult(t, "AUTHENTICATE", ts.Authenticate(4, "admin", "secret"), "1")
+	expectError(t, "REGISTER", ts.Register(5, "admin", "guess"), socketserver.CodeAccountExists)
+}
+
+func TestTransactionServer_ExpireOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "XYZ", 5)
+	quotes.addRule("XYZ", decimal.NewFromFloat(5.00))
+
+	ts.Buy(2, "user1", "ABC", "40.00")
+	ts.Sell(3, "user1", "XYZ", "10.00")
+	expectFunds(t, ts, "user1", 60.00)
+	expectStock(t, ts, "user1", "XYZ", 3)
+
+	// Nothing has expired yet
+	ts.expireOrders(time.Now())
+	expectFunds(t, ts, "user1", 60.00)
+
+	ts.expireOrders(time.Now().Add(database.PendingOrderTimeout + time.Second))
+	expectFunds(t, ts, "user1", 100.00)
+	expectStock(t, ts, "user1", "XYZ", 5)
+	expectStock(t, ts, "user1", "ABC", 0)
+	expectResult(t, "COMMIT_BUY", ts.CommitBuy(4, "user1"), "-1")
+	expectResult(t, "COMMIT_SELL", ts.CommitSell(5, "user1"), "-1")
+}
+
+func TestTransactionServer_LimitOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	triggers := ts.TriggerClient.(*MockTriggerClient)
+	quotes.addRule("ABC", decimal.NewFromFloat(12.00))
+	ts.Add(1, "user1", "100.00")
+
+	// IOC orders fill right away at the quote or not at all
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(2, "user1", "ABC", "50.00", "10.00", "IOC"), socketserver.CodeLimitNotMet)
+	expectFunds(t, ts, "user1", 100.00)
+	expectID(t, "LIMIT_BUY", ts.LimitBuy(3, "user1", "ABC", "50.00", "12.50", "IOC"))
+	expectStock(t, ts, "user1", "ABC", 4)
+	expectFunds(t, ts, "user1", 52.00)
+
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(4, "user1", "ABC", "1.00", "5.00", "GTC"), socketserver.CodeBadRequest)
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(4, "user1", "ABC", "40.00", "10.00", "GTC", "1"), socketserver.CodeBadRequest)
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(4, "user1", "ABC", "40.00", "10.00", "FOK"), socketserver.CodeBadRequest)
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(4, "user1", "ABC", "40.00", "10.00", "GTD",
+		strconv.FormatInt(time.Now().Add(-time.Hour).UnixNano()/int64(time.Millisecond), 10)), socketserver.CodeBadRequest)
+
+	// A GTC buy holds its cost at the limit price until the triggerserver fires it
+	buy := expectID(t, "LIMIT_BUY", ts.LimitBuy(5, "user1", "ABC", "40.00", "10.00", "GTC"))
+	expectFunds(t, ts, "user1", 12.00)
+	if order, ok := triggers.limitOrder(buy); !ok || order.GetAction() != "LIMIT_BUY" || !order.GetAmount().Equal(decimal.New(4, 0)) {
+		t.Error("Expected a limit order for 4 shares with the triggerserver, have ", order)
+	}
+	orders := ts.ListLimitOrders(6, "user1").Payload.([]database.LimitOrder)
+	if len(orders) != 1 || orders[0].ID != buy || orders[0].Shares != 4 {
+		t.Error("Unexpected limit orders ", orders)
+	}
+
+	expectError(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(7, "user1", buy, "200.00", "10.00"),
+		socketserver.CodeInsufficientFunds)
+	expectResult(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(8, "user1", buy, "20.00", "5.00"), "1")
+	expectFunds(t, ts, "user1", 32.00)
+	if order, _ := triggers.limitOrder(buy); !order.GetPrice().Equal(decimal.NewFromFloat(5.00)) {
+		t.Error("The triggerserver's order should be amended, have ", order)
+	}
+	expectError(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(9, "user1", "missing", "20.00", "5.00"),
+		socketserver.CodeNoLimitOrder)
+
+	// Limit orders fill at the limit price, and redelivery isn't applied again
+	triggers.fire(buy)
+	expectError(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(10, "user1", buy, "25.00", "5.00"),
+		socketserver.CodeTriggerFired)
+	expectFunds(t, ts, "user1", 32.00)
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(11, "user1", "ABC", "5.00", "4", "LIMIT_BUY", buy), "1")
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(11, "user1", "ABC", "5.00", "4", "LIMIT_BUY", buy), "1")
+	expectStock(t, ts, "user1", "ABC", 8)
+	expectFunds(t, ts, "user1", 32.00)
+	if reserved, _ := ts.UserDatabase.GetReserveFunds("user1"); !reserved.Equal(decimal.Zero) {
+		t.Error("Reserve should be empty after the order fills, has ", reserved)
+	}
+
+	sell := expectID(t, "LIMIT_SELL", ts.LimitSell(12, "user1", "ABC", "8", "20.00", "GTC"))
+	expectStock(t, ts, "user1", "ABC", 0)
+	expectResult(t, "CANCEL_LIMIT_ORDER", ts.CancelLimitOrder(13, "user1", sell), "1")
+	expectStock(t, ts, "user1", "ABC", 8)
+	expectError(t, "CANCEL_LIMIT_ORDER", ts.CancelLimitOrder(14, "user1", sell), socketserver.CodeNoLimitOrder)
+	if _, ok := triggers.limitOrder(sell); ok {
+		t.Error("The cancelled order should be removed from the triggerserver")
+	}
+
+	// DAY and GTD orders are cancelled once they expire, unless they already fired
+	day := expectID(t, "LIMIT_SELL", ts.LimitSell(15, "user1", "ABC", "3", "30.00", "DAY"))
+	expiry := time.Now().Add(time.Hour)
+	gtd := expectID(t, "LIMIT_BUY", ts.LimitBuy(16, "user1", "ABC", "10.00", "5.00", "GTD",
+		strconv.FormatInt(expiry.UnixNano()/int64(time.Millisecond), 10)))
+	fired := expectID(t, "LIMIT_SELL", ts.LimitSell(17, "user1", "ABC", "2", "20.00", "DAY"))
+	triggers.fire(fired)
+	expectStock(t, ts, "user1", "ABC", 3)
+	expectFunds(t, ts, "user1", 22.00)
+
+	ts.expireOrders(time.Now())
+	expectStock(t, ts, "user1", "ABC", 3)
+	ts.expireOrders(expiry.Add(time.Minute))
+	expectFunds(t, ts, "user1", 32.00)
+	expectStock(t, ts, "user1", "ABC", 3)
+	ts.expireOrders(time.Now().Add(25 * time.Hour))
+	expectStock(t, ts, "user1", "ABC", 6)
+	for _, id := range []string{day, gtd} {
+		if _, ok := triggers.limitOrder(id); ok {
+			t.Error("Expired orders should be removed from the triggerserver, have ", id)
+		}
+	}
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(18, "user1", "ABC", "20.00", "2", "LIMIT_SELL", fired), "1")
+	expectFunds(t, ts, "user1", 72.00)
+
+	// An order released before its trigger fired is acknowledged without filling
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(19, "user1", "ABC", "30.00", "3", "LIMIT_SELL", day), "1")
+	expectFunds(t, ts, "user1", 72.00)
+
+	// The triggerserver restarted without its orders and gained one with no reserve
+	expectID(t, "LIMIT_BUY", ts.LimitBuy(20, "user1", "ABC", "20.00", "10.00", "GTC"))
+	expectFunds(t, ts, "user1", 52.00)
+	restarted := NewMockTriggerClient()
+	restarted.PlaceLimitOrder(21, triggerclient.NewLimitOrder(21, "stray", "user1", "ABC", 1, decimal.NewFromFloat(5.00),
+		triggerclient.ActionLimitBuy))
+	ts.TriggerClient = restarted
+	expectResult(t, "RECONCILE_TRIGGERS", ts.ReconcileTriggers(22), "1")
+	expectFunds(t, ts, "user1", 72.00)
+	if remaining, _ := restarted.ListTriggers(); len(remaining) != 0 {
+		t.Error("The unreserved limit order should be cancelled, have ", remaining)
+	}
+	if orders, _ := ts.UserDatabase.GetLimitOrders("user1"); len(orders) != 0 {
+		t.Error("Released limit orders should be removed, have ", orders)
+	}
+}
+
+func TestTransactionServer_ImmediateFillFails(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	ts.UserDatabase = failingFillDatabase{ts.UserDatabase}
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.Add(1, "user1", "100.00")
+
+	// An IOC order that can't fill hands its reserve straight back
+	expectError(t, "LIMIT_BUY", ts.LimitBuy(2, "user1", "ABC", "50.00", "10.00", "IOC"),
+		socketserver.CodeDatabaseUnavailable)
+	expectFunds(t, ts, "user1", 100.00)
+	if orders, _ := ts.UserDatabase.GetLimitOrders("user1"); len(orders) != 0 {
+		t.Error("The unfilled order should be released, have ", orders)
+	}
+}
+
+func TestTransactionServer_StopOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	triggers := ts.TriggerClient.(*MockTriggerClient)
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.Add(1, "user1", "100.00")
+	expectID(t, "LIMIT_BUY", ts.LimitBuy(2, "user1", "ABC", "50.00", "10.00", "IOC"))
+	expectStock(t, ts, "user1", "ABC", 5)
+
+	// A stop sell holds its shares until the price falls to the stop
+	expectError(t, "STOP_SELL", ts.StopSell(3, "user1", "ABC", "5", "8.00", "IOC"), socketserver.CodeBadRequest)
+	stop := expectID(t, "STOP_SELL", ts.StopSell(4, "user1", "ABC", "5", "8.00", "GTC"))
+	expectStock(t, ts, "user1", "ABC", 0)
+	if order, ok := triggers.limitOrder(stop); !ok || order.GetAction() != "STOP_SELL" {
+		t.Error("Expected a stop sell with the triggerserver, have ", order)
+	}
+	expectResult(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(5, "user1", stop, "3", "7.50"), "1")
+	expectStock(t, ts, "user1", "ABC", 2)
+	triggers.fire(stop)
+	expectResult(t, "TRIGGER_SUCCESS", ts.TriggerSuccess(6, "user1", "ABC", "7.50", "3", "STOP_SELL", stop), "1")
+	expectFunds(t, ts, "user1", 72.50)
+
+	stopBuy := expectID(t, "STOP_BUY", ts.StopBuy(7, "user1", "ABC", "24.00", "12.00", "GTC"))
+	expectFunds(t, ts, "user1", 48.50)
+	orders := ts.ListLimitOrders(8, "user1").Payload.([]database.LimitOrder)
+	if len(orders) != 1 || orders[0].Action() != "STOP_BUY" || orders[0].Shares != 2 {
+		t.Error("Unexpected stop orders ", orders)
+	}
+	expectResult(t, "CANCEL_LIMIT_ORDER", ts.CancelLimitOrder(9, "user1", stopBuy), "1")
+	expectFunds(t, ts, "user1", 72.50)
+
+	// Trailing stops start from the quote and can't be amended
+	expectError(t, "TRAILING_STOP_SELL", ts.TrailingStopSell(10, "user1", "ABC", "2", "0", "GTC"),
+		socketserver.CodeBadRequest)
+	expectError(t, "TRAILING_STOP_SELL", ts.TrailingStopSell(10, "user1", "ABC", "2", "100%", "GTC"),
+		socketserver.CodeBadRequest)
+	expectError(t, "TRAILING_STOP_SELL", ts.TrailingStopSell(10, "user1", "XYZ", "2", "10%", "GTC"),
+		socketserver.CodeQuoteUnavailable)
+	trailing := expectID(t, "TRAILING_STOP_SELL", ts.TrailingStopSell(11, "user1", "ABC", "2", "10%", "GTC"))
+	expectStock(t, ts, "user1", "ABC", 0)
+	if order, _ := triggers.limitOrder(trailing); !order.GetPrice().Equal(decimal.NewFromFloat(10.00)) ||
+		order.GetTrail() != "10%" {
+		t.Error("Expected a trailing stop from a mark of 10.00 with the triggerserver, have ", order)
+	}
+	expectError(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(12, "user1", trailing, "1", "9.00"),
+		socketserver.CodeBadRequest)
+	expectResult(t, "TRIGGER_SUCCESS",
+		ts.TriggerSuccess(13, "user1", "ABC", "9.00", "2", "TRAILING_STOP_SELL", trailing), "1")
+	expectFunds(t, ts, "user1", 90.50)
+
+	// A trailing buy holds its shares at the quote and fills what that pays for at the stop
+	trailingBuy := expectID(t, "TRAILING_STOP_BUY", ts.TrailingStopBuy(14, "user1", "ABC", "50.00", "1.00", "DAY"))
+	expectFunds(t, ts, "user1", 40.50)
+	expectResult(t, "TRIGGER_SUCCESS",
+		ts.TriggerSuccess(15, "user1", "ABC", "10.50", "5", "TRAILING_STOP_BUY", trailingBuy), "1")
+	expectStock(t, ts, "user1", "ABC", 4)
+	expectFunds(t, ts, "user1", 48.50)
+	if orders, _ := ts.UserDatabase.GetLimitOrders("user1"); len(orders) != 0 {
+		t.Error("Filled stop orders should be removed, have ", orders)
+	}
+}
+
+func TestTransactionServer_OrderGroups(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	triggers := ts.TriggerClient.(*MockTriggerClient)
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.Add(1, "user1", "100.00")
+	ts.UserDatabase.AddStock("user1", "ABC", 10)
+
+	// An OCO group reserves its shares once, for both exits
+	expectError(t, "OCO_SELL", ts.OCOSell(2, "user1", "ABC", "4", "8.00", "12.00", "GTC"),
+		socketserver.CodeBadRequest)
+	expectError(t, "OCO_SELL", ts.OCOSell(2, "user1", "ABC", "4", "12.00", "8.00", "IOC"),
+		socketserver.CodeBadRequest)
+	oco := expectID(t, "OCO_SELL", ts.OCOSell(3, "user1", "ABC", "4", "12.00", "8.00", "GTC"))
+	expectStock(t, ts, "user1", "ABC", 6)
+	if leg, ok := triggers.limitOrder(oco + ".SL"); !ok || leg.GetAction() != "STOP_SELL" || leg.GetGroup() != oco {
+		t.Error("Expected a stop-loss leg with the triggerserver, have ", leg)
+	}
+	expectError(t, "AMEND_LIMIT_ORDER", ts.AmendLimitOrder(4, "user1", oco, "2", "11.00"),
+		socketserver.CodeBadRequest)
+	expectResult(t, "RECONCILE_TRIGGERS", ts.ReconcileTriggers(5), "1")
+	expectStock(t, ts, "user1", "ABC", 6)
+
+	// The take-profit fills, and the stop-loss has nothing left to sell
+	triggers.fire(oco + ".TP")
+	expectError(t, "CANCEL_LIMIT_ORDER", ts.CancelLimitOrder(6, "user1", oco), socketserver.CodeTriggerFired)
+	expectResult(t, "TRIGGER_SUCCESS",
+		ts.TriggerSuccess(7, "user1", "ABC", "12.00", "4", "LIMIT_SELL", oco+".TP"), "1")
+	expectFunds(t, ts, "user1", 148.00)
+	expectResult(t, "TRIGGER_SUCCESS",
+		ts.TriggerSuccess(8, "user1", "ABC", "8.00", "4", "STOP_SELL", oco+".SL"), "1")
+	expectFunds(t, ts, "user1", 148.00)
+	expectStock(t, ts, "user1", "ABC", 6)
+
+	// A bracket holds the cost of its entry, then the shares the entry bought
+	expectError(t, "BRACKET_BUY", ts.BracketBuy(9, "user1", "ABC", "40.00", "13.00", "12.00", "8.00", "GTC"),
+		socketserver.CodeBadRequest)
+	bracket := expectID(t, "BRACKET_BUY", ts.BracketBuy(10, "user1", "ABC", "40.00", "10.00", "12.00", "8.00", "GTC"))
+	expectFunds(t, ts, "user1", 108.00)
+	expectResult(t, "TRIGGER_SUCCESS",
+		ts.TriggerSuccess(11, "user1", "ABC", "9.50", "4", "LIMIT_BUY", bracket+".ENTRY"), "1")
+	expectFunds(t, ts, "user1", 110.00)
+	expectStock(t, ts, "user1", "ABC", 6)
+	orders := ts.ListLimitOrders(12, "user1").Payload.([]database.LimitOrder)
+	if len(orders) != 1 || orders[0].Action() != "BRACKET_SELL" || orders[0].Shares != 4 {
+		t.Error("Expected the bracket to hold the shares for its exits, have ", orders)
+	}
+	expectResult(t, "CANCEL_LIMIT_ORDER", ts.CancelLimitOrder(13, "user1", bracket), "1")
+	expectStock(t, ts, "user1", "ABC", 10)
+	if leg, ok := triggers.limitOrder(bracket + ".TP"); ok {
+		t.Error("Cancelling the bracket should cancel its exits, have ", leg)
+	}
+
+	// A group the triggerserver holds with no reserve is cancelled
+	triggers.PlaceOrderGroup(14, triggerclient.OrderGroup{ID: "g1", Type: triggerclient.GroupOCO, User: "user1",
+		Stock: "ABC", Shares: 2, TakeProfit: decimal.NewFromFloat(12.00), StopLoss: decimal.NewFromFloat(8.00)})
+	expectResult(t, "RECONCILE_TRIGGERS", ts.ReconcileTriggers(15), "1")
+	if _, ok := triggers.limitOrder("g1.TP"); ok {
+		t.Error("Unreserved order group should be cancelled")
+	}
+}
+
+func TestTransactionServer_Events(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	events := newRecordingPublisher()
+	ts.Events = events
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+
+	ts.Add(1, "user1", "100.00")
+	if balance := events.next(t, EventBalance); balance.User != "user1" ||
+		!balance.Data.(balanceEvent).Funds.Equal(decimal.NewFromFloat(100.00)) {
+		t.Error("Unexpected balance event ", balance)
+	}
+
+	ts.Quote(2, "user1", "ABC")
+	if quote := events.next(t, EventQuote); quote.User != "" || quote.Data.(quoteEvent).Stock != "ABC" {
+		t.Error("Quotes should be published for anyone watching the stock, got ", quote)
+	}
+
+	ts.Buy(3, "user1", "ABC", "35.00")
+	pending := events.next(t, EventOrder).Data.(orderEvent)
+	if pending.State != OrderPending || pending.Side != "BUY" || pending.Shares != 3 || pending.Expires == 0 {
+		t.Error("Unexpected pending order event ", pending)
+	}
+	ts.CancelBuy(4, "user1")
+	if cancelled := events.next(t, EventOrder).Data.(orderEvent); cancelled.State != OrderCancelled ||
+		!cancelled.Cost.Equal(pending.Cost) {
+		t.Error("Unexpected cancelled order event ", cancelled)
+	}
+
+	ts.UserDatabase.AddStock("user1", "ABC", 2)
+	ts.Sell(5, "user1", "ABC", "10.00")
+	events.next(t, EventOrder)
+	ts.expireOrders(time.Now().Add(database.PendingOrderTimeout + time.Second))
+	if expired := events.next(t, EventOrder); expired.TransNum != 0 || expired.Data.(orderEvent).State != OrderExpired {
+		t.Error("Unexpected expired order event ", expired)
+	}
+
+	id := expectID(t, "SET_BUY_AMOUNT", ts.SetBuyAmount(6, "user1", "ABC", "50.00"))
+	ts.SetBuyTrigger(7, "user1", "ABC", "20.00")
+	ts.TriggerSuccess(8, "user1", "ABC", "10.00", "50.00", "BUY", id)
+	if fill := events.next(t, EventTriggerFill); fill.User != "user1" || fill.Data.(TriggerFill).TriggerID != id {
+		t.Error("Unexpected trigger fill event ", fill)
+	}
+}
+
+// Many clients buying, committing and cancelling for one user at once must
+// never lose or invent money: every dollar ends up either in the account or
+// spent on shares, and every pending order is taken by exactly one commit or cancel.
+func TestTransactionServer_ConcurrentOrders(t *testing.T) {
+	ts, quotes := NewMockTransactionServer()
+	quotes.addRule("ABC", decimal.NewFromFloat(10.00))
+	ts.Add(1, "user1", "100000.00")
+
+	clients, rounds := 50, 40
+	var placed, taken int64
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			var myPlaced, myTaken int64
+			for r := 0; r < rounds; r++ {
+				transNum := c*rounds + r + 2
+				amount := strconv.Itoa((c+r)%5*10 + 10)
+				if ts.Buy(transNum, "user1", "ABC", amount).Succeeded() {
+					myPlaced++
+				}
+				var result socketserver.Result
+				switch (c + r) % 3 {
+				case 0:
+					result = ts.CommitBuy(transNum, "user1")
+				case 1:
+					result = ts.CancelBuy(transNum, "user1")
+				default:
+					// Leave it for another client to take
+					continue
+				}
+				if result.Succeeded() {
+					myTaken++
+				} else if result.Code != socketserver.CodeNoPendingBuy {
+					t.Error("Unexpected failure ", result)
+				}
+			}
+			lock.Lock()
+			placed += myPlaced
+			taken += myTaken
+			lock.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	for ts.CancelBuy(0, "user1").Succeeded() {
+		taken++
+	}
+	if placed != taken {
+		t.Errorf("%d orders were placed but %d committed or cancelled", placed, taken)
+	}
+	funds, _ := ts.UserDatabase.GetFunds("user1")
+	shares, _ := ts.UserDatabase.GetStock("user1", "ABC")
+	if total := funds.Add(decimal.New(shares*10, 0)); !total.Equal(decimal.New(100000, 0)) {
+		t.Errorf("Funds %s and %d shares should add up to the 100000 deposited", funds, shares)
+	}
+	if account, _ := ts.UserDatabase.GetAccount("user1"); len(account.BuyOrders) != 0 {
+		t.Error("No orders should be left pending, have ", account.BuyOrders)
+	}
+}
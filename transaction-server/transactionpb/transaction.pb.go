@@ -0,0 +1,2489 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: transaction.proto
+
+// The transaction server's gRPC interface. Each RPC dispatches to the same
+// TransactionServer method as the socket command of the same name.
+//
+// Failures are returned as gRPC statuses carrying a google.rpc.ErrorInfo
+// detail whose reason is the socket protocol's error code, such as
+// INSUFFICIENT_FUNDS or NO_PENDING_BUY.
+//
+// Regenerate with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative transaction.proto
+
+package transactionpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type UserRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserRequest) Reset() {
+	*x = UserRequest{}
+	mi := &file_transaction_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserRequest) ProtoMessage() {}
+
+func (x *UserRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserRequest.ProtoReflect.Descriptor instead.
+func (*UserRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UserRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *UserRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+type CredentialsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Password      string                 `protobuf:"bytes,3,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CredentialsRequest) Reset() {
+	*x = CredentialsRequest{}
+	mi := &file_transaction_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CredentialsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CredentialsRequest) ProtoMessage() {}
+
+func (x *CredentialsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CredentialsRequest.ProtoReflect.Descriptor instead.
+func (*CredentialsRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CredentialsRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *CredentialsRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *CredentialsRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type AddRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Amount        string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddRequest) Reset() {
+	*x = AddRequest{}
+	mi := &file_transaction_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddRequest) ProtoMessage() {}
+
+func (x *AddRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddRequest.ProtoReflect.Descriptor instead.
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AddRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *AddRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *AddRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+type StockRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User     string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	// The trigger CancelSetBuy or CancelSetSell cancels. Without one it's the
+	// user's latest trigger on the stock.
+	TriggerId     string `protobuf:"bytes,4,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StockRequest) Reset() {
+	*x = StockRequest{}
+	mi := &file_transaction_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StockRequest) ProtoMessage() {}
+
+func (x *StockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StockRequest.ProtoReflect.Descriptor instead.
+func (*StockRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StockRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *StockRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *StockRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *StockRequest) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+// OrderRequest is a BUY, SELL, or trigger amount/price for a stock
+type OrderRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User     string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount   string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	// The trigger SetBuyTrigger or SetSellTrigger sets the price of. Without one
+	// it's the user's latest trigger on the stock.
+	TriggerId string `protobuf:"bytes,5,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	// When the trigger SetBuyTrigger or SetSellTrigger starts expires, in unix
+	// milliseconds. Without one it runs until it fires.
+	Expires       string `protobuf:"bytes,6,opt,name=expires,proto3" json:"expires,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderRequest) Reset() {
+	*x = OrderRequest{}
+	mi := &file_transaction_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderRequest) ProtoMessage() {}
+
+func (x *OrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderRequest.ProtoReflect.Descriptor instead.
+func (*OrderRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *OrderRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *OrderRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+func (x *OrderRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+type QuoteReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Price         string                 `protobuf:"bytes,1,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QuoteReply) Reset() {
+	*x = QuoteReply{}
+	mi := &file_transaction_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuoteReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuoteReply) ProtoMessage() {}
+
+func (x *QuoteReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuoteReply.ProtoReflect.Descriptor instead.
+func (*QuoteReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *QuoteReply) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+type TriggerIDReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TriggerId     string                 `protobuf:"bytes,1,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerIDReply) Reset() {
+	*x = TriggerIDReply{}
+	mi := &file_transaction_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerIDReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerIDReply) ProtoMessage() {}
+
+func (x *TriggerIDReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerIDReply.ProtoReflect.Descriptor instead.
+func (*TriggerIDReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TriggerIDReply) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+type TriggerSuccessRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User     string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Price    string                 `protobuf:"bytes,4,opt,name=price,proto3" json:"price,omitempty"`
+	Amount   string                 `protobuf:"bytes,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	// BUY or SELL
+	Action        string `protobuf:"bytes,6,opt,name=action,proto3" json:"action,omitempty"`
+	TriggerId     string `protobuf:"bytes,7,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerSuccessRequest) Reset() {
+	*x = TriggerSuccessRequest{}
+	mi := &file_transaction_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerSuccessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerSuccessRequest) ProtoMessage() {}
+
+func (x *TriggerSuccessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerSuccessRequest.ProtoReflect.Descriptor instead.
+func (*TriggerSuccessRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *TriggerSuccessRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *TriggerSuccessRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *TriggerSuccessRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *TriggerSuccessRequest) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *TriggerSuccessRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *TriggerSuccessRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *TriggerSuccessRequest) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+// LimitOrderRequest is a limit or stop order. A buy's amount is dollars and a sell's is shares.
+type LimitOrderRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User     string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount   string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	// The limit price, or a stop order's stop price
+	Price string `protobuf:"bytes,5,opt,name=price,proto3" json:"price,omitempty"`
+	// IOC, DAY, GTC or GTD
+	TimeInForce string `protobuf:"bytes,6,opt,name=time_in_force,json=timeInForce,proto3" json:"time_in_force,omitempty"`
+	// When a GTD order expires, in unix milliseconds. Other orders take none.
+	Expires       string `protobuf:"bytes,7,opt,name=expires,proto3" json:"expires,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitOrderRequest) Reset() {
+	*x = LimitOrderRequest{}
+	mi := &file_transaction_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitOrderRequest) ProtoMessage() {}
+
+func (x *LimitOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitOrderRequest.ProtoReflect.Descriptor instead.
+func (*LimitOrderRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *LimitOrderRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *LimitOrderRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *LimitOrderRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *LimitOrderRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *LimitOrderRequest) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *LimitOrderRequest) GetTimeInForce() string {
+	if x != nil {
+		return x.TimeInForce
+	}
+	return ""
+}
+
+func (x *LimitOrderRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+// TrailingStopRequest is a trailing stop, which starts from the current quote
+type TrailingStopRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User     string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount   string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	// A dollar amount such as 1.50 or a percentage such as 5%
+	Trail string `protobuf:"bytes,5,opt,name=trail,proto3" json:"trail,omitempty"`
+	// DAY, GTC or GTD
+	TimeInForce   string `protobuf:"bytes,6,opt,name=time_in_force,json=timeInForce,proto3" json:"time_in_force,omitempty"`
+	Expires       string `protobuf:"bytes,7,opt,name=expires,proto3" json:"expires,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrailingStopRequest) Reset() {
+	*x = TrailingStopRequest{}
+	mi := &file_transaction_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrailingStopRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrailingStopRequest) ProtoMessage() {}
+
+func (x *TrailingStopRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrailingStopRequest.ProtoReflect.Descriptor instead.
+func (*TrailingStopRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TrailingStopRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *TrailingStopRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *TrailingStopRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *TrailingStopRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *TrailingStopRequest) GetTrail() string {
+	if x != nil {
+		return x.Trail
+	}
+	return ""
+}
+
+func (x *TrailingStopRequest) GetTimeInForce() string {
+	if x != nil {
+		return x.TimeInForce
+	}
+	return ""
+}
+
+func (x *TrailingStopRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+// OCOSellRequest sells shares at the take-profit or the stop-loss, whichever
+// the price reaches first
+type OCOSellRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	TransNum   int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User       string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock      string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Shares     string                 `protobuf:"bytes,4,opt,name=shares,proto3" json:"shares,omitempty"`
+	TakeProfit string                 `protobuf:"bytes,5,opt,name=take_profit,json=takeProfit,proto3" json:"take_profit,omitempty"`
+	StopLoss   string                 `protobuf:"bytes,6,opt,name=stop_loss,json=stopLoss,proto3" json:"stop_loss,omitempty"`
+	// DAY, GTC or GTD
+	TimeInForce   string `protobuf:"bytes,7,opt,name=time_in_force,json=timeInForce,proto3" json:"time_in_force,omitempty"`
+	Expires       string `protobuf:"bytes,8,opt,name=expires,proto3" json:"expires,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OCOSellRequest) Reset() {
+	*x = OCOSellRequest{}
+	mi := &file_transaction_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OCOSellRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OCOSellRequest) ProtoMessage() {}
+
+func (x *OCOSellRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OCOSellRequest.ProtoReflect.Descriptor instead.
+func (*OCOSellRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *OCOSellRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *OCOSellRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetShares() string {
+	if x != nil {
+		return x.Shares
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetTakeProfit() string {
+	if x != nil {
+		return x.TakeProfit
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetStopLoss() string {
+	if x != nil {
+		return x.StopLoss
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetTimeInForce() string {
+	if x != nil {
+		return x.TimeInForce
+	}
+	return ""
+}
+
+func (x *OCOSellRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+// BracketBuyRequest buys the dollar amount at the entry price, then sells the
+// shares at the take-profit or the stop-loss
+type BracketBuyRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	TransNum   int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User       string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Stock      string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Amount     string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Entry      string                 `protobuf:"bytes,5,opt,name=entry,proto3" json:"entry,omitempty"`
+	TakeProfit string                 `protobuf:"bytes,6,opt,name=take_profit,json=takeProfit,proto3" json:"take_profit,omitempty"`
+	StopLoss   string                 `protobuf:"bytes,7,opt,name=stop_loss,json=stopLoss,proto3" json:"stop_loss,omitempty"`
+	// DAY, GTC or GTD
+	TimeInForce   string `protobuf:"bytes,8,opt,name=time_in_force,json=timeInForce,proto3" json:"time_in_force,omitempty"`
+	Expires       string `protobuf:"bytes,9,opt,name=expires,proto3" json:"expires,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BracketBuyRequest) Reset() {
+	*x = BracketBuyRequest{}
+	mi := &file_transaction_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BracketBuyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BracketBuyRequest) ProtoMessage() {}
+
+func (x *BracketBuyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BracketBuyRequest.ProtoReflect.Descriptor instead.
+func (*BracketBuyRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BracketBuyRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *BracketBuyRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetEntry() string {
+	if x != nil {
+		return x.Entry
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetTakeProfit() string {
+	if x != nil {
+		return x.TakeProfit
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetStopLoss() string {
+	if x != nil {
+		return x.StopLoss
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetTimeInForce() string {
+	if x != nil {
+		return x.TimeInForce
+	}
+	return ""
+}
+
+func (x *BracketBuyRequest) GetExpires() string {
+	if x != nil {
+		return x.Expires
+	}
+	return ""
+}
+
+type OrderIDReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OrderId       string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderIDReply) Reset() {
+	*x = OrderIDReply{}
+	mi := &file_transaction_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderIDReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderIDReply) ProtoMessage() {}
+
+func (x *OrderIDReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderIDReply.ProtoReflect.Descriptor instead.
+func (*OrderIDReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *OrderIDReply) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+type LimitOrderIDRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	OrderId       string                 `protobuf:"bytes,3,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitOrderIDRequest) Reset() {
+	*x = LimitOrderIDRequest{}
+	mi := &file_transaction_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitOrderIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitOrderIDRequest) ProtoMessage() {}
+
+func (x *LimitOrderIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitOrderIDRequest.ProtoReflect.Descriptor instead.
+func (*LimitOrderIDRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *LimitOrderIDRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *LimitOrderIDRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *LimitOrderIDRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+// AmendLimitOrderRequest changes an order's amount and price, the amount being
+// dollars for a buy and shares for a sell as when the order was placed
+type AmendLimitOrderRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	OrderId       string                 `protobuf:"bytes,3,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Amount        string                 `protobuf:"bytes,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Price         string                 `protobuf:"bytes,5,opt,name=price,proto3" json:"price,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AmendLimitOrderRequest) Reset() {
+	*x = AmendLimitOrderRequest{}
+	mi := &file_transaction_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AmendLimitOrderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AmendLimitOrderRequest) ProtoMessage() {}
+
+func (x *AmendLimitOrderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AmendLimitOrderRequest.ProtoReflect.Descriptor instead.
+func (*AmendLimitOrderRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *AmendLimitOrderRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *AmendLimitOrderRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *AmendLimitOrderRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *AmendLimitOrderRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *AmendLimitOrderRequest) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+type LimitOrder struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	OrderId string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Stock   string                 `protobuf:"bytes,2,opt,name=stock,proto3" json:"stock,omitempty"`
+	// The order type, such as STOP, or empty for a limit order
+	Type string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	// BUY or SELL
+	Side   string `protobuf:"bytes,4,opt,name=side,proto3" json:"side,omitempty"`
+	Shares int64  `protobuf:"varint,5,opt,name=shares,proto3" json:"shares,omitempty"`
+	// The limit price, a stop order's stop price, the quote a trailing stop
+	// started from, a bracket's entry price or an OCO group's take-profit
+	Price string `protobuf:"bytes,6,opt,name=price,proto3" json:"price,omitempty"`
+	// Dollars reserved by a BUY order
+	Funds       string `protobuf:"bytes,7,opt,name=funds,proto3" json:"funds,omitempty"`
+	TimeInForce string `protobuf:"bytes,8,opt,name=time_in_force,json=timeInForce,proto3" json:"time_in_force,omitempty"`
+	// Milliseconds since the Unix epoch, or 0 for an order that doesn't expire
+	Expires int64 `protobuf:"varint,9,opt,name=expires,proto3" json:"expires,omitempty"`
+	// Milliseconds since the Unix epoch
+	Created int64 `protobuf:"varint,10,opt,name=created,proto3" json:"created,omitempty"`
+	// A trailing stop's trail
+	Trail string `protobuf:"bytes,11,opt,name=trail,proto3" json:"trail,omitempty"`
+	// The exits of an OCO group or bracket
+	TakeProfit    string `protobuf:"bytes,12,opt,name=take_profit,json=takeProfit,proto3" json:"take_profit,omitempty"`
+	StopLoss      string `protobuf:"bytes,13,opt,name=stop_loss,json=stopLoss,proto3" json:"stop_loss,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitOrder) Reset() {
+	*x = LimitOrder{}
+	mi := &file_transaction_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitOrder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitOrder) ProtoMessage() {}
+
+func (x *LimitOrder) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitOrder.ProtoReflect.Descriptor instead.
+func (*LimitOrder) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *LimitOrder) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetShares() int64 {
+	if x != nil {
+		return x.Shares
+	}
+	return 0
+}
+
+func (x *LimitOrder) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetFunds() string {
+	if x != nil {
+		return x.Funds
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetTimeInForce() string {
+	if x != nil {
+		return x.TimeInForce
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetExpires() int64 {
+	if x != nil {
+		return x.Expires
+	}
+	return 0
+}
+
+func (x *LimitOrder) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *LimitOrder) GetTrail() string {
+	if x != nil {
+		return x.Trail
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetTakeProfit() string {
+	if x != nil {
+		return x.TakeProfit
+	}
+	return ""
+}
+
+func (x *LimitOrder) GetStopLoss() string {
+	if x != nil {
+		return x.StopLoss
+	}
+	return ""
+}
+
+type LimitOrdersReply struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Oldest first
+	Orders        []*LimitOrder `protobuf:"bytes,1,rep,name=orders,proto3" json:"orders,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LimitOrdersReply) Reset() {
+	*x = LimitOrdersReply{}
+	mi := &file_transaction_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LimitOrdersReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LimitOrdersReply) ProtoMessage() {}
+
+func (x *LimitOrdersReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LimitOrdersReply.ProtoReflect.Descriptor instead.
+func (*LimitOrdersReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *LimitOrdersReply) GetOrders() []*LimitOrder {
+	if x != nil {
+		return x.Orders
+	}
+	return nil
+}
+
+type ReconcileTriggersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReconcileTriggersRequest) Reset() {
+	*x = ReconcileTriggersRequest{}
+	mi := &file_transaction_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReconcileTriggersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReconcileTriggersRequest) ProtoMessage() {}
+
+func (x *ReconcileTriggersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReconcileTriggersRequest.ProtoReflect.Descriptor instead.
+func (*ReconcileTriggersRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ReconcileTriggersRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+type DumpLogRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	// Without a user every transaction is dumped
+	User          string `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Filename      string `protobuf:"bytes,3,opt,name=filename,proto3" json:"filename,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DumpLogRequest) Reset() {
+	*x = DumpLogRequest{}
+	mi := &file_transaction_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DumpLogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DumpLogRequest) ProtoMessage() {}
+
+func (x *DumpLogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DumpLogRequest.ProtoReflect.Descriptor instead.
+func (*DumpLogRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DumpLogRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *DumpLogRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *DumpLogRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+type SummaryLine struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Line          string                 `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SummaryLine) Reset() {
+	*x = SummaryLine{}
+	mi := &file_transaction_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SummaryLine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SummaryLine) ProtoMessage() {}
+
+func (x *SummaryLine) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SummaryLine.ProtoReflect.Descriptor instead.
+func (*SummaryLine) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *SummaryLine) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+type HistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransNum      int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	User          string                 `protobuf:"bytes,2,opt,name=user,proto3" json:"user,omitempty"`
+	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryRequest) Reset() {
+	*x = HistoryRequest{}
+	mi := &file_transaction_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryRequest) ProtoMessage() {}
+
+func (x *HistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryRequest.ProtoReflect.Descriptor instead.
+func (*HistoryRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *HistoryRequest) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *HistoryRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *HistoryRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+type HistoryEntry struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	TransNum int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	Command  string                 `protobuf:"bytes,2,opt,name=command,proto3" json:"command,omitempty"`
+	Stock    string                 `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	Funds    string                 `protobuf:"bytes,4,opt,name=funds,proto3" json:"funds,omitempty"`
+	Shares   int64                  `protobuf:"varint,5,opt,name=shares,proto3" json:"shares,omitempty"`
+	Price    string                 `protobuf:"bytes,6,opt,name=price,proto3" json:"price,omitempty"`
+	// Milliseconds since the Unix epoch
+	Timestamp     int64 `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryEntry) Reset() {
+	*x = HistoryEntry{}
+	mi := &file_transaction_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryEntry) ProtoMessage() {}
+
+func (x *HistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryEntry.ProtoReflect.Descriptor instead.
+func (*HistoryEntry) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *HistoryEntry) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *HistoryEntry) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetFunds() string {
+	if x != nil {
+		return x.Funds
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetShares() int64 {
+	if x != nil {
+		return x.Shares
+	}
+	return 0
+}
+
+func (x *HistoryEntry) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type HistoryReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*HistoryEntry        `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HistoryReply) Reset() {
+	*x = HistoryReply{}
+	mi := &file_transaction_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryReply) ProtoMessage() {}
+
+func (x *HistoryReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryReply.ProtoReflect.Descriptor instead.
+func (*HistoryReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *HistoryReply) GetEntries() []*HistoryEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type PendingOrder struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Buy or Sell
+	Type   string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Stock  string `protobuf:"bytes,2,opt,name=stock,proto3" json:"stock,omitempty"`
+	Cost   string `protobuf:"bytes,3,opt,name=cost,proto3" json:"cost,omitempty"`
+	Shares int64  `protobuf:"varint,4,opt,name=shares,proto3" json:"shares,omitempty"`
+	// Milliseconds since the Unix epoch
+	Created       int64 `protobuf:"varint,5,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PendingOrder) Reset() {
+	*x = PendingOrder{}
+	mi := &file_transaction_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PendingOrder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingOrder) ProtoMessage() {}
+
+func (x *PendingOrder) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingOrder.ProtoReflect.Descriptor instead.
+func (*PendingOrder) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *PendingOrder) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *PendingOrder) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *PendingOrder) GetCost() string {
+	if x != nil {
+		return x.Cost
+	}
+	return ""
+}
+
+func (x *PendingOrder) GetShares() int64 {
+	if x != nil {
+		return x.Shares
+	}
+	return 0
+}
+
+func (x *PendingOrder) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+type AccountReply struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	User           string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	Funds          string                 `protobuf:"bytes,2,opt,name=funds,proto3" json:"funds,omitempty"`
+	ReservedFunds  string                 `protobuf:"bytes,3,opt,name=reserved_funds,json=reservedFunds,proto3" json:"reserved_funds,omitempty"`
+	Stocks         map[string]int64       `protobuf:"bytes,4,rep,name=stocks,proto3" json:"stocks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	ReservedStocks map[string]int64       `protobuf:"bytes,5,rep,name=reserved_stocks,json=reservedStocks,proto3" json:"reserved_stocks,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	BuyOrders      []*PendingOrder        `protobuf:"bytes,6,rep,name=buy_orders,json=buyOrders,proto3" json:"buy_orders,omitempty"`
+	SellOrders     []*PendingOrder        `protobuf:"bytes,7,rep,name=sell_orders,json=sellOrders,proto3" json:"sell_orders,omitempty"`
+	// Funds reserved for each buy trigger, by stock
+	BuyTriggers map[string]string `protobuf:"bytes,8,rep,name=buy_triggers,json=buyTriggers,proto3" json:"buy_triggers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Shares reserved for each sell trigger, by stock
+	SellTriggers map[string]int64 `protobuf:"bytes,9,rep,name=sell_triggers,json=sellTriggers,proto3" json:"sell_triggers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// The most recent history, newest first
+	History []*HistoryEntry `protobuf:"bytes,10,rep,name=history,proto3" json:"history,omitempty"`
+	// Each trigger by its ID, sorted by stock and ID
+	Triggers      []*TriggerStatus `protobuf:"bytes,11,rep,name=triggers,proto3" json:"triggers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AccountReply) Reset() {
+	*x = AccountReply{}
+	mi := &file_transaction_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AccountReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AccountReply) ProtoMessage() {}
+
+func (x *AccountReply) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AccountReply.ProtoReflect.Descriptor instead.
+func (*AccountReply) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *AccountReply) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *AccountReply) GetFunds() string {
+	if x != nil {
+		return x.Funds
+	}
+	return ""
+}
+
+func (x *AccountReply) GetReservedFunds() string {
+	if x != nil {
+		return x.ReservedFunds
+	}
+	return ""
+}
+
+func (x *AccountReply) GetStocks() map[string]int64 {
+	if x != nil {
+		return x.Stocks
+	}
+	return nil
+}
+
+func (x *AccountReply) GetReservedStocks() map[string]int64 {
+	if x != nil {
+		return x.ReservedStocks
+	}
+	return nil
+}
+
+func (x *AccountReply) GetBuyOrders() []*PendingOrder {
+	if x != nil {
+		return x.BuyOrders
+	}
+	return nil
+}
+
+func (x *AccountReply) GetSellOrders() []*PendingOrder {
+	if x != nil {
+		return x.SellOrders
+	}
+	return nil
+}
+
+func (x *AccountReply) GetBuyTriggers() map[string]string {
+	if x != nil {
+		return x.BuyTriggers
+	}
+	return nil
+}
+
+func (x *AccountReply) GetSellTriggers() map[string]int64 {
+	if x != nil {
+		return x.SellTriggers
+	}
+	return nil
+}
+
+func (x *AccountReply) GetHistory() []*HistoryEntry {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *AccountReply) GetTriggers() []*TriggerStatus {
+	if x != nil {
+		return x.Triggers
+	}
+	return nil
+}
+
+type TriggerStatus struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	TriggerId string                 `protobuf:"bytes,1,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	// BUY or SELL
+	Side  string `protobuf:"bytes,2,opt,name=side,proto3" json:"side,omitempty"`
+	Stock string `protobuf:"bytes,3,opt,name=stock,proto3" json:"stock,omitempty"`
+	// Dollars reserved by a BUY trigger
+	Funds string `protobuf:"bytes,4,opt,name=funds,proto3" json:"funds,omitempty"`
+	// Shares reserved by a SELL trigger
+	Shares        int64 `protobuf:"varint,5,opt,name=shares,proto3" json:"shares,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerStatus) Reset() {
+	*x = TriggerStatus{}
+	mi := &file_transaction_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerStatus) ProtoMessage() {}
+
+func (x *TriggerStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerStatus.ProtoReflect.Descriptor instead.
+func (*TriggerStatus) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *TriggerStatus) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+func (x *TriggerStatus) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *TriggerStatus) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *TriggerStatus) GetFunds() string {
+	if x != nil {
+		return x.Funds
+	}
+	return ""
+}
+
+func (x *TriggerStatus) GetShares() int64 {
+	if x != nil {
+		return x.Shares
+	}
+	return 0
+}
+
+type TriggerFillsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	User          string                 `protobuf:"bytes,1,opt,name=user,proto3" json:"user,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerFillsRequest) Reset() {
+	*x = TriggerFillsRequest{}
+	mi := &file_transaction_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerFillsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerFillsRequest) ProtoMessage() {}
+
+func (x *TriggerFillsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerFillsRequest.ProtoReflect.Descriptor instead.
+func (*TriggerFillsRequest) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *TriggerFillsRequest) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+type TriggerFill struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	TransNum  int32                  `protobuf:"varint,1,opt,name=trans_num,json=transNum,proto3" json:"trans_num,omitempty"`
+	TriggerId string                 `protobuf:"bytes,2,opt,name=trigger_id,json=triggerId,proto3" json:"trigger_id,omitempty"`
+	User      string                 `protobuf:"bytes,3,opt,name=user,proto3" json:"user,omitempty"`
+	Stock     string                 `protobuf:"bytes,4,opt,name=stock,proto3" json:"stock,omitempty"`
+	// BUY or SELL
+	Action string `protobuf:"bytes,5,opt,name=action,proto3" json:"action,omitempty"`
+	Price  string `protobuf:"bytes,6,opt,name=price,proto3" json:"price,omitempty"`
+	Amount string `protobuf:"bytes,7,opt,name=amount,proto3" json:"amount,omitempty"`
+	// Milliseconds since the Unix epoch
+	Timestamp     int64 `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerFill) Reset() {
+	*x = TriggerFill{}
+	mi := &file_transaction_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerFill) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerFill) ProtoMessage() {}
+
+func (x *TriggerFill) ProtoReflect() protoreflect.Message {
+	mi := &file_transaction_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerFill.ProtoReflect.Descriptor instead.
+func (*TriggerFill) Descriptor() ([]byte, []int) {
+	return file_transaction_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *TriggerFill) GetTransNum() int32 {
+	if x != nil {
+		return x.TransNum
+	}
+	return 0
+}
+
+func (x *TriggerFill) GetTriggerId() string {
+	if x != nil {
+		return x.TriggerId
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetStock() string {
+	if x != nil {
+		return x.Stock
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *TriggerFill) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+var File_transaction_proto protoreflect.FileDescriptor
+
+const file_transaction_proto_rawDesc = "" +
+	"\n" +
+	"\x11transaction.proto\x12\vtransaction\x1a\x1bgoogle/protobuf/empty.proto\">\n" +
+	"\vUserRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\"a\n" +
+	"\x12CredentialsRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x1a\n" +
+	"\bpassword\x18\x03 \x01(\tR\bpassword\"U\n" +
+	"\n" +
+	"AddRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\"t\n" +
+	"\fStockRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x04 \x01(\tR\ttriggerId\"\xa6\x01\n" +
+	"\fOrderRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x05 \x01(\tR\ttriggerId\x12\x18\n" +
+	"\aexpires\x18\x06 \x01(\tR\aexpires\"\"\n" +
+	"\n" +
+	"QuoteReply\x12\x14\n" +
+	"\x05price\x18\x01 \x01(\tR\x05price\"/\n" +
+	"\x0eTriggerIDReply\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x01 \x01(\tR\ttriggerId\"\xc3\x01\n" +
+	"\x15TriggerSuccessRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x14\n" +
+	"\x05price\x18\x04 \x01(\tR\x05price\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\tR\x06amount\x12\x16\n" +
+	"\x06action\x18\x06 \x01(\tR\x06action\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\a \x01(\tR\ttriggerId\"\xc6\x01\n" +
+	"\x11LimitOrderRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\tR\x05price\x12\"\n" +
+	"\rtime_in_force\x18\x06 \x01(\tR\vtimeInForce\x12\x18\n" +
+	"\aexpires\x18\a \x01(\tR\aexpires\"\xc8\x01\n" +
+	"\x13TrailingStopRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x14\n" +
+	"\x05trail\x18\x05 \x01(\tR\x05trail\x12\"\n" +
+	"\rtime_in_force\x18\x06 \x01(\tR\vtimeInForce\x12\x18\n" +
+	"\aexpires\x18\a \x01(\tR\aexpires\"\xeb\x01\n" +
+	"\x0eOCOSellRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06shares\x18\x04 \x01(\tR\x06shares\x12\x1f\n" +
+	"\vtake_profit\x18\x05 \x01(\tR\n" +
+	"takeProfit\x12\x1b\n" +
+	"\tstop_loss\x18\x06 \x01(\tR\bstopLoss\x12\"\n" +
+	"\rtime_in_force\x18\a \x01(\tR\vtimeInForce\x12\x18\n" +
+	"\aexpires\x18\b \x01(\tR\aexpires\"\x84\x02\n" +
+	"\x11BracketBuyRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x14\n" +
+	"\x05entry\x18\x05 \x01(\tR\x05entry\x12\x1f\n" +
+	"\vtake_profit\x18\x06 \x01(\tR\n" +
+	"takeProfit\x12\x1b\n" +
+	"\tstop_loss\x18\a \x01(\tR\bstopLoss\x12\"\n" +
+	"\rtime_in_force\x18\b \x01(\tR\vtimeInForce\x12\x18\n" +
+	"\aexpires\x18\t \x01(\tR\aexpires\")\n" +
+	"\fOrderIDReply\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\"a\n" +
+	"\x13LimitOrderIDRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x19\n" +
+	"\border_id\x18\x03 \x01(\tR\aorderId\"\x92\x01\n" +
+	"\x16AmendLimitOrderRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x19\n" +
+	"\border_id\x18\x03 \x01(\tR\aorderId\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\tR\x06amount\x12\x14\n" +
+	"\x05price\x18\x05 \x01(\tR\x05price\"\xd5\x02\n" +
+	"\n" +
+	"LimitOrder\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x14\n" +
+	"\x05stock\x18\x02 \x01(\tR\x05stock\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x12\n" +
+	"\x04side\x18\x04 \x01(\tR\x04side\x12\x16\n" +
+	"\x06shares\x18\x05 \x01(\x03R\x06shares\x12\x14\n" +
+	"\x05price\x18\x06 \x01(\tR\x05price\x12\x14\n" +
+	"\x05funds\x18\a \x01(\tR\x05funds\x12\"\n" +
+	"\rtime_in_force\x18\b \x01(\tR\vtimeInForce\x12\x18\n" +
+	"\aexpires\x18\t \x01(\x03R\aexpires\x12\x18\n" +
+	"\acreated\x18\n" +
+	" \x01(\x03R\acreated\x12\x14\n" +
+	"\x05trail\x18\v \x01(\tR\x05trail\x12\x1f\n" +
+	"\vtake_profit\x18\f \x01(\tR\n" +
+	"takeProfit\x12\x1b\n" +
+	"\tstop_loss\x18\r \x01(\tR\bstopLoss\"C\n" +
+	"\x10LimitOrdersReply\x12/\n" +
+	"\x06orders\x18\x01 \x03(\v2\x17.transaction.LimitOrderR\x06orders\"7\n" +
+	"\x18ReconcileTriggersRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\"]\n" +
+	"\x0eDumpLogRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x1a\n" +
+	"\bfilename\x18\x03 \x01(\tR\bfilename\"!\n" +
+	"\vSummaryLine\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\tR\x04line\"U\n" +
+	"\x0eHistoryRequest\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x12\n" +
+	"\x04user\x18\x02 \x01(\tR\x04user\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\"\xbd\x01\n" +
+	"\fHistoryEntry\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x18\n" +
+	"\acommand\x18\x02 \x01(\tR\acommand\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x14\n" +
+	"\x05funds\x18\x04 \x01(\tR\x05funds\x12\x16\n" +
+	"\x06shares\x18\x05 \x01(\x03R\x06shares\x12\x14\n" +
+	"\x05price\x18\x06 \x01(\tR\x05price\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestamp\"C\n" +
+	"\fHistoryReply\x123\n" +
+	"\aentries\x18\x01 \x03(\v2\x19.transaction.HistoryEntryR\aentries\"~\n" +
+	"\fPendingOrder\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x14\n" +
+	"\x05stock\x18\x02 \x01(\tR\x05stock\x12\x12\n" +
+	"\x04cost\x18\x03 \x01(\tR\x04cost\x12\x16\n" +
+	"\x06shares\x18\x04 \x01(\x03R\x06shares\x12\x18\n" +
+	"\acreated\x18\x05 \x01(\x03R\acreated\"\xf9\x06\n" +
+	"\fAccountReply\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\x12\x14\n" +
+	"\x05funds\x18\x02 \x01(\tR\x05funds\x12%\n" +
+	"\x0ereserved_funds\x18\x03 \x01(\tR\rreservedFunds\x12=\n" +
+	"\x06stocks\x18\x04 \x03(\v2%.transaction.AccountReply.StocksEntryR\x06stocks\x12V\n" +
+	"\x0freserved_stocks\x18\x05 \x03(\v2-.transaction.AccountReply.ReservedStocksEntryR\x0ereservedStocks\x128\n" +
+	"\n" +
+	"buy_orders\x18\x06 \x03(\v2\x19.transaction.PendingOrderR\tbuyOrders\x12:\n" +
+	"\vsell_orders\x18\a \x03(\v2\x19.transaction.PendingOrderR\n" +
+	"sellOrders\x12M\n" +
+	"\fbuy_triggers\x18\b \x03(\v2*.transaction.AccountReply.BuyTriggersEntryR\vbuyTriggers\x12P\n" +
+	"\rsell_triggers\x18\t \x03(\v2+.transaction.AccountReply.SellTriggersEntryR\fsellTriggers\x123\n" +
+	"\ahistory\x18\n" +
+	" \x03(\v2\x19.transaction.HistoryEntryR\ahistory\x126\n" +
+	"\btriggers\x18\v \x03(\v2\x1a.transaction.TriggerStatusR\btriggers\x1a9\n" +
+	"\vStocksEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1aA\n" +
+	"\x13ReservedStocksEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a>\n" +
+	"\x10BuyTriggersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a?\n" +
+	"\x11SellTriggersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\"\x86\x01\n" +
+	"\rTriggerStatus\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x01 \x01(\tR\ttriggerId\x12\x12\n" +
+	"\x04side\x18\x02 \x01(\tR\x04side\x12\x14\n" +
+	"\x05stock\x18\x03 \x01(\tR\x05stock\x12\x14\n" +
+	"\x05funds\x18\x04 \x01(\tR\x05funds\x12\x16\n" +
+	"\x06shares\x18\x05 \x01(\x03R\x06shares\")\n" +
+	"\x13TriggerFillsRequest\x12\x12\n" +
+	"\x04user\x18\x01 \x01(\tR\x04user\"\xd7\x01\n" +
+	"\vTriggerFill\x12\x1b\n" +
+	"\ttrans_num\x18\x01 \x01(\x05R\btransNum\x12\x1d\n" +
+	"\n" +
+	"trigger_id\x18\x02 \x01(\tR\ttriggerId\x12\x12\n" +
+	"\x04user\x18\x03 \x01(\tR\x04user\x12\x14\n" +
+	"\x05stock\x18\x04 \x01(\tR\x05stock\x12\x16\n" +
+	"\x06action\x18\x05 \x01(\tR\x06action\x12\x14\n" +
+	"\x05price\x18\x06 \x01(\tR\x05price\x12\x16\n" +
+	"\x06amount\x18\a \x01(\tR\x06amount\x12\x1c\n" +
+	"\ttimestamp\x18\b \x01(\x03R\ttimestamp2\xd3\x12\n" +
+	"\vTransaction\x12C\n" +
+	"\bRegister\x12\x1f.transaction.CredentialsRequest\x1a\x16.google.protobuf.Empty\x12G\n" +
+	"\fAuthenticate\x12\x1f.transaction.CredentialsRequest\x1a\x16.google.protobuf.Empty\x126\n" +
+	"\x03Add\x12\x17.transaction.AddRequest\x1a\x16.google.protobuf.Empty\x12;\n" +
+	"\x05Quote\x12\x19.transaction.StockRequest\x1a\x17.transaction.QuoteReply\x128\n" +
+	"\x03Buy\x12\x19.transaction.OrderRequest\x1a\x16.google.protobuf.Empty\x12=\n" +
+	"\tCommitBuy\x12\x18.transaction.UserRequest\x1a\x16.google.protobuf.Empty\x12=\n" +
+	"\tCancelBuy\x12\x18.transaction.UserRequest\x1a\x16.google.protobuf.Empty\x129\n" +
+	"\x04Sell\x12\x19.transaction.OrderRequest\x1a\x16.google.protobuf.Empty\x12>\n" +
+	"\n" +
+	"CommitSell\x12\x18.transaction.UserRequest\x1a\x16.google.protobuf.Empty\x12>\n" +
+	"\n" +
+	"CancelSell\x12\x18.transaction.UserRequest\x1a\x16.google.protobuf.Empty\x12F\n" +
+	"\fSetBuyAmount\x12\x19.transaction.OrderRequest\x1a\x1b.transaction.TriggerIDReply\x12A\n" +
+	"\fCancelSetBuy\x12\x19.transaction.StockRequest\x1a\x16.google.protobuf.Empty\x12B\n" +
+	"\rSetBuyTrigger\x12\x19.transaction.OrderRequest\x1a\x16.google.protobuf.Empty\x12G\n" +
+	"\rSetSellAmount\x12\x19.transaction.OrderRequest\x1a\x1b.transaction.TriggerIDReply\x12C\n" +
+	"\x0eSetSellTrigger\x12\x19.transaction.OrderRequest\x1a\x16.google.protobuf.Empty\x12B\n" +
+	"\rCancelSetSell\x12\x19.transaction.StockRequest\x1a\x16.google.protobuf.Empty\x12L\n" +
+	"\x0eTriggerSuccess\x12\".transaction.TriggerSuccessRequest\x1a\x16.google.protobuf.Empty\x12R\n" +
+	"\x11ReconcileTriggers\x12%.transaction.ReconcileTriggersRequest\x1a\x16.google.protobuf.Empty\x12E\n" +
+	"\bLimitBuy\x12\x1e.transaction.LimitOrderRequest\x1a\x19.transaction.OrderIDReply\x12F\n" +
+	"\tLimitSell\x12\x1e.transaction.LimitOrderRequest\x1a\x19.transaction.OrderIDReply\x12D\n" +
+	"\aStopBuy\x12\x1e.transaction.LimitOrderRequest\x1a\x19.transaction.OrderIDReply\x12E\n" +
+	"\bStopSell\x12\x1e.transaction.LimitOrderRequest\x1a\x19.transaction.OrderIDReply\x12N\n" +
+	"\x0fTrailingStopBuy\x12 .transaction.TrailingStopRequest\x1a\x19.transaction.OrderIDReply\x12O\n" +
+	"\x10TrailingStopSell\x12 .transaction.TrailingStopRequest\x1a\x19.transaction.OrderIDReply\x12A\n" +
+	"\aOCOSell\x12\x1b.transaction.OCOSellRequest\x1a\x19.transaction.OrderIDReply\x12G\n" +
+	"\n" +
+	"BracketBuy\x12\x1e.transaction.BracketBuyRequest\x1a\x19.transaction.OrderIDReply\x12J\n" +
+	"\x0fListLimitOrders\x12\x18.transaction.UserRequest\x1a\x1d.transaction.LimitOrdersReply\x12O\n" +
+	"\x0fAmendLimitOrder\x12#.transaction.AmendLimitOrderRequest\x1a\x17.transaction.LimitOrder\x12L\n" +
+	"\x10CancelLimitOrder\x12 .transaction.LimitOrderIDRequest\x1a\x16.google.protobuf.Empty\x12>\n" +
+	"\aDumpLog\x12\x1b.transaction.DumpLogRequest\x1a\x16.google.protobuf.Empty\x12F\n" +
+	"\x0eDisplaySummary\x12\x18.transaction.UserRequest\x1a\x18.transaction.SummaryLine0\x01\x12A\n" +
+	"\aHistory\x12\x1b.transaction.HistoryRequest\x1a\x19.transaction.HistoryReply\x12>\n" +
+	"\aAccount\x12\x18.transaction.UserRequest\x1a\x19.transaction.AccountReply\x12L\n" +
+	"\fTriggerFills\x12 .transaction.TriggerFillsRequest\x1a\x18.transaction.TriggerFill0\x01B*Z(seng468/transaction-server/transactionpbb\x06proto3"
+
+var (
+	file_transaction_proto_rawDescOnce sync.Once
+	file_transaction_proto_rawDescData []byte
+)
+
+func file_transaction_proto_rawDescGZIP() []byte {
+	file_transaction_proto_rawDescOnce.Do(func() {
+		file_transaction_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_transaction_proto_rawDesc), len(file_transaction_proto_rawDesc)))
+	})
+	return file_transaction_proto_rawDescData
+}
+
+var file_transaction_proto_msgTypes = make([]protoimpl.MessageInfo, 32)
+var file_transaction_proto_goTypes = []any{
+	(*UserRequest)(nil),              // 0: transaction.UserRequest
+	(*CredentialsRequest)(nil),       // 1: transaction.CredentialsRequest
+	(*AddRequest)(nil),               // 2: transaction.AddRequest
+	(*StockRequest)(nil),             // 3: transaction.StockRequest
+	(*OrderRequest)(nil),             // 4: transaction.OrderRequest
+	(*QuoteReply)(nil),               // 5: transaction.QuoteReply
+	(*TriggerIDReply)(nil),           // 6: transaction.TriggerIDReply
+	(*TriggerSuccessRequest)(nil),    // 7: transaction.TriggerSuccessRequest
+	(*LimitOrderRequest)(nil),        // 8: transaction.LimitOrderRequest
+	(*TrailingStopRequest)(nil),      // 9: transaction.TrailingStopRequest
+	(*OCOSellRequest)(nil),           // 10: transaction.OCOSellRequest
+	(*BracketBuyRequest)(nil),        // 11: transaction.BracketBuyRequest
+	(*OrderIDReply)(nil),             // 12: transaction.OrderIDReply
+	(*LimitOrderIDRequest)(nil),      // 13: transaction.LimitOrderIDRequest
+	(*AmendLimitOrderRequest)(nil),   // 14: transaction.AmendLimitOrderRequest
+	(*LimitOrder)(nil),               // 15: transaction.LimitOrder
+	(*LimitOrdersReply)(nil),         // 16: transaction.LimitOrdersReply
+	(*ReconcileTriggersRequest)(nil), // 17: transaction.ReconcileTriggersRequest
+	(*DumpLogRequest)(nil),           // 18: transaction.DumpLogRequest
+	(*SummaryLine)(nil),              // 19: transaction.SummaryLine
+	(*HistoryRequest)(nil),           // 20: transaction.HistoryRequest
+	(*HistoryEntry)(nil),             // 21: transaction.HistoryEntry
+	(*HistoryReply)(nil),             // 22: transaction.HistoryReply
+	(*PendingOrder)(nil),             // 23: transaction.PendingOrder
+	(*AccountReply)(nil),             // 24: transaction.AccountReply
+	(*TriggerStatus)(nil),            // 25: transaction.TriggerStatus
+	(*TriggerFillsRequest)(nil),      // 26: transaction.TriggerFillsRequest
+	(*TriggerFill)(nil),              // 27: transaction.TriggerFill
+	nil,                              // 28: transaction.AccountReply.StocksEntry
+	nil,                              // 29: transaction.AccountReply.ReservedStocksEntry
+	nil,                              // 30: transaction.AccountReply.BuyTriggersEntry
+	nil,                              // 31: transaction.AccountReply.SellTriggersEntry
+	(*emptypb.Empty)(nil),            // 32: google.protobuf.Empty
+}
+var file_transaction_proto_depIdxs = []int32{
+	15, // 0: transaction.LimitOrdersReply.orders:type_name -> transaction.LimitOrder
+	21, // 1: transaction.HistoryReply.entries:type_name -> transaction.HistoryEntry
+	28, // 2: transaction.AccountReply.stocks:type_name -> transaction.AccountReply.StocksEntry
+	29, // 3: transaction.AccountReply.reserved_stocks:type_name -> transaction.AccountReply.ReservedStocksEntry
+	23, // 4: transaction.AccountReply.buy_orders:type_name -> transaction.PendingOrder
+	23, // 5: transaction.AccountReply.sell_orders:type_name -> transaction.PendingOrder
+	30, // 6: transaction.AccountReply.buy_triggers:type_name -> transaction.AccountReply.BuyTriggersEntry
+	31, // 7: transaction.AccountReply.sell_triggers:type_name -> transaction.AccountReply.SellTriggersEntry
+	21, // 8: transaction.AccountReply.history:type_name -> transaction.HistoryEntry
+	25, // 9: transaction.AccountReply.triggers:type_name -> transaction.TriggerStatus
+	1,  // 10: transaction.Transaction.Register:input_type -> transaction.CredentialsRequest
+	1,  // 11: transaction.Transaction.Authenticate:input_type -> transaction.CredentialsRequest
+	2,  // 12: transaction.Transaction.Add:input_type -> transaction.AddRequest
+	3,  // 13: transaction.Transaction.Quote:input_type -> transaction.StockRequest
+	4,  // 14: transaction.Transaction.Buy:input_type -> transaction.OrderRequest
+	0,  // 15: transaction.Transaction.CommitBuy:input_type -> transaction.UserRequest
+	0,  // 16: transaction.Transaction.CancelBuy:input_type -> transaction.UserRequest
+	4,  // 17: transaction.Transaction.Sell:input_type -> transaction.OrderRequest
+	0,  // 18: transaction.Transaction.CommitSell:input_type -> transaction.UserRequest
+	0,  // 19: transaction.Transaction.CancelSell:input_type -> transaction.UserRequest
+	4,  // 20: transaction.Transaction.SetBuyAmount:input_type -> transaction.OrderRequest
+	3,  // 21: transaction.Transaction.CancelSetBuy:input_type -> transaction.StockRequest
+	4,  // 22: transaction.Transaction.SetBuyTrigger:input_type -> transaction.OrderRequest
+	4,  // 23: transaction.Transaction.SetSellAmount:input_type -> transaction.OrderRequest
+	4,  // 24: transaction.Transaction.SetSellTrigger:input_type -> transaction.OrderRequest
+	3,  // 25: transaction.Transaction.CancelSetSell:input_type -> transaction.StockRequest
+	7,  // 26: transaction.Transaction.TriggerSuccess:input_type -> transaction.TriggerSuccessRequest
+	17, // 27: transaction.Transaction.ReconcileTriggers:input_type -> transaction.ReconcileTriggersRequest
+	8,  // 28: transaction.Transaction.LimitBuy:input_type -> transaction.LimitOrderRequest
+	8,  // 29: transaction.Transaction.LimitSell:input_type -> transaction.LimitOrderRequest
+	8,  // 30: transaction.Transaction.StopBuy:input_type -> transaction.LimitOrderRequest
+	8,  // 31: transaction.Transaction.StopSell:input_type -> transaction.LimitOrderRequest
+	9,  // 32: transaction.Transaction.TrailingStopBuy:input_type -> transaction.TrailingStopRequest
+	9,  // 33: transaction.Transaction.TrailingStopSell:input_type -> transaction.TrailingStopRequest
+	10, // 34: transaction.Transaction.OCOSell:input_type -> transaction.OCOSellRequest
+	11, // 35: transaction.Transaction.BracketBuy:input_type -> transaction.BracketBuyRequest
+	0,  // 36: transaction.Transaction.ListLimitOrders:input_type -> transaction.UserRequest
+	14, // 37: transaction.Transaction.AmendLimitOrder:input_type -> transaction.AmendLimitOrderRequest
+	13, // 38: transaction.Transaction.CancelLimitOrder:input_type -> transaction.LimitOrderIDRequest
+	18, // 39: transaction.Transaction.DumpLog:input_type -> transaction.DumpLogRequest
+	0,  // 40: transaction.Transaction.DisplaySummary:input_type -> transaction.UserRequest
+	20, // 41: transaction.Transaction.History:input_type -> transaction.HistoryRequest
+	0,  // 42: transaction.Transaction.Account:input_type -> transaction.UserRequest
+	26, // 43: transaction.Transaction.TriggerFills:input_type -> transaction.TriggerFillsRequest
+	32, // 44: transaction.Transaction.Register:output_type -> google.protobuf.Empty
+	32, // 45: transaction.Transaction.Authenticate:output_type -> google.protobuf.Empty
+	32, // 46: transaction.Transaction.Add:output_type -> google.protobuf.Empty
+	5,  // 47: transaction.Transaction.Quote:output_type -> transaction.QuoteReply
+	32, // 48: transaction.Transaction.Buy:output_type -> google.protobuf.Empty
+	32, // 49: transaction.Transaction.CommitBuy:output_type -> google.protobuf.Empty
+	32, // 50: transaction.Transaction.CancelBuy:output_type -> google.protobuf.Empty
+	32, // 51: transaction.Transaction.Sell:output_type -> google.protobuf.Empty
+	32, // 52: transaction.Transaction.CommitSell:output_type -> google.protobuf.Empty
+	32, // 53: transaction.Transaction.CancelSell:output_type -> google.protobuf.Empty
+	6,  // 54: transaction.Transaction.SetBuyAmount:output_type -> transaction.TriggerIDReply
+	32, // 55: transaction.Transaction.CancelSetBuy:output_type -> google.protobuf.Empty
+	32, // 56: transaction.Transaction.SetBuyTrigger:output_type -> google.protobuf.Empty
+	6,  // 57: transaction.Transaction.SetSellAmount:output_type -> transaction.TriggerIDReply
+	32, // 58: transaction.Transaction.SetSellTrigger:output_type -> google.protobuf.Empty
+	32, // 59: transaction.Transaction.CancelSetSell:output_type -> google.protobuf.Empty
+	32, // 60: transaction.Transaction.TriggerSuccess:output_type -> google.protobuf.Empty
+	32, // 61: transaction.Transaction.ReconcileTriggers:output_type -> google.protobuf.Empty
+	12, // 62: transaction.Transaction.LimitBuy:output_type -> transaction.OrderIDReply
+	12, // 63: transaction.Transaction.LimitSell:output_type -> transaction.OrderIDReply
+	12, // 64: transaction.Transaction.StopBuy:output_type -> transaction.OrderIDReply
+	12, // 65: transaction.Transaction.StopSell:output_type -> transaction.OrderIDReply
+	12, // 66: transaction.Transaction.TrailingStopBuy:output_type -> transaction.OrderIDReply
+	12, // 67: transaction.Transaction.TrailingStopSell:output_type -> transaction.OrderIDReply
+	12, // 68: transaction.Transaction.OCOSell:output_type -> transaction.OrderIDReply
+	12, // 69: transaction.Transaction.BracketBuy:output_type -> transaction.OrderIDReply
+	16, // 70: transaction.Transaction.ListLimitOrders:output_type -> transaction.LimitOrdersReply
+	15, // 71: transaction.Transaction.AmendLimitOrder:output_type -> transaction.LimitOrder
+	32, // 72: transaction.Transaction.CancelLimitOrder:output_type -> google.protobuf.Empty
+	32, // 73: transaction.Transaction.DumpLog:output_type -> google.protobuf.Empty
+	19, // 74: transaction.Transaction.DisplaySummary:output_type -> transaction.SummaryLine
+	22, // 75: transaction.Transaction.History:output_type -> transaction.HistoryReply
+	24, // 76: transaction.Transaction.Account:output_type -> transaction.AccountReply
+	27, // 77: transaction.Transaction.TriggerFills:output_type -> transaction.TriggerFill
+	44, // [44:78] is the sub-list for method output_type
+	10, // [10:44] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_transaction_proto_init() }
+func file_transaction_proto_init() {
+	if File_transaction_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_transaction_proto_rawDesc), len(file_transaction_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   32,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_transaction_proto_goTypes,
+		DependencyIndexes: file_transaction_proto_depIdxs,
+		MessageInfos:      file_transaction_proto_msgTypes,
+	}.Build()
+	File_transaction_proto = out.File
+	file_transaction_proto_goTypes = nil
+	file_transaction_proto_depIdxs = nil
+}
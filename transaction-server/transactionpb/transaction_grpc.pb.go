@@ -0,0 +1,1422 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: transaction.proto
+
+// The transaction server's gRPC interface. Each RPC dispatches to the same
+// TransactionServer method as the socket command of the same name.
+//
+// Failures are returned as gRPC statuses carrying a google.rpc.ErrorInfo
+// detail whose reason is the socket protocol's error code, such as
+// INSUFFICIENT_FUNDS or NO_PENDING_BUY.
+//
+// Regenerate with:
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//          --go-grpc_out=. --go-grpc_opt=paths=source_relative transaction.proto
+
+package transactionpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Transaction_Register_FullMethodName          = "/transaction.Transaction/Register"
+	Transaction_Authenticate_FullMethodName      = "/transaction.Transaction/Authenticate"
+	Transaction_Add_FullMethodName               = "/transaction.Transaction/Add"
+	Transaction_Quote_FullMethodName             = "/transaction.Transaction/Quote"
+	Transaction_Buy_FullMethodName               = "/transaction.Transaction/Buy"
+	Transaction_CommitBuy_FullMethodName         = "/transaction.Transaction/CommitBuy"
+	Transaction_CancelBuy_FullMethodName         = "/transaction.Transaction/CancelBuy"
+	Transaction_Sell_FullMethodName              = "/transaction.Transaction/Sell"
+	Transaction_CommitSell_FullMethodName        = "/transaction.Transaction/CommitSell"
+	Transaction_CancelSell_FullMethodName        = "/transaction.Transaction/CancelSell"
+	Transaction_SetBuyAmount_FullMethodName      = "/transaction.Transaction/SetBuyAmount"
+	Transaction_CancelSetBuy_FullMethodName      = "/transaction.Transaction/CancelSetBuy"
+	Transaction_SetBuyTrigger_FullMethodName     = "/transaction.Transaction/SetBuyTrigger"
+	Transaction_SetSellAmount_FullMethodName     = "/transaction.Transaction/SetSellAmount"
+	Transaction_SetSellTrigger_FullMethodName    = "/transaction.Transaction/SetSellTrigger"
+	Transaction_CancelSetSell_FullMethodName     = "/transaction.Transaction/CancelSetSell"
+	Transaction_TriggerSuccess_FullMethodName    = "/transaction.Transaction/TriggerSuccess"
+	Transaction_ReconcileTriggers_FullMethodName = "/transaction.Transaction/ReconcileTriggers"
+	Transaction_LimitBuy_FullMethodName          = "/transaction.Transaction/LimitBuy"
+	Transaction_LimitSell_FullMethodName         = "/transaction.Transaction/LimitSell"
+	Transaction_StopBuy_FullMethodName           = "/transaction.Transaction/StopBuy"
+	Transaction_StopSell_FullMethodName          = "/transaction.Transaction/StopSell"
+	Transaction_TrailingStopBuy_FullMethodName   = "/transaction.Transaction/TrailingStopBuy"
+	Transaction_TrailingStopSell_FullMethodName  = "/transaction.Transaction/TrailingStopSell"
+	Transaction_OCOSell_FullMethodName           = "/transaction.Transaction/OCOSell"
+	Transaction_BracketBuy_FullMethodName        = "/transaction.Transaction/BracketBuy"
+	Transaction_ListLimitOrders_FullMethodName   = "/transaction.Transaction/ListLimitOrders"
+	Transaction_AmendLimitOrder_FullMethodName   = "/transaction.Transaction/AmendLimitOrder"
+	Transaction_CancelLimitOrder_FullMethodName  = "/transaction.Transaction/CancelLimitOrder"
+	Transaction_DumpLog_FullMethodName           = "/transaction.Transaction/DumpLog"
+	Transaction_DisplaySummary_FullMethodName    = "/transaction.Transaction/DisplaySummary"
+	Transaction_History_FullMethodName           = "/transaction.Transaction/History"
+	Transaction_Account_FullMethodName           = "/transaction.Transaction/Account"
+	Transaction_TriggerFills_FullMethodName      = "/transaction.Transaction/TriggerFills"
+)
+
+// TransactionClient is the client API for Transaction service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TransactionClient interface {
+	Register(ctx context.Context, in *CredentialsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Authenticate fails with Unauthenticated for an unknown user or wrong password
+	Authenticate(ctx context.Context, in *CredentialsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Quote(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*QuoteReply, error)
+	Buy(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CommitBuy(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CancelBuy(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Sell(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CommitSell(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CancelSell(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// SetBuyAmount and SetSellAmount reply with the new trigger's ID, which the
+	// trigger_id of SetBuyTrigger, CancelSetBuy and so on picks it out by
+	SetBuyAmount(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*TriggerIDReply, error)
+	CancelSetBuy(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	SetBuyTrigger(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	SetSellAmount(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*TriggerIDReply, error)
+	SetSellTrigger(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	CancelSetSell(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	TriggerSuccess(ctx context.Context, in *TriggerSuccessRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ReconcileTriggers(ctx context.Context, in *ReconcileTriggersRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// LimitBuy and LimitSell reply with the new order's ID, which
+	// AmendLimitOrder and CancelLimitOrder pick it out by
+	LimitBuy(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	LimitSell(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	// Stop orders reply with their ID as limit orders do, and are listed,
+	// amended and cancelled along with them. Trailing stops can't be amended.
+	StopBuy(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	StopSell(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	TrailingStopBuy(ctx context.Context, in *TrailingStopRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	TrailingStopSell(ctx context.Context, in *TrailingStopRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	// OCOSell and BracketBuy reply with the group's ID, which is listed and
+	// cancelled as an order's is. Order groups can't be amended.
+	OCOSell(ctx context.Context, in *OCOSellRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	BracketBuy(ctx context.Context, in *BracketBuyRequest, opts ...grpc.CallOption) (*OrderIDReply, error)
+	ListLimitOrders(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*LimitOrdersReply, error)
+	// AmendLimitOrder replies with the order as amended
+	AmendLimitOrder(ctx context.Context, in *AmendLimitOrderRequest, opts ...grpc.CallOption) (*LimitOrder, error)
+	CancelLimitOrder(ctx context.Context, in *LimitOrderIDRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	DumpLog(ctx context.Context, in *DumpLogRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// DisplaySummary streams the user's summary one line at a time
+	DisplaySummary(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SummaryLine], error)
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error)
+	// Account returns the same information as DisplaySummary, structured
+	Account(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*AccountReply, error)
+	// TriggerFills streams triggers as the transaction server executes them,
+	// for one user or, if user is empty, for everyone
+	TriggerFills(ctx context.Context, in *TriggerFillsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TriggerFill], error)
+}
+
+type transactionClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransactionClient(cc grpc.ClientConnInterface) TransactionClient {
+	return &transactionClient{cc}
+}
+
+func (c *transactionClient) Register(ctx context.Context, in *CredentialsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Authenticate(ctx context.Context, in *CredentialsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_Authenticate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_Add_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Quote(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*QuoteReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QuoteReply)
+	err := c.cc.Invoke(ctx, Transaction_Quote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Buy(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_Buy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CommitBuy(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CommitBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CancelBuy(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CancelBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Sell(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_Sell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CommitSell(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CommitSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CancelSell(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CancelSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) SetBuyAmount(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*TriggerIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TriggerIDReply)
+	err := c.cc.Invoke(ctx, Transaction_SetBuyAmount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CancelSetBuy(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CancelSetBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) SetBuyTrigger(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_SetBuyTrigger_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) SetSellAmount(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*TriggerIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TriggerIDReply)
+	err := c.cc.Invoke(ctx, Transaction_SetSellAmount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) SetSellTrigger(ctx context.Context, in *OrderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_SetSellTrigger_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CancelSetSell(ctx context.Context, in *StockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CancelSetSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) TriggerSuccess(ctx context.Context, in *TriggerSuccessRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_TriggerSuccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) ReconcileTriggers(ctx context.Context, in *ReconcileTriggersRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_ReconcileTriggers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) LimitBuy(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_LimitBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) LimitSell(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_LimitSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) StopBuy(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_StopBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) StopSell(ctx context.Context, in *LimitOrderRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_StopSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) TrailingStopBuy(ctx context.Context, in *TrailingStopRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_TrailingStopBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) TrailingStopSell(ctx context.Context, in *TrailingStopRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_TrailingStopSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) OCOSell(ctx context.Context, in *OCOSellRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_OCOSell_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) BracketBuy(ctx context.Context, in *BracketBuyRequest, opts ...grpc.CallOption) (*OrderIDReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OrderIDReply)
+	err := c.cc.Invoke(ctx, Transaction_BracketBuy_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) ListLimitOrders(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*LimitOrdersReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LimitOrdersReply)
+	err := c.cc.Invoke(ctx, Transaction_ListLimitOrders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) AmendLimitOrder(ctx context.Context, in *AmendLimitOrderRequest, opts ...grpc.CallOption) (*LimitOrder, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LimitOrder)
+	err := c.cc.Invoke(ctx, Transaction_AmendLimitOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) CancelLimitOrder(ctx context.Context, in *LimitOrderIDRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_CancelLimitOrder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) DumpLog(ctx context.Context, in *DumpLogRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Transaction_DumpLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) DisplaySummary(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SummaryLine], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transaction_ServiceDesc.Streams[0], Transaction_DisplaySummary_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UserRequest, SummaryLine]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transaction_DisplaySummaryClient = grpc.ServerStreamingClient[SummaryLine]
+
+func (c *transactionClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HistoryReply)
+	err := c.cc.Invoke(ctx, Transaction_History_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) Account(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*AccountReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AccountReply)
+	err := c.cc.Invoke(ctx, Transaction_Account_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionClient) TriggerFills(ctx context.Context, in *TriggerFillsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TriggerFill], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Transaction_ServiceDesc.Streams[1], Transaction_TriggerFills_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TriggerFillsRequest, TriggerFill]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transaction_TriggerFillsClient = grpc.ServerStreamingClient[TriggerFill]
+
+// TransactionServer is the server API for Transaction service.
+// All implementations must embed UnimplementedTransactionServer
+// for forward compatibility.
+type TransactionServer interface {
+	Register(context.Context, *CredentialsRequest) (*emptypb.Empty, error)
+	// Authenticate fails with Unauthenticated for an unknown user or wrong password
+	Authenticate(context.Context, *CredentialsRequest) (*emptypb.Empty, error)
+	Add(context.Context, *AddRequest) (*emptypb.Empty, error)
+	Quote(context.Context, *StockRequest) (*QuoteReply, error)
+	Buy(context.Context, *OrderRequest) (*emptypb.Empty, error)
+	CommitBuy(context.Context, *UserRequest) (*emptypb.Empty, error)
+	CancelBuy(context.Context, *UserRequest) (*emptypb.Empty, error)
+	Sell(context.Context, *OrderRequest) (*emptypb.Empty, error)
+	CommitSell(context.Context, *UserRequest) (*emptypb.Empty, error)
+	CancelSell(context.Context, *UserRequest) (*emptypb.Empty, error)
+	// SetBuyAmount and SetSellAmount reply with the new trigger's ID, which the
+	// trigger_id of SetBuyTrigger, CancelSetBuy and so on picks it out by
+	SetBuyAmount(context.Context, *OrderRequest) (*TriggerIDReply, error)
+	CancelSetBuy(context.Context, *StockRequest) (*emptypb.Empty, error)
+	SetBuyTrigger(context.Context, *OrderRequest) (*emptypb.Empty, error)
+	SetSellAmount(context.Context, *OrderRequest) (*TriggerIDReply, error)
+	SetSellTrigger(context.Context, *OrderRequest) (*emptypb.Empty, error)
+	CancelSetSell(context.Context, *StockRequest) (*emptypb.Empty, error)
+	TriggerSuccess(context.Context, *TriggerSuccessRequest) (*emptypb.Empty, error)
+	ReconcileTriggers(context.Context, *ReconcileTriggersRequest) (*emptypb.Empty, error)
+	// LimitBuy and LimitSell reply with the new order's ID, which
+	// AmendLimitOrder and CancelLimitOrder pick it out by
+	LimitBuy(context.Context, *LimitOrderRequest) (*OrderIDReply, error)
+	LimitSell(context.Context, *LimitOrderRequest) (*OrderIDReply, error)
+	// Stop orders reply with their ID as limit orders do, and are listed,
+	// amended and cancelled along with them. Trailing stops can't be amended.
+	StopBuy(context.Context, *LimitOrderRequest) (*OrderIDReply, error)
+	StopSell(context.Context, *LimitOrderRequest) (*OrderIDReply, error)
+	TrailingStopBuy(context.Context, *TrailingStopRequest) (*OrderIDReply, error)
+	TrailingStopSell(context.Context, *TrailingStopRequest) (*OrderIDReply, error)
+	// OCOSell and BracketBuy reply with the group's ID, which is listed and
+	// cancelled as an order's is. Order groups can't be amended.
+	OCOSell(context.Context, *OCOSellRequest) (*OrderIDReply, error)
+	BracketBuy(context.Context, *BracketBuyRequest) (*OrderIDReply, error)
+	ListLimitOrders(context.Context, *UserRequest) (*LimitOrdersReply, error)
+	// AmendLimitOrder replies with the order as amended
+	AmendLimitOrder(context.Context, *AmendLimitOrderRequest) (*LimitOrder, error)
+	CancelLimitOrder(context.Context, *LimitOrderIDRequest) (*emptypb.Empty, error)
+	DumpLog(context.Context, *DumpLogRequest) (*emptypb.Empty, error)
+	// DisplaySummary streams the user's summary one line at a time
+	DisplaySummary(*UserRequest, grpc.ServerStreamingServer[SummaryLine]) error
+	History(context.Context, *HistoryRequest) (*HistoryReply, error)
+	// Account returns the same information as DisplaySummary, structured
+	Account(context.Context, *UserRequest) (*AccountReply, error)
+	// TriggerFills streams triggers as the transaction server executes them,
+	// for one user or, if user is empty, for everyone
+	TriggerFills(*TriggerFillsRequest, grpc.ServerStreamingServer[TriggerFill]) error
+	mustEmbedUnimplementedTransactionServer()
+}
+
+// UnimplementedTransactionServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTransactionServer struct{}
+
+func (UnimplementedTransactionServer) Register(context.Context, *CredentialsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedTransactionServer) Authenticate(context.Context, *CredentialsRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Authenticate not implemented")
+}
+func (UnimplementedTransactionServer) Add(context.Context, *AddRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedTransactionServer) Quote(context.Context, *StockRequest) (*QuoteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Quote not implemented")
+}
+func (UnimplementedTransactionServer) Buy(context.Context, *OrderRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Buy not implemented")
+}
+func (UnimplementedTransactionServer) CommitBuy(context.Context, *UserRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitBuy not implemented")
+}
+func (UnimplementedTransactionServer) CancelBuy(context.Context, *UserRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelBuy not implemented")
+}
+func (UnimplementedTransactionServer) Sell(context.Context, *OrderRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sell not implemented")
+}
+func (UnimplementedTransactionServer) CommitSell(context.Context, *UserRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitSell not implemented")
+}
+func (UnimplementedTransactionServer) CancelSell(context.Context, *UserRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelSell not implemented")
+}
+func (UnimplementedTransactionServer) SetBuyAmount(context.Context, *OrderRequest) (*TriggerIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetBuyAmount not implemented")
+}
+func (UnimplementedTransactionServer) CancelSetBuy(context.Context, *StockRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelSetBuy not implemented")
+}
+func (UnimplementedTransactionServer) SetBuyTrigger(context.Context, *OrderRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetBuyTrigger not implemented")
+}
+func (UnimplementedTransactionServer) SetSellAmount(context.Context, *OrderRequest) (*TriggerIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSellAmount not implemented")
+}
+func (UnimplementedTransactionServer) SetSellTrigger(context.Context, *OrderRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSellTrigger not implemented")
+}
+func (UnimplementedTransactionServer) CancelSetSell(context.Context, *StockRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelSetSell not implemented")
+}
+func (UnimplementedTransactionServer) TriggerSuccess(context.Context, *TriggerSuccessRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TriggerSuccess not implemented")
+}
+func (UnimplementedTransactionServer) ReconcileTriggers(context.Context, *ReconcileTriggersRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReconcileTriggers not implemented")
+}
+func (UnimplementedTransactionServer) LimitBuy(context.Context, *LimitOrderRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LimitBuy not implemented")
+}
+func (UnimplementedTransactionServer) LimitSell(context.Context, *LimitOrderRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LimitSell not implemented")
+}
+func (UnimplementedTransactionServer) StopBuy(context.Context, *LimitOrderRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopBuy not implemented")
+}
+func (UnimplementedTransactionServer) StopSell(context.Context, *LimitOrderRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopSell not implemented")
+}
+func (UnimplementedTransactionServer) TrailingStopBuy(context.Context, *TrailingStopRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TrailingStopBuy not implemented")
+}
+func (UnimplementedTransactionServer) TrailingStopSell(context.Context, *TrailingStopRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TrailingStopSell not implemented")
+}
+func (UnimplementedTransactionServer) OCOSell(context.Context, *OCOSellRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method OCOSell not implemented")
+}
+func (UnimplementedTransactionServer) BracketBuy(context.Context, *BracketBuyRequest) (*OrderIDReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BracketBuy not implemented")
+}
+func (UnimplementedTransactionServer) ListLimitOrders(context.Context, *UserRequest) (*LimitOrdersReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLimitOrders not implemented")
+}
+func (UnimplementedTransactionServer) AmendLimitOrder(context.Context, *AmendLimitOrderRequest) (*LimitOrder, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AmendLimitOrder not implemented")
+}
+func (UnimplementedTransactionServer) CancelLimitOrder(context.Context, *LimitOrderIDRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelLimitOrder not implemented")
+}
+func (UnimplementedTransactionServer) DumpLog(context.Context, *DumpLogRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DumpLog not implemented")
+}
+func (UnimplementedTransactionServer) DisplaySummary(*UserRequest, grpc.ServerStreamingServer[SummaryLine]) error {
+	return status.Errorf(codes.Unimplemented, "method DisplaySummary not implemented")
+}
+func (UnimplementedTransactionServer) History(context.Context, *HistoryRequest) (*HistoryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedTransactionServer) Account(context.Context, *UserRequest) (*AccountReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Account not implemented")
+}
+func (UnimplementedTransactionServer) TriggerFills(*TriggerFillsRequest, grpc.ServerStreamingServer[TriggerFill]) error {
+	return status.Errorf(codes.Unimplemented, "method TriggerFills not implemented")
+}
+func (UnimplementedTransactionServer) mustEmbedUnimplementedTransactionServer() {}
+func (UnimplementedTransactionServer) testEmbeddedByValue()                     {}
+
+// UnsafeTransactionServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransactionServer will
+// result in compilation errors.
+type UnsafeTransactionServer interface {
+	mustEmbedUnimplementedTransactionServer()
+}
+
+func RegisterTransactionServer(s grpc.ServiceRegistrar, srv TransactionServer) {
+	// If the following call pancis, it indicates UnimplementedTransactionServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Transaction_ServiceDesc, srv)
+}
+
+func _Transaction_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Register(ctx, req.(*CredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Authenticate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Authenticate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Authenticate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Authenticate(ctx, req.(*CredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Add_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Quote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Quote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Quote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Quote(ctx, req.(*StockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Buy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Buy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Buy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Buy(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CommitBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CommitBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CommitBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CommitBuy(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CancelBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CancelBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CancelBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CancelBuy(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Sell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Sell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Sell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Sell(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CommitSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CommitSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CommitSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CommitSell(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CancelSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CancelSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CancelSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CancelSell(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_SetBuyAmount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).SetBuyAmount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_SetBuyAmount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).SetBuyAmount(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CancelSetBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CancelSetBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CancelSetBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CancelSetBuy(ctx, req.(*StockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_SetBuyTrigger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).SetBuyTrigger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_SetBuyTrigger_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).SetBuyTrigger(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_SetSellAmount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).SetSellAmount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_SetSellAmount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).SetSellAmount(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_SetSellTrigger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).SetSellTrigger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_SetSellTrigger_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).SetSellTrigger(ctx, req.(*OrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CancelSetSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CancelSetSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CancelSetSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CancelSetSell(ctx, req.(*StockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_TriggerSuccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerSuccessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).TriggerSuccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_TriggerSuccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).TriggerSuccess(ctx, req.(*TriggerSuccessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_ReconcileTriggers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReconcileTriggersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).ReconcileTriggers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_ReconcileTriggers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).ReconcileTriggers(ctx, req.(*ReconcileTriggersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_LimitBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).LimitBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_LimitBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).LimitBuy(ctx, req.(*LimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_LimitSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).LimitSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_LimitSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).LimitSell(ctx, req.(*LimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_StopBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).StopBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_StopBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).StopBuy(ctx, req.(*LimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_StopSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).StopSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_StopSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).StopSell(ctx, req.(*LimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_TrailingStopBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrailingStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).TrailingStopBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_TrailingStopBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).TrailingStopBuy(ctx, req.(*TrailingStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_TrailingStopSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrailingStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).TrailingStopSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_TrailingStopSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).TrailingStopSell(ctx, req.(*TrailingStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_OCOSell_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OCOSellRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).OCOSell(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_OCOSell_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).OCOSell(ctx, req.(*OCOSellRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_BracketBuy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BracketBuyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).BracketBuy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_BracketBuy_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).BracketBuy(ctx, req.(*BracketBuyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_ListLimitOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).ListLimitOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_ListLimitOrders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).ListLimitOrders(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_AmendLimitOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AmendLimitOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).AmendLimitOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_AmendLimitOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).AmendLimitOrder(ctx, req.(*AmendLimitOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_CancelLimitOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LimitOrderIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).CancelLimitOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_CancelLimitOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).CancelLimitOrder(ctx, req.(*LimitOrderIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_DumpLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DumpLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).DumpLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_DumpLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).DumpLog(ctx, req.(*DumpLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_DisplaySummary_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UserRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransactionServer).DisplaySummary(m, &grpc.GenericServerStream[UserRequest, SummaryLine]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transaction_DisplaySummaryServer = grpc.ServerStreamingServer[SummaryLine]
+
+func _Transaction_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_History_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_Account_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServer).Account(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transaction_Account_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServer).Account(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transaction_TriggerFills_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TriggerFillsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TransactionServer).TriggerFills(m, &grpc.GenericServerStream[TriggerFillsRequest, TriggerFill]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Transaction_TriggerFillsServer = grpc.ServerStreamingServer[TriggerFill]
+
+// Transaction_ServiceDesc is the grpc.ServiceDesc for Transaction service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Transaction_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "transaction.Transaction",
+	HandlerType: (*TransactionServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Transaction_Register_Handler,
+		},
+		{
+			MethodName: "Authenticate",
+			Handler:    _Transaction_Authenticate_Handler,
+		},
+		{
+			MethodName: "Add",
+			Handler:    _Transaction_Add_Handler,
+		},
+		{
+			MethodName: "Quote",
+			Handler:    _Transaction_Quote_Handler,
+		},
+		{
+			MethodName: "Buy",
+			Handler:    _Transaction_Buy_Handler,
+		},
+		{
+			MethodName: "CommitBuy",
+			Handler:    _Transaction_CommitBuy_Handler,
+		},
+		{
+			MethodName: "CancelBuy",
+			Handler:    _Transaction_CancelBuy_Handler,
+		},
+		{
+			MethodName: "Sell",
+			Handler:    _Transaction_Sell_Handler,
+		},
+		{
+			MethodName: "CommitSell",
+			Handler:    _Transaction_CommitSell_Handler,
+		},
+		{
+			MethodName: "CancelSell",
+			Handler:    _Transaction_CancelSell_Handler,
+		},
+		{
+			MethodName: "SetBuyAmount",
+			Handler:    _Transaction_SetBuyAmount_Handler,
+		},
+		{
+			MethodName: "CancelSetBuy",
+			Handler:    _Transaction_CancelSetBuy_Handler,
+		},
+		{
+			MethodName: "SetBuyTrigger",
+			Handler:    _Transaction_SetBuyTrigger_Handler,
+		},
+		{
+			MethodName: "SetSellAmount",
+			Handler:    _Transaction_SetSellAmount_Handler,
+		},
+		{
+			MethodName: "SetSellTrigger",
+			Handler:    _Transaction_SetSellTrigger_Handler,
+		},
+		{
+			MethodName: "CancelSetSell",
+			Handler:    _Transaction_CancelSetSell_Handler,
+		},
+		{
+			MethodName: "TriggerSuccess",
+			Handler:    _Transaction_TriggerSuccess_Handler,
+		},
+		{
+			MethodName: "ReconcileTriggers",
+			Handler:    _Transaction_ReconcileTriggers_Handler,
+		},
+		{
+			MethodName: "LimitBuy",
+			Handler:    _Transaction_LimitBuy_Handler,
+		},
+		{
+			MethodName: "LimitSell",
+			Handler:    _Transaction_LimitSell_Handler,
+		},
+		{
+			MethodName: "StopBuy",
+			Handler:    _Transaction_StopBuy_Handler,
+		},
+		{
+			MethodName: "StopSell",
+			Handler:    _Transaction_StopSell_Handler,
+		},
+		{
+			MethodName: "TrailingStopBuy",
+			Handler:    _Transaction_TrailingStopBuy_Handler,
+		},
+		{
+			MethodName: "TrailingStopSell",
+			Handler:    _Transaction_TrailingStopSell_Handler,
+		},
+		{
+			MethodName: "OCOSell",
+			Handler:    _Transaction_OCOSell_Handler,
+		},
+		{
+			MethodName: "BracketBuy",
+			Handler:    _Transaction_BracketBuy_Handler,
+		},
+		{
+			MethodName: "ListLimitOrders",
+			Handler:    _Transaction_ListLimitOrders_Handler,
+		},
+		{
+			MethodName: "AmendLimitOrder",
+			Handler:    _Transaction_AmendLimitOrder_Handler,
+		},
+		{
+			MethodName: "CancelLimitOrder",
+			Handler:    _Transaction_CancelLimitOrder_Handler,
+		},
+		{
+			MethodName: "DumpLog",
+			Handler:    _Transaction_DumpLog_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Transaction_History_Handler,
+		},
+		{
+			MethodName: "Account",
+			Handler:    _Transaction_Account_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "DisplaySummary",
+			Handler:       _Transaction_DisplaySummary_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TriggerFills",
+			Handler:       _Transaction_TriggerFills_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "transaction.proto",
+}
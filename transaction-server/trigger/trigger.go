@@ -2,19 +2,80 @@ package triggerclient
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type Trigger struct {
+	id        string
 	username  string
 	stockname string
 	amount    decimal.Decimal
 	price     decimal.Decimal
 	action    string
+	trail     string
 	transNum  int
+	state     string
+	group     string
+	expires   time.Time
 }
 
+// Limit order actions. A limit order's amount is always in shares.
+const (
+	ActionLimitBuy  = "LIMIT_BUY"
+	ActionLimitSell = "LIMIT_SELL"
+)
+
+// Stop order actions, placed and cancelled the same way as limit orders.
+// A stop sell fires once the price falls to its price and a stop buy once it
+// rises to it. A trailing stop's price is the mark it starts from, which
+// follows the price by its trail, see NewTrailingStop.
+const (
+	ActionStopBuy          = "STOP_BUY"
+	ActionStopSell         = "STOP_SELL"
+	ActionTrailingStopBuy  = "TRAILING_STOP_BUY"
+	ActionTrailingStopSell = "TRAILING_STOP_SELL"
+)
+
+// IsLimitOrder reports whether action is one of the limit order actions
+func IsLimitOrder(action string) bool {
+	return action == ActionLimitBuy || action == ActionLimitSell
+}
+
+// IsStopOrder reports whether action is one of the stop or trailing stop actions
+func IsStopOrder(action string) bool {
+	return action == ActionStopBuy || action == ActionStopSell ||
+		action == ActionTrailingStopBuy || action == ActionTrailingStopSell
+}
+
+// Order group types. An OCO group sells shares at a take-profit or a stop-loss,
+// whichever the price reaches first. A bracket buys the shares at its entry
+// price first. Each leg is a limit or stop order whose ID is the group's ID
+// followed by the leg, and whose group is the group's ID, see PlaceOrderGroup.
+const (
+	GroupOCO     = "OCO"
+	GroupBracket = "BRACKET"
+)
+
+// IsOrderGroup reports whether action is that of an OCO group or bracket, such as OCO_SELL
+func IsOrderGroup(action string) bool {
+	return strings.HasPrefix(action, GroupOCO+"_") || strings.HasPrefix(action, GroupBracket+"_")
+}
+
+// IsOrder reports whether action is placed with an ID, as limit and stop orders are
+func IsOrder(action string) bool {
+	return IsLimitOrder(action) || IsStopOrder(action)
+}
+
+// Trigger states reported by the triggerserver
+const (
+	StateWaiting = "WAITING"
+	StateRunning = "RUNNING"
+	StateFiring  = "FIRING"
+)
+
 func (t Trigger) getPriceStr() string {
 	return t.price.String()
 }
@@ -40,8 +101,104 @@ func (t Trigger) GetPrice() decimal.Decimal {
 	return t.price
 }
 
-func newSellTrigger(transNum int, username string, stockname string, amount decimal.Decimal) Trigger {
+func (t Trigger) GetUsername() string {
+	return t.username
+}
+
+func (t Trigger) GetStock() string {
+	return t.stockname
+}
+
+func (t Trigger) GetAction() string {
+	return t.action
+}
+
+// GetID returns the id the triggerserver knows the trigger or limit order by,
+// which is also the id its reserve is held under
+func (t Trigger) GetID() string {
+	return t.id
+}
+
+// GetTrail returns a trailing stop's trail, a dollar amount or a percentage such as "5%"
+func (t Trigger) GetTrail() string {
+	return t.trail
+}
+
+// GetGroup returns the ID of the OCO group or bracket the order is a leg of, if any
+func (t Trigger) GetGroup() string {
+	return t.group
+}
+
+// GetState returns the state the trigger was listed in, see ListTriggers
+func (t Trigger) GetState() string {
+	return t.state
+}
+
+// WithState returns a copy of the trigger in the given state
+func (t Trigger) WithState(state string) Trigger {
+	t.state = state
+	return t
+}
+
+// GetExpiry returns when a running trigger expires, or the zero time if it doesn't
+func (t Trigger) GetExpiry() time.Time {
+	return t.expires
+}
+
+// WithExpiry returns a copy of the trigger that expires once it has run until expires
+func (t Trigger) WithExpiry(expires time.Time) Trigger {
+	t.expires = expires
+	return t
+}
+
+// WithGroup returns a copy of the trigger as a leg of the OCO group or bracket with id
+func (t Trigger) WithGroup(id string) Trigger {
+	t.group = id
+	return t
+}
+
+// NewTrigger builds a trigger from its parts, for TriggerFunctions
+// implementations that don't talk to the triggerserver
+func NewTrigger(transNum int, id string, username string, stockname string, amount decimal.Decimal,
+	price decimal.Decimal, action string) Trigger {
+	return Trigger{
+		transNum:  transNum,
+		id:        id,
+		username:  username,
+		stockname: stockname,
+		amount:    amount,
+		price:     price,
+		action:    action,
+	}
+}
+
+// NewLimitOrder builds a limit order to place with the triggerserver, id being
+// the id its reserve is held under
+func NewLimitOrder(transNum int, id string, username string, stockname string, shares int64,
+	price decimal.Decimal, action string) Trigger {
+	return Trigger{
+		transNum:  transNum,
+		id:        id,
+		username:  username,
+		stockname: stockname,
+		amount:    decimal.New(shares, 0),
+		price:     price,
+		action:    action,
+	}
+}
+
+// NewTrailingStop builds a trailing stop to place with the triggerserver,
+// starting from the mark and following the price by the trail
+func NewTrailingStop(transNum int, id string, username string, stockname string, shares int64,
+	mark decimal.Decimal, trail string, action string) Trigger {
+	order := NewLimitOrder(transNum, id, username, stockname, shares, mark, action)
+	order.trail = trail
+	return order
+}
+
+func newSellTrigger(transNum int, id string, username string, stockname string, amount decimal.Decimal) Trigger {
 	t := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stockname,
@@ -52,8 +209,9 @@ func newSellTrigger(transNum int, username string, stockname string, amount deci
 	return t
 }
 
-func newBuyTrigger(transNum int, username string, stockname string, amount decimal.Decimal) Trigger {
+func newBuyTrigger(transNum int, id string, username string, stockname string, amount decimal.Decimal) Trigger {
 	t := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stockname,
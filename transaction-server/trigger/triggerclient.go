@@ -1,38 +1,65 @@
 package triggerclient
 
 import (
+	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 const (
-	setEndpoint    = "/setTrigger"
-	startEndpoint  = "/startTrigger"
-	cancelEndpoint = "/cancelTrigger"
-	listEndpoint   = "/runningTriggers"
+	setEndpoint         = "/setTrigger"
+	startEndpoint       = "/startTrigger"
+	cancelEndpoint      = "/cancelTrigger"
+	listEndpoint        = "/runningTriggers"
+	triggersEndpoint    = "/triggers"
+	placeEndpoint       = "/placeLimitOrder"
+	amendEndpoint       = "/amendLimitOrder"
+	cancelLimitEndpoint = "/cancelLimitOrder"
+	placeGroupEndpoint  = "/placeOrderGroup"
+	cancelGroupEndpoint = "/cancelOrderGroup"
 )
 
-// TriggerFunctions are all of the functionality needed to support the trigger
+// Errors starting, amending or cancelling a trigger or limit order
+var (
+	ErrNoTrigger     = errors.New("the triggerserver has no such trigger or order")
+	ErrTriggerFired  = errors.New("the order has already fired")
+	ErrTriggerExists = errors.New("the triggerserver already has a trigger with that ID")
+)
+
+// TriggerFunctions are all of the functionality needed to support the trigger.
+// A user can have several triggers on a stock, each set under its own id.
+// Starting or cancelling a trigger with an empty id acts on the user's latest
+// trigger on the stock, and the trigger returned carries the id it acted on.
+// A trigger started with a non-zero expires expires once it has run until then.
 type TriggerFunctions interface {
-	SetNewSellTrigger(transNum int, username string, stock string, amount decimal.Decimal) error
+	SetNewSellTrigger(transNum int, id string, username string, stock string, amount int64) error
 	SetSellTrigger(transNum int, trig Trigger) error
-	StartSellTrigger(transNum int, trig Trigger) error
-	StartNewSellTrigger(transNum int, username string, stock string, price decimal.Decimal) error
-	CancelSellTrigger(transNum int, username string, stock string) (Trigger, error)
+	StartSellTrigger(transNum int, trig Trigger) (Trigger, error)
+	StartNewSellTrigger(transNum int, username string, stock string, price decimal.Decimal, id string,
+		expires time.Time) (Trigger, error)
+	CancelSellTrigger(transNum int, username string, stock string, id string) (Trigger, error)
 
-	SetNewBuyTrigger(transNum int, username string, stock string, amount decimal.Decimal) error
+	SetNewBuyTrigger(transNum int, id string, username string, stock string, amount decimal.Decimal) error
 	SetBuyTrigger(transNum int, trig Trigger) error
-	StartBuyTrigger(transNum int, trig Trigger) error
-	StartNewBuyTrigger(transNum int, username string, stock string, price decimal.Decimal) error
-	CancelBuyTrigger(transNum int, username string, stock string) (Trigger, error)
+	StartBuyTrigger(transNum int, trig Trigger) (Trigger, error)
+	StartNewBuyTrigger(transNum int, username string, stock string, price decimal.Decimal, id string,
+		expires time.Time) (Trigger, error)
+	CancelBuyTrigger(transNum int, username string, stock string, id string) (Trigger, error)
+
+	PlaceLimitOrder(transNum int, order Trigger) error
+	AmendLimitOrder(transNum int, order Trigger) (Trigger, error)
+	CancelLimitOrder(transNum int, username string, stock string, action string, id string) (Trigger, error)
+
+	PlaceOrderGroup(transNum int, group OrderGroup) ([]Trigger, error)
+	CancelOrderGroup(transNum int, username string, stock string, id string) ([]Trigger, error)
 
 	ListRunningTriggers()
+	ListTriggers() ([]Trigger, error)
 }
 
 // TriggerClient acts as an interface for the trigger server
@@ -40,9 +67,9 @@ type TriggerClient struct {
 	TriggerURL string
 }
 
-// SetNewSellTrigger adds a new sell trigger to the triggerserver
-func (tc TriggerClient) SetNewSellTrigger(transNum int, username string, stock string, amount int64) error {
-	trig := newSellTrigger(transNum, username, stock, decimal.New(amount, 0))
+// SetNewSellTrigger adds a new sell trigger to the triggerserver under id
+func (tc TriggerClient) SetNewSellTrigger(transNum int, id string, username string, stock string, amount int64) error {
+	trig := newSellTrigger(transNum, id, username, stock, decimal.New(amount, 0))
 	return tc.setTrigger(transNum, trig)
 }
 
@@ -57,20 +84,24 @@ func (tc TriggerClient) StartSellTrigger(transNum int, trig Trigger) (Trigger, e
 }
 
 // StartNewSellTrigger starts an existing sell trigger on the triggerserver
-func (tc TriggerClient) StartNewSellTrigger(transNum int, username string, stock string, price decimal.Decimal) (Trigger, error) {
+func (tc TriggerClient) StartNewSellTrigger(transNum int, username string, stock string, price decimal.Decimal,
+	id string, expires time.Time) (Trigger, error) {
 	trig := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stock,
 		price:     price,
 		action:    "SELL",
+		expires:   expires,
 	}
 	return tc.startTrigger(transNum, trig)
 }
 
 // CancelSellTrigger attempts to cancel an existing sell trigger on the server
-func (tc TriggerClient) CancelSellTrigger(transNum int, username string, stock string) (Trigger, error) {
+func (tc TriggerClient) CancelSellTrigger(transNum int, username string, stock string, id string) (Trigger, error) {
 	trig := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stock,
@@ -79,9 +110,10 @@ func (tc TriggerClient) CancelSellTrigger(transNum int, username string, stock s
 	return tc.cancelTrigger(transNum, trig)
 }
 
-// SetNewBuyTrigger adds a new sell trigger to the triggerserver
-func (tc TriggerClient) SetNewBuyTrigger(transNum int, username string, stock string, amount decimal.Decimal) error {
-	trig := newBuyTrigger(transNum, username, stock, amount)
+// SetNewBuyTrigger adds a new buy trigger to the triggerserver under id
+func (tc TriggerClient) SetNewBuyTrigger(transNum int, id string, username string, stock string,
+	amount decimal.Decimal) error {
+	trig := newBuyTrigger(transNum, id, username, stock, amount)
 	return tc.setTrigger(transNum, trig)
 }
 
@@ -96,20 +128,24 @@ func (tc TriggerClient) StartBuyTrigger(transNum int, trig Trigger) (Trigger, er
 }
 
 // StartNewBuyTrigger starts an existing Buy trigger on the triggerserver
-func (tc TriggerClient) StartNewBuyTrigger(transNum int, username string, stock string, price decimal.Decimal) (Trigger, error) {
+func (tc TriggerClient) StartNewBuyTrigger(transNum int, username string, stock string, price decimal.Decimal,
+	id string, expires time.Time) (Trigger, error) {
 	trig := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stock,
 		price:     price,
 		action:    "BUY",
+		expires:   expires,
 	}
 	return tc.startTrigger(transNum, trig)
 }
 
 // CancelBuyTrigger attempts to cancel an existing Buy trigger on the server
-func (tc TriggerClient) CancelBuyTrigger(transNum int, username string, stock string) (Trigger, error) {
+func (tc TriggerClient) CancelBuyTrigger(transNum int, username string, stock string, id string) (Trigger, error) {
 	trig := Trigger{
+		id:        id,
 		transNum:  transNum,
 		username:  username,
 		stockname: stock,
@@ -119,9 +155,11 @@ func (tc TriggerClient) CancelBuyTrigger(transNum int, username string, stock st
 }
 
 // setTrigger adds a new trigger to the triggerserver.
-// Action is either 'BUY' or 'SELL'
+// Action is either 'BUY' or 'SELL'. Returns ErrTriggerExists if the user
+// already has a trigger on the stock with the same ID.
 func (tc TriggerClient) setTrigger(transNum int, newTrigger Trigger) error {
 	values := url.Values{
+		"id":       {newTrigger.id},
 		"action":   {newTrigger.action},
 		"transnum": {strconv.Itoa(transNum)},
 		"username": {newTrigger.username},
@@ -129,97 +167,257 @@ func (tc TriggerClient) setTrigger(transNum int, newTrigger Trigger) error {
 		"amount":   {newTrigger.getAmountStr()},
 	}
 	resp, err := http.PostForm(tc.TriggerURL+setEndpoint, values)
-	defer resp.Body.Close()
 	if err != nil {
 		return err
 	}
-
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusConflict {
+		return ErrTriggerExists
+	} else if resp.StatusCode != http.StatusOK {
+		return errors.New("Bad response from the triggerserver: " + resp.Status)
+	}
 	return nil
 }
 
 // startTrigger starts an existing trigger on the triggerserver.
+// Returns ErrNoTrigger if there is no such waiting trigger.
 func (tc TriggerClient) startTrigger(transNum int, newTrigger Trigger) (Trigger, error) {
 	values := url.Values{
+		"id":       {newTrigger.id},
 		"action":   {newTrigger.action},
 		"transnum": {strconv.Itoa(transNum)},
 		"username": {newTrigger.username},
 		"stock":    {newTrigger.stockname},
 		"price":    {newTrigger.getPriceStr()},
 	}
-	resp, err := http.PostForm(tc.TriggerURL+startEndpoint, values) // TODO: verify BadRequest causes error
-	defer resp.Body.Close()
+	if !newTrigger.expires.IsZero() {
+		values.Set("expires", strconv.FormatInt(newTrigger.expires.UnixNano()/int64(time.Millisecond), 10))
+	}
+	resp, err := http.PostForm(tc.TriggerURL+startEndpoint, values)
 	if err != nil {
 		return Trigger{}, err
 	}
-
 	return tc.getTriggerFromResponse(resp)
 }
 
 // CancelTrigger cancels a running trigger on the triggerserver.
 // Action is either 'BUY' or 'SELL'
-// If the given trigger could not be found, returns an error.
-// Should return the cancelled triggers details
+// Returns ErrNoTrigger if the given trigger could not be found, or ErrTriggerFired
+// if it has already fired. Returns the cancelled trigger's details.
 func (tc TriggerClient) cancelTrigger(transNum int, cancel Trigger) (Trigger, error) {
 	values := url.Values{
+		"id":       {cancel.id},
 		"action":   {cancel.action},
 		"transnum": {strconv.Itoa(transNum)},
 		"username": {cancel.username},
 		"stock":    {cancel.stockname},
 	}
 	resp, err := http.PostForm(tc.TriggerURL+cancelEndpoint, values)
-	defer resp.Body.Close()
 	if err != nil {
 		return Trigger{}, err
 	}
+	return tc.getTriggerFromResponse(resp)
+}
 
+// PlaceLimitOrder starts a new limit or stop order on the triggerserver
+func (tc TriggerClient) PlaceLimitOrder(transNum int, order Trigger) error {
+	_, err := tc.postLimitOrder(placeEndpoint, transNum, order)
+	return err
+}
+
+// AmendLimitOrder replaces the shares and price of a running limit order.
+// Returns ErrNoTrigger if there is no such order, or ErrTriggerFired if it has already fired.
+func (tc TriggerClient) AmendLimitOrder(transNum int, order Trigger) (Trigger, error) {
+	return tc.postLimitOrder(amendEndpoint, transNum, order)
+}
+
+// CancelLimitOrder cancels a running limit order.
+// Returns ErrNoTrigger if there is no such order, or ErrTriggerFired if it has already fired.
+func (tc TriggerClient) CancelLimitOrder(transNum int, username string, stock string, action string,
+	id string) (Trigger, error) {
+	return tc.postLimitOrder(cancelLimitEndpoint, transNum,
+		Trigger{id: id, username: username, stockname: stock, action: action})
+}
+
+// postLimitOrder sends a limit or stop order to one of the limit order endpoints
+func (tc TriggerClient) postLimitOrder(endpoint string, transNum int, order Trigger) (Trigger, error) {
+	values := url.Values{
+		"id":       {order.id},
+		"action":   {order.action},
+		"transnum": {strconv.Itoa(transNum)},
+		"username": {order.username},
+		"stock":    {order.stockname},
+		"amount":   {order.getAmountStr()},
+		"price":    {order.getPriceStr()},
+	}
+	if order.trail != "" {
+		values.Set("trail", order.trail)
+	}
+	resp, err := http.PostForm(tc.TriggerURL+endpoint, values)
+	if err != nil {
+		return Trigger{}, err
+	}
 	return tc.getTriggerFromResponse(resp)
 }
 
+// OrderGroup is an OCO group or bracket of Shares, placed under the ID the
+// transaction server holds its reserve under. Price is a bracket's entry price.
+type OrderGroup struct {
+	ID         string
+	Type       string
+	User       string
+	Stock      string
+	Shares     int64
+	Price      decimal.Decimal
+	TakeProfit decimal.Decimal
+	StopLoss   decimal.Decimal
+}
+
+// PlaceOrderGroup starts an OCO group or bracket on the triggerserver, returning its legs
+func (tc TriggerClient) PlaceOrderGroup(transNum int, group OrderGroup) ([]Trigger, error) {
+	values := url.Values{
+		"id":         {group.ID},
+		"type":       {group.Type},
+		"transnum":   {strconv.Itoa(transNum)},
+		"username":   {group.User},
+		"stock":      {group.Stock},
+		"amount":     {strconv.FormatInt(group.Shares, 10)},
+		"takeProfit": {group.TakeProfit.String()},
+		"stopLoss":   {group.StopLoss.String()},
+	}
+	if group.Type == GroupBracket {
+		values.Set("price", group.Price.String())
+	}
+	resp, err := http.PostForm(tc.TriggerURL+placeGroupEndpoint, values)
+	if err != nil {
+		return nil, err
+	}
+	return tc.getTriggersFromResponse(resp)
+}
+
+// CancelOrderGroup cancels every leg of an OCO group or bracket, returning them.
+// Returns ErrNoTrigger if there is no such group, or ErrTriggerFired if a leg has already fired.
+func (tc TriggerClient) CancelOrderGroup(transNum int, username string, stock string, id string) ([]Trigger, error) {
+	values := url.Values{
+		"id":       {id},
+		"transnum": {strconv.Itoa(transNum)},
+		"username": {username},
+		"stock":    {stock},
+	}
+	resp, err := http.PostForm(tc.TriggerURL+cancelGroupEndpoint, values)
+	if err != nil {
+		return nil, err
+	}
+	return tc.getTriggersFromResponse(resp)
+}
+
 // ListRunningTriggers returns a list of all running triggers on the TriggerServer
 // TODO: something useful if needed
 func (tc TriggerClient) ListRunningTriggers() {
 	resp, err := http.Get(tc.TriggerURL + listEndpoint)
-	defer resp.Body.Close()
 	if err != nil {
 		panic(err)
 	}
+	resp.Body.Close()
 }
 
-func (tc TriggerClient) getTriggerFromResponse(resp *http.Response) (Trigger, error) {
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+// triggerRecord is a trigger as listed by the triggerserver's /triggers endpoint
+type triggerRecord struct {
+	ID       string          `json:"id"`
+	Action   string          `json:"action"`
+	Stock    string          `json:"stock"`
+	User     string          `json:"user"`
+	Amount   decimal.Decimal `json:"amount"`
+	Price    decimal.Decimal `json:"price"`
+	TransNum int             `json:"transNum"`
+	State    string          `json:"state"`
+	Trail    string          `json:"trail,omitempty"`
+	Group    string          `json:"group,omitempty"`
+	Expires  time.Time       `json:"expires"`
+}
+
+// ListTriggers returns every waiting and running trigger on the TriggerServer
+func (tc TriggerClient) ListTriggers() ([]Trigger, error) {
+	resp, err := http.Get(tc.TriggerURL + triggersEndpoint)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	resp.Body.Close()
-	bodyString := string(bodyBytes)
-	return tc.parseTriggerFromString(bodyString)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("Bad response listing triggers: " + resp.Status)
+	}
+
+	var records []triggerRecord
+	err = json.NewDecoder(resp.Body).Decode(&records)
+	if err != nil {
+		return nil, err
+	}
+
+	triggers := make([]Trigger, len(records))
+	for i, record := range records {
+		triggers[i] = record.trigger()
+	}
+	return triggers, nil
 }
 
-// "{%v %v %v %v %v}", t.username, t.stockname, t.getPriceStr(), t.amount, t.action
-func (tc TriggerClient) parseTriggerFromString(trigStr string) (Trigger, error) {
-	re := regexp.MustCompile(`{(\w+) (\w+) (\d+.\d+) (\d+.\d+) (\w+)}`)
-	matches := re.FindStringSubmatch(trigStr)
-	if len(matches) != 6 {
-		// These errors are OK -- they happen when an nonexistent trigger is cancelled
-		// TODO: revise nonexistent trigger handling
-		return Trigger{}, errors.New("Can't parse trigger from string")
+func (r triggerRecord) trigger() Trigger {
+	return Trigger{
+		id:        r.ID,
+		transNum:  r.TransNum,
+		username:  r.User,
+		stockname: r.Stock,
+		amount:    r.Amount,
+		price:     r.Price,
+		action:    r.Action,
+		state:     r.State,
+		trail:     r.Trail,
+		group:     r.Group,
+		expires:   r.Expires,
 	}
+}
 
-	price, err := decimal.NewFromString(matches[3])
-	if err != nil {
-		panic(err)
+// getTriggerFromResponse reads the trigger the triggerserver replied with, which
+// it sends as JSON in the same form /triggers lists it
+func (tc TriggerClient) getTriggerFromResponse(resp *http.Response) (Trigger, error) {
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return Trigger{}, err
 	}
-	amount, err := decimal.NewFromString(matches[4])
-	if err != nil {
-		panic(err)
+
+	var record triggerRecord
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return Trigger{}, err
 	}
+	return record.trigger(), nil
+}
 
-	trig := Trigger{
-		username:  matches[1],
-		stockname: matches[2],
-		price:     price,
-		amount:    amount,
-		action:    matches[5],
+// getTriggersFromResponse reads the list of triggers the triggerserver replied with
+func (tc TriggerClient) getTriggersFromResponse(resp *http.Response) ([]Trigger, error) {
+	defer resp.Body.Close()
+	if err := responseError(resp); err != nil {
+		return nil, err
+	}
+
+	var records []triggerRecord
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, err
+	}
+	triggers := make([]Trigger, len(records))
+	for i, record := range records {
+		triggers[i] = record.trigger()
+	}
+	return triggers, nil
+}
+
+// responseError maps the status the triggerserver replied with to an error
+func responseError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrNoTrigger
+	case http.StatusConflict:
+		return ErrTriggerFired
 	}
-	return trig, nil
+	return errors.New("Bad response from the triggerserver: " + resp.Status)
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/shopspring/decimal"
+)
+
+// eventsChannel is the redis channel the web servers take user events from
+const eventsChannel = "UserEvents"
+
+// eventBuffer is how many quotes can wait to be published before new ones are dropped
+const eventBuffer = 1000
+
+// quoteEvent is the transaction server's "quote" user event, which the web
+// servers push to everyone watching the stock
+type quoteEvent struct {
+	Type string `json:"type"`
+	Time int64  `json:"time"`
+	Data struct {
+		Stock string          `json:"stock"`
+		Price decimal.Decimal `json:"price"`
+	} `json:"data"`
+}
+
+// quoteEvents publishes the prices the watcher polls, so users watching a stock
+// see it move without quoting it themselves
+type quoteEvents struct {
+	pool    *redis.Pool
+	channel string
+	queue   chan quoteEvent
+}
+
+func newQuoteEvents(addr string, channel string) *quoteEvents {
+	return &quoteEvents{
+		pool: &redis.Pool{
+			MaxIdle: 1,
+			Dial:    func() (redis.Conn, error) { return redis.Dial("tcp", addr) },
+		},
+		channel: channel,
+		queue:   make(chan quoteEvent, eventBuffer),
+	}
+}
+
+// publish queues a quote without waiting on redis
+func (e *quoteEvents) publish(stock string, price decimal.Decimal) {
+	event := quoteEvent{Type: "quote", Time: time.Now().UnixNano() / int64(time.Millisecond)}
+	event.Data.Stock = stock
+	event.Data.Price = price
+	select {
+	case e.queue <- event:
+	default:
+	}
+}
+
+// run publishes queued quotes, forever
+func (e *quoteEvents) run() {
+	for event := range e.queue {
+		message, err := json.Marshal(event)
+		if err != nil {
+			fmt.Println("Error encoding quote event: ", err)
+			continue
+		}
+		c := e.pool.Get()
+		_, err = c.Do("PUBLISH", e.channel, message)
+		c.Close()
+		if err != nil {
+			fmt.Println("Error publishing quote event: ", err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// How long a trigger set with SET_*_AMOUNT may wait for its SET_*_TRIGGER before it
+// expires, unless the waitingtimeout environment variable gives another duration
+const defaultWaitingTimeout = time.Minute * 10
+
+// How often the sweeper looks for expired triggers
+const sweepInterval = time.Second * 5
+
+// waitingTimeout returns how long a waiting trigger lives, see defaultWaitingTimeout
+func waitingTimeout() time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv("waitingtimeout"))
+	if err != nil || timeout <= 0 {
+		return defaultWaitingTimeout
+	}
+	return timeout
+}
+
+// expiries tells the transaction server about expired triggers until it acknowledges them
+var expiries = newOutbox(sendTriggerExpired)
+
+// expiredBy reports whether the trigger has timed out by now. A waiting trigger
+// lives for timeout after it was set, and a running trigger until its expiry if
+// it was started with one. Limit and stop orders expire through the transaction server.
+func (t trigger) expiredBy(now time.Time, timeout time.Duration) bool {
+	if isOrder(t.action) {
+		return false
+	}
+	switch t.state() {
+	case stateWaiting:
+		return !t.created.Add(timeout).After(now)
+	case stateRunning:
+		return !t.expires.IsZero() && !t.expires.After(now)
+	}
+	return false
+}
+
+// sweepExpiredTriggers expires triggers that have timed out, checking once per interval
+func sweepExpiredTriggers(interval time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, t := range expireTriggers(now, timeout) {
+			go handleTriggerExpired(t)
+		}
+	}
+}
+
+// expireTriggers moves every trigger that has timed out by now to EXPIRED and
+// stops watching it, returning them. A trigger a quote is crossing at the same
+// moment either fires first or expires, never both. Expired triggers stay
+// persisted until the transaction server has released their reserve.
+func expireTriggers(now time.Time, timeout time.Duration) []trigger {
+	var expired []trigger
+	triggersLock.Lock()
+	defer triggersLock.Unlock()
+	for _, pool := range []map[triggersKey]trigger{waitingTriggers, runningTriggers} {
+		for key, t := range pool {
+			if !t.expiredBy(now, timeout) {
+				continue
+			}
+			from := t.state()
+			if !t.transition(from, stateExpired) {
+				continue
+			}
+			if err := store.Put(newTriggerRecord(t, stateExpired)); err != nil {
+				fmt.Println("Error persisting expired trigger: ", err)
+			}
+			if from == stateRunning {
+				watcher.Remove(t)
+			}
+			delete(pool, key)
+			expired = append(expired, t)
+		}
+	}
+	return expired
+}
+
+// handleTriggerExpired tells the transaction server the trigger expired, so it
+// returns the trigger's reserve, then forgets the trigger. An expiry the
+// transaction server refuses for good is persisted as FAILED instead, like a
+// trigger whose success is refused, see failTrigger.
+func handleTriggerExpired(t trigger) {
+	err := expiries.deliver(t)
+	if err != nil {
+		fmt.Println("Giving up on delivering expired trigger ", t, ": ", err)
+	}
+
+	triggersLock.Lock()
+	defer triggersLock.Unlock()
+	// A new trigger may have been set under the same key since
+	_, waiting := waitingTriggers[t.key()]
+	_, running := runningTriggers[t.key()]
+	if waiting || running {
+		return
+	}
+	if err != nil {
+		err = store.Put(newTriggerRecord(t, stateFailed))
+	} else {
+		err = store.Delete(t.key())
+	}
+	if err != nil {
+		fmt.Println("Error updating persisted trigger: ", err)
+	}
+}
+
+// sendTriggerExpired sends one TRIGGER_EXPIRED and returns the transaction server's reply
+func sendTriggerExpired(t trigger) (transactionResult, error) {
+	return sendToTransactionServer(t.transNum, t.getExpiredString())
+}
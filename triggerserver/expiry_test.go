@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestExpireTriggers(t *testing.T) {
+	useStore(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+
+	set := func(id string) url.Values {
+		values := url.Values{"id": {id}, "action": {"BUY"}, "transnum": {"1"}, "username": {"user1"},
+			"stock": {"ABC"}, "amount": {"50.00"}}
+		postForm(setTriggerHandler, values)
+		return values
+	}
+	unstarted := set("unstarted")
+	expiring := set("expiring")
+	lasting := set("lasting")
+	order := url.Values{"id": {"order1"}, "action": {"LIMIT_BUY"}, "transnum": {"2"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "price": {"5.00"}}
+	postForm(placeLimitOrderHandler, order)
+
+	now := time.Now()
+	expiring.Set("price", "10.00")
+	expiring.Set("expires", strconv.FormatInt(now.Add(-time.Minute).UnixNano()/int64(time.Millisecond), 10))
+	if status := postForm(startTriggerHandler, expiring); status != http.StatusBadRequest {
+		t.Error("Starting a trigger with a past expiry should be refused, replied ", status)
+	}
+	expiry := now.Add(time.Minute)
+	expiring.Set("expires", strconv.FormatInt(expiry.UnixNano()/int64(time.Millisecond), 10))
+	if status := postForm(startTriggerHandler, expiring); status != http.StatusOK {
+		t.Fatal("Starting a trigger with an expiry replied ", status)
+	}
+	lasting.Set("price", "10.00")
+	postForm(startTriggerHandler, lasting)
+
+	// Nothing has timed out yet
+	if expired := expireTriggers(now, time.Hour); len(expired) != 0 {
+		t.Error("Expected nothing to expire yet, got ", expired)
+	}
+
+	// The running trigger passes its expiry and the unstarted one its timeout,
+	// while the trigger with no expiry and the limit order keep running
+	expired := expireTriggers(expiry.Add(time.Second), time.Minute)
+	if len(expired) != 2 {
+		t.Fatal("Expected the unstarted and expiring triggers to expire, got ", expired)
+	}
+	for _, e := range expired {
+		if e.state() != stateExpired || (e.id != unstarted.Get("id") && e.id != expiring.Get("id")) {
+			t.Error("Unexpected expired trigger ", e.id, e.state())
+		}
+	}
+	records, _ := store.Load()
+	if len(records) != 4 {
+		t.Error("Expired triggers should stay persisted until delivered, got ", records)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(9.00))
+	select {
+	case f := <-fired:
+		if f.id != "lasting" {
+			t.Error("Only the trigger with no expiry should fire, got ", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The trigger with no expiry should fire")
+	}
+	if status := postForm(cancelTriggerHandler, unstarted); status != http.StatusNotFound {
+		t.Error("Cancelling an expired trigger should find nothing, replied ", status)
+	}
+
+	// The transaction server is told about each expiry before the trigger is forgotten
+	var delivered []string
+	old := expiries
+	expiries = newOutbox(func(t trigger) (transactionResult, error) {
+		delivered = append(delivered, t.getExpiredString())
+		return transactionResult{Status: 1}, nil
+	})
+	defer func() { expiries = old }()
+	for _, e := range expired {
+		handleTriggerExpired(e)
+	}
+	if len(delivered) != 2 || delivered[0] != "TRIGGER_EXPIRED,user1,ABC,BUY,"+expired[0].id {
+		t.Error("Unexpected expiries delivered ", delivered)
+	}
+	if records, _ := store.Load(); len(records) != 2 {
+		t.Error("Expected only the fired trigger and the order to stay persisted, got ", records)
+	}
+
+	triggersLock.Lock()
+	delete(runningTriggers, newTriggersKey("BUY", "ABC", "user1", "lasting"))
+	delete(runningTriggers, newTriggersKey("LIMIT_BUY", "ABC", "user1", "order1"))
+	triggersLock.Unlock()
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Order group types. An OCO group sells shares the transaction server holds
+// in reserve at a take-profit or a stop-loss, whichever the price reaches
+// first. A bracket first buys the shares at its entry price, then sells them
+// the same way.
+const (
+	groupOCO     = "OCO"
+	groupBracket = "BRACKET"
+)
+
+// Legs of an OCO group or bracket. Each leg is a limit or stop order whose id is
+// the group's id followed by the leg, such as "<id>.TP", and it fires with that id.
+const (
+	legEntry      = "ENTRY" // A bracket's LIMIT_BUY of the shares its exits sell
+	legTakeProfit = "TP"    // A LIMIT_SELL above the price
+	legStopLoss   = "SL"    // A STOP_SELL below the price
+)
+
+var errBadOrderGroup = errors.New("order group amounts and prices must be positive")
+
+var legActions = map[string]string{
+	legEntry:      "LIMIT_BUY",
+	legTakeProfit: "LIMIT_SELL",
+	legStopLoss:   "STOP_SELL",
+}
+
+// groupLegs lists the legs in the order they are cancelled, entry first
+var groupLegs = []string{legEntry, legTakeProfit, legStopLoss}
+
+func legID(group string, leg string) string {
+	return group + "." + leg
+}
+
+func legKey(stock string, user string, group string, leg string) triggersKey {
+	return newTriggersKey(legActions[leg], stock, user, legID(group, leg))
+}
+
+// leg returns which leg of its group the trigger is
+func (t trigger) leg() string {
+	return strings.TrimPrefix(t.id, t.group+".")
+}
+
+// isExit reports whether the trigger is the take-profit or stop-loss of a group
+func (t trigger) isExit() bool {
+	return t.group != "" && t.leg() != legEntry
+}
+
+// newOrderGroup returns the legs of a new OCO group or bracket. The take-profit
+// and stop-loss share one status, so whichever fires first leaves the other
+// unable to, and cancelling one cancels both. An OCO's exits run straight away,
+// while a bracket's wait for its entry to be delivered.
+func newOrderGroup(sls chan trigger, transNum int, groupType string, id string, username string, stockname string,
+	shares decimal.Decimal, price decimal.Decimal, takeProfit decimal.Decimal, stopLoss decimal.Decimal) []trigger {
+	exits := newTriggerStatus(stateRunning)
+	var legs []trigger
+	if groupType == groupBracket {
+		exits = newTriggerStatus(stateWaiting)
+		legs = append(legs, newLimitOrder(sls, transNum, legID(id, legEntry), username, stockname,
+			legActions[legEntry], shares, price))
+	}
+	tp := newLimitOrder(sls, transNum, legID(id, legTakeProfit), username, stockname,
+		legActions[legTakeProfit], shares, takeProfit)
+	sl := newLimitOrder(sls, transNum, legID(id, legStopLoss), username, stockname,
+		legActions[legStopLoss], shares, stopLoss)
+	tp.status, sl.status = exits, exits
+	legs = append(legs, tp, sl)
+	for i := range legs {
+		legs[i].group = id
+	}
+	return legs
+}
+
+// writeTriggers replies with the triggers as a JSON list, in the same form /triggers lists them
+func writeTriggers(w http.ResponseWriter, triggers []trigger) {
+	records := make([]triggerRecord, len(triggers))
+	for i, t := range triggers {
+		records[i] = newTriggerRecord(t, t.state())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
+
+// placeOrderGroupHandler places an OCO group or bracket under the id the transaction
+// server holds its reserve under, and replies with its legs. A bracket's price is
+// its entry price. Replies 409 if the id is already taken.
+func placeOrderGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	groupType := r.FormValue("type")
+	transnum, err := strconv.Atoi(r.FormValue("transnum"))
+	if err != nil || id == "" || (groupType != groupOCO && groupType != groupBracket) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	shares, price, takeProfit, stopLoss, ok := parseOrderGroup(r, groupType)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	legs := newOrderGroup(successListener, transnum, groupType, id, r.FormValue("username"), r.FormValue("stock"),
+		shares, price, takeProfit, stopLoss)
+	triggersLock.Lock()
+	for _, t := range legs {
+		_, waiting := waitingTriggers[t.key()]
+		_, running := runningTriggers[t.key()]
+		if waiting || running {
+			triggersLock.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	}
+	for i, t := range legs {
+		err = store.Put(newTriggerRecord(t, t.state()))
+		if err != nil {
+			for _, put := range legs[:i] {
+				store.Delete(put.key())
+			}
+			triggersLock.Unlock()
+			fmt.Println("Error persisting order group: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	for _, t := range legs {
+		if t.state() == stateWaiting {
+			waitingTriggers[t.key()] = t
+		} else {
+			runningTriggers[t.key()] = t
+		}
+	}
+	triggersLock.Unlock()
+
+	for _, t := range legs {
+		if t.state() == stateRunning {
+			watcher.Add(t)
+		}
+	}
+	writeTriggers(w, legs)
+}
+
+// parseOrderGroup reads the shares and prices of an order group request. The
+// stop-loss must be below the take-profit, and a bracket's entry between them.
+func parseOrderGroup(r *http.Request, groupType string) (shares decimal.Decimal, price decimal.Decimal,
+	takeProfit decimal.Decimal, stopLoss decimal.Decimal, ok bool) {
+	var err error
+	parse := func(field string) decimal.Decimal {
+		var d decimal.Decimal
+		if err == nil {
+			d, err = decimal.NewFromString(r.FormValue(field))
+		}
+		if err == nil && !d.IsPositive() {
+			err = errBadOrderGroup
+		}
+		return d
+	}
+	shares = parse("amount")
+	takeProfit = parse("takeProfit")
+	stopLoss = parse("stopLoss")
+	if groupType == groupBracket {
+		price = parse("price")
+	}
+	if err != nil || !stopLoss.LessThan(takeProfit) {
+		return shares, price, takeProfit, stopLoss, false
+	}
+	if groupType == groupBracket && (!stopLoss.LessThan(price) || !price.LessThan(takeProfit)) {
+		return shares, price, takeProfit, stopLoss, false
+	}
+	return shares, price, takeProfit, stopLoss, true
+}
+
+// cancelOrderGroupHandler cancels every leg of an OCO group or bracket and replies
+// with them. Replies 404 if there is no such group and 409 if a leg has already fired.
+func cancelOrderGroupHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	triggersLock.Lock()
+	legs, err := cancelGroup(r.FormValue("stock"), r.FormValue("username"), id)
+	if err == errTriggerFired {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for _, t := range legs {
+		if err = store.Delete(t.key()); err != nil {
+			fmt.Println("Error removing persisted trigger: ", err)
+		}
+	}
+	triggersLock.Unlock()
+	writeTriggers(w, legs)
+}
+
+// cancelGroup moves every leg of the group with id to CANCELLED, entry first, and
+// stops watching them. The exits share a status, so they are cancelled together.
+// The caller must hold triggersLock.
+func cancelGroup(stock string, user string, id string) ([]trigger, error) {
+	var legs []trigger
+	for _, leg := range groupLegs {
+		key := legKey(stock, user, id, leg)
+		if t, ok := runningTriggers[key]; ok {
+			legs = append(legs, t)
+		} else if t, ok := waitingTriggers[key]; ok {
+			legs = append(legs, t)
+		}
+	}
+	if len(legs) == 0 {
+		return nil, errNoTrigger
+	}
+	for i, t := range legs {
+		if i > 0 && t.sameAs(legs[i-1]) {
+			continue
+		}
+		if !t.transition(stateRunning, stateCancelled) && !t.transition(stateWaiting, stateCancelled) {
+			return nil, errTriggerFired
+		}
+	}
+	for _, t := range legs {
+		watcher.Remove(t)
+		delete(runningTriggers, t.key())
+		delete(waitingTriggers, t.key())
+	}
+	return legs, nil
+}
+
+// isGroupLeg reports whether the trigger with key is a leg of an OCO group or
+// bracket, which are only cancelled together. The caller must hold triggersLock.
+func isGroupLeg(key triggersKey) bool {
+	if t, ok := runningTriggers[key]; ok {
+		return t.group != ""
+	}
+	t, ok := waitingTriggers[key]
+	return ok && t.group != ""
+}
+
+// legDelivered settles the rest of a group once one of its legs has been delivered
+// to the transaction server. A bracket's exits start running once its entry has
+// bought their shares, and are returned to be watched. Once an exit has fired the
+// other exit is removed. The caller must hold triggersLock.
+func legDelivered(t trigger) []trigger {
+	var started []trigger
+	for _, leg := range []string{legTakeProfit, legStopLoss} {
+		key := legKey(t.stockname, t.username, t.group, leg)
+		if key == t.key() {
+			continue
+		}
+		if t.leg() == legEntry {
+			exit, ok := waitingTriggers[key]
+			if !ok {
+				continue
+			}
+			// The exits share a status, so only the first of them moves it
+			exit.transition(stateWaiting, stateRunning)
+			if err := store.Put(newTriggerRecord(exit, stateRunning)); err != nil {
+				fmt.Println("Error persisting bracket exit: ", err)
+			}
+			delete(waitingTriggers, key)
+			runningTriggers[key] = exit
+			started = append(started, exit)
+		} else if exit, ok := runningTriggers[key]; ok && exit.sameAs(t) {
+			watcher.Remove(exit)
+			delete(runningTriggers, key)
+			if err := store.Delete(key); err != nil {
+				fmt.Println("Error removing persisted trigger: ", err)
+			}
+		}
+	}
+	return started
+}
+
+// relinkGroups gives the restored exits of each group their shared status again.
+// If one exit was firing when the server stopped, the other can no longer fire,
+// so it is dropped and its record removed.
+func relinkGroups(records []triggerRecord, triggers []trigger) []trigger {
+	firing := make(map[string]bool)
+	for i, record := range records {
+		if triggers[i].isExit() && record.State == stateFiring {
+			firing[record.Group] = true
+		}
+	}
+
+	statuses := make(map[string]*triggerStatus)
+	relinked := triggers[:0]
+	for i, t := range triggers {
+		if !t.isExit() {
+			relinked = append(relinked, t)
+			continue
+		}
+		if firing[t.group] && records[i].State != stateFiring {
+			if err := store.Delete(records[i].key()); err != nil {
+				fmt.Println("Error removing persisted trigger: ", err)
+			}
+			continue
+		}
+		if status, ok := statuses[t.group]; ok {
+			t.status = status
+		} else {
+			statuses[t.group] = t.status
+		}
+		relinked = append(relinked, t)
+	}
+	return relinked
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Delays between attempts to deliver a fired trigger, doubling after every failure
+const (
+	minDeliveryBackoff = time.Second / 2
+	maxDeliveryBackoff = time.Minute * 5
+)
+
+// outbox delivers fired triggers to the transaction server. Triggers are persisted
+// as FIRING before they are handed to the outbox, and each one is resent with
+// exponential backoff while the transaction server can't be reached or reports a
+// service it needs, such as the database, as unavailable. The transaction server
+// ignores a trigger ID it has already executed, so resending after a lost reply or
+// a restart never applies a trigger twice. Any other error reply would only be repeated, so it ends delivery.
+type outbox struct {
+	send       func(t trigger) (transactionResult, error)
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+func newOutbox(send func(t trigger) (transactionResult, error)) *outbox {
+	return &outbox{
+		send:       send,
+		minBackoff: minDeliveryBackoff,
+		maxBackoff: maxDeliveryBackoff,
+	}
+}
+
+// deliver sends t until the transaction server acknowledges it. Returns an
+// error if the transaction server refused t in a way resending can't fix.
+func (o *outbox) deliver(t trigger) error {
+	backoff := o.minBackoff
+	for {
+		reply, err := o.send(t)
+		if err == nil && reply.succeeded() {
+			return nil
+		} else if err == nil && !reply.retryable() {
+			return fmt.Errorf("transaction server replied %s", reply)
+		}
+		if err != nil {
+			fmt.Println("Error delivering trigger ", t, ", retrying in ", backoff, ": ", err)
+		} else {
+			fmt.Println("Transaction server replied ", reply, " to trigger ", t, ", retrying in ", backoff)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > o.maxBackoff {
+			backoff = o.maxBackoff
+		}
+	}
+}
+
+// sendTriggerSuccess sends one TRIGGER_SUCCESS and returns the transaction server's reply
+func sendTriggerSuccess(t trigger) (transactionResult, error) {
+	return sendToTransactionServer(t.transNum, t.getSuccessString())
+}
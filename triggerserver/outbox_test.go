@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOutboxRetriesUntilAcknowledged(t *testing.T) {
+	replies := []int{0, 0, -1, 1}
+	var sent []string
+	var sentAt []time.Time
+	o := newOutbox(func(t trigger) (transactionResult, error) {
+		status := replies[len(sent)]
+		sent = append(sent, t.getSuccessString())
+		sentAt = append(sentAt, time.Now())
+		if status == 0 {
+			return transactionResult{}, errors.New("connection refused")
+		}
+		return transactionResult{Status: status, Code: "QUOTE_UNAVAILABLE"}, nil
+	})
+	o.minBackoff = time.Millisecond * 5
+	o.maxBackoff = time.Millisecond * 10
+
+	trig := newBuyTrigger(nil, 1, "user1", "ABC", decimal.New(10, 0))
+	if err := o.deliver(trig); err != nil {
+		t.Fatal("Delivery should succeed once acknowledged, got ", err)
+	}
+
+	if len(sent) != len(replies) {
+		t.Fatal("Expected ", len(replies), " attempts, made ", len(sent))
+	}
+	for _, s := range sent {
+		if s != sent[0] {
+			t.Error("Every attempt should resend the same trigger, got ", s, " and ", sent[0])
+		}
+	}
+	// Backoff doubles from 5ms and is capped at 10ms
+	for i, min := range []time.Duration{5, 10, 10} {
+		if gap := sentAt[i+1].Sub(sentAt[i]); gap < min*time.Millisecond {
+			t.Error("Attempt ", i+2, " came ", gap, " after the last, expected at least ", min, "ms")
+		}
+	}
+}
+
+func TestOutboxGivesUpOnRefusal(t *testing.T) {
+	attempts := 0
+	o := newOutbox(func(t trigger) (transactionResult, error) {
+		attempts++
+		return transactionResult{Status: -1, Code: "INSUFFICIENT_RESERVE"}, nil
+	})
+	o.minBackoff = time.Millisecond
+
+	if err := o.deliver(newBuyTrigger(nil, 1, "user1", "ABC", decimal.New(10, 0))); err == nil {
+		t.Error("A refused trigger should not be delivered")
+	}
+	if attempts != 1 {
+		t.Error("A refused trigger should not be resent, sent ", attempts, " times")
+	}
+}
+
+func TestOutboxRetriesDatabaseUnavailable(t *testing.T) {
+	attempts := 0
+	o := newOutbox(func(t trigger) (transactionResult, error) {
+		attempts++
+		if attempts == 1 {
+			return transactionResult{Status: -1, Code: "DATABASE_UNAVAILABLE"}, nil
+		}
+		return transactionResult{Status: 1}, nil
+	})
+	o.minBackoff = time.Millisecond
+
+	if err := o.deliver(newBuyTrigger(nil, 1, "user1", "ABC", decimal.New(10, 0))); err != nil {
+		t.Error("A trigger should be delivered once the database is back, got ", err)
+	}
+	if attempts != 2 {
+		t.Error("Expected the trigger to be resent once, sent ", attempts, " times")
+	}
+}
+
+func TestFailedDelivery(t *testing.T) {
+	useStore(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+	old := deliveries
+	deliveries = newOutbox(func(t trigger) (transactionResult, error) {
+		return transactionResult{Status: -1, Code: "BAD_REQUEST", Message: "Trigger price must be at least a cent"}, nil
+	})
+	defer func() { deliveries = old }()
+
+	order := url.Values{"id": {"order1"}, "action": {"LIMIT_BUY"}, "transnum": {"1"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "price": {"10.00"}}
+	if status := postForm(placeLimitOrderHandler, order); status != http.StatusOK {
+		t.Fatal("Placing a limit order replied ", status)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(9.00))
+	var f trigger
+	select {
+	case f = <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("The order should fire")
+	}
+	handleTriggerSuccess(f)
+
+	if f.state() != stateFailed {
+		t.Error("A refused trigger should end up FAILED, is ", f.state())
+	}
+	records, _ := store.Load()
+	if len(records) != 1 || records[0].State != stateFailed {
+		t.Error("A refused trigger should stay persisted as FAILED, got ", records)
+	}
+	triggersLock.Lock()
+	_, running := runningTriggers[f.key()]
+	triggersLock.Unlock()
+	if running {
+		t.Error("A refused trigger should no longer be listed")
+	}
+}
+
+func TestSuccessStringHasTriggerID(t *testing.T) {
+	trig := newSellTrigger(nil, 1, "user1", "ABC", decimal.New(4, 0))
+	trig.price = decimal.NewFromFloat(30.00)
+	expected := "TRIGGER_SUCCESS,user1,ABC,30,4,SELL," + trig.id
+	if trig.getSuccessString() != expected {
+		t.Error("Expected ", expected, ", got ", trig.getSuccessString())
+	}
+
+	restored := newTriggerRecord(trig, stateFiring).trigger(nil)
+	if restored.id != trig.id {
+		t.Error("A restored trigger must keep its ID, got ", restored.id, " for ", trig.id)
+	}
+}
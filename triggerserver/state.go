@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// triggerState is where a trigger is in its life. A trigger only moves forward:
+//
+//	WAITING -> RUNNING -> FIRING -> FIRED
+//
+// and a WAITING or RUNNING trigger can instead end up CANCELLED or EXPIRED.
+// FIRING means a quote crossed the trigger price and the transaction server
+// has not yet been told, so the trigger can no longer be cancelled. A FIRING
+// trigger the transaction server refuses for good ends up FAILED instead of FIRED.
+type triggerState int32
+
+const (
+	stateWaiting triggerState = iota
+	stateRunning
+	stateFiring
+	stateFired
+	stateCancelled
+	stateExpired
+	stateFailed
+)
+
+var stateNames = [...]string{"WAITING", "RUNNING", "FIRING", "FIRED", "CANCELLED", "EXPIRED", "FAILED"}
+
+// transitions lists the states each state can move to
+var transitions = map[triggerState][]triggerState{
+	stateWaiting: {stateRunning, stateCancelled, stateExpired},
+	stateRunning: {stateFiring, stateCancelled, stateExpired},
+	stateFiring:  {stateFired, stateFailed},
+}
+
+func (s triggerState) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("triggerState(%d)", int32(s))
+	}
+	return stateNames[s]
+}
+
+// MarshalText stores states by name, so persisted triggers and /triggers read "WAITING" or "RUNNING"
+func (s triggerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *triggerState) UnmarshalText(text []byte) error {
+	for i, name := range stateNames {
+		if name == string(text) {
+			*s = triggerState(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown trigger state %q", text)
+}
+
+// canTransition reports whether a trigger in state from may move to state to
+func canTransition(from triggerState, to triggerState) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// triggerStatus holds the state of one trigger. Copies of a trigger share it,
+// so whichever of a cancel and a fire moves the trigger out of RUNNING first wins
+// and the other sees the transition fail.
+type triggerStatus struct {
+	state int32
+}
+
+func newTriggerStatus(state triggerState) *triggerStatus {
+	return &triggerStatus{state: int32(state)}
+}
+
+func (s *triggerStatus) get() triggerState {
+	return triggerState(atomic.LoadInt32(&s.state))
+}
+
+// transition atomically moves the trigger from one state to another.
+// Returns false if the trigger is not in from, or the move is not allowed.
+func (s *triggerStatus) transition(from triggerState, to triggerState) bool {
+	if !canTransition(from, to) {
+		return false
+	}
+	return atomic.CompareAndSwapInt32(&s.state, int32(from), int32(to))
+}
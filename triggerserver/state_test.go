@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTriggerStatus(t *testing.T) {
+	s := newTriggerStatus(stateWaiting)
+	if s.transition(stateWaiting, stateFiring) {
+		t.Error("A waiting trigger can't fire")
+	}
+	if !s.transition(stateWaiting, stateRunning) {
+		t.Error("A waiting trigger should start")
+	}
+	if s.transition(stateWaiting, stateCancelled) {
+		t.Error("Transition should fail from the wrong state")
+	}
+	if !s.transition(stateRunning, stateFiring) {
+		t.Error("A running trigger should fire")
+	}
+	if s.transition(stateFiring, stateCancelled) {
+		t.Error("A firing trigger can't be cancelled")
+	}
+	if !s.transition(stateFiring, stateFired) {
+		t.Error("A firing trigger should finish firing")
+	}
+	if s.get() != stateFired {
+		t.Error("Expected FIRED, got ", s.get())
+	}
+
+	failed := newTriggerStatus(stateFiring)
+	if !failed.transition(stateFiring, stateFailed) {
+		t.Error("A firing trigger should be able to fail")
+	}
+
+	for _, final := range []triggerState{stateFired, stateCancelled, stateExpired, stateFailed} {
+		for next := stateWaiting; next <= stateFailed; next++ {
+			if canTransition(final, next) {
+				t.Error(final, " should be final, can move to ", next)
+			}
+		}
+	}
+}
+
+func TestTriggerStateJSON(t *testing.T) {
+	for state := stateWaiting; state <= stateFailed; state++ {
+		encoded, err := json.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != `"`+state.String()+`"` {
+			t.Error("Expected ", state, " to encode by name, got ", string(encoded))
+		}
+		var decoded triggerState
+		if err = json.Unmarshal(encoded, &decoded); err != nil || decoded != state {
+			t.Error("Expected ", state, " to decode, got ", decoded, err)
+		}
+	}
+
+	var decoded triggerState
+	if json.Unmarshal([]byte(`"DONE"`), &decoded) == nil {
+		t.Error("Unknown states should not decode")
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// triggerStore persists triggers so they survive a restart of the trigger server
+type triggerStore interface {
+	Load() ([]triggerRecord, error)
+	Put(record triggerRecord) error
+	Delete(key triggersKey) error
+}
+
+// triggerRecord is the persisted form of a trigger, also used by the /triggers endpoint
+type triggerRecord struct {
+	ID       string          `json:"id"`
+	Action   string          `json:"action"`
+	Stock    string          `json:"stock"`
+	User     string          `json:"user"`
+	Amount   decimal.Decimal `json:"amount"`
+	Price    decimal.Decimal `json:"price"`
+	TransNum int             `json:"transNum"`
+	State    triggerState    `json:"state"`
+	Created  time.Time       `json:"created"`
+
+	// Trailing stops keep their trail and the mark it follows, see trailingStop
+	Trail string           `json:"trail,omitempty"`
+	Mark  *decimal.Decimal `json:"mark,omitempty"`
+
+	// Group is the id of the OCO group or bracket the trigger is a leg of
+	Group string `json:"group,omitempty"`
+
+	// Expires is when a running trigger expires, and zero if it doesn't
+	Expires time.Time `json:"expires"`
+}
+
+func newTriggerRecord(t trigger, state triggerState) triggerRecord {
+	record := triggerRecord{
+		ID:       t.id,
+		Action:   t.action,
+		Stock:    t.stockname,
+		User:     t.username,
+		Amount:   t.amount,
+		Price:    t.price,
+		TransNum: t.transNum,
+		State:    state,
+		Created:  t.created,
+		Group:    t.group,
+		Expires:  t.expires,
+	}
+	if t.trail != nil {
+		mark := t.trail.getMark()
+		record.Trail = t.trail.trail()
+		record.Mark = &mark
+	}
+	return record
+}
+
+func (r triggerRecord) key() triggersKey {
+	return newTriggersKey(r.Action, r.Stock, r.User, r.ID)
+}
+
+func (r triggerRecord) trigger(sls chan trigger) trigger {
+	// Triggers persisted before they had IDs get a new one
+	id := r.ID
+	if id == "" {
+		id = newTriggerID()
+	}
+	t := trigger{
+		id:              id,
+		transNum:        r.TransNum,
+		username:        r.User,
+		stockname:       r.Stock,
+		amount:          r.Amount,
+		price:           r.Price,
+		action:          r.Action,
+		successListener: sls,
+		status:          newTriggerStatus(r.State),
+		created:         r.Created,
+		group:           r.Group,
+		expires:         r.Expires,
+	}
+	if r.Mark != nil {
+		// The trail was checked when the order was placed
+		t.trail, _ = newTrailingStop(r.Action, r.Trail, *r.Mark)
+	}
+	return t
+}
+
+const (
+	snapshotFile = "triggers.snapshot"
+	logFile      = "triggers.log"
+
+	// Number of logged changes before the log is folded into a new snapshot
+	compactEvery = 10000
+)
+
+// logEntry is one line of the append log, Op is either "put" or "delete"
+type logEntry struct {
+	Op      string        `json:"op"`
+	Trigger triggerRecord `json:"trigger"`
+}
+
+// fileStore keeps a snapshot of every trigger plus an append log of the changes
+// made since the snapshot. Every change is synced to disk before it returns.
+// Replaying the log over the snapshot is idempotent, so a crash part way through
+// compacting only means some changes get applied twice.
+type fileStore struct {
+	lock     sync.Mutex
+	dir      string
+	log      *os.File
+	logged   int
+	triggers map[triggersKey]triggerRecord
+}
+
+// openFileStore reads any triggers persisted in dir and starts a fresh log
+func openFileStore(dir string) (*fileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &fileStore{
+		dir:      dir,
+		triggers: make(map[triggersKey]triggerRecord),
+	}
+	if err := s.readSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Load returns every persisted trigger
+func (s *fileStore) Load() ([]triggerRecord, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	records := make([]triggerRecord, 0, len(s.triggers))
+	for _, record := range s.triggers {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Put saves a new trigger or replaces the existing one with the same key
+func (s *fileStore) Put(record triggerRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.triggers[record.key()] = record
+	return s.append(logEntry{"put", record})
+}
+
+// Delete removes a trigger
+func (s *fileStore) Delete(key triggersKey) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.triggers, key)
+	return s.append(logEntry{"delete", triggerRecord{ID: key.id, Action: key.action, Stock: key.stock, User: key.user}})
+}
+
+// Close releases the log file
+func (s *fileStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.log.Close()
+}
+
+// append writes an entry to the log. The caller must hold s.lock.
+func (s *fileStore) append(entry logEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = s.log.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err = s.log.Sync(); err != nil {
+		return err
+	}
+
+	s.logged++
+	if s.logged >= compactEvery {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes every trigger to a new snapshot and truncates the log.
+// The caller must hold s.lock.
+func (s *fileStore) compact() error {
+	records := make([]triggerRecord, 0, len(s.triggers))
+	for _, record := range s.triggers {
+		records = append(records, record)
+	}
+
+	tmp, err := os.Create(filepath.Join(s.dir, snapshotFile+".tmp"))
+	if err != nil {
+		return err
+	}
+	if err = json.NewEncoder(tmp).Encode(records); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), filepath.Join(s.dir, snapshotFile)); err != nil {
+		return err
+	}
+
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.Create(filepath.Join(s.dir, logFile))
+	s.logged = 0
+	return err
+}
+
+func (s *fileStore) readSnapshot() error {
+	f, err := os.Open(filepath.Join(s.dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var records []triggerRecord
+	if err = json.NewDecoder(f).Decode(&records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		s.triggers[record.key()] = record
+	}
+	return nil
+}
+
+func (s *fileStore) replayLog() error {
+	f, err := os.Open(filepath.Join(s.dir, logFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn write from a crash can only be the final line
+			break
+		}
+		if entry.Op == "put" {
+			s.triggers[entry.Trigger.key()] = entry.Trigger
+		} else if entry.Op == "delete" {
+			delete(s.triggers, entry.Trigger.key())
+		}
+	}
+	return scanner.Err()
+}
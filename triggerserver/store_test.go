@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triggerstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buy := newBuyTrigger(nil, 1, "user1", "ABC", decimal.NewFromFloat(50.00))
+	sell := newSellTrigger(nil, 2, "user1", "XYZ", decimal.New(4, 0))
+	s.Put(newTriggerRecord(buy, stateWaiting))
+	s.Put(newTriggerRecord(sell, stateWaiting))
+	sell.price = decimal.NewFromFloat(30.00)
+	s.Put(newTriggerRecord(sell, stateRunning))
+	s.Delete(buy.key())
+	s.Close()
+
+	// A crash can leave half of an entry at the end of the log
+	log, _ := os.OpenFile(filepath.Join(dir, logFile), os.O_APPEND|os.O_WRONLY, 0644)
+	log.WriteString(`{"op":"delete","trigger":{"act`)
+	log.Close()
+
+	s, err = openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	records, _ := s.Load()
+	if len(records) != 1 {
+		t.Fatal("Expected only the sell trigger to be restored, got ", records)
+	}
+	restored := records[0]
+	if restored.State != stateRunning || restored.User != "user1" || restored.Stock != "XYZ" ||
+		!restored.Amount.Equal(decimal.New(4, 0)) || !restored.Price.Equal(decimal.NewFromFloat(30.00)) {
+		t.Error("Restored the wrong trigger ", restored)
+	}
+}
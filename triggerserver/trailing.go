@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var errBadTrail = errors.New("trail must be a positive amount or a percentage below 100%")
+
+// trailingStop is the moving stop price of a trailing stop. A trailing sell
+// follows the highest price quoted since it was placed, its mark, and fires once
+// the price falls the trail below the mark. A trailing buy follows the lowest
+// price and fires once the price rises the trail above it. Copies of a trigger share it.
+type trailingStop struct {
+	lock    sync.Mutex
+	below   bool            // The stop trails below the mark, for sells
+	offset  decimal.Decimal // The trail, in dollars or as a percentage of the mark
+	percent bool
+	mark    decimal.Decimal
+}
+
+// newTrailingStop parses a trail of a dollar amount such as "1.50" or a
+// percentage such as "5%", for a trailing stop on action starting from mark
+func newTrailingStop(action string, trail string, mark decimal.Decimal) (*trailingStop, error) {
+	s := &trailingStop{below: action == "TRAILING_STOP_SELL", mark: mark}
+	var err error
+	if strings.HasSuffix(trail, "%") {
+		s.percent = true
+		s.offset, err = decimal.NewFromString(strings.TrimSuffix(trail, "%"))
+		if err == nil && s.offset.GreaterThanOrEqual(decimal.New(100, 0)) {
+			return nil, errBadTrail
+		}
+	} else {
+		s.offset, err = decimal.NewFromString(trail)
+	}
+	if err != nil || !s.offset.IsPositive() || !mark.IsPositive() {
+		return nil, errBadTrail
+	}
+	return s, nil
+}
+
+// trail returns the trail as it was given
+func (s *trailingStop) trail() string {
+	if s.percent {
+		return s.offset.String() + "%"
+	}
+	return s.offset.String()
+}
+
+// getMark returns the high or low water mark the stop trails
+func (s *trailingStop) getMark() decimal.Decimal {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.mark
+}
+
+// follow moves the mark to a new high, or low for a buy, and returns the stop
+// price, whether price has reached it, and whether the mark moved
+func (s *trailingStop) follow(price decimal.Decimal) (stop decimal.Decimal, reached bool, moved bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if (s.below && price.GreaterThan(s.mark)) || (!s.below && price.LessThan(s.mark)) {
+		s.mark = price
+		moved = true
+	}
+
+	offset := s.offset
+	if s.percent {
+		offset = s.mark.Mul(s.offset).Div(decimal.New(100, 0))
+	}
+	if s.below {
+		stop = s.mark.Sub(offset).Round(2)
+		return stop, price.LessThanOrEqual(stop), moved
+	}
+	stop = s.mark.Add(offset).Round(2)
+	return stop, price.GreaterThanOrEqual(stop), moved
+}
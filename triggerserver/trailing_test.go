@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestTrailingStop(t *testing.T) {
+	for _, bad := range []string{"", "0", "-1", "100%", "abc%"} {
+		if _, err := newTrailingStop("TRAILING_STOP_SELL", bad, decimal.New(10, 0)); err == nil {
+			t.Errorf("Trail %q should not parse", bad)
+		}
+	}
+
+	sell, err := newTrailingStop("TRAILING_STOP_SELL", "2.00", decimal.New(20, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buy, err := newTrailingStop("TRAILING_STOP_BUY", "10%", decimal.New(20, 0))
+	if err != nil || buy.trail() != "10%" {
+		t.Fatal("Expected a 10% trail, got ", buy, err)
+	}
+
+	steps := []struct {
+		price               float64
+		sellStop, buyStop   float64
+		sellFires, buyFires bool
+	}{
+		{19.00, 18.00, 20.90, false, false},
+		{25.00, 23.00, 20.90, false, true},
+		{24.00, 23.00, 20.90, false, true},
+		{18.50, 23.00, 20.35, true, false},
+	}
+	for _, step := range steps {
+		price := decimal.NewFromFloat(step.price)
+		stop, reached, _ := sell.follow(price)
+		if !stop.Equal(decimal.NewFromFloat(step.sellStop)) || reached != step.sellFires {
+			t.Errorf("At %.2f the trailing sell should stop at %.2f (fires %v), got %s (%v)",
+				step.price, step.sellStop, step.sellFires, stop, reached)
+		}
+		stop, reached, _ = buy.follow(price)
+		if !stop.Equal(decimal.NewFromFloat(step.buyStop)) || reached != step.buyFires {
+			t.Errorf("At %.2f the trailing buy should stop at %.2f (fires %v), got %s (%v)",
+				step.price, step.buyStop, step.buyFires, stop, reached)
+		}
+	}
+	if !sell.getMark().Equal(decimal.New(25, 0)) || !buy.getMark().Equal(decimal.NewFromFloat(18.50)) {
+		t.Error("Expected marks of 25.00 and 18.50, got ", sell.getMark(), buy.getMark())
+	}
+}
@@ -1,27 +1,49 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"seng468/triggerserver/quote"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type trigger struct {
+	// id identifies the trigger to the transaction server, which uses it to
+	// ignore a TRIGGER_SUCCESS that is delivered twice
+	id              string
 	username        string
 	stockname       string
 	amount          decimal.Decimal
 	price           decimal.Decimal
 	action          string
 	transNum        int
-	done            bool
 	successListener chan trigger
+
+	// created orders a user's triggers on a stock, for requests that don't give an id
+	created time.Time
+
+	// status is shared by every copy of the trigger
+	status *triggerStatus
+
+	// trail is set for trailing stops, whose price is only set once they fire
+	trail *trailingStop
+
+	// group is the id of the OCO group or bracket the trigger is a leg of, see newOrderGroup
+	group string
+
+	// expires is when a running trigger expires, or zero if it runs until it fires or is cancelled
+	expires time.Time
 }
 
 func (t trigger) getSuccessString() string {
-	return fmt.Sprintf("TRIGGER_SUCCESS,%v,%v,%v,%v,%v\n",
-		t.username, t.stockname, t.price, t.amount, t.action)
+	return fmt.Sprintf("TRIGGER_SUCCESS,%v,%v,%v,%v,%v,%v",
+		t.username, t.stockname, t.price, t.amount, t.action, t.id)
+}
+
+func (t trigger) getExpiredString() string {
+	return fmt.Sprintf("TRIGGER_EXPIRED,%v,%v,%v,%v", t.username, t.stockname, t.action, t.id)
 }
 
 func (t trigger) getPriceStr() string {
@@ -37,67 +59,57 @@ func (t trigger) String() string {
 	return str
 }
 
-func (t trigger) StartPolling() {
-	t.done = false
-	if t.checkTriggerStatus() {
-		successListener <- t
-		return
-	}
-
-	ticker := time.NewTicker((time.Second * 60) + time.Millisecond)
-	defer ticker.Stop()
-
-	for _ = range ticker.C {
-		if t.done {
-			return
-		}
-		if t.checkTriggerStatus() {
-			successListener <- t
-			return
-		}
-	}
+func (t trigger) key() triggersKey {
+	return newTriggersKey(t.action, t.stockname, t.username, t.id)
 }
 
-func (t trigger) Cancel() {
-	t.done = true
-	return
+func (t trigger) state() triggerState {
+	return t.status.get()
 }
 
-func (t trigger) checkTriggerStatus() bool {
-	result := t.hitQuoteServer()
-	return t.checkResult(result)
+// transition moves the trigger between states, see triggerStatus.transition
+func (t trigger) transition(from triggerState, to triggerState) bool {
+	return t.status.transition(from, to)
 }
 
-func (t trigger) hitQuoteServer() decimal.Decimal {
-	result, err := quoteclient.Query(t.username, t.stockname, t.transNum)
-	if err != nil {
-		panic(err)
-	}
-
-	return result
+// sameAs reports whether t and other are copies of the same trigger,
+// rather than two triggers that were set on the same key
+func (t trigger) sameAs(other trigger) bool {
+	return t.status == other.status
 }
 
 // See if the result from the quoteserver is enough to stop the trigger
 func (t trigger) checkResult(result decimal.Decimal) bool {
 	switch t.action {
-	case "BUY":
+	case "BUY", "LIMIT_BUY", "STOP_SELL":
 		return t.price.GreaterThanOrEqual(result)
-	case "SELL":
+	case "SELL", "LIMIT_SELL", "STOP_BUY":
 		return t.price.LessThanOrEqual(result)
+	case "TRAILING_STOP_BUY", "TRAILING_STOP_SELL":
+		_, reached, _ := t.trail.follow(result)
+		return reached
 	}
 
 	panic("Should never reach here...")
 }
 
+// firesOnFall reports whether the trigger fires once the price falls to its
+// trigger price, rather than once the price rises to it
+func (t trigger) firesOnFall() bool {
+	return t.action == "BUY" || t.action == "LIMIT_BUY" || t.action == "STOP_SELL"
+}
+
 func newSellTrigger(sls chan trigger, transNum int, username string, stockname string, amount decimal.Decimal) trigger {
 	t := trigger{
 		transNum:        transNum,
 		username:        username,
 		stockname:       stockname,
 		amount:          amount,
+		id:              newTriggerID(),
 		action:          "SELL",
-		done:            false,
 		successListener: sls,
+		status:          newTriggerStatus(stateWaiting),
+		created:         time.Now(),
 	}
 
 	return t
@@ -109,10 +121,62 @@ func newBuyTrigger(sls chan trigger, transNum int, username string, stockname st
 		username:        username,
 		stockname:       stockname,
 		amount:          amount,
+		id:              newTriggerID(),
 		action:          "BUY",
-		done:            false,
 		successListener: sls,
+		status:          newTriggerStatus(stateWaiting),
+		created:         time.Now(),
 	}
 
 	return t
 }
+
+// newLimitOrder returns a running limit or stop order. Unlike a trigger it is set
+// and started in one step, and its id is chosen by the transaction server, which
+// keeps the order's reserve under the same id. A trailing stop also needs its trail set.
+func newLimitOrder(sls chan trigger, transNum int, id string, username string, stockname string, action string,
+	shares decimal.Decimal, price decimal.Decimal) trigger {
+	return trigger{
+		transNum:        transNum,
+		username:        username,
+		stockname:       stockname,
+		amount:          shares,
+		price:           price,
+		id:              id,
+		action:          action,
+		successListener: sls,
+		status:          newTriggerStatus(stateRunning),
+		created:         time.Now(),
+	}
+}
+
+// isLimitOrder reports whether action is one of the limit order actions
+func isLimitOrder(action string) bool {
+	return action == "LIMIT_BUY" || action == "LIMIT_SELL"
+}
+
+// isStopOrder reports whether action is one of the stop or trailing stop actions.
+// A STOP_SELL fires once the price falls to its stop price and a STOP_BUY once it
+// rises to it, so they protect a position instead of waiting for a better price.
+func isStopOrder(action string) bool {
+	return action == "STOP_BUY" || action == "STOP_SELL" || isTrailingStop(action)
+}
+
+// isTrailingStop reports whether action is one of the trailing stop actions, see trailingStop
+func isTrailingStop(action string) bool {
+	return action == "TRAILING_STOP_BUY" || action == "TRAILING_STOP_SELL"
+}
+
+// isOrder reports whether action is placed as an order with an id, rather than set as a trigger
+func isOrder(action string) bool {
+	return isLimitOrder(action) || isStopOrder(action)
+}
+
+// newTriggerID returns a random ID for a new trigger
+func newTriggerID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"seng468/triggerserver/quote"
 	"strconv"
 	"sync"
 	"time"
@@ -14,26 +16,98 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// triggersKey follows [action][stock][user] indexing
+// triggersKey follows [action][stock][user] indexing. A user can hold any number
+// of triggers and orders on a stock, so each is also indexed by its id.
 type triggersKey struct {
-	action, stock, user string
+	action, stock, user, id string
 }
 
+func newTriggersKey(action string, stock string, user string, id string) triggersKey {
+	return triggersKey{action, stock, user, id}
+}
+
+// latestTrigger returns the key of the user's most recently set trigger with the
+// action on stock in any of triggers, for requests that don't give a trigger id.
+// The caller must hold triggersLock.
+func latestTrigger(action string, stock string, user string, triggers ...map[triggersKey]trigger) (triggersKey, bool) {
+	var latest trigger
+	found := false
+	for _, pool := range triggers {
+		for key, t := range pool {
+			if key.action == action && key.stock == stock && key.user == user && (!found ||
+				t.created.After(latest.created) || t.created.Equal(latest.created) && t.transNum > latest.transNum) {
+				latest, found = t, true
+			}
+		}
+	}
+	return latest.key(), found
+}
+
+// writeTrigger replies with the trigger as JSON, in the same form /triggers lists it
+func writeTrigger(w http.ResponseWriter, t trigger) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newTriggerRecord(t, t.state()))
+}
+
+// Errors cancelling a trigger or limit order
+var (
+	errTriggerFired = errors.New("Trigger has already fired")
+	errNoTrigger    = errors.New("Can't find waiting or running trigger to cancel")
+)
+
 var waitingTriggers = make(map[triggersKey]trigger)
 var triggersLock sync.Mutex
 var runningTriggers = make(map[triggersKey]trigger)
 
 var successListener = make(chan trigger, 2048)
 
+// store persists every waiting and running trigger
+var store triggerStore
+
+// watcher checks every running trigger against the latest quote for its stock
+var watcher = newPriceWatcher(quoteclient.Query, successListener, pollInterval)
+
+// deliveries sends fired triggers to the transaction server until they are acknowledged
+var deliveries = newOutbox(sendTriggerSuccess)
+
 func main() {
 	fmt.Println("Launching server...")
+	dataDir := os.Getenv("triggerdata")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	var err error
+	store, err = openFileStore(dataDir)
+	if err != nil {
+		panic(err)
+	}
+	if dbAddr := os.Getenv("dbaddr"); dbAddr != "" {
+		events := newQuoteEvents(dbAddr+":"+os.Getenv("dbport"), eventsChannel)
+		go events.run()
+		watcher.quoted = events.publish
+	}
+	watcher.trailed = persistTrail
+	err = restoreTriggers()
+	if err != nil {
+		panic(err)
+	}
+
 	http.HandleFunc("/setTrigger", setTriggerHandler)
 	http.HandleFunc("/startTrigger", startTriggerHandler)
 	http.HandleFunc("/cancelTrigger", cancelTriggerHandler)
+	http.HandleFunc("/placeLimitOrder", placeLimitOrderHandler)
+	http.HandleFunc("/amendLimitOrder", amendLimitOrderHandler)
+	http.HandleFunc("/cancelLimitOrder", cancelTriggerHandler)
+	http.HandleFunc("/placeOrderGroup", placeOrderGroupHandler)
+	http.HandleFunc("/cancelOrderGroup", cancelOrderGroupHandler)
 	http.HandleFunc("/runningTriggers", getRunningTriggersHandler)
 	http.HandleFunc("/waitingTriggers", getWaitingTriggersHandler)
+	http.HandleFunc("/triggers", getTriggersHandler)
 
 	go startSuccessListener()
+	go watcher.Run()
+	go sweepExpiredTriggers(sweepInterval, waitingTimeout())
+	go reconcileTriggers()
 
 	fmt.Printf("Trigger server listening on %s:%s\n", os.Getenv("triggeraddr"), os.Getenv("triggerport"))
 	if err := http.ListenAndServe(":"+os.Getenv("triggerport"), nil); err != nil {
@@ -41,12 +115,16 @@ func main() {
 	}
 }
 
+// startTriggerHandler starts the waiting trigger with the given id, or the user's
+// latest waiting trigger on the stock without one. Replies 404 if there is none.
+// An optional expires, in unix milliseconds, is when the running trigger expires.
 func startTriggerHandler(w http.ResponseWriter, r *http.Request) {
 	action := r.FormValue("action")
 	//transnumStr := r.FormValue("transnum")
 	username := r.FormValue("username")
 	stock := r.FormValue("stock")
 	priceStr := r.FormValue("price")
+	id := r.FormValue("id")
 
 	//transnum, err := strconv.Atoi(transnumStr)
 	//if err != nil {
@@ -59,32 +137,58 @@ func startTriggerHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		panic(err)
 	}
+	var expires time.Time
+	if expiresStr := r.FormValue("expires"); expiresStr != "" {
+		millis, err := strconv.ParseInt(expiresStr, 10, 64)
+		expires = time.Unix(0, millis*int64(time.Millisecond))
+		if err != nil || !expires.After(time.Now()) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
 
 	// START LOCKING -- BE CAREFUL OF DEADLOCKS HERE
 	//defer fmt.Println("Done starting")
 	triggersLock.Lock()
-	t, ok := waitingTriggers[triggersKey{action, stock, username}]
+	key := newTriggersKey(action, stock, username, id)
+	if id == "" {
+		key, _ = latestTrigger(action, stock, username, waitingTriggers)
+	}
+	t, ok := waitingTriggers[key]
 
 	if ok {
 		t.price = price
-		delete(waitingTriggers, triggersKey{t.action, t.stockname, t.username})
-		runningTriggers[triggersKey{t.action, t.stockname, t.username}] = t
+		t.expires = expires
+		err = store.Put(newTriggerRecord(t, stateRunning))
+		if err != nil {
+			triggersLock.Unlock()
+			fmt.Println("Error persisting trigger: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		t.transition(stateWaiting, stateRunning)
+		delete(waitingTriggers, t.key())
+		runningTriggers[t.key()] = t
 		triggersLock.Unlock()
 
-		go t.StartPolling()
-		w.Write([]byte(t.String()))
+		watcher.Add(t)
+		writeTrigger(w, t)
 	} else {
 		triggersLock.Unlock()
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusNotFound)
 	}
 }
 
+// setTriggerHandler adds a waiting trigger under the id the transaction server
+// keeps its reserve under, or a new id if it isn't given one. Replies 409 if
+// the id is already taken.
 func setTriggerHandler(w http.ResponseWriter, r *http.Request) {
 	action := r.FormValue("action")
 	transnumStr := r.FormValue("transnum")
 	username := r.FormValue("username")
 	stock := r.FormValue("stock")
 	amountStr := r.FormValue("amount")
+	id := r.FormValue("id")
 
 	if !verifyAction(action) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -109,35 +213,189 @@ func setTriggerHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		t = newSellTrigger(successListener, transnum, username, stock, amount)
 	}
+	if id != "" {
+		t.id = id
+	}
 
 	triggersLock.Lock()
-	waitingTriggers[triggersKey{t.action, t.stockname, t.username}] = t
+	_, waiting := waitingTriggers[t.key()]
+	_, running := runningTriggers[t.key()]
+	if waiting || running {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	err = store.Put(newTriggerRecord(t, stateWaiting))
+	if err != nil {
+		triggersLock.Unlock()
+		fmt.Println("Error persisting trigger: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	waitingTriggers[t.key()] = t
 	triggersLock.Unlock()
 	//fmt.Println("Added but not started: ", t)
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// cancelTriggerHandler cancels the trigger or the limit or stop order with the given
+// id. Without an id it cancels the user's latest trigger on the stock.
+// Replies 404 if there is nothing to cancel and 409 if it has already fired.
+// The legs of an OCO group or bracket are refused, see cancelOrderGroupHandler.
 func cancelTriggerHandler(w http.ResponseWriter, r *http.Request) {
 	action := r.FormValue("action")
 	//transnumStr := r.FormValue("transnum")
 	username := r.FormValue("username")
 	stock := r.FormValue("stock")
+	id := r.FormValue("id")
 
-	if !verifyAction(action) {
+	if !verifyAction(action) && !isOrder(action) {
 		w.WriteHeader(http.StatusBadRequest)
-		panic("Tried to post a bad action (BUY/SELL)")
+		panic("Tried to post a bad action (BUY/SELL or a limit or stop order)")
 	}
 
+	key := newTriggersKey(action, stock, username, id)
 	triggersLock.Lock()
-	cancelledTrigger, err := cancelTrigger(triggersKey{action, stock, username})
+	if id == "" && !isOrder(action) {
+		key, _ = latestTrigger(action, stock, username, waitingTriggers, runningTriggers)
+	}
+	if isGroupLeg(key) {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	cancelledTrigger, err := cancelTrigger(key)
+	if err == errTriggerFired {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	err = store.Delete(key)
 	triggersLock.Unlock()
 	if err != nil {
+		fmt.Println("Error removing persisted trigger: ", err)
+	}
+	//fmt.Println("CANCELLED: ", cancelledTrigger)
+	writeTrigger(w, cancelledTrigger)
+}
+
+// placeLimitOrderHandler starts watching a new limit or stop order. The transaction
+// server has already reserved its funds or shares under the given id.
+// A trailing stop is also given its trail, and its price is the mark it starts from.
+func placeLimitOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	action := r.FormValue("action")
+	username := r.FormValue("username")
+	stock := r.FormValue("stock")
+	transnum, err := strconv.Atoi(r.FormValue("transnum"))
+	if err != nil || id == "" || !isOrder(action) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	//fmt.Println("CANCELLED: ", cancelledTrigger)
-	w.Write([]byte(cancelledTrigger.String()))
+	shares, price, ok := parseLimitOrder(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	t := newLimitOrder(successListener, transnum, id, username, stock, action, shares, price)
+	if isTrailingStop(action) {
+		t.trail, err = newTrailingStop(action, r.FormValue("trail"), price)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	triggersLock.Lock()
+	if _, exists := runningTriggers[t.key()]; exists {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	err = store.Put(newTriggerRecord(t, stateRunning))
+	if err != nil {
+		triggersLock.Unlock()
+		fmt.Println("Error persisting limit order: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	runningTriggers[t.key()] = t
+	triggersLock.Unlock()
+
+	watcher.Add(t)
+	writeTrigger(w, t)
+}
+
+// amendLimitOrderHandler changes the shares and limit or stop price of a running order.
+// Trailing stops and the legs of an OCO group or bracket can't be amended.
+// Replies 404 if there is no such order and 409 if it has already fired.
+func amendLimitOrderHandler(w http.ResponseWriter, r *http.Request) {
+	key := newTriggersKey(r.FormValue("action"), r.FormValue("stock"), r.FormValue("username"), r.FormValue("id"))
+	shares, price, ok := parseLimitOrder(r)
+	if !ok || !isOrder(key.action) || isTrailingStop(key.action) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// The order as it was is cancelled and replaced under the same id, so a quote
+	// crossing the old price at the same moment either fires it first or is ignored
+	triggersLock.Lock()
+	current, running := runningTriggers[key]
+	if !running {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if current.group != "" {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !current.transition(stateRunning, stateCancelled) {
+		triggersLock.Unlock()
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	watcher.Remove(current)
+
+	amended := current
+	amended.amount = shares
+	amended.price = price
+	amended.status = newTriggerStatus(stateRunning)
+	err := store.Put(newTriggerRecord(amended, stateRunning))
+	if err != nil {
+		fmt.Println("Error persisting amended limit order: ", err)
+		// Keep watching the order as it was, which is what the store still holds
+		amended = current
+		amended.status = newTriggerStatus(stateRunning)
+	}
+	runningTriggers[key] = amended
+	triggersLock.Unlock()
+
+	watcher.Add(amended)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeTrigger(w, amended)
+}
+
+// parseLimitOrder reads the shares and price of a limit or stop order request
+func parseLimitOrder(r *http.Request) (shares decimal.Decimal, price decimal.Decimal, ok bool) {
+	shares, err := decimal.NewFromString(r.FormValue("amount"))
+	if err != nil || !shares.IsPositive() {
+		return shares, price, false
+	}
+	price, err = decimal.NewFromString(r.FormValue("price"))
+	if err != nil || !price.IsPositive() {
+		return shares, price, false
+	}
+	return shares, price, true
 }
 
 func startSuccessListener() {
@@ -149,44 +407,239 @@ func startSuccessListener() {
 	}
 }
 
+// handleTriggerSuccess is called with a trigger the watcher has moved to FIRING
 func handleTriggerSuccess(trig trigger) {
-	go alertTriggerSuccess(trig)
 	//fmt.Println("Closing successful trigger: ", trig)
+	key := trig.key()
 
+	// The trigger stays persisted as FIRING until the transaction server has been
+	// told, so a restart in between sends the success again rather than losing the reserve
 	triggersLock.Lock()
-	delete(runningTriggers, triggersKey{trig.action, trig.stockname, trig.username})
+	if isPersisted(trig) {
+		err := store.Put(newTriggerRecord(trig, stateFiring))
+		if err != nil {
+			fmt.Println("Error persisting firing trigger: ", err)
+		}
+	}
 	triggersLock.Unlock()
 
+	if err := deliveries.deliver(trig); err != nil {
+		failTrigger(trig, err)
+		return
+	}
+
+	triggersLock.Lock()
+	trig.transition(stateFiring, stateFired)
+	// The rest of a group is settled before the leg's record goes, so a restart
+	// in between never leaves a bracket's exits waiting on an entry that is gone
+	var started []trigger
+	if trig.group != "" {
+		started = legDelivered(trig)
+	}
+	if isPersisted(trig) {
+		err := store.Delete(key)
+		if err != nil {
+			fmt.Println("Error removing persisted trigger: ", err)
+		}
+	}
+	if current, ok := runningTriggers[key]; ok && current.sameAs(trig) {
+		delete(runningTriggers, key)
+	}
+	triggersLock.Unlock()
+
+	for _, t := range started {
+		watcher.Add(t)
+	}
+
 	//fmt.Println("Trigger should be closed: ", trig)
 
 }
 
-// Send an alert back to the transaction server when a trigger successfully finishes
-func alertTriggerSuccess(t trigger) {
-	var conn net.Conn
-	var err error
+// failTrigger gives up on a firing trigger the transaction server refused for good.
+// It is persisted as FAILED, where it stays as a dead letter and is not resent
+// after a restart, and it is no longer listed by /triggers, so the next
+// RECONCILE_TRIGGERS returns any reserve still held for it. The rest of its
+// group can't fire any more either, so it is dropped.
+func failTrigger(trig trigger, err error) {
+	fmt.Println("Giving up on delivering trigger ", trig, ": ", err)
+	triggersLock.Lock()
+	defer triggersLock.Unlock()
+	trig.transition(stateFiring, stateFailed)
+	if isPersisted(trig) {
+		if err := store.Put(newTriggerRecord(trig, stateFailed)); err != nil {
+			fmt.Println("Error persisting failed trigger: ", err)
+		}
+	}
+	if current, ok := runningTriggers[trig.key()]; ok && current.sameAs(trig) {
+		delete(runningTriggers, trig.key())
+	}
+	if trig.group == "" {
+		return
+	}
+	for _, leg := range groupLegs {
+		key := legKey(trig.stockname, trig.username, trig.group, leg)
+		if key == trig.key() {
+			continue
+		}
+		t, ok := runningTriggers[key]
+		if !ok {
+			t, ok = waitingTriggers[key]
+		}
+		if !ok {
+			continue
+		}
+		watcher.Remove(t)
+		delete(runningTriggers, key)
+		delete(waitingTriggers, key)
+		if err := store.Delete(key); err != nil {
+			fmt.Println("Error removing persisted trigger: ", err)
+		}
+	}
+}
+
+// persistTrail saves the new mark of a running trailing stop, so after a restart
+// it carries on trailing the highest or lowest price it had seen
+func persistTrail(t trigger) {
+	triggersLock.Lock()
+	defer triggersLock.Unlock()
+	if t.state() != stateRunning || !isPersisted(t) {
+		return
+	}
+	if err := store.Put(newTriggerRecord(t, stateRunning)); err != nil {
+		fmt.Println("Error persisting trailing stop: ", err)
+	}
+}
+
+// isPersisted reports whether the stored record for t's key belongs to t, as a new
+// trigger can be set on the same key while t is firing. The caller must hold triggersLock.
+func isPersisted(t trigger) bool {
+	if _, replaced := waitingTriggers[t.key()]; replaced {
+		return false
+	}
+	current, ok := runningTriggers[t.key()]
+	return ok && current.sameAs(t)
+}
+
+// restoreTriggers reloads the persisted triggers, resuming polling for the running ones
+// and resending the success of any trigger that was firing, or the expiry of one that expired
+func restoreTriggers() error {
+	records, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	var running, firing, expired []trigger
+	failed := 0
+	triggersLock.Lock()
+	restored := make([]trigger, len(records))
+	for i, record := range records {
+		restored[i] = record.trigger(successListener)
+	}
+	for _, t := range relinkGroups(records, restored) {
+		switch t.state() {
+		case stateWaiting:
+			waitingTriggers[t.key()] = t
+		case stateRunning:
+			runningTriggers[t.key()] = t
+			running = append(running, t)
+		case stateFiring:
+			runningTriggers[t.key()] = t
+			firing = append(firing, t)
+		case stateExpired:
+			expired = append(expired, t)
+		case stateFailed:
+			failed++
+		}
+	}
+	fmt.Printf("Restored %d running, %d firing, %d expired and %d waiting triggers, skipped %d failed\n",
+		len(running), len(firing), len(expired), len(waitingTriggers), failed)
+	triggersLock.Unlock()
+
+	for _, t := range running {
+		watcher.Add(t)
+	}
+	for _, t := range firing {
+		go handleTriggerSuccess(t)
+	}
+	for _, t := range expired {
+		go handleTriggerExpired(t)
+	}
+	return nil
+}
+
+// reconcileTriggers asks the transaction server to compare its reserve accounts
+// against /triggers, releasing any reserve held for a trigger this server lost.
+// Retries until the transaction server is up.
+func reconcileTriggers() {
 	for {
-		conn, err = net.DialTimeout("tcp",
-			os.Getenv("transaddr")+":"+os.Getenv("transport"),
-			time.Second*15,
-		)
-		if err != nil { // trans server down? retry
-			fmt.Println("Trans server timedout -- retrying")
-		} else {
-			break
+		reply, err := sendToTransactionServer(0, "RECONCILE_TRIGGERS")
+		if err == nil && reply.succeeded() {
+			return
 		}
+		fmt.Println("Could not reconcile triggers with the transaction server -- retrying")
+		time.Sleep(time.Second * 5)
 	}
+}
+
+// transactionResult is the JSON reply the transaction server sends to every command
+type transactionResult struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r transactionResult) succeeded() bool {
+	return r.Status == 1
+}
+
+// retryable reports whether a failed command may succeed if sent again, as
+// the transaction server only failed it because a service it needs is down
+func (r transactionResult) retryable() bool {
+	return r.Code == "QUOTE_UNAVAILABLE" || r.Code == "TRIGGER_UNAVAILABLE" || r.Code == "DATABASE_UNAVAILABLE"
+}
+
+func (r transactionResult) String() string {
+	if r.succeeded() {
+		return "OK"
+	}
+	return r.Code + ": " + r.Message
+}
 
-	//fmt.Println(strconv.Itoa(t.transNum) + ";" + t.getSuccessString())
-	_, err = fmt.Fprintf(conn, strconv.Itoa(t.transNum)+";"+t.getSuccessString())
+// sendToTransactionServer sends one command and returns the transaction server's reply
+func sendToTransactionServer(transNum int, command string) (transactionResult, error) {
+	conn, err := net.DialTimeout("tcp",
+		os.Getenv("transaddr")+":"+os.Getenv("transport"),
+		time.Second*15,
+	)
 	if err != nil {
-		panic(err)
+		return transactionResult{}, err
 	}
+	defer conn.Close()
 
-	err = conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+	_, err = fmt.Fprintf(conn, "%d;%s\n", transNum, command)
 	if err != nil {
-		panic(err)
+		return transactionResult{}, err
+	}
+	var reply transactionResult
+	err = json.NewDecoder(conn).Decode(&reply)
+	return reply, err
+}
+
+// getTriggersHandler lists every waiting and running trigger as JSON
+func getTriggersHandler(w http.ResponseWriter, r *http.Request) {
+	triggersLock.Lock()
+	records := make([]triggerRecord, 0, len(waitingTriggers)+len(runningTriggers))
+	for _, t := range waitingTriggers {
+		records = append(records, newTriggerRecord(t, t.state()))
+	}
+	for _, t := range runningTriggers {
+		records = append(records, newTriggerRecord(t, t.state()))
 	}
+	triggersLock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
 }
 
 func getRunningTriggersHandler(w http.ResponseWriter, r *http.Request) {
@@ -208,20 +661,25 @@ func verifyAction(action string) bool {
 	return true
 }
 
-// Removes the trigger from the poller, returns the removed key and any errors
+// Moves the trigger to CANCELLED and removes it from the poller, returns the removed
+// trigger and any errors. A trigger a quote has already crossed is FIRING and can't be
+// cancelled. The caller must hold triggersLock.
 func cancelTrigger(t triggersKey) (trigger, error) {
 	trigger, running := runningTriggers[t]
 	if running {
+		if !trigger.transition(stateRunning, stateCancelled) {
+			return trigger, errTriggerFired
+		}
+		watcher.Remove(trigger)
 		delete(runningTriggers, t)
-		//trigger.Cancel()
 		return trigger, nil
 	}
 
 	trigger, waiting := waitingTriggers[t]
-	if waiting {
+	if waiting && trigger.transition(stateWaiting, stateCancelled) {
 		delete(waitingTriggers, t)
 		return trigger, nil
 	}
 
-	return trigger, errors.New("Can't find waiting or running trigger to cancel")
+	return trigger, errNoTrigger
 }
@@ -0,0 +1,448 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// useWatcher swaps in a watcher for the test, returning a func that restores the old one
+func useWatcher(w *priceWatcher) func() {
+	old := watcher
+	watcher = w
+	return func() { watcher = old }
+}
+
+func TestCancelTrigger(t *testing.T) {
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+
+	waiting := newBuyTrigger(fired, 1, "user1", "ABC", decimal.New(10, 0))
+	running := runningTrigger("SELL", "user1", "ABC", 20.00)
+	triggersLock.Lock()
+	waitingTriggers[waiting.key()] = waiting
+	runningTriggers[running.key()] = running
+	triggersLock.Unlock()
+	watcher.stocks["ABC"] = &stockWatch{}
+	watcher.Add(running)
+
+	triggersLock.Lock()
+	_, err := cancelTrigger(waiting.key())
+	triggersLock.Unlock()
+	if err != nil || waiting.state() != stateCancelled {
+		t.Error("Waiting trigger should be cancelled, is ", waiting.state(), err)
+	}
+
+	triggersLock.Lock()
+	_, err = cancelTrigger(running.key())
+	triggersLock.Unlock()
+	if err != nil || running.state() != stateCancelled {
+		t.Error("Running trigger should be cancelled, is ", running.state(), err)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(25.00))
+	expectFired(t, fired)
+
+	triggersLock.Lock()
+	_, err = cancelTrigger(running.key())
+	triggersLock.Unlock()
+	if err == nil {
+		t.Error("Cancelling twice should fail")
+	}
+}
+
+// TestCancelWhilePriceCrosses cancels a trigger at the same moment a quote crosses
+// its price. Exactly one of the cancel and the fire must win, every time.
+func TestCancelWhilePriceCrosses(t *testing.T) {
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+
+	// Keeps ABC watched so adding a trigger doesn't start a background quote
+	watcher.stocks["ABC"] = &stockWatch{}
+	watcher.Add(runningTrigger("SELL", "holder", "ABC", 1000.00))
+
+	cancels, fires := 0, 0
+	for i := 0; i < 2000; i++ {
+		trig := runningTrigger("BUY", "user1", "ABC", 10.00)
+		triggersLock.Lock()
+		runningTriggers[trig.key()] = trig
+		triggersLock.Unlock()
+		watcher.update("ABC", decimal.NewFromFloat(50.00))
+		watcher.Add(trig)
+
+		var cancelErr error
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			triggersLock.Lock()
+			_, cancelErr = cancelTrigger(trig.key())
+			triggersLock.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			watcher.update("ABC", decimal.NewFromFloat(10.00))
+		}()
+		close(start)
+		wg.Wait()
+
+		didFire := false
+		select {
+		case f := <-fired:
+			didFire = true
+			if !f.sameAs(trig) {
+				t.Fatal("Wrong trigger fired ", f)
+			}
+		default:
+		}
+
+		cancelled := cancelErr == nil
+		if cancelled == didFire {
+			t.Fatal("Expected exactly one of cancel and fire, cancelled: ", cancelled, " fired: ", didFire)
+		}
+		if cancelled && trig.state() != stateCancelled {
+			t.Fatal("Cancelled trigger is ", trig.state())
+		}
+		if didFire && trig.state() != stateFiring {
+			t.Fatal("Fired trigger is ", trig.state())
+		}
+
+		if cancelled {
+			cancels++
+		} else {
+			fires++
+			triggersLock.Lock()
+			delete(runningTriggers, trig.key())
+			triggersLock.Unlock()
+		}
+	}
+	t.Log("Cancel won ", cancels, " times, fire won ", fires, " times")
+}
+
+// useStore swaps in a file store in a temporary directory for the test
+func useStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "triggerstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := openFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := store
+	store = s
+	t.Cleanup(func() {
+		store = old
+		s.Close()
+		os.RemoveAll(dir)
+	})
+}
+
+// postForm calls handler with a form POST and returns the status it replied with
+func postForm(handler http.HandlerFunc, values url.Values) int {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+// postFormTrigger calls handler with a form POST and returns the status and the trigger it replied with
+func postFormTrigger(handler http.HandlerFunc, values url.Values) (int, triggerRecord) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	var record triggerRecord
+	json.NewDecoder(w.Body).Decode(&record)
+	return w.Code, record
+}
+
+func TestTriggerLadder(t *testing.T) {
+	useStore(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+
+	// Two buy triggers on the same stock are kept apart by their ids
+	low := url.Values{"id": {"low"}, "action": {"BUY"}, "transnum": {"1"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"50.00"}}
+	lower := url.Values{"id": {"lower"}, "action": {"BUY"}, "transnum": {"2"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"30.00"}}
+	if status := postForm(setTriggerHandler, low); status != http.StatusOK {
+		t.Fatal("Setting a trigger replied ", status)
+	}
+	postForm(setTriggerHandler, lower)
+	if status := postForm(setTriggerHandler, low); status != http.StatusConflict {
+		t.Error("Setting a trigger id twice should conflict, replied ", status)
+	}
+
+	// Without an id, the latest waiting trigger is started
+	latest := url.Values{"action": {"BUY"}, "username": {"user1"}, "stock": {"ABC"}, "price": {"9.00"}}
+	if status, record := postFormTrigger(startTriggerHandler, latest); status != http.StatusOK || record.ID != "lower" {
+		t.Error("Expected the latest trigger to start, replied ", status, record)
+	}
+	low.Set("price", "10.00")
+	if status, record := postFormTrigger(startTriggerHandler, low); status != http.StatusOK || record.ID != "low" {
+		t.Error("Expected the trigger with the id to start, replied ", status, record)
+	}
+	if status := postForm(startTriggerHandler, latest); status != http.StatusNotFound {
+		t.Error("Starting with no waiting trigger should find nothing, replied ", status)
+	}
+
+	watcher.update("ABC", decimal.NewFromFloat(9.50))
+	select {
+	case f := <-fired:
+		if f.id != "low" {
+			t.Error("Expected only the higher trigger to fire, got ", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The higher trigger should fire")
+	}
+
+	cancel := url.Values{"action": {"BUY"}, "username": {"user1"}, "stock": {"ABC"}}
+	if status, record := postFormTrigger(cancelTriggerHandler, cancel); status != http.StatusOK || record.ID != "lower" {
+		t.Error("Expected the latest trigger to be cancelled, replied ", status, record)
+	}
+	if status := postForm(cancelTriggerHandler, cancel); status != http.StatusConflict {
+		t.Error("Cancelling the firing trigger should conflict, replied ", status)
+	}
+	triggersLock.Lock()
+	delete(runningTriggers, newTriggersKey("BUY", "ABC", "user1", "low"))
+	triggersLock.Unlock()
+}
+
+func TestLimitOrders(t *testing.T) {
+	useStore(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+
+	// Two limit buys on the same stock are kept apart by their ids
+	order := url.Values{"id": {"order1"}, "action": {"LIMIT_BUY"}, "transnum": {"1"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "price": {"10.00"}}
+	if status := postForm(placeLimitOrderHandler, order); status != http.StatusOK {
+		t.Fatal("Placing a limit order replied ", status)
+	}
+	other := url.Values{"id": {"order2"}, "action": {"LIMIT_BUY"}, "transnum": {"2"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"2"}, "price": {"5.00"}}
+	postForm(placeLimitOrderHandler, other)
+	if status := postForm(placeLimitOrderHandler, other); status != http.StatusConflict {
+		t.Error("Placing an order twice should conflict, replied ", status)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(11.00))
+	expectFired(t, fired)
+
+	// Raising the limit to the current price fills the amended order
+	order.Set("price", "11.00")
+	order.Set("amount", "3")
+	if status := postForm(amendLimitOrderHandler, order); status != http.StatusOK {
+		t.Fatal("Amending a limit order replied ", status)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(11.00))
+	select {
+	case f := <-fired:
+		if f.id != "order1" || !f.price.Equal(decimal.NewFromFloat(11.00)) || !f.amount.Equal(decimal.New(3, 0)) {
+			t.Error("Expected the amended order to fire, got ", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The amended order should fire")
+	}
+	records, _ := store.Load()
+	if len(records) != 2 {
+		t.Error("Expected both orders to be persisted, got ", records)
+	}
+
+	// A firing order can no longer be amended or cancelled
+	if status := postForm(amendLimitOrderHandler, order); status != http.StatusConflict {
+		t.Error("Amending a firing order should conflict, replied ", status)
+	}
+	if status := postForm(cancelTriggerHandler, order); status != http.StatusConflict {
+		t.Error("Cancelling a firing order should conflict, replied ", status)
+	}
+	if status := postForm(cancelTriggerHandler, other); status != http.StatusOK {
+		t.Error("Cancelling a running order replied ", status)
+	}
+	if status := postForm(cancelTriggerHandler, other); status != http.StatusNotFound {
+		t.Error("Cancelling an order twice should find nothing, replied ", status)
+	}
+	triggersLock.Lock()
+	delete(runningTriggers, newTriggersKey("LIMIT_BUY", "ABC", "user1", "order1"))
+	triggersLock.Unlock()
+}
+
+func TestTrailingStopOrders(t *testing.T) {
+	useStore(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+	watcher.trailed = persistTrail
+
+	order := url.Values{"id": {"trail1"}, "action": {"TRAILING_STOP_SELL"}, "transnum": {"1"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "price": {"20.00"}, "trail": {"150%"}}
+	if status := postForm(placeLimitOrderHandler, order); status != http.StatusBadRequest {
+		t.Error("A trail of 150% should be refused, replied ", status)
+	}
+	order.Set("trail", "2.00")
+	if status := postForm(placeLimitOrderHandler, order); status != http.StatusOK {
+		t.Fatal("Placing a trailing stop replied ", status)
+	}
+	if status := postForm(amendLimitOrderHandler, order); status != http.StatusBadRequest {
+		t.Error("Amending a trailing stop should be refused, replied ", status)
+	}
+
+	// The new high is persisted, so a restart keeps trailing from it
+	watcher.update("ABC", decimal.NewFromFloat(25.00))
+	expectFired(t, fired)
+	records, _ := store.Load()
+	if len(records) != 1 || records[0].Trail != "2" || records[0].Mark == nil ||
+		!records[0].Mark.Equal(decimal.NewFromFloat(25.00)) {
+		t.Error("Expected the trailing stop to be persisted with a mark of 25.00, got ", records)
+	}
+	if restored := records[0].trigger(nil); restored.trail == nil || !restored.trail.getMark().Equal(decimal.New(25, 0)) {
+		t.Error("Expected the restored trailing stop to trail from 25.00, got ", restored.trail)
+	}
+
+	if status := postForm(cancelTriggerHandler, order); status != http.StatusOK {
+		t.Error("Cancelling a trailing stop replied ", status)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(20.00))
+	expectFired(t, fired)
+}
+
+// postFormTriggers calls handler with a form POST and returns the status and the triggers it replied with
+func postFormTriggers(handler http.HandlerFunc, values url.Values) (int, []triggerRecord) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	var records []triggerRecord
+	json.NewDecoder(w.Body).Decode(&records)
+	return w.Code, records
+}
+
+// useDeliveries swaps in an outbox that acknowledges every trigger straight away
+func useDeliveries(t *testing.T) {
+	old := deliveries
+	deliveries = newOutbox(func(t trigger) (transactionResult, error) {
+		return transactionResult{Status: 1}, nil
+	})
+	t.Cleanup(func() { deliveries = old })
+}
+
+func TestOrderGroups(t *testing.T) {
+	useStore(t)
+	useDeliveries(t)
+	fired := make(chan trigger, 1)
+	defer useWatcher(newPriceWatcher(newMockQuotes().Query, fired, time.Hour))()
+	watcher.stocks["ABC"] = &stockWatch{}
+
+	oco := url.Values{"id": {"oco1"}, "type": {"OCO"}, "transnum": {"1"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "takeProfit": {"10.00"}, "stopLoss": {"12.00"}}
+	if status := postForm(placeOrderGroupHandler, oco); status != http.StatusBadRequest {
+		t.Error("A stop-loss above the take-profit should be refused, replied ", status)
+	}
+	oco.Set("stopLoss", "8.00")
+	status, legs := postFormTriggers(placeOrderGroupHandler, oco)
+	if status != http.StatusOK || len(legs) != 2 || legs[0].ID != "oco1.TP" || legs[1].ID != "oco1.SL" {
+		t.Fatal("Expected the take-profit and stop-loss legs, replied ", status, legs)
+	}
+	if status := postForm(placeOrderGroupHandler, oco); status != http.StatusConflict {
+		t.Error("Placing a group twice should conflict, replied ", status)
+	}
+	leg := url.Values{"id": {"oco1.SL"}, "action": {"STOP_SELL"}, "username": {"user1"}, "stock": {"ABC"},
+		"amount": {"4"}, "price": {"7.00"}}
+	if status := postForm(amendLimitOrderHandler, leg); status != http.StatusBadRequest {
+		t.Error("Amending a leg should be refused, replied ", status)
+	}
+	if status := postForm(cancelTriggerHandler, leg); status != http.StatusBadRequest {
+		t.Error("Cancelling a leg on its own should be refused, replied ", status)
+	}
+
+	// The take-profit fires, which leaves the stop-loss unable to
+	watcher.update("ABC", decimal.NewFromFloat(10.50))
+	var tp trigger
+	select {
+	case tp = <-fired:
+		if tp.id != "oco1.TP" || tp.group != "oco1" {
+			t.Fatal("Expected the take-profit to fire, got ", tp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The take-profit should fire")
+	}
+	cancel := url.Values{"id": {"oco1"}, "username": {"user1"}, "stock": {"ABC"}}
+	if status := postForm(cancelOrderGroupHandler, cancel); status != http.StatusConflict {
+		t.Error("Cancelling a firing group should conflict, replied ", status)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(7.00))
+	expectFired(t, fired)
+	handleTriggerSuccess(tp)
+	if records, _ := store.Load(); len(records) != 0 {
+		t.Error("Expected both legs to be removed once the take-profit was delivered, got ", records)
+	}
+	if status := postForm(cancelOrderGroupHandler, cancel); status != http.StatusNotFound {
+		t.Error("Cancelling a delivered group should find nothing, replied ", status)
+	}
+
+	// A bracket's exits wait for its entry to be delivered
+	bracket := url.Values{"id": {"br1"}, "type": {"BRACKET"}, "transnum": {"2"}, "username": {"user1"},
+		"stock": {"ABC"}, "amount": {"4"}, "price": {"12.00"}, "takeProfit": {"10.00"}, "stopLoss": {"8.00"}}
+	if status := postForm(placeOrderGroupHandler, bracket); status != http.StatusBadRequest {
+		t.Error("An entry above the take-profit should be refused, replied ", status)
+	}
+	bracket.Set("price", "9.00")
+	bracket.Set("takeProfit", "11.00")
+	status, legs = postFormTriggers(placeOrderGroupHandler, bracket)
+	if status != http.StatusOK || len(legs) != 3 || legs[0].State != stateRunning || legs[1].State != stateWaiting {
+		t.Fatal("Expected a running entry and waiting exits, replied ", status, legs)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(9.00))
+	var entry trigger
+	select {
+	case entry = <-fired:
+		if entry.id != "br1.ENTRY" {
+			t.Fatal("Expected the entry to fire, got ", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The entry should fire")
+	}
+	handleTriggerSuccess(entry)
+	records, _ := store.Load()
+	if len(records) != 2 || records[0].State != stateRunning || records[1].State != stateRunning {
+		t.Error("Expected both exits to be running once the entry was delivered, got ", records)
+	}
+
+	// A restart gives the exits their shared status back
+	triggersLock.Lock()
+	restored := make([]trigger, len(records))
+	for i, record := range records {
+		restored[i] = record.trigger(nil)
+	}
+	restored = relinkGroups(records, restored)
+	triggersLock.Unlock()
+	if len(restored) != 2 || !restored[0].sameAs(restored[1]) {
+		t.Error("Expected the restored exits to share a status, got ", restored)
+	}
+
+	status, legs = postFormTriggers(cancelOrderGroupHandler, url.Values{"id": {"br1"}, "username": {"user1"},
+		"stock": {"ABC"}})
+	if status != http.StatusOK || len(legs) != 2 || legs[0].State != stateCancelled {
+		t.Error("Expected both exits to be cancelled, replied ", status, legs)
+	}
+	if records, _ := store.Load(); len(records) != 0 {
+		t.Error("Expected a cancelled group to be removed, got ", records)
+	}
+	watcher.update("ABC", decimal.NewFromFloat(12.00))
+	expectFired(t, fired)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// pollInterval matches the quoteserver's cache lifetime, polling any faster
+// would only return the same quote
+const pollInterval = (time.Second * 60) + time.Millisecond
+
+// maxConcurrentQuotes bounds how many stocks are quoted at once
+const maxConcurrentQuotes = 32
+
+// quoteFunc fetches the current price of stock on behalf of user
+type quoteFunc func(user string, stock string, transNum int) (decimal.Decimal, error)
+
+// priceWatcher holds every running trigger, grouped by stock. Each stock is
+// quoted once per interval no matter how many triggers are watching it, and
+// every trigger the new price crosses is sent to fired.
+type priceWatcher struct {
+	lock     sync.Mutex
+	stocks   map[string]*stockWatch
+	quote    quoteFunc
+	fired    chan<- trigger
+	interval time.Duration
+
+	// quoted, if set, is passed every price the watcher gets
+	quoted func(stock string, price decimal.Decimal)
+
+	// trailed, if set, is passed every trailing stop whose mark a price moved
+	trailed func(t trigger)
+}
+
+func newPriceWatcher(quote quoteFunc, fired chan<- trigger, interval time.Duration) *priceWatcher {
+	return &priceWatcher{
+		stocks:   make(map[string]*stockWatch),
+		quote:    quote,
+		fired:    fired,
+		interval: interval,
+	}
+}
+
+// Add starts watching a running trigger. It is checked against the last quote
+// for its stock straight away, and a stock nobody was watching is quoted immediately.
+func (w *priceWatcher) Add(t trigger) {
+	w.lock.Lock()
+	watch, ok := w.stocks[t.stockname]
+	if !ok {
+		watch = &stockWatch{}
+		w.stocks[t.stockname] = watch
+	}
+	watch.add(t)
+	var fired, trailed []trigger
+	if watch.quoted {
+		fired, trailed = watch.crossed(watch.last)
+	}
+	w.lock.Unlock()
+
+	w.trail(trailed)
+	w.fire(fired)
+	if !ok {
+		go w.poll(t.stockname)
+	}
+}
+
+// Remove stops watching a trigger. Returns false if it isn't being watched,
+// which for a running trigger means it has already been taken to fire.
+func (w *priceWatcher) Remove(t trigger) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	watch, ok := w.stocks[t.stockname]
+	if !ok {
+		return false
+	}
+	return watch.remove(t)
+}
+
+// Run quotes every watched stock once per interval, forever
+func (w *priceWatcher) Run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.pollAll()
+	}
+}
+
+// pollAll quotes every watched stock once
+func (w *priceWatcher) pollAll() {
+	w.lock.Lock()
+	stocks := make([]string, 0, len(w.stocks))
+	for stock := range w.stocks {
+		stocks = append(stocks, stock)
+	}
+	w.lock.Unlock()
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, maxConcurrentQuotes)
+	for _, stock := range stocks {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(stock string) {
+			defer wg.Done()
+			w.poll(stock)
+			<-limit
+		}(stock)
+	}
+	wg.Wait()
+}
+
+// poll quotes one stock and fires the triggers its price crosses
+func (w *priceWatcher) poll(stock string) {
+	w.lock.Lock()
+	watch, ok := w.stocks[stock]
+	if !ok {
+		w.lock.Unlock()
+		return
+	}
+	user, transNum := watch.user, watch.transNum
+	w.lock.Unlock()
+
+	price, err := w.quote(user, stock, transNum)
+	if err != nil {
+		fmt.Println("Error quoting "+stock+" for triggers: ", err)
+		return
+	}
+	if w.quoted != nil {
+		w.quoted(stock, price)
+	}
+	w.update(stock, price)
+}
+
+// update records a new price for stock and fires the triggers it crosses
+func (w *priceWatcher) update(stock string, price decimal.Decimal) {
+	w.lock.Lock()
+	watch, ok := w.stocks[stock]
+	if !ok {
+		w.lock.Unlock()
+		return
+	}
+	watch.last = price
+	watch.quoted = true
+	fired, trailed := watch.crossed(price)
+	if len(watch.falls) == 0 && len(watch.rises) == 0 && len(watch.trailing) == 0 {
+		delete(w.stocks, stock)
+	}
+	w.lock.Unlock()
+
+	w.trail(trailed)
+	w.fire(fired)
+}
+
+func (w *priceWatcher) trail(trailed []trigger) {
+	if w.trailed == nil {
+		return
+	}
+	for _, t := range trailed {
+		w.trailed(t)
+	}
+}
+
+func (w *priceWatcher) fire(fired []trigger) {
+	for _, t := range fired {
+		w.fired <- t
+	}
+}
+
+// stockWatch indexes the running triggers on one stock by price.
+// A buy, limit buy or stop sell fires once the price falls to its trigger price
+// and is kept in falls. A sell, limit sell or stop buy fires once the price rises
+// to its trigger price and is kept in rises, indexed by its negated price so in
+// both indexes the triggers a price crosses are a suffix. Trailing stops move
+// their stop price with every quote, so they are checked one by one.
+type stockWatch struct {
+	falls    priceIndex
+	rises    priceIndex
+	trailing []trigger
+
+	// last quote, valid once quoted is set
+	last   decimal.Decimal
+	quoted bool
+
+	// Quotes are requested on behalf of the most recent trigger
+	user     string
+	transNum int
+}
+
+func (w *stockWatch) add(t trigger) {
+	if t.trail != nil {
+		w.trailing = append(w.trailing, t)
+	} else if t.firesOnFall() {
+		w.falls.insert(watchEntry{t.price, t})
+	} else {
+		w.rises.insert(watchEntry{t.price.Neg(), t})
+	}
+	w.user = t.username
+	w.transNum = t.transNum
+}
+
+func (w *stockWatch) remove(t trigger) bool {
+	if t.trail != nil {
+		for i, watched := range w.trailing {
+			if watched.key() == t.key() {
+				w.trailing = append(w.trailing[:i], w.trailing[i+1:]...)
+				return true
+			}
+		}
+		return false
+	} else if t.firesOnFall() {
+		return w.falls.remove(t.price, t.key())
+	}
+	return w.rises.remove(t.price.Neg(), t.key())
+}
+
+// crossed removes every trigger that fires at price and returns the ones it moved
+// from RUNNING to FIRING. A trigger cancelled at the same moment has already left
+// RUNNING, so it is dropped here instead of firing. A trailing stop fires at the
+// stop price it reached. Also returns the trailing stops whose mark price moved.
+func (w *stockWatch) crossed(price decimal.Decimal) (fired []trigger, trailed []trigger) {
+	var taken []trigger
+	taken = w.falls.takeFrom(price, taken)
+	taken = w.rises.takeFrom(price.Neg(), taken)
+
+	watching := w.trailing[:0]
+	for _, t := range w.trailing {
+		stop, reached, moved := t.trail.follow(price)
+		if reached {
+			t.price = stop
+			taken = append(taken, t)
+			continue
+		}
+		watching = append(watching, t)
+		if moved {
+			trailed = append(trailed, t)
+		}
+	}
+	w.trailing = watching
+
+	fired = taken[:0]
+	for _, t := range taken {
+		if t.transition(stateRunning, stateFiring) {
+			fired = append(fired, t)
+		}
+	}
+	return fired, trailed
+}
+
+// watchEntry is a trigger in a priceIndex, at is its sort key
+type watchEntry struct {
+	at decimal.Decimal
+	t  trigger
+}
+
+// priceIndex is kept sorted by ascending at. Entries with the same at stay in
+// the order they were inserted.
+type priceIndex []watchEntry
+
+// search returns the index of the first entry at or above at
+func (idx priceIndex) search(at decimal.Decimal) int {
+	return sort.Search(len(idx), func(i int) bool {
+		return idx[i].at.GreaterThanOrEqual(at)
+	})
+}
+
+func (idx *priceIndex) insert(e watchEntry) {
+	i := sort.Search(len(*idx), func(i int) bool {
+		return (*idx)[i].at.GreaterThan(e.at)
+	})
+	*idx = append(*idx, watchEntry{})
+	copy((*idx)[i+1:], (*idx)[i:])
+	(*idx)[i] = e
+}
+
+func (idx *priceIndex) remove(at decimal.Decimal, key triggersKey) bool {
+	entries := *idx
+	for i := entries.search(at); i < len(entries) && entries[i].at.Equal(at); i++ {
+		if entries[i].t.key() == key {
+			copy(entries[i:], entries[i+1:])
+			*idx = entries[:len(entries)-1]
+			return true
+		}
+	}
+	return false
+}
+
+// takeFrom removes every entry at or above at, appending their triggers to fired
+func (idx *priceIndex) takeFrom(at decimal.Decimal, fired []trigger) []trigger {
+	i := idx.search(at)
+	for _, e := range (*idx)[i:] {
+		fired = append(fired, e.t)
+	}
+	*idx = (*idx)[:i]
+	return fired
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// mockQuotes answers quotes from a fixed price list and counts every call
+type mockQuotes struct {
+	lock   sync.Mutex
+	calls  int
+	prices map[string]decimal.Decimal
+}
+
+func newMockQuotes() *mockQuotes {
+	return &mockQuotes{prices: make(map[string]decimal.Decimal)}
+}
+
+func (q *mockQuotes) set(stock string, price float64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.prices[stock] = decimal.NewFromFloat(price)
+}
+
+func (q *mockQuotes) Query(user string, stock string, transNum int) (decimal.Decimal, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.calls++
+	return q.prices[stock], nil
+}
+
+func (q *mockQuotes) count() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.calls
+}
+
+func runningTrigger(action string, user string, stock string, price float64) trigger {
+	var t trigger
+	if action == "BUY" {
+		t = newBuyTrigger(nil, 1, user, stock, decimal.New(1, 0))
+	} else {
+		t = newSellTrigger(nil, 1, user, stock, decimal.New(1, 0))
+	}
+	t.price = decimal.NewFromFloat(price)
+	t.status = newTriggerStatus(stateRunning)
+	return t
+}
+
+func expectFired(t *testing.T, fired chan trigger, expected ...trigger) {
+	for _, e := range expected {
+		select {
+		case f := <-fired:
+			if f.key() != e.key() {
+				t.Error("Expected ", e, " to fire, got ", f)
+			}
+		case <-time.After(time.Second):
+			t.Error("Expected ", e, " to fire")
+		}
+	}
+	select {
+	case f := <-fired:
+		t.Error("Unexpected trigger fired ", f)
+	default:
+	}
+}
+
+// waitForQuote waits for the quote a newly watched stock gets in the background
+func waitForQuote(t *testing.T, w *priceWatcher, stock string) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		w.lock.Lock()
+		quoted := w.stocks[stock] != nil && w.stocks[stock].quoted
+		w.lock.Unlock()
+		if quoted {
+			return
+		}
+	}
+	t.Fatal("Timed out waiting for a quote of ", stock)
+}
+
+func TestPriceWatcher(t *testing.T) {
+	quotes := newMockQuotes()
+	quotes.set("ABC", 20.00)
+	quotes.set("XYZ", 5.00)
+	fired := make(chan trigger, 10)
+	w := newPriceWatcher(quotes.Query, fired, time.Hour)
+
+	buy15 := runningTrigger("BUY", "user1", "ABC", 15.00)
+	buy10 := runningTrigger("BUY", "user2", "ABC", 10.00)
+	sell25 := runningTrigger("SELL", "user3", "ABC", 25.00)
+	sell30 := runningTrigger("SELL", "user4", "ABC", 30.00)
+	buyXYZ := runningTrigger("BUY", "user1", "XYZ", 6.00)
+	w.Add(buy15)
+	waitForQuote(t, w, "ABC")
+	w.Add(buy10)
+	w.Add(sell25)
+	w.Add(sell30)
+
+	// A new stock is quoted as soon as it is watched
+	w.Add(buyXYZ)
+	expectFired(t, fired, buyXYZ)
+
+	w.update("ABC", decimal.NewFromFloat(15.00))
+	expectFired(t, fired, buy15)
+	if buy15.state() != stateFiring {
+		t.Error("Fired trigger should be FIRING, is ", buy15.state())
+	}
+
+	// A trigger cancelled before it is removed from the index never fires
+	buy12 := runningTrigger("BUY", "user6", "ABC", 12.00)
+	w.Add(buy12)
+	buy12.transition(stateRunning, stateCancelled)
+	w.update("ABC", decimal.NewFromFloat(12.00))
+	expectFired(t, fired)
+
+	if !w.Remove(sell30) {
+		t.Error("Running sell trigger should be removed")
+	}
+	if w.Remove(buy15) {
+		t.Error("Fired trigger should not be removed")
+	}
+
+	w.update("ABC", decimal.NewFromFloat(30.00))
+	expectFired(t, fired, sell25)
+
+	// Triggers are checked against the last quote when added
+	sell29 := runningTrigger("SELL", "user5", "ABC", 29.00)
+	w.Add(sell29)
+	expectFired(t, fired, sell29)
+
+	before := quotes.count()
+	w.pollAll()
+	if quotes.count()-before != 1 {
+		t.Error("Only ABC should be quoted, made ", quotes.count()-before, " calls")
+	}
+	expectFired(t, fired)
+}
+
+func TestPriceWatcher_Quoted(t *testing.T) {
+	quotes := newMockQuotes()
+	quotes.set("ABC", 20.00)
+	w := newPriceWatcher(quotes.Query, make(chan trigger, 10), time.Hour)
+	published := make(chan decimal.Decimal, 10)
+	w.quoted = func(stock string, price decimal.Decimal) {
+		published <- price
+	}
+
+	w.Add(runningTrigger("BUY", "user1", "ABC", 15.00))
+	waitForQuote(t, w, "ABC")
+	quotes.set("ABC", 18.00)
+	w.pollAll()
+	for _, expected := range []float64{20.00, 18.00} {
+		select {
+		case price := <-published:
+			if !price.Equal(decimal.NewFromFloat(expected)) {
+				t.Errorf("Expected %.2f to be published, got %s", expected, price)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %.2f to be published", expected)
+		}
+	}
+}
+
+func TestPriceWatcher_Stops(t *testing.T) {
+	fired := make(chan trigger, 10)
+	w := newPriceWatcher(newMockQuotes().Query, fired, time.Hour)
+	w.stocks["ABC"] = &stockWatch{}
+	var trailed []trigger
+	w.trailed = func(t trigger) {
+		trailed = append(trailed, t)
+	}
+
+	stopSell := newLimitOrder(nil, 1, "stop1", "user1", "ABC", "STOP_SELL", decimal.New(1, 0),
+		decimal.NewFromFloat(18.00))
+	stopBuy := newLimitOrder(nil, 1, "stop2", "user1", "ABC", "STOP_BUY", decimal.New(1, 0),
+		decimal.NewFromFloat(24.00))
+	trailingSell := newLimitOrder(nil, 1, "trail1", "user1", "ABC", "TRAILING_STOP_SELL", decimal.New(1, 0),
+		decimal.NewFromFloat(20.00))
+	trailingSell.trail, _ = newTrailingStop("TRAILING_STOP_SELL", "10%", trailingSell.price)
+	for _, stop := range []trigger{stopSell, stopBuy, trailingSell} {
+		w.Add(stop)
+	}
+
+	w.update("ABC", decimal.NewFromFloat(20.00))
+	expectFired(t, fired)
+
+	// The trailing stop follows the price up to 25.00, moving its stop to 22.50
+	w.update("ABC", decimal.NewFromFloat(25.00))
+	expectFired(t, fired, stopBuy)
+	if len(trailed) != 1 || trailed[0].id != "trail1" {
+		t.Error("Expected the trailing stop's mark to move, got ", trailed)
+	}
+	w.update("ABC", decimal.NewFromFloat(23.00))
+	expectFired(t, fired)
+
+	// A fall to 22.00 reaches the trailing stop but not the fixed one
+	w.update("ABC", decimal.NewFromFloat(22.00))
+	select {
+	case f := <-fired:
+		if f.id != "trail1" || !f.price.Equal(decimal.NewFromFloat(22.50)) {
+			t.Error("Expected the trailing stop to fire at 22.50, got ", f)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the trailing stop to fire")
+	}
+	if w.Remove(trailingSell) {
+		t.Error("Fired trailing stop should not be removed")
+	}
+
+	w.update("ABC", decimal.NewFromFloat(17.50))
+	expectFired(t, fired, stopSell)
+}
+
+const benchmarkTriggers = 100000
+const benchmarkStocks = 100
+
+// benchmarkTrigger spreads triggers over stocks with prices that a quote of 50.00 never crosses
+func benchmarkTrigger(i int) trigger {
+	stock := fmt.Sprintf("S%02d", i%benchmarkStocks)
+	user := fmt.Sprintf("user%d", i)
+	if i%2 == 0 {
+		return runningTrigger("BUY", user, stock, float64(1+i%4000)/100)
+	}
+	return runningTrigger("SELL", user, stock, float64(6000+i%4000)/100)
+}
+
+// BenchmarkPerTriggerPolling is the old model, where every trigger quoted its own stock
+func BenchmarkPerTriggerPolling(b *testing.B) {
+	quotes := newMockQuotes()
+	triggers := make([]trigger, benchmarkTriggers)
+	for i := range triggers {
+		triggers[i] = benchmarkTrigger(i)
+		quotes.set(triggers[i].stockname, 50.00)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, t := range triggers {
+			price, _ := quotes.Query(t.username, t.stockname, t.transNum)
+			t.checkResult(price)
+		}
+	}
+	b.ReportMetric(float64(quotes.count())/float64(b.N), "quotes/op")
+}
+
+// BenchmarkPriceWatcherPoll checks the same triggers with one quote per stock
+func BenchmarkPriceWatcherPoll(b *testing.B) {
+	quotes := newMockQuotes()
+	w := newPriceWatcher(quotes.Query, make(chan trigger), time.Hour)
+	for i := 0; i < benchmarkTriggers; i++ {
+		t := benchmarkTrigger(i)
+		quotes.set(t.stockname, 50.00)
+		w.lock.Lock()
+		if _, ok := w.stocks[t.stockname]; !ok {
+			w.stocks[t.stockname] = &stockWatch{}
+		}
+		w.stocks[t.stockname].add(t)
+		w.lock.Unlock()
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		w.pollAll()
+	}
+	b.ReportMetric(float64(quotes.count())/float64(b.N), "quotes/op")
+}
+
+// BenchmarkPriceWatcherFire measures a price update that fires one trigger
+// on a stock watched by 100k triggers
+func BenchmarkPriceWatcherFire(b *testing.B) {
+	fired := make(chan trigger, 1)
+	w := newPriceWatcher(newMockQuotes().Query, fired, time.Hour)
+	w.stocks["ABC"] = &stockWatch{}
+	for i := 0; i < benchmarkTriggers; i++ {
+		t := benchmarkTrigger(i)
+		t.stockname = "ABC"
+		w.stocks["ABC"].add(t)
+	}
+	quiet := decimal.NewFromFloat(50.00)
+	crossing := decimal.NewFromFloat(45.00)
+	w.update("ABC", quiet)
+	t := runningTrigger("BUY", "firer", "ABC", 45.00)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		t.status = newTriggerStatus(stateRunning)
+		w.Add(t)
+		w.update("ABC", crossing)
+		<-fired
+		w.update("ABC", quiet)
+	}
+}